@@ -2,13 +2,26 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/nerock/invoicebidder/internal/auth"
+	authStorage "github.com/nerock/invoicebidder/internal/auth/storage"
+
+	"github.com/nerock/invoicebidder/internal/idempotency"
+	idempotencyStorage "github.com/nerock/invoicebidder/internal/idempotency/storage"
+
+	"github.com/nerock/invoicebidder/internal/fee"
+	feeStorage "github.com/nerock/invoicebidder/internal/fee/storage"
+
 	"github.com/nerock/invoicebidder/internal/investor"
 	investorStorage "github.com/nerock/invoicebidder/internal/investor/storage"
 
@@ -16,12 +29,21 @@ import (
 	issuerStorage "github.com/nerock/invoicebidder/internal/issuer/storage"
 
 	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/clamav"
+	"github.com/nerock/invoicebidder/internal/invoice/pdftext"
 	invoiceStorage "github.com/nerock/invoicebidder/internal/invoice/storage"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/nerock/invoicebidder/internal/config"
 	"github.com/nerock/invoicebidder/internal/orchestrator/api"
 	"github.com/nerock/invoicebidder/internal/orchestrator/broker"
+	"github.com/nerock/invoicebidder/internal/orchestrator/bulk"
+	"github.com/nerock/invoicebidder/internal/orchestrator/cleaner"
+	"github.com/nerock/invoicebidder/internal/orchestrator/integrity"
+	"github.com/nerock/invoicebidder/internal/orchestrator/sweeper"
+	"github.com/nerock/invoicebidder/internal/sqlite"
 )
 
 type Server interface {
@@ -36,32 +58,211 @@ func main() {
 	}
 
 	ctx := context.Background()
-	invoiceDB, err := pgxpool.New(ctx, cfg.InvoiceDB)
+	st, err := newStorages(ctx, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer invoiceDB.Close()
+	defer st.close()
 
-	issuerDB, err := pgxpool.New(ctx, cfg.IssuerDB)
+	jwtVerifier, err := newJWTVerifier(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer issuerDB.Close()
 
-	investorDB, err := pgxpool.New(ctx, cfg.InvestorDB)
+	fileStorage, err := newFileStorage(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer investorDB.Close()
 
-	invoiceSvc := invoice.NewService(invoiceStorage.New(invoiceDB), invoiceStorage.NewFileStorage(cfg.BasePath))
-	issuerSvc := issuer.NewService(issuerStorage.New(issuerDB))
-	investorSvc := investor.NewService(investorStorage.New(investorDB))
+	policy := invoice.UploadPolicy{
+		MaxSize:     cfg.Uploads.MaxSize,
+		AllowImages: cfg.Uploads.AllowImages,
+	}
+	if clam := cfg.Uploads.ClamAV; clam.Address != "" {
+		policy.Scanner = clamav.New(clam.Network, clam.Address, time.Duration(clam.Timeout)*time.Second)
+	}
+
+	invoiceSvc := invoice.NewService(st.invoice, fileStorage, policy).WithExtractor(pdftext.New())
+	issuerSvc := issuer.NewService(st.issuer)
+	investorSvc := investor.NewService(st.investor)
+	feeSvc := fee.NewService(st.fee, cfg.Fees)
+
+	brk := broker.New(cfg.Broker.Handlers, cfg.Broker.Buffer, cfg.Broker.MaxRetries, invoiceSvc, investorSvc, issuerSvc, feeSvc)
+	uploader := bulk.New(cfg.Bulk.Workers, cfg.Bulk.Buffer, invoiceSvc, issuerSvc, brk)
+	srv := api.New(cfg.Server.Port, invoiceSvc, investorSvc, issuerSvc, feeSvc, brk, uploader).
+		WithAuth(auth.NewService(st.auth, jwtVerifier)).
+		WithSignedURLs([]byte(cfg.Documents.URLSecret), time.Duration(cfg.Documents.URLTTL)*time.Second).
+		WithUploadLimit(cfg.Uploads.MaxSize)
+	checker := integrity.New(invoiceSvc)
+	srv.WithIntegrityChecks(checker)
+
+	servers := []Server{srv, brk, uploader, checker}
+	if cfg.Idempotency.TTL > 0 {
+		idempotencySvc := idempotency.NewService(st.idempotency, time.Duration(cfg.Idempotency.TTL)*time.Second)
+		srv.WithIdempotency(idempotencySvc)
+
+		if cfg.Idempotency.CleanupInterval > 0 {
+			servers = append(servers, cleaner.New(time.Duration(cfg.Idempotency.CleanupInterval)*time.Second, idempotencySvc))
+		}
+	}
+	if cfg.Sweeper.Interval > 0 {
+		servers = append(servers, sweeper.New(
+			time.Duration(cfg.Sweeper.Interval)*time.Second,
+			time.Duration(cfg.Sweeper.Grace)*time.Second,
+			invoiceSvc,
+		))
+	}
+
+	run(servers...)
+}
+
+func newJWTVerifier(cfg config.Config) (*auth.JWTVerifier, error) {
+	var keys map[string]*rsa.PublicKey
+	if cfg.Auth.JWKSFile != "" {
+		var err error
+		if keys, err = auth.LoadJWKS(cfg.Auth.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return auth.NewJWTVerifier([]byte(cfg.Auth.HS256Secret), keys, cfg.Auth.Issuer, cfg.Auth.Audience), nil
+}
+
+type storages struct {
+	invoice     invoice.Storage
+	issuer      issuer.Storage
+	investor    investor.Storage
+	fee         fee.Storage
+	auth        auth.Storage
+	idempotency idempotency.Storage
+	close       func()
+}
 
-	brk := broker.New(cfg.Broker.Handlers, cfg.Broker.Buffer, cfg.Broker.MaxRetries, invoiceSvc, investorSvc, issuerSvc)
-	srv := api.New(cfg.Server.Port, invoiceSvc, investorSvc, issuerSvc, brk)
+func newStorages(ctx context.Context, cfg config.Config) (storages, error) {
+	switch cfg.Storage.Backend {
+	case "", "postgres":
+		pools, closeAll, err := openPools(ctx, cfg.InvoiceDB, cfg.IssuerDB, cfg.InvestorDB, cfg.PlatformDB, cfg.AuthDB, cfg.IdempotencyDB)
+		if err != nil {
+			return storages{}, err
+		}
 
-	run(srv, brk)
+		return storages{
+			invoice:     invoiceStorage.New(pools[0]),
+			issuer:      issuerStorage.New(pools[1]),
+			investor:    investorStorage.New(pools[2]),
+			fee:         feeStorage.New(pools[3]),
+			auth:        authStorage.New(pools[4]),
+			idempotency: idempotencyStorage.New(pools[5]),
+			close:       closeAll,
+		}, nil
+	case "memory":
+		return storages{
+			invoice:     invoiceStorage.NewMemoryStorage(),
+			issuer:      issuerStorage.NewMemoryStorage(),
+			investor:    investorStorage.NewMemoryStorage(),
+			fee:         feeStorage.NewMemoryStorage(),
+			auth:        authStorage.NewMemoryStorage(),
+			idempotency: idempotencyStorage.NewMemoryStorage(),
+			close:       func() {},
+		}, nil
+	case "sqlite":
+		return newSQLiteStorages(ctx, cfg)
+	default:
+		return storages{}, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
+
+// openPools connects to every database, closing them all if any fails
+func openPools(ctx context.Context, dsns ...string) ([]*pgxpool.Pool, func(), error) {
+	var pools []*pgxpool.Pool
+	closeAll := func() {
+		for _, p := range pools {
+			p.Close()
+		}
+	}
+
+	for _, dsn := range dsns {
+		p, err := pgxpool.New(ctx, dsn)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		pools = append(pools, p)
+	}
+
+	return pools, closeAll, nil
+}
+
+// newSQLiteStorages keeps each domain in its own db file, like the postgres
+// databases, and migrates them on start.
+func newSQLiteStorages(ctx context.Context, cfg config.Config) (storages, error) {
+	var dbs []*sql.DB
+	closeAll := func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}
+
+	for _, name := range []string{"invoice.db", "issuer.db", "investor.db", "platform.db", "auth.db", "idempotency.db"} {
+		db, err := sqlite.Open(filepath.Join(cfg.Storage.SQLiteDir, name))
+		if err != nil {
+			closeAll()
+			return storages{}, err
+		}
+		dbs = append(dbs, db)
+	}
+
+	invoiceSt := invoiceStorage.NewSQLiteStorage(dbs[0])
+	issuerSt := issuerStorage.NewSQLiteStorage(dbs[1])
+	investorSt := investorStorage.NewSQLiteStorage(dbs[2])
+	feeSt := feeStorage.NewSQLiteStorage(dbs[3])
+	authSt := authStorage.NewSQLiteStorage(dbs[4])
+	idempotencySt := idempotencyStorage.NewSQLiteStorage(dbs[5])
+	for _, migrate := range []func(context.Context) error{invoiceSt.Migrate, issuerSt.Migrate, investorSt.Migrate, feeSt.Migrate, authSt.Migrate, idempotencySt.Migrate} {
+		if err := migrate(ctx); err != nil {
+			closeAll()
+			return storages{}, err
+		}
+	}
+
+	return storages{
+		invoice:     invoiceSt,
+		issuer:      issuerSt,
+		investor:    investorSt,
+		fee:         feeSt,
+		auth:        authSt,
+		idempotency: idempotencySt,
+		close:       closeAll,
+	}, nil
+}
+
+func newFileStorage(cfg config.Config) (invoice.FileStorage, error) {
+	switch cfg.Files.Backend {
+	case "", "local":
+		return invoiceStorage.NewFileStorage(cfg.BasePath), nil
+	case "memory":
+		return invoiceStorage.NewMemoryFileStorage(), nil
+	case "s3":
+		s3 := cfg.Files.S3
+		// keys left out of the config are read from the AWS_* or MINIO_* env vars
+		creds := credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.Static{Value: credentials.Value{AccessKeyID: s3.AccessKey, SecretAccessKey: s3.SecretKey, SignerType: credentials.SignatureV4}},
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+		})
+		c, err := minio.New(s3.Endpoint, &minio.Options{
+			Creds:  creds,
+			Secure: s3.UseSSL,
+			Region: s3.Region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not create s3 client: %w", err)
+		}
+
+		return invoiceStorage.NewS3FileStorage(c, s3.Bucket), nil
+	default:
+		return nil, fmt.Errorf("unknown file storage backend %q", cfg.Files.Backend)
+	}
 }
 
 func run(servers ...Server) {
@@ -20,8 +20,166 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/auth/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "List the API keys of the authenticated user, without the keys themselves",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.APIKeyResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Create an API key acting as the authenticated user, with the same roles. The key is only returned once. API keys cannot create other keys.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "New API key",
+                "parameters": [
+                    {
+                        "description": "API key",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.APIKeyRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.APIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/api-keys/:id": {
+            "delete": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Revoke an API key of the authenticated user",
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Revoke API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
         "/investor": {
             "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
                 "description": "Retrieve investors optionally filtering by ids",
                 "consumes": [
                     "application/json"
@@ -55,15 +213,26 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             },
             "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
                 "description": "Create a new investor to bid on invoices",
                 "consumes": [
                     "application/json"
@@ -84,6 +253,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.CreateInvestorRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -96,32 +271,43 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/invoice": {
+        "/investor/:id": {
             "get": {
-                "description": "Retrieve an invoice by ID",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve an investor by ID with its active bids, funded positions, committed capital and returns",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "invoice"
+                    "investor"
                 ],
-                "summary": "Get Invoice",
+                "summary": "Get investor",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Invoice id",
+                        "description": "Investor id",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -131,222 +317,275 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.InvoiceResponse"
+                            "$ref": "#/definitions/api.InvestorResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/investor/:id/deposits": {
             "post": {
-                "description": "Create a new invoice to sell",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Record a pending deposit that credits the investor balance once it settles",
                 "consumes": [
-                    "application/x-www-form-urlencoded"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "invoice"
+                    "investor"
                 ],
-                "summary": "New invoice",
+                "summary": "New deposit",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID of publishing issuer",
-                        "name": "issuer_id",
-                        "in": "formData",
+                        "description": "Investor id",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Price string",
-                        "name": "price",
-                        "in": "formData",
-                        "required": true
+                        "description": "Deposit request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PaymentRequest"
+                        }
                     },
                     {
                         "type": "string",
-                        "description": "Currency code",
-                        "name": "currency",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "file",
-                        "description": "Invoice file",
-                        "name": "invoice",
-                        "in": "formData",
-                        "required": true
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/api.InvoiceResponse"
+                            "$ref": "#/definitions/api.PaymentResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/invoice/:id/bid": {
-            "post": {
-                "description": "Places a bid in an invoice",
-                "consumes": [
-                    "application/json"
+        "/investor/:id/payments": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
                 ],
+                "description": "Retrieve the deposits and withdrawals of an investor",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "invoice"
+                    "investor"
                 ],
-                "summary": "Bid on invoice",
+                "summary": "List payments",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Invoice id",
+                        "description": "Investor id",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Bid request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.BidRequest"
-                        }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.InvoiceBidResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.PaymentResponse"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/invoice/:id/trade": {
+        "/investor/:id/payments/:paymentId/settlement": {
             "post": {
-                "description": "Approves or cancels an invoice trade",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Mark a pending payment as settled, applying it to the balance, or as failed",
                 "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "invoice"
+                    "investor"
                 ],
-                "summary": "Approve invoice trade",
+                "summary": "Settle payment",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Invoice id",
+                        "description": "Investor id",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Trade request",
+                        "type": "string",
+                        "description": "Payment id",
+                        "name": "paymentId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Settlement request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.ApproveTradeRequest"
+                            "$ref": "#/definitions/api.SettlePaymentRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PaymentResponse"
+                        }
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/issuer": {
+        "/investor/:id/rules": {
             "get": {
-                "description": "Retrieve an issuer by ID",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the auto-bid rules of an investor with the bids each of them placed",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "issuer"
+                    "investor"
                 ],
-                "summary": "Get Issuer",
+                "summary": "List auto-bid rules",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Issuer id",
+                        "description": "Investor id",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -356,31 +595,39 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.IssuerResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.RuleResponse"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Create a new issuer to sell invoices",
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Create a rule that automatically bids on new invoices matching its criteria",
                 "consumes": [
                     "application/json"
                 ],
@@ -388,256 +635,3405 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "issuer"
+                    "investor"
                 ],
-                "summary": "New issuer",
+                "summary": "New auto-bid rule",
                 "parameters": [
                     {
-                        "description": "Issuer request",
+                        "type": "string",
+                        "description": "Investor id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Rule request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.CreateIssuerRequest"
+                            "$ref": "#/definitions/api.CreateRuleRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/api.IssuerResponse"
+                            "$ref": "#/definitions/api.RuleResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.HTTPError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.AmountRequest": {
+        },
+        "/investor/:id/withdrawals": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Record a pending withdrawal that debits the investor balance once it settles, up to the available funds",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "investor"
+                ],
+                "summary": "New withdrawal",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Investor id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Withdrawal request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PaymentRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.PaymentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Browse the invoice marketplace with filters, sorting and cursor based pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "List invoices",
+                "parameters": [
+                    {
+                        "enum": [
+                            "open",
+                            "locked",
+                            "traded"
+                        ],
+                        "type": "string",
+                        "description": "Invoice status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency code",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum price",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum price",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the issuer",
+                        "name": "issuer_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest due date (YYYY-MM-DD)",
+                        "name": "due_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest due date (YYYY-MM-DD)",
+                        "name": "due_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum APR as a fraction",
+                        "name": "min_apr",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Maximum APR as a fraction",
+                        "name": "max_apr",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "due_date",
+                            "-due_date",
+                            "price",
+                            "-price",
+                            "apr",
+                            "-apr"
+                        ],
+                        "type": "string",
+                        "description": "Sort field, prefix with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, up to 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.InvoicePageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Create a new invoice to sell. UBL 2.1 xml and Factur-X pdfs fill the currency, face value and due date from the e-invoice, and the price defaults to its total.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "New invoice",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of publishing issuer, which must be the authenticated one",
+                        "name": "issuer_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Price string, required unless the file is an e-invoice",
+                        "name": "price",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency code, required with a price or face value",
+                        "name": "currency",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Face value string, defaults to the price",
+                        "name": "face_value",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Due date (YYYY-MM-DD), required unless the file is an e-invoice",
+                        "name": "due_date",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "Invoice file, a pdf, a UBL 2.1 or CII xml e-invoice, or an image if allowed",
+                        "name": "invoice",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.InvoiceResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve an invoice by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Get Invoice",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.InvoiceResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/bid": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Places a bid in an invoice as the investor of the request, which defaults to the authenticated one",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Bid on invoice",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Bid request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.BidRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.InvoiceBidResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/document": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Download the invoice file, supporting range requests. Allowed for the owning issuer, investors, admins and auditors, or with a valid signed url.",
+                "produces": [
+                    "application/pdf",
+                    "application/xml",
+                    "image/png",
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Get invoice document",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signed url expiration",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signed url signature",
+                        "name": "signature",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/document/url": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Create a short-lived url to download the invoice file without identifying the requester",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Get signed document url",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.DocumentURLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/einvoice": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Get the seller, buyer, lines and totals of the UBL, CII or Factur-X file of an invoice. Allowed for the owning issuer, investors, admins and auditors.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Get invoice e-invoice",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.EInvoiceResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/extraction": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Get the values read from the invoice file and the declared values they contradict",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Get invoice extraction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ExtractionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/extraction/review": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Accept the mismatches between the invoice file and the declared values, releasing the hold on the invoice",
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Review invoice extraction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/integrity": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Re-hash the stored invoice file and compare it with the hash recorded on upload",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Verify invoice document",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.IntegrityResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/settlement": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the fees charged on a traded invoice and the amount received by the issuer",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Get trade settlement",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SettlementResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/:id/trade": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Approves or cancels an invoice trade, only allowed for its issuer",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Approve invoice trade",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Invoice id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Trade request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ApproveTradeRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/bulk": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Create invoices from a csv manifest with columns issuer_id, price, currency, face_value (optional), due_date and file, plus a zip archive with the files. Every row must belong to the authenticated issuer. Rows are processed in the background.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Bulk invoice upload",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV manifest",
+                        "name": "manifest",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "ZIP archive with the invoice files",
+                        "name": "archive",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/bulk/:id": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the status of a bulk upload and the result of every manifest row",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Get bulk upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/integrity": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Start re-hashing every stored invoice file in the background to compare it with the hash recorded on upload. Returns the check already in progress if there is one.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Verify invoice documents",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.IntegrityJobResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/integrity/:id": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the status of an integrity check and the result of every invoice file",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "Get invoice documents verification",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.IntegrityJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/invoice/review": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "List the extractions of invoices whose file does not match the declared face value, currency or due date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "invoice"
+                ],
+                "summary": "List invoices to review",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.ExtractionResponse"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/issuer": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve an issuer by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "issuer"
+                ],
+                "summary": "Get Issuer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Issuer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.IssuerResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Create a new issuer to sell invoices",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "issuer"
+                ],
+                "summary": "New issuer",
+                "parameters": [
+                    {
+                        "description": "Issuer request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.CreateIssuerRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.IssuerResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/issuer/:id/dashboard": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the invoice pipeline, funding and approval figures, cash received net of fees and upcoming maturities of an issuer",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "issuer"
+                ],
+                "summary": "Get issuer dashboard",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Issuer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.IssuerDashboardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/issuer/:id/payout-accounts": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the payout accounts of an issuer",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "issuer"
+                ],
+                "summary": "List payout accounts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Issuer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.PayoutAccountResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Register a bank account to receive payouts, optionally receiving trade proceeds automatically",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "issuer"
+                ],
+                "summary": "New payout account",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Issuer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Payout account request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PayoutAccountRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.PayoutAccountResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/issuer/:id/payouts": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the payouts of an issuer",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "issuer"
+                ],
+                "summary": "List payouts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Issuer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.PayoutResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Request a payout from the issuer balance to one of its payout accounts",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "issuer"
+                ],
+                "summary": "New payout",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Issuer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Payout request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PayoutRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.PayoutResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/issuer/:id/payouts/:payoutId/status": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Mark a pending payout as sent, deducting the issuer balance, or a payout as failed, reversing it if it was sent",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "issuer"
+                ],
+                "summary": "Update payout status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Issuer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payout id",
+                        "name": "payoutId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Payout status request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PayoutStatusRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PayoutResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/market/listings": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the positions currently for sale in the secondary market",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "market"
+                ],
+                "summary": "List open listings",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.ListingResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Put a funded bid of a traded invoice up for sale in the secondary market",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "market"
+                ],
+                "summary": "List position",
+                "parameters": [
+                    {
+                        "description": "Listing request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.CreateListingRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.ListingResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/market/listings/:id": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve a secondary market listing by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "market"
+                ],
+                "summary": "Get listing",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Listing id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ListingResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/market/listings/:id/buy": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Buy a listed position, paying the seller and taking over the bid",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "market"
+                ],
+                "summary": "Buy position",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Listing id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Buyer request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ListingInvestorRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ListingResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/market/listings/:id/withdraw": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Take a listed position off the secondary market",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "market"
+                ],
+                "summary": "Withdraw listing",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Listing id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Seller request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ListingInvestorRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/platform/revenue": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "description": "Retrieve the fees collected by the platform per currency",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "platform"
+                ],
+                "summary": "Get platform revenue",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.RevenueResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.APIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "accounting export"
+                }
+            }
+        },
+        "api.APIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:00:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "investorId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "issuerId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "ibk_q1w2e3r4t5y6u7i8o9p0"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "accounting export"
+                },
+                "revokedAt": {
+                    "type": "string",
+                    "example": "2023-07-21T10:00:00Z"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "issuer"
+                    ]
+                }
+            }
+        },
+        "api.AmountRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "1200.50"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "EUR"
+                }
+            }
+        },
+        "api.ApproveTradeRequest": {
+            "type": "object",
+            "properties": {
+                "approve": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "api.BidInvestorResponse": {
+            "type": "object",
+            "properties": {
+                "fullName": {
+                    "type": "string",
+                    "example": "Manuel Adalid"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                }
+            }
+        },
+        "api.BidPricingResponse": {
+            "type": "object",
+            "properties": {
+                "apr": {
+                    "type": "number",
+                    "example": 0.2292
+                },
+                "expectedPayout": {
+                    "type": "string",
+                    "example": "1 300,00 €"
+                },
+                "expectedReturn": {
+                    "type": "string",
+                    "example": "69,55 €"
+                },
+                "yield": {
+                    "type": "number",
+                    "example": 0.0565
+                }
+            }
+        },
+        "api.BidRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "$ref": "#/definitions/api.AmountRequest"
+                },
+                "investorId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                }
+            }
+        },
+        "api.BulkJobResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:00:00Z"
+                },
+                "failed": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "finishedAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:01:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BulkResultResponse"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "finished"
+                },
+                "succeeded": {
+                    "type": "integer",
+                    "example": 98
+                }
+            }
+        },
+        "api.BulkResultResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "file \"invoice-2.pdf\" not found in archive"
+                },
+                "invoiceId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "line": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "api.CreateInvestorRequest": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "$ref": "#/definitions/api.AmountRequest"
+                },
+                "fullName": {
+                    "type": "string",
+                    "example": "Manuel Adalid"
+                }
+            }
+        },
+        "api.CreateIssuerRequest": {
+            "type": "object",
+            "properties": {
+                "fullName": {
+                    "type": "string"
+                },
+                "rating": {
+                    "type": "string",
+                    "example": "B"
+                }
+            }
+        },
+        "api.CreateListingRequest": {
+            "type": "object",
+            "properties": {
+                "bidId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "investorId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "price": {
+                    "$ref": "#/definitions/api.AmountRequest"
+                }
+            }
+        },
+        "api.CreateRuleRequest": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "EUR"
+                },
+                "maxDeployed": {
+                    "type": "string",
+                    "example": "10000"
+                },
+                "maxDueDays": {
+                    "type": "integer",
+                    "example": 90
+                },
+                "maxPerInvoice": {
+                    "type": "string",
+                    "example": "500"
+                },
+                "minRating": {
+                    "type": "string",
+                    "example": "B"
+                }
+            }
+        },
+        "api.DashboardInvoiceResponse": {
+            "type": "object",
+            "properties": {
+                "dueDate": {
+                    "type": "string",
+                    "example": "2023-12-31"
+                },
+                "fundedRatio": {
+                    "type": "number",
+                    "example": 0.8127
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "price": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "remainingPrice": {
+                    "type": "string",
+                    "example": "230,45 €"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "open"
+                }
+            }
+        },
+        "api.DocumentURLResponse": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:00:00Z"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "/invoice/343abd7a-874c-4bb7-ba7b-81e9c71cf1b0/document?expires=1689847200\u0026signature=q1w2e3"
+                }
+            }
+        },
+        "api.EInvoiceLineResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "1 300,00 €"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Consulting services"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "quantity": {
+                    "type": "string",
+                    "example": "10"
+                }
+            }
+        },
+        "api.EInvoicePartyResponse": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "ACME Ltd"
+                },
+                "taxId": {
+                    "type": "string",
+                    "example": "DE811907980"
+                }
+            }
+        },
+        "api.EInvoiceResponse": {
+            "type": "object",
+            "properties": {
+                "buyer": {
+                    "$ref": "#/definitions/api.EInvoicePartyResponse"
+                },
+                "dueDate": {
+                    "type": "string",
+                    "example": "2023-12-31"
+                },
+                "format": {
+                    "type": "string",
+                    "example": "ubl"
+                },
+                "issueDate": {
+                    "type": "string",
+                    "example": "2023-11-01"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.EInvoiceLineResponse"
+                    }
+                },
+                "number": {
+                    "type": "string",
+                    "example": "INV-2023-001"
+                },
+                "seller": {
+                    "$ref": "#/definitions/api.EInvoicePartyResponse"
+                },
+                "total": {
+                    "type": "string",
+                    "example": "1 300,00 €"
+                }
+            }
+        },
+        "api.ExtractionResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "EUR"
+                },
+                "debtorTaxId": {
+                    "type": "string",
+                    "example": "DE811907980"
+                },
+                "dueDate": {
+                    "type": "string",
+                    "example": "2023-12-31"
+                },
+                "extractedAt": {
+                    "type": "string",
+                    "example": "2023-11-02T10:00:00Z"
+                },
+                "flagged": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "invoiceId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "issueDate": {
+                    "type": "string",
+                    "example": "2023-11-01"
+                },
+                "mismatches": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "total"
+                    ]
+                },
+                "number": {
+                    "type": "string",
+                    "example": "INV-2023-001"
+                },
+                "reviewedAt": {
+                    "type": "string",
+                    "example": "2023-11-03T09:00:00Z"
+                },
+                "total": {
+                    "type": "string",
+                    "example": "1190.00"
+                }
+            }
+        },
+        "api.IntegrityJobResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:00:00Z"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "could not retrieve invoice files"
+                },
+                "finishedAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:01:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.IntegrityResponse"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "finished"
+                }
+            }
+        },
+        "api.IntegrityResponse": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "expected": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "invoiceId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "intact"
+                }
+            }
+        },
+        "api.InvestorBidResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "cost": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "invoice": {
+                    "$ref": "#/definitions/api.InvestorInvoiceResponse"
+                },
+                "pricing": {
+                    "$ref": "#/definitions/api.BidPricingResponse"
+                }
+            }
+        },
+        "api.InvestorInvoiceResponse": {
+            "type": "object",
+            "properties": {
+                "dueDate": {
+                    "type": "string",
+                    "example": "2023-12-31"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "issuer": {
+                    "$ref": "#/definitions/api.InvoiceIssuerResponse"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "traded"
+                }
+            }
+        },
+        "api.InvestorResponse": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "bids": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.InvestorBidResponse"
+                    }
+                },
+                "fullName": {
+                    "type": "string",
+                    "example": "Manuel Adalid"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "portfolio": {
+                    "$ref": "#/definitions/api.PortfolioResponse"
+                }
+            }
+        },
+        "api.InvoiceBidResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "investor": {
+                    "$ref": "#/definitions/api.BidInvestorResponse"
+                },
+                "pricing": {
+                    "$ref": "#/definitions/api.BidPricingResponse"
+                },
+                "string": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                }
+            }
+        },
+        "api.InvoiceIssuerResponse": {
+            "type": "object",
+            "properties": {
+                "fullName": {
+                    "type": "string",
+                    "example": "Manuel Adalid"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                }
+            }
+        },
+        "api.InvoicePageResponse": {
+            "type": "object",
+            "properties": {
+                "invoices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.InvoiceResponse"
+                    }
+                },
+                "nextCursor": {
+                    "type": "string",
+                    "example": "eyJzIjoiZHVlX2RhdGUiLCJ2IjoiMjAyMy0xMi0zMSIsImlkIjoiMzQzYWJkN2EifQ"
+                }
+            }
+        },
+        "api.InvoicePricingResponse": {
+            "type": "object",
+            "properties": {
+                "apr": {
+                    "type": "number",
+                    "example": 0.2292
+                },
+                "daysToMaturity": {
+                    "type": "integer",
+                    "example": 90
+                },
+                "discount": {
+                    "type": "string",
+                    "example": "69,55 €"
+                },
+                "discountRate": {
+                    "type": "number",
+                    "example": 0.0535
+                },
+                "yield": {
+                    "type": "number",
+                    "example": 0.0565
+                }
+            }
+        },
+        "api.InvoiceResponse": {
+            "type": "object",
+            "properties": {
+                "bids": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.InvoiceBidResponse"
+                    }
+                },
+                "dueDate": {
+                    "type": "string",
+                    "example": "2023-12-31"
+                },
+                "eInvoice": {
+                    "$ref": "#/definitions/api.EInvoiceResponse"
+                },
+                "faceValue": {
+                    "type": "string",
+                    "example": "1 300,00 €"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "issuer": {
+                    "$ref": "#/definitions/api.InvoiceIssuerResponse"
+                },
+                "price": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "pricing": {
+                    "$ref": "#/definitions/api.InvoicePricingResponse"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "open"
+                }
+            }
+        },
+        "api.IssuerBidResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "string": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                }
+            }
+        },
+        "api.IssuerCommitmentResponse": {
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "issuer": {
+                    "$ref": "#/definitions/api.InvoiceIssuerResponse"
+                }
+            }
+        },
+        "api.IssuerDashboardResponse": {
+            "type": "object",
+            "properties": {
+                "approvalRate": {
+                    "type": "number",
+                    "example": 0.75
+                },
+                "averageFundingHours": {
+                    "type": "number",
+                    "example": 36.5
+                },
+                "balance": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "cashReceived": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "fullName": {
+                    "type": "string",
+                    "example": "Manuel Adalid"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "invoices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DashboardInvoiceResponse"
+                    }
+                },
+                "pipeline": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.PipelineResponse"
+                    }
+                },
+                "upcomingMaturities": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DashboardInvoiceResponse"
+                    }
+                }
+            }
+        },
+        "api.IssuerInvoiceResponse": {
+            "type": "object",
+            "properties": {
+                "bids": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.IssuerBidResponse"
+                    }
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "price": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "open"
+                }
+            }
+        },
+        "api.IssuerResponse": {
             "type": "object",
             "properties": {
-                "amount": {
+                "balance": {
                     "type": "string",
-                    "example": "1200.50"
+                    "example": "1 230,45 €"
                 },
-                "currency": {
+                "fullName": {
                     "type": "string",
-                    "example": "EUR"
+                    "example": "Manuel Adalid"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "invoices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.IssuerInvoiceResponse"
+                    }
+                },
+                "rating": {
+                    "type": "string",
+                    "example": "B"
                 }
             }
         },
-        "api.ApproveTradeRequest": {
+        "api.ListingInvestorRequest": {
             "type": "object",
             "properties": {
-                "approve": {
-                    "type": "boolean",
-                    "example": true
+                "investorId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
                 }
             }
         },
-        "api.BidInvestorResponse": {
+        "api.ListingResponse": {
             "type": "object",
             "properties": {
-                "fullName": {
+                "bidId": {
                     "type": "string",
-                    "example": "Manuel Adalid"
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "buyerId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:00:00Z"
                 },
                 "id": {
                     "type": "string",
                     "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "invoiceId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "price": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "sellerId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "listed"
                 }
             }
         },
-        "api.BidRequest": {
+        "api.PaymentRequest": {
             "type": "object",
             "properties": {
                 "amount": {
                     "$ref": "#/definitions/api.AmountRequest"
                 },
-                "investorId": {
+                "iban": {
+                    "type": "string",
+                    "example": "ES9121000418450200051332"
+                },
+                "reference": {
+                    "type": "string",
+                    "example": "Top up July"
+                }
+            }
+        },
+        "api.PaymentResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:00:00Z"
+                },
+                "iban": {
+                    "type": "string",
+                    "example": "ES9121000418450200051332"
+                },
+                "id": {
                     "type": "string",
                     "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "deposit"
+                },
+                "reference": {
+                    "type": "string",
+                    "example": "Top up July"
+                },
+                "settledAt": {
+                    "type": "string",
+                    "example": "2023-07-21T10:00:00Z"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "pending"
                 }
             }
         },
-        "api.CreateInvestorRequest": {
+        "api.PayoutAccountRequest": {
             "type": "object",
             "properties": {
-                "balance": {
-                    "$ref": "#/definitions/api.AmountRequest"
+                "auto": {
+                    "type": "boolean",
+                    "example": true
                 },
-                "fullName": {
+                "holder": {
                     "type": "string",
                     "example": "Manuel Adalid"
+                },
+                "iban": {
+                    "type": "string",
+                    "example": "ES9121000418450200051332"
                 }
             }
         },
-        "api.CreateIssuerRequest": {
+        "api.PayoutAccountResponse": {
             "type": "object",
             "properties": {
-                "fullName": {
-                    "type": "string"
+                "auto": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "holder": {
+                    "type": "string",
+                    "example": "Manuel Adalid"
+                },
+                "iban": {
+                    "type": "string",
+                    "example": "ES9121000418450200051332"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
                 }
             }
         },
-        "api.HTTPError": {
+        "api.PayoutRequest": {
             "type": "object",
             "properties": {
-                "error": {
-                    "type": "string"
+                "accountId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "amount": {
+                    "$ref": "#/definitions/api.AmountRequest"
                 }
             }
         },
-        "api.InvestorResponse": {
+        "api.PayoutResponse": {
             "type": "object",
             "properties": {
-                "balance": {
+                "accountId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "amount": {
                     "type": "string",
                     "example": "1 230,45 €"
                 },
-                "bids": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/api.BidInvestorResponse"
-                    }
+                "createdAt": {
+                    "type": "string",
+                    "example": "2023-07-20T10:00:00Z"
                 },
-                "fullName": {
+                "failedAt": {
                     "type": "string",
-                    "example": "Manuel Adalid"
+                    "example": "2023-07-22T10:00:00Z"
                 },
                 "id": {
                     "type": "string",
                     "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "sentAt": {
+                    "type": "string",
+                    "example": "2023-07-21T10:00:00Z"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "pending"
                 }
             }
         },
-        "api.InvoiceBidResponse": {
+        "api.PayoutStatusRequest": {
             "type": "object",
             "properties": {
-                "id": {
-                    "type": "string",
-                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
-                },
-                "investor": {
-                    "$ref": "#/definitions/api.BidInvestorResponse"
-                },
-                "string": {
+                "status": {
                     "type": "string",
-                    "example": "1 230,45 €"
+                    "example": "sent"
                 }
             }
         },
-        "api.InvoiceIssuerResponse": {
+        "api.PipelineResponse": {
             "type": "object",
             "properties": {
-                "fullName": {
-                    "type": "string",
-                    "example": "Manuel Adalid"
+                "count": {
+                    "type": "integer",
+                    "example": 3
                 },
-                "id": {
+                "status": {
                     "type": "string",
-                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                    "example": "open"
+                },
+                "value": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "api.InvoiceResponse": {
+        "api.PortfolioResponse": {
             "type": "object",
             "properties": {
-                "bids": {
+                "committedByCurrency": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "committedByIssuer": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/api.InvoiceBidResponse"
+                        "$ref": "#/definitions/api.IssuerCommitmentResponse"
                     }
                 },
-                "id": {
+                "positions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.InvestorBidResponse"
+                    }
+                },
+                "realisedReturns": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "unrealisedReturns": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
                     "type": "string",
-                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                    "example": "insufficient_funds"
                 },
-                "issuer": {
-                    "$ref": "#/definitions/api.InvoiceIssuerResponse"
+                "detail": {
+                    "type": "string",
+                    "example": "insufficient funds"
                 },
-                "price": {
+                "instance": {
                     "type": "string",
-                    "example": "1 230,45 €"
+                    "example": "/invoice/343abd7a-874c-4bb7-ba7b-81e9c71cf1b0/bid"
                 },
                 "status": {
+                    "type": "integer",
+                    "example": 409
+                },
+                "title": {
                     "type": "string",
-                    "example": "open"
+                    "example": "Insufficient funds"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/insufficient-funds"
                 }
             }
         },
-        "api.IssuerBidResponse": {
+        "api.RevenueResponse": {
             "type": "object",
             "properties": {
-                "id": {
+                "revenue": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.RuleBidResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "500,00 €"
+                },
+                "bidId": {
                     "type": "string",
                     "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
                 },
-                "string": {
+                "createdAt": {
                     "type": "string",
-                    "example": "1 230,45 €"
+                    "example": "2023-07-20T10:00:00Z"
+                },
+                "invoiceId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
                 }
             }
         },
-        "api.IssuerInvoiceResponse": {
+        "api.RuleResponse": {
             "type": "object",
             "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
                 "bids": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/api.IssuerBidResponse"
+                        "$ref": "#/definitions/api.RuleBidResponse"
                     }
                 },
+                "currency": {
+                    "type": "string",
+                    "example": "EUR"
+                },
+                "deployed": {
+                    "type": "string",
+                    "example": "1 230,45 €"
+                },
                 "id": {
                     "type": "string",
                     "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
                 },
-                "price": {
+                "maxDeployed": {
+                    "type": "string",
+                    "example": "10 000,00 €"
+                },
+                "maxDueDays": {
+                    "type": "integer",
+                    "example": 90
+                },
+                "maxPerInvoice": {
+                    "type": "string",
+                    "example": "500,00 €"
+                },
+                "minRating": {
+                    "type": "string",
+                    "example": "B"
+                }
+            }
+        },
+        "api.SettlePaymentRequest": {
+            "type": "object",
+            "properties": {
+                "settled": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "api.SettlementItemResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "12,30 €"
+                },
+                "base": {
                     "type": "string",
                     "example": "1 230,45 €"
                 },
-                "status": {
+                "kind": {
                     "type": "string",
-                    "example": "open"
+                    "example": "percentage"
+                },
+                "payer": {
+                    "type": "string",
+                    "example": "issuer"
+                },
+                "payerId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "rate": {
+                    "type": "string",
+                    "example": "1"
                 }
             }
         },
-        "api.IssuerResponse": {
+        "api.SettlementResponse": {
             "type": "object",
             "properties": {
-                "balance": {
+                "amount": {
                     "type": "string",
                     "example": "1 230,45 €"
                 },
-                "fullName": {
+                "createdAt": {
                     "type": "string",
-                    "example": "Manuel Adalid"
+                    "example": "2023-07-20T10:00:00Z"
                 },
-                "id": {
+                "investorFees": {
+                    "type": "string",
+                    "example": "5,00 €"
+                },
+                "invoiceId": {
                     "type": "string",
                     "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
                 },
-                "invoices": {
+                "issuerFees": {
+                    "type": "string",
+                    "example": "12,30 €"
+                },
+                "issuerId": {
+                    "type": "string",
+                    "example": "343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"
+                },
+                "issuerNet": {
+                    "type": "string",
+                    "example": "1 218,15 €"
+                },
+                "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/api.IssuerInvoiceResponse"
+                        "$ref": "#/definitions/api.SettlementItemResponse"
                     }
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "Bearer": {
+            "description": "JWT or API key, as \"Bearer \u003ccredential\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
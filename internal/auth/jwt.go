@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type claims struct {
+	jwt.RegisteredClaims
+	IssuerID   string   `json:"issuer_id,omitempty"`
+	InvestorID string   `json:"investor_id,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+}
+
+// JWTVerifier validates HS256 tokens signed with a shared secret and RS256
+// tokens signed with one of the keys of a JWKS.
+type JWTVerifier struct {
+	secret   []byte
+	keys     map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+}
+
+func NewJWTVerifier(secret []byte, keys map[string]*rsa.PublicKey, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		secret:   secret,
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+func (v *JWTVerifier) Verify(token string) (Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if v.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		opts = append(opts, jwt.WithAudience(v.audience))
+	}
+
+	var c claims
+	if _, err := jwt.ParseWithClaims(token, &c, v.key, opts...); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	if c.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	}
+
+	roles := linkedRoles(c.IssuerID, c.InvestorID)
+	if len(c.Roles) > 0 {
+		roles = make([]Role, 0, len(c.Roles))
+		for _, r := range c.Roles {
+			if !Role(r).valid() {
+				return Principal{}, fmt.Errorf("%w: unknown role %q", ErrUnauthenticated, r)
+			}
+
+			roles = append(roles, Role(r))
+		}
+	}
+
+	return Principal{
+		Subject:    c.Subject,
+		IssuerID:   c.IssuerID,
+		InvestorID: c.InvestorID,
+		Roles:      roles,
+		Method:     JWT,
+	}, nil
+}
+
+func (v *JWTVerifier) key(t *jwt.Token) (interface{}, error) {
+	switch t.Method {
+	case jwt.SigningMethodHS256:
+		if len(v.secret) == 0 {
+			return nil, fmt.Errorf("hs256 tokens are not accepted")
+		}
+
+		return v.secret, nil
+	case jwt.SigningMethodRS256:
+		kid, _ := t.Header["kid"].(string)
+		key, ok := v.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// LoadJWKS reads the RSA signing keys of a JWKS file by key id. Keys of
+// other types or uses are ignored.
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read jwks file: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("could not unmarshal jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
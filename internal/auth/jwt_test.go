@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func sign(method jwt.SigningMethod, key interface{}, kid string, c jwt.MapClaims) string {
+	t := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		t.Header["kid"] = kid
+	}
+
+	s, err := t.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+func TestJWTVerifier(t *testing.T) {
+	Convey("JWTVerifier", t, func() {
+		secret := []byte("secret")
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+
+		jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+		jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		}}})
+		So(os.WriteFile(jwksPath, jwks, 0o600), ShouldBeNil)
+
+		keys, err := LoadJWKS(jwksPath)
+		So(err, ShouldBeNil)
+		So(keys, ShouldContainKey, "key-1")
+
+		v := NewJWTVerifier(secret, keys, "ibidder", "api")
+		valid := jwt.MapClaims{
+			"sub":         "user-1",
+			"iss":         "ibidder",
+			"aud":         "api",
+			"exp":         time.Now().Add(time.Hour).Unix(),
+			"issuer_id":   "issuer-1",
+			"investor_id": "investor-1",
+		}
+		with := func(key string, value interface{}) jwt.MapClaims {
+			c := jwt.MapClaims{}
+			for k, v := range valid {
+				c[k] = v
+			}
+			if value == nil {
+				delete(c, key)
+			} else {
+				c[key] = value
+			}
+			return c
+		}
+
+		Convey("when the token is valid", func() {
+			for name, token := range map[string]string{
+				"HS256": sign(jwt.SigningMethodHS256, secret, "", valid),
+				"RS256": sign(jwt.SigningMethodRS256, rsaKey, "key-1", valid),
+			} {
+				Convey("return the principal of a "+name+" token", func() {
+					p, err := v.Verify(token)
+					So(err, ShouldBeNil)
+					So(p, ShouldResemble, Principal{
+						Subject:    "user-1",
+						IssuerID:   "issuer-1",
+						InvestorID: "investor-1",
+						Roles:      []Role{ISSUER, INVESTOR},
+						Method:     JWT,
+					})
+				})
+			}
+		})
+
+		Convey("when the token grants roles", func() {
+			p, err := v.Verify(sign(jwt.SigningMethodHS256, secret, "", with("roles", []string{"admin", "auditor"})))
+			So(err, ShouldBeNil)
+
+			Convey("use them instead of the linked ones", func() {
+				So(p.Roles, ShouldResemble, []Role{ADMIN, AUDITOR})
+				So(p.HasRole(ISSUER), ShouldBeFalse)
+				So(p.IsIssuer("issuer-1"), ShouldBeFalse)
+			})
+		})
+
+		Convey("when the token is invalid", func() {
+			otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+			for name, token := range map[string]string{
+				"wrong secret":    sign(jwt.SigningMethodHS256, []byte("other"), "", valid),
+				"wrong rsa key":   sign(jwt.SigningMethodRS256, otherKey, "key-1", valid),
+				"unknown kid":     sign(jwt.SigningMethodRS256, rsaKey, "key-2", valid),
+				"unsupported alg": sign(jwt.SigningMethodHS512, secret, "", valid),
+				"none alg":        sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", valid),
+				"expired":         sign(jwt.SigningMethodHS256, secret, "", with("exp", time.Now().Add(-time.Minute).Unix())),
+				"no expiration":   sign(jwt.SigningMethodHS256, secret, "", with("exp", nil)),
+				"wrong issuer":    sign(jwt.SigningMethodHS256, secret, "", with("iss", "other")),
+				"wrong audience":  sign(jwt.SigningMethodHS256, secret, "", with("aud", "other")),
+				"no subject":      sign(jwt.SigningMethodHS256, secret, "", with("sub", nil)),
+				"unknown role":    sign(jwt.SigningMethodHS256, secret, "", with("roles", []string{"root"})),
+				"malformed":       "not.a.token",
+			} {
+				Convey("return unauthenticated for "+name, func() {
+					_, err := v.Verify(token)
+					So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+				})
+			}
+		})
+
+		Convey("when no secret is configured", func() {
+			v := NewJWTVerifier(nil, keys, "", "")
+
+			Convey("reject HS256 tokens", func() {
+				_, err := v.Verify(sign(jwt.SigningMethodHS256, []byte{}, "", valid))
+				So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrUnauthenticated is returned for missing, invalid or expired credentials
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrNotFound        = errors.New("api key not found")
+	// ErrKeyFromKey is returned when an api key tries to issue another one
+	ErrKeyFromKey = errors.New("api keys cannot create api keys")
+)
+
+type Method string
+
+const (
+	JWT    Method = "jwt"
+	APIKEY Method = "api_key"
+)
+
+// Principal is the authenticated user of a request, linked to the issuer or
+// investor it acts for and with the roles it was granted.
+type Principal struct {
+	Subject    string
+	IssuerID   string
+	InvestorID string
+	Roles      []Role
+	Method     Method
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
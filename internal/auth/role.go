@@ -0,0 +1,56 @@
+package auth
+
+type Role string
+
+const (
+	ISSUER   Role = "issuer"
+	INVESTOR Role = "investor"
+	ADMIN    Role = "admin"
+	AUDITOR  Role = "auditor"
+)
+
+func (r Role) valid() bool {
+	switch r {
+	case ISSUER, INVESTOR, ADMIN, AUDITOR:
+		return true
+	default:
+		return false
+	}
+}
+
+// HasRole tells if the principal was granted any of the roles
+func (p Principal) HasRole(roles ...Role) bool {
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsIssuer tells if the principal acts as the issuer with the id
+func (p Principal) IsIssuer(id string) bool {
+	return id != "" && p.IssuerID == id && p.HasRole(ISSUER)
+}
+
+// IsInvestor tells if the principal acts as the investor with the id
+func (p Principal) IsInvestor(id string) bool {
+	return id != "" && p.InvestorID == id && p.HasRole(INVESTOR)
+}
+
+// linkedRoles are the roles of a principal without explicit ones, granted
+// by the issuer or investor it is linked to
+func linkedRoles(issuerID, investorID string) []Role {
+	var roles []Role
+	if issuerID != "" {
+		roles = append(roles, ISSUER)
+	}
+	if investorID != "" {
+		roles = append(roles, INVESTOR)
+	}
+
+	return roles
+}
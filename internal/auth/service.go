@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Storage interface {
+	SaveAPIKey(context.Context, APIKey) error
+	RetrieveAPIKeyByHash(context.Context, string) (APIKey, error)
+	RetrieveAPIKeysBySubject(context.Context, string) ([]APIKey, error)
+	RevokeAPIKey(context.Context, string, string, time.Time) error
+}
+
+type Service struct {
+	st  Storage
+	jwt *JWTVerifier
+}
+
+func NewService(st Storage, jwt *JWTVerifier) *Service {
+	return &Service{
+		st:  st,
+		jwt: jwt,
+	}
+}
+
+// Authenticate resolves the principal of a bearer credential, either an
+// API key or a JWT.
+func (s *Service) Authenticate(ctx context.Context, credential string) (Principal, error) {
+	if credential == "" {
+		return Principal{}, fmt.Errorf("%w: missing credentials", ErrUnauthenticated)
+	}
+
+	if strings.HasPrefix(credential, keyPrefix) {
+		return s.authenticateKey(ctx, credential)
+	}
+
+	return s.jwt.Verify(credential)
+}
+
+func (s *Service) authenticateKey(ctx context.Context, key string) (Principal, error) {
+	k, err := s.st.RetrieveAPIKeyByHash(ctx, hashKey(key))
+	if errors.Is(err, ErrNotFound) {
+		return Principal{}, fmt.Errorf("%w: unknown api key", ErrUnauthenticated)
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if k.RevokedAt != nil {
+		return Principal{}, fmt.Errorf("%w: api key was revoked", ErrUnauthenticated)
+	}
+
+	return k.principal(), nil
+}
+
+// CreateAPIKey issues a key acting as the principal. The key itself is only
+// returned here, as just its hash is stored. A leaked key must not be able to
+// outlive its revocation, so keys cannot issue keys.
+func (s *Service) CreateAPIKey(ctx context.Context, p Principal, name string) (string, APIKey, error) {
+	if p.Method == APIKEY {
+		return "", APIKey{}, ErrKeyFromKey
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return "", APIKey{}, fmt.Errorf("could not generate id: %w", err)
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		return "", APIKey{}, err
+	}
+
+	k := APIKey{
+		ID:         id.String(),
+		Name:       name,
+		Hash:       hashKey(key),
+		Subject:    p.Subject,
+		IssuerID:   p.IssuerID,
+		InvestorID: p.InvestorID,
+		Roles:      p.Roles,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.st.SaveAPIKey(ctx, k); err != nil {
+		return "", APIKey{}, err
+	}
+
+	return key, k, nil
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context, p Principal) ([]APIKey, error) {
+	return s.st.RetrieveAPIKeysBySubject(ctx, p.Subject)
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, p Principal, id string) error {
+	return s.st.RevokeAPIKey(ctx, id, p.Subject, time.Now())
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockStorage struct {
+	keys map[string]APIKey
+}
+
+func (m *mockStorage) SaveAPIKey(_ context.Context, k APIKey) error {
+	m.keys[k.Hash] = k
+	return nil
+}
+
+func (m *mockStorage) RetrieveAPIKeyByHash(_ context.Context, hash string) (APIKey, error) {
+	k, ok := m.keys[hash]
+	if !ok {
+		return APIKey{}, ErrNotFound
+	}
+
+	return k, nil
+}
+
+func (m *mockStorage) RetrieveAPIKeysBySubject(context.Context, string) ([]APIKey, error) {
+	panic("implement me")
+}
+
+func (m *mockStorage) RevokeAPIKey(_ context.Context, id, subject string, at time.Time) error {
+	for hash, k := range m.keys {
+		if k.ID == id && k.Subject == subject {
+			k.RevokedAt = &at
+			m.keys[hash] = k
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func TestAPIKeys(t *testing.T) {
+	Convey("API keys", t, func() {
+		st := &mockStorage{keys: map[string]APIKey{}}
+		svc := NewService(st, NewJWTVerifier([]byte("secret"), nil, "", ""))
+		ctx := context.Background()
+		owner := Principal{Subject: "user-1", IssuerID: "issuer-1", Roles: []Role{ISSUER}, Method: JWT}
+
+		Convey("when a key was created", func() {
+			key, k, err := svc.CreateAPIKey(ctx, owner, "export")
+			So(err, ShouldBeNil)
+
+			Convey("store only its hash", func() {
+				So(strings.HasPrefix(key, keyPrefix), ShouldBeTrue)
+				So(k.Hash, ShouldEqual, hashKey(key))
+				So(k.Hash, ShouldNotContainSubstring, key)
+			})
+
+			Convey("authenticate it as its owner", func() {
+				p, err := svc.Authenticate(ctx, key)
+				So(err, ShouldBeNil)
+				So(p, ShouldResemble, Principal{Subject: "user-1", IssuerID: "issuer-1", Roles: []Role{ISSUER}, Method: APIKEY})
+			})
+
+			Convey("reject it once revoked", func() {
+				So(svc.RevokeAPIKey(ctx, owner, k.ID), ShouldBeNil)
+
+				_, err := svc.Authenticate(ctx, key)
+				So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+			})
+
+			Convey("do not let others revoke it", func() {
+				err := svc.RevokeAPIKey(ctx, Principal{Subject: "user-2"}, k.ID)
+				So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when a key tries to create another key", func() {
+			_, _, err := svc.CreateAPIKey(ctx, Principal{Subject: "user-1", Roles: []Role{ISSUER}, Method: APIKEY}, "escalate")
+
+			Convey("forbid it", func() {
+				So(errors.Is(err, ErrKeyFromKey), ShouldBeTrue)
+				So(st.keys, ShouldBeEmpty)
+			})
+		})
+
+		Convey("reject unknown keys and missing credentials", func() {
+			_, err := svc.Authenticate(ctx, keyPrefix+"unknown")
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+
+			_, err = svc.Authenticate(ctx, "")
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/auth"
+)
+
+// MemoryStorage keeps API keys in memory, indexed by id. Keys are looked up
+// by their hash, which must be unique.
+type MemoryStorage struct {
+	mu   sync.RWMutex
+	keys map[string]auth.APIKey
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{keys: map[string]auth.APIKey{}}
+}
+
+func (s *MemoryStorage) SaveAPIKey(_ context.Context, k auth.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, held := range s.keys {
+		if held.ID == k.ID || held.Hash == k.Hash {
+			return fmt.Errorf("could not save api key: key %s already exists", k.ID)
+		}
+	}
+
+	s.keys[k.ID] = copyKey(k)
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveAPIKeyByHash(_ context.Context, hash string) (auth.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return copyKey(k), nil
+		}
+	}
+
+	return auth.APIKey{}, auth.ErrNotFound
+}
+
+func (s *MemoryStorage) RetrieveAPIKeysBySubject(_ context.Context, subject string) ([]auth.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []auth.APIKey
+	for _, k := range s.keys {
+		if k.Subject == subject {
+			keys = append(keys, copyKey(k))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes a key of the subject, keeping the first revocation
+func (s *MemoryStorage) RevokeAPIKey(_ context.Context, id, subject string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok || k.Subject != subject {
+		return auth.ErrNotFound
+	}
+
+	if k.RevokedAt == nil {
+		k.RevokedAt = &at
+		s.keys[id] = k
+	}
+
+	return nil
+}
+
+func copyKey(k auth.APIKey) auth.APIKey {
+	k.Roles = append([]auth.Role(nil), k.Roles...)
+	if k.RevokedAt != nil {
+		revoked := *k.RevokedAt
+		k.RevokedAt = &revoked
+	}
+
+	return k
+}
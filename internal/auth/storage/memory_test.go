@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/auth/storage/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) auth.Storage {
+		return NewMemoryStorage()
+	})
+}
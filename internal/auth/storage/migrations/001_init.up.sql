@@ -0,0 +1,12 @@
+CREATE TABLE api_keys (
+    id CHAR(36) PRIMARY KEY,
+    name TEXT NOT NULL,
+    hash CHAR(64) NOT NULL UNIQUE,
+    subject TEXT NOT NULL,
+    issuer_id CHAR(36),
+    investor_id CHAR(36),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX api_keys_subject_idx ON api_keys (subject);
@@ -0,0 +1,6 @@
+ALTER TABLE api_keys ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE api_keys SET roles = array_remove(ARRAY[
+    CASE WHEN issuer_id IS NOT NULL THEN 'issuer' END,
+    CASE WHEN investor_id IS NOT NULL THEN 'investor' END
+], NULL);
@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    subject TEXT NOT NULL,
+    issuer_id TEXT,
+    investor_id TEXT,
+    roles TEXT NOT NULL DEFAULT '[]',
+    created_at TEXT NOT NULL,
+    revoked_at TEXT
+);
+
+CREATE INDEX api_keys_subject_idx ON api_keys (subject);
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nerock/invoicebidder/internal/auth"
+)
+
+type Storage struct {
+	c *pgxpool.Pool
+}
+
+func New(c *pgxpool.Pool) *Storage {
+	return &Storage{c}
+}
+
+func (s *Storage) SaveAPIKey(ctx context.Context, k auth.APIKey) error {
+	const query = `INSERT INTO api_keys (id, name, hash, subject, issuer_id, investor_id, roles, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8)`
+
+	roles := make([]string, 0, len(k.Roles))
+	for _, r := range k.Roles {
+		roles = append(roles, string(r))
+	}
+
+	if _, err := s.c.Exec(ctx, query, k.ID, k.Name, k.Hash, k.Subject, k.IssuerID, k.InvestorID, roles, k.CreatedAt); err != nil {
+		return fmt.Errorf("could not save api key in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrieveAPIKeyByHash(ctx context.Context, hash string) (auth.APIKey, error) {
+	const query = `SELECT k.id, k.name, k.hash, k.subject, COALESCE(k.issuer_id, ''), COALESCE(k.investor_id, ''), k.roles, k.created_at, k.revoked_at
+		FROM api_keys k WHERE k.hash = $1`
+
+	k, err := scanAPIKey(s.c.QueryRow(ctx, query, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return k, auth.ErrNotFound
+	}
+	if err != nil {
+		return k, fmt.Errorf("could not retrieve api key: %w", err)
+	}
+
+	return k, nil
+}
+
+func (s *Storage) RetrieveAPIKeysBySubject(ctx context.Context, subject string) ([]auth.APIKey, error) {
+	const query = `SELECT k.id, k.name, k.hash, k.subject, COALESCE(k.issuer_id, ''), COALESCE(k.investor_id, ''), k.roles, k.created_at, k.revoked_at
+		FROM api_keys k WHERE k.subject = $1 ORDER BY k.created_at`
+
+	rows, err := s.c.Query(ctx, query, subject)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []auth.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan api key: %w", err)
+		}
+
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes a key of the subject, which cannot revoke the keys
+// of anyone else.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id, subject string, at time.Time) error {
+	const query = `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $3) WHERE id = $1 AND subject = $2`
+
+	tag, err := s.c.Exec(ctx, query, id, subject, at)
+	if err != nil {
+		return fmt.Errorf("could not revoke api key in db: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return auth.ErrNotFound
+	}
+
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (auth.APIKey, error) {
+	var (
+		k     auth.APIKey
+		roles []string
+	)
+	if err := row.Scan(&k.ID, &k.Name, &k.Hash, &k.Subject, &k.IssuerID, &k.InvestorID, &roles, &k.CreatedAt, &k.RevokedAt); err != nil {
+		return k, err
+	}
+
+	for _, r := range roles {
+		k.Roles = append(k.Roles, auth.Role(r))
+	}
+
+	return k, nil
+}
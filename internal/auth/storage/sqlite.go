@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/sqlite"
+)
+
+//go:embed migrations/sqlite/*.up.sql
+var sqliteMigrations embed.FS
+
+// SQLiteStorage keeps API keys in an embedded sqlite db, the roles of a key
+// are stored as a json array.
+type SQLiteStorage struct {
+	c *sql.DB
+}
+
+func NewSQLiteStorage(c *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{c}
+}
+
+func (s *SQLiteStorage) Migrate(ctx context.Context) error {
+	migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	return sqlite.Migrate(ctx, s.c, migrations)
+}
+
+func (s *SQLiteStorage) SaveAPIKey(ctx context.Context, k auth.APIKey) error {
+	const query = `INSERT INTO api_keys (id, name, hash, subject, issuer_id, investor_id, roles, created_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?)`
+
+	roles := make([]string, 0, len(k.Roles))
+	for _, r := range k.Roles {
+		roles = append(roles, string(r))
+	}
+
+	js, err := json.Marshal(roles)
+	if err != nil {
+		return fmt.Errorf("could not marshal roles: %w", err)
+	}
+
+	if _, err := s.c.ExecContext(ctx, query, k.ID, k.Name, k.Hash, k.Subject, k.IssuerID, k.InvestorID, string(js), sqlite.FormatTime(k.CreatedAt)); err != nil {
+		return fmt.Errorf("could not save api key in db: %w", err)
+	}
+
+	return nil
+}
+
+const sqliteAPIKeyColumns = `k.id, k.name, k.hash, k.subject, COALESCE(k.issuer_id, ''), COALESCE(k.investor_id, ''), k.roles, k.created_at, k.revoked_at`
+
+func (s *SQLiteStorage) RetrieveAPIKeyByHash(ctx context.Context, hash string) (auth.APIKey, error) {
+	query := fmt.Sprintf(`SELECT %s FROM api_keys k WHERE k.hash = ?`, sqliteAPIKeyColumns)
+
+	k, err := scanSQLiteAPIKey(s.c.QueryRowContext(ctx, query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return k, auth.ErrNotFound
+	}
+	if err != nil {
+		return k, fmt.Errorf("could not retrieve api key: %w", err)
+	}
+
+	return k, nil
+}
+
+func (s *SQLiteStorage) RetrieveAPIKeysBySubject(ctx context.Context, subject string) ([]auth.APIKey, error) {
+	query := fmt.Sprintf(`SELECT %s FROM api_keys k WHERE k.subject = ? ORDER BY k.created_at`, sqliteAPIKeyColumns)
+
+	rows, err := s.c.QueryContext(ctx, query, subject)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []auth.APIKey
+	for rows.Next() {
+		k, err := scanSQLiteAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan api key: %w", err)
+		}
+
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes a key of the subject, which cannot revoke the keys
+// of anyone else.
+func (s *SQLiteStorage) RevokeAPIKey(ctx context.Context, id, subject string, at time.Time) error {
+	const query = `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ? AND subject = ?`
+
+	res, err := s.c.ExecContext(ctx, query, sqlite.FormatTime(at), id, subject)
+	if err != nil {
+		return fmt.Errorf("could not revoke api key in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not revoke api key in db: %w", err)
+	}
+	if n == 0 {
+		return auth.ErrNotFound
+	}
+
+	return nil
+}
+
+type sqliteRow interface {
+	Scan(...any) error
+}
+
+func scanSQLiteAPIKey(row sqliteRow) (auth.APIKey, error) {
+	var (
+		k     auth.APIKey
+		roles string
+	)
+	if err := row.Scan(&k.ID, &k.Name, &k.Hash, &k.Subject, &k.IssuerID, &k.InvestorID, &roles, sqlite.Time{T: &k.CreatedAt},
+		sqlite.NullTime{T: &k.RevokedAt}); err != nil {
+		return k, err
+	}
+
+	var rs []string
+	if err := json.Unmarshal([]byte(roles), &rs); err != nil {
+		return k, fmt.Errorf("could not unmarshal roles: %w", err)
+	}
+	for _, r := range rs {
+		k.Roles = append(k.Roles, auth.Role(r))
+	}
+
+	return k, nil
+}
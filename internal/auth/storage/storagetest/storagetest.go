@@ -0,0 +1,112 @@
+// Package storagetest holds the behaviour every auth.Storage must have, so
+// all the backends are tested alike.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/auth"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Run checks an auth.Storage, newStorage must return an empty storage on
+// every call.
+func Run(t *testing.T, newStorage func(*testing.T) auth.Storage) {
+	Convey("auth.Storage", t, func() {
+		ctx := context.Background()
+		st := newStorage(t)
+
+		created := time.Now().UTC().Truncate(time.Millisecond)
+		issuerKey := auth.APIKey{ID: id(1), Name: "export", Hash: hash("1"), Subject: "user-1", IssuerID: id(10),
+			Roles: []auth.Role{auth.ISSUER}, CreatedAt: created}
+		So(st.SaveAPIKey(ctx, issuerKey), ShouldBeNil)
+		So(st.SaveAPIKey(ctx, auth.APIKey{ID: id(2), Name: "audit", Hash: hash("2"), Subject: "user-1",
+			Roles: []auth.Role{auth.ADMIN, auth.AUDITOR}, CreatedAt: created.Add(time.Second)}), ShouldBeNil)
+
+		Convey("when a key is retrieved by its hash", func() {
+			k, err := st.RetrieveAPIKeyByHash(ctx, hash("1"))
+			So(err, ShouldBeNil)
+
+			Convey("return it as it was saved", func() {
+				So(k.ID, ShouldEqual, id(1))
+				So(k.Name, ShouldEqual, "export")
+				So(k.Subject, ShouldEqual, "user-1")
+				So(k.IssuerID, ShouldEqual, id(10))
+				So(k.InvestorID, ShouldBeEmpty)
+				So(k.Roles, ShouldResemble, []auth.Role{auth.ISSUER})
+				So(k.CreatedAt.Equal(created), ShouldBeTrue)
+				So(k.RevokedAt, ShouldBeNil)
+			})
+		})
+
+		Convey("when nothing was saved under a hash", func() {
+			_, err := st.RetrieveAPIKeyByHash(ctx, hash("99"))
+
+			Convey("return not found", func() {
+				So(errors.Is(err, auth.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when a key with a taken hash is saved", func() {
+			err := st.SaveAPIKey(ctx, auth.APIKey{ID: id(3), Name: "copy", Hash: hash("1"), Subject: "user-2", CreatedAt: created})
+
+			Convey("reject it", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the keys of a subject are retrieved", func() {
+			keys, err := st.RetrieveAPIKeysBySubject(ctx, "user-1")
+			So(err, ShouldBeNil)
+
+			Convey("return them in creation order", func() {
+				So(keys, ShouldHaveLength, 2)
+				So(keys[0].ID, ShouldEqual, id(1))
+				So(keys[1].ID, ShouldEqual, id(2))
+				So(keys[1].Roles, ShouldResemble, []auth.Role{auth.ADMIN, auth.AUDITOR})
+
+				keys, err = st.RetrieveAPIKeysBySubject(ctx, "user-2")
+				So(err, ShouldBeNil)
+				So(keys, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when a key is revoked", func() {
+			revoked := created.Add(time.Minute)
+			So(st.RevokeAPIKey(ctx, id(1), "user-1", revoked), ShouldBeNil)
+
+			Convey("keep the first revocation", func() {
+				So(st.RevokeAPIKey(ctx, id(1), "user-1", revoked.Add(time.Hour)), ShouldBeNil)
+
+				k, err := st.RetrieveAPIKeyByHash(ctx, hash("1"))
+				So(err, ShouldBeNil)
+				So(k.RevokedAt, ShouldNotBeNil)
+				So(k.RevokedAt.Equal(revoked), ShouldBeTrue)
+			})
+
+			Convey("but not by another subject", func() {
+				err := st.RevokeAPIKey(ctx, id(2), "user-2", revoked)
+				So(errors.Is(err, auth.ErrNotFound), ShouldBeTrue)
+
+				k, err := st.RetrieveAPIKeyByHash(ctx, hash("2"))
+				So(err, ShouldBeNil)
+				So(k.RevokedAt, ShouldBeNil)
+			})
+		})
+	})
+}
+
+// id builds the ids like uuids, as postgres stores them in fixed width
+func id(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+// hash pads n to the width of a sha256 hex digest, as postgres stores them
+// in fixed width
+func hash(n string) string {
+	return fmt.Sprintf("%064s", n)
+}
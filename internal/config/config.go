@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+
+	"github.com/nerock/invoicebidder/internal/fee"
 )
 
 type Config struct {
@@ -15,10 +17,60 @@ type Config struct {
 		Buffer     int `json:"event_buffer"`
 		MaxRetries int `json:"max_retries"`
 	} `json:"broker"`
-	InvoiceDB  string `json:"invoice_db"`
-	IssuerDB   string `json:"issuer_db"`
-	InvestorDB string `json:"investor_db"`
-	BasePath   string `json:"base_path"`
+	Bulk struct {
+		Workers int `json:"workers"`
+		Buffer  int `json:"buffer"`
+	} `json:"bulk"`
+	Sweeper struct {
+		Interval int `json:"interval_seconds"`
+		Grace    int `json:"grace_seconds"`
+	} `json:"sweeper"`
+	Documents struct {
+		URLSecret string `json:"url_secret"`
+		URLTTL    int    `json:"url_ttl_seconds"`
+	} `json:"documents"`
+	Auth struct {
+		HS256Secret string `json:"hs256_secret"`
+		JWKSFile    string `json:"jwks_file"`
+		Issuer      string `json:"issuer"`
+		Audience    string `json:"audience"`
+	} `json:"auth"`
+	Idempotency struct {
+		TTL             int `json:"ttl_seconds"`
+		CleanupInterval int `json:"cleanup_interval_seconds"`
+	} `json:"idempotency"`
+	Storage struct {
+		Backend   string `json:"backend"`
+		SQLiteDir string `json:"sqlite_dir"`
+	} `json:"storage"`
+	InvoiceDB     string `json:"invoice_db"`
+	IssuerDB      string `json:"issuer_db"`
+	InvestorDB    string `json:"investor_db"`
+	PlatformDB    string `json:"platform_db"`
+	AuthDB        string `json:"auth_db"`
+	IdempotencyDB string `json:"idempotency_db"`
+	BasePath      string `json:"base_path"`
+	Files         struct {
+		Backend string `json:"backend"`
+		S3      struct {
+			Endpoint  string `json:"endpoint"`
+			Region    string `json:"region"`
+			Bucket    string `json:"bucket"`
+			AccessKey string `json:"access_key"`
+			SecretKey string `json:"secret_key"`
+			UseSSL    bool   `json:"use_ssl"`
+		} `json:"s3"`
+	} `json:"files"`
+	Uploads struct {
+		MaxSize     int64 `json:"max_size_bytes"`
+		AllowImages bool  `json:"allow_images"`
+		ClamAV      struct {
+			Network string `json:"network"`
+			Address string `json:"address"`
+			Timeout int    `json:"timeout_seconds"`
+		} `json:"clamav"`
+	} `json:"uploads"`
+	Fees []fee.Schedule `json:"fees"`
 }
 
 func Read() (Config, error) {
@@ -34,5 +86,32 @@ func Read() (Config, error) {
 		return Config{}, fmt.Errorf("could not unmarshal json: %s", err)
 	}
 
+	if err := requireSecret("documents.url_secret", cfg.Documents.URLSecret); err != nil {
+		return Config{}, err
+	}
+	// The shared secret may only be left empty when tokens are verified with
+	// the JWKS keys
+	if cfg.Auth.JWKSFile == "" || cfg.Auth.HS256Secret != "" {
+		if err := requireSecret("auth.hs256_secret", cfg.Auth.HS256Secret); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range cfg.Fees {
+		if err := s.Validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid fee schedule: %s", err)
+		}
+	}
+
 	return cfg, nil
 }
+
+// requireSecret refuses to start with an empty secret or the placeholder the
+// config used to ship with
+func requireSecret(name, secret string) error {
+	if secret == "" || secret == "change-me" {
+		return fmt.Errorf("%s must be set to a random secret", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package fee
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+type Kind string
+
+const (
+	PERCENTAGE Kind = "percentage"
+	FIXED      Kind = "fixed"
+)
+
+type Payer string
+
+const (
+	ISSUER   Payer = "issuer"
+	INVESTOR Payer = "investor"
+	BOTH     Payer = "both"
+)
+
+// Schedule is a fee charged on every trade. The tier applied is the one with
+// the highest minimum volume not above the traded amount.
+type Schedule struct {
+	Kind  Kind   `json:"kind"`
+	Payer Payer  `json:"payer"`
+	Tiers []Tier `json:"tiers"`
+}
+
+// Tier values are decimals in the trade currency. Rate is a percentage and
+// Fixed an amount, depending on the schedule kind.
+type Tier struct {
+	MinVolume string `json:"min_volume"`
+	Rate      string `json:"rate,omitempty"`
+	Fixed     string `json:"fixed,omitempty"`
+}
+
+func (s Schedule) Validate() error {
+	if s.Kind != PERCENTAGE && s.Kind != FIXED {
+		return fmt.Errorf("invalid fee kind %q", s.Kind)
+	}
+
+	if s.Payer != ISSUER && s.Payer != INVESTOR && s.Payer != BOTH {
+		return fmt.Errorf("invalid fee payer %q", s.Payer)
+	}
+
+	if len(s.Tiers) == 0 {
+		return errors.New("fee schedule needs at least one tier")
+	}
+
+	for _, t := range s.Tiers {
+		value := t.Rate
+		if s.Kind == FIXED {
+			value = t.Fixed
+		}
+
+		for _, n := range []string{t.MinVolume, value} {
+			if a, err := currency.NewAmount(n, "EUR"); err != nil || a.IsNegative() {
+				return fmt.Errorf("invalid fee tier value %q", n)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s Schedule) tier(amount currency.Amount) (Tier, bool) {
+	var (
+		tier  Tier
+		found bool
+		best  currency.Amount
+	)
+	for _, t := range s.Tiers {
+		min, _ := currency.NewAmount(t.MinVolume, amount.CurrencyCode())
+		if c, _ := min.Cmp(amount); c > 0 {
+			continue
+		}
+
+		if found {
+			if c, _ := min.Cmp(best); c <= 0 {
+				continue
+			}
+		}
+		tier, found, best = t, true, min
+	}
+
+	return tier, found
+}
+
+func (s Schedule) charges(payer Payer) bool {
+	return s.Payer == payer || s.Payer == BOTH
+}
+
+// fee returns the fee of the tier for the given base amount, rounded to the
+// minor units of its currency.
+func (s Schedule) fee(t Tier, base currency.Amount) currency.Amount {
+	if s.Kind == FIXED {
+		fixed, _ := currency.NewAmount(t.Fixed, base.CurrencyCode())
+		return fixed.Round()
+	}
+
+	fee, _ := base.Mul(t.Rate)
+	fee, _ = fee.Div("100")
+	return fee.Round()
+}
+
+type Bid struct {
+	InvestorID string
+	Amount     currency.Amount
+}
+
+// Item is a single fee charged on a trade.
+type Item struct {
+	Payer   Payer
+	PayerID string
+	Kind    Kind
+	Rate    string
+	Base    currency.Amount
+	Amount  currency.Amount
+}
+
+// Settlement itemises the fees of a trade and what the issuer receives.
+type Settlement struct {
+	InvoiceID string
+	IssuerID  string
+	Amount    currency.Amount
+	IssuerNet currency.Amount
+	Items     []Item
+	CreatedAt time.Time
+}
+
+// Total returns the fees charged to the given payer, or to everyone if the
+// payer is BOTH.
+func (s Settlement) Total(payer Payer) currency.Amount {
+	total, _ := currency.NewAmount("0", s.Amount.CurrencyCode())
+	for _, item := range s.Items {
+		if payer == BOTH || item.Payer == payer {
+			total, _ = total.Add(item.Amount)
+		}
+	}
+
+	return total
+}
+
+// Calculate applies the schedules to a trade. Issuer fees are deducted from
+// the traded amount while investors pay theirs on top of their bids.
+func Calculate(schedules []Schedule, invoiceID, issuerID string, amount currency.Amount, bids []Bid) (Settlement, error) {
+	invested := map[string]currency.Amount{}
+	var investors []string
+	for _, b := range bids {
+		current, ok := invested[b.InvestorID]
+		if !ok {
+			investors = append(investors, b.InvestorID)
+			current, _ = currency.NewAmount("0", amount.CurrencyCode())
+		}
+
+		total, err := current.Add(b.Amount)
+		if err != nil {
+			return Settlement{}, fmt.Errorf("could not perform currency operation: %w", err)
+		}
+		invested[b.InvestorID] = total
+	}
+
+	settlement := Settlement{
+		InvoiceID: invoiceID,
+		IssuerID:  issuerID,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+	for _, s := range schedules {
+		t, ok := s.tier(amount)
+		if !ok {
+			continue
+		}
+
+		if s.charges(ISSUER) {
+			settlement.Items = append(settlement.Items, s.item(t, ISSUER, issuerID, amount))
+		}
+
+		if s.charges(INVESTOR) {
+			for _, id := range investors {
+				settlement.Items = append(settlement.Items, s.item(t, INVESTOR, id, invested[id]))
+			}
+		}
+	}
+
+	net, err := amount.Sub(settlement.Total(ISSUER))
+	if err != nil {
+		return Settlement{}, fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	if net.IsNegative() {
+		return Settlement{}, errors.New("issuer fees exceed the traded amount")
+	}
+	settlement.IssuerNet = net
+
+	return settlement, nil
+}
+
+func (s Schedule) item(t Tier, payer Payer, payerID string, base currency.Amount) Item {
+	item := Item{
+		Payer:   payer,
+		PayerID: payerID,
+		Kind:    s.Kind,
+		Base:    base,
+		Amount:  s.fee(t, base),
+	}
+	if s.Kind == PERCENTAGE {
+		item.Rate = t.Rate
+	}
+
+	return item
+}
@@ -0,0 +1,95 @@
+package fee
+
+import (
+	"testing"
+
+	"github.com/bojanz/currency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCalculate(t *testing.T) {
+	Convey("Calculate", t, func() {
+		amount, _ := currency.NewAmount("1234.57", "EUR")
+		bid1, _ := currency.NewAmount("1000", "EUR")
+		bid2, _ := currency.NewAmount("134.57", "EUR")
+		bid3, _ := currency.NewAmount("100", "EUR")
+		bids := []Bid{{"inv1", bid1}, {"inv2", bid2}, {"inv1", bid3}}
+
+		Convey("when there are no schedules", func() {
+			st, err := Calculate(nil, "invoice", "issuer", amount, bids)
+
+			Convey("the issuer receives the whole amount", func() {
+				So(err, ShouldBeNil)
+				So(st.Items, ShouldBeEmpty)
+				So(st.IssuerNet.String(), ShouldEqual, "1234.57 EUR")
+			})
+		})
+
+		Convey("when a percentage schedule charges the issuer", func() {
+			schedules := []Schedule{{
+				Kind:  PERCENTAGE,
+				Payer: ISSUER,
+				Tiers: []Tier{{MinVolume: "0", Rate: "1.5"}, {MinVolume: "1000", Rate: "1"}, {MinVolume: "10000", Rate: "0.5"}},
+			}}
+			st, err := Calculate(schedules, "invoice", "issuer", amount, bids)
+
+			Convey("apply the tier of the traded amount rounded to minor units", func() {
+				So(err, ShouldBeNil)
+				So(st.Items, ShouldHaveLength, 1)
+				So(st.Items[0].Payer, ShouldEqual, ISSUER)
+				So(st.Items[0].Rate, ShouldEqual, "1")
+				So(st.Items[0].Amount.String(), ShouldEqual, "12.35 EUR")
+				So(st.IssuerNet.String(), ShouldEqual, "1222.22 EUR")
+			})
+		})
+
+		Convey("when a fixed schedule charges both sides", func() {
+			schedules := []Schedule{{
+				Kind:  FIXED,
+				Payer: BOTH,
+				Tiers: []Tier{{MinVolume: "0", Fixed: "5"}, {MinVolume: "5000", Fixed: "10"}},
+			}}
+			st, err := Calculate(schedules, "invoice", "issuer", amount, bids)
+
+			Convey("charge the issuer and every investor once", func() {
+				So(err, ShouldBeNil)
+				So(st.Items, ShouldHaveLength, 3)
+				So(st.Items[1].PayerID, ShouldEqual, "inv1")
+				So(st.Items[1].Base.String(), ShouldEqual, "1100 EUR")
+				So(st.Items[2].PayerID, ShouldEqual, "inv2")
+				So(st.Total(INVESTOR).String(), ShouldEqual, "10.00 EUR")
+				So(st.Total(BOTH).String(), ShouldEqual, "15.00 EUR")
+				So(st.IssuerNet.String(), ShouldEqual, "1229.57 EUR")
+			})
+		})
+
+		Convey("when no tier applies to the traded amount", func() {
+			schedules := []Schedule{{Kind: FIXED, Payer: ISSUER, Tiers: []Tier{{MinVolume: "5000", Fixed: "10"}}}}
+			st, err := Calculate(schedules, "invoice", "issuer", amount, bids)
+
+			Convey("charge nothing", func() {
+				So(err, ShouldBeNil)
+				So(st.Items, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the issuer fees exceed the traded amount", func() {
+			schedules := []Schedule{{Kind: FIXED, Payer: ISSUER, Tiers: []Tier{{MinVolume: "0", Fixed: "2000"}}}}
+			_, err := Calculate(schedules, "invoice", "issuer", amount, bids)
+
+			Convey("return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	Convey("Validate", t, func() {
+		So(Schedule{Kind: PERCENTAGE, Payer: BOTH, Tiers: []Tier{{MinVolume: "0", Rate: "1"}}}.Validate(), ShouldBeNil)
+		So(Schedule{Kind: "other", Payer: BOTH, Tiers: []Tier{{MinVolume: "0", Rate: "1"}}}.Validate(), ShouldNotBeNil)
+		So(Schedule{Kind: FIXED, Payer: "platform", Tiers: []Tier{{MinVolume: "0", Fixed: "1"}}}.Validate(), ShouldNotBeNil)
+		So(Schedule{Kind: FIXED, Payer: ISSUER}.Validate(), ShouldNotBeNil)
+		So(Schedule{Kind: FIXED, Payer: ISSUER, Tiers: []Tier{{MinVolume: "0", Rate: "1"}}}.Validate(), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,83 @@
+package fee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bojanz/currency"
+)
+
+// ErrNotFound is returned by storages when a settlement does not exist
+var ErrNotFound = errors.New("not found")
+
+type Storage interface {
+	SaveSettlement(context.Context, Settlement) error
+	RetrieveSettlement(context.Context, string) (Settlement, error)
+	RetrieveRevenue(context.Context) ([]currency.Amount, error)
+	// DebitRevenue takes an amount back out of the revenue of its currency
+	DebitRevenue(context.Context, currency.Amount) error
+	RetrieveSettlementsByIssuerID(context.Context, string) ([]Settlement, error)
+}
+
+func NewService(st Storage, schedules []Schedule) *Service {
+	return &Service{
+		st:        st,
+		schedules: schedules,
+	}
+}
+
+type Service struct {
+	st        Storage
+	schedules []Schedule
+}
+
+// Settle charges the fees of a trade and credits them to the platform
+// revenue. Settling the same invoice again returns the stored settlement, so
+// retried trades are never charged twice.
+func (s *Service) Settle(ctx context.Context, invoiceID, issuerID string, amount currency.Amount, bids []Bid) (Settlement, error) {
+	settlement, err := Calculate(s.schedules, invoiceID, issuerID, amount, bids)
+	if err != nil {
+		return Settlement{}, err
+	}
+
+	if err := s.st.SaveSettlement(ctx, settlement); err != nil {
+		return Settlement{}, err
+	}
+
+	return s.st.RetrieveSettlement(ctx, invoiceID)
+}
+
+func (s *Service) GetSettlement(ctx context.Context, invoiceID string) (Settlement, error) {
+	return s.st.RetrieveSettlement(ctx, invoiceID)
+}
+
+// GetIssuerFees returns the fees deducted from each trade of an issuer, keyed
+// by invoice id
+func (s *Service) GetIssuerFees(ctx context.Context, issuerID string) (map[string]currency.Amount, error) {
+	settlements, err := s.st.RetrieveSettlementsByIssuerID(ctx, issuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	fees := make(map[string]currency.Amount, len(settlements))
+	for _, st := range settlements {
+		fee, err := st.Amount.Sub(st.IssuerNet)
+		if err != nil {
+			return nil, fmt.Errorf("could not perform currency operation: %w", err)
+		}
+		fees[st.InvoiceID] = fee
+	}
+
+	return fees, nil
+}
+
+// WaiveFee takes an investor fee that could never be charged back out of the
+// revenue it was credited to when the trade was settled.
+func (s *Service) WaiveFee(ctx context.Context, amount currency.Amount) error {
+	return s.st.DebitRevenue(ctx, amount)
+}
+
+func (s *Service) GetRevenue(ctx context.Context) ([]currency.Amount, error) {
+	return s.st.RetrieveRevenue(ctx)
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/fee"
+)
+
+// MemoryStorage keeps settlements and the revenue of each currency in
+// memory, settling every invoice once.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	settlements map[string]fee.Settlement
+	revenue     map[string]currency.Amount
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		settlements: map[string]fee.Settlement{},
+		revenue:     map[string]currency.Amount{},
+	}
+}
+
+func (s *MemoryStorage) SaveSettlement(_ context.Context, st fee.Settlement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.settlements[st.InvoiceID]; ok {
+		return nil
+	}
+
+	total := st.Total(fee.BOTH)
+	if !total.IsZero() {
+		revenue, ok := s.revenue[total.CurrencyCode()]
+		if ok {
+			var err error
+			if total, err = revenue.Add(total); err != nil {
+				return fmt.Errorf("could not credit revenue: %w", err)
+			}
+		}
+		s.revenue[total.CurrencyCode()] = total
+	}
+
+	st.Items = append([]fee.Item(nil), st.Items...)
+	s.settlements[st.InvoiceID] = st
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveSettlement(_ context.Context, invoiceID string) (fee.Settlement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok := s.settlements[invoiceID]
+	if !ok {
+		return fee.Settlement{InvoiceID: invoiceID}, fmt.Errorf("could not retrieve settlement: %w", fee.ErrNotFound)
+	}
+	st.Items = append([]fee.Item(nil), st.Items...)
+
+	return st, nil
+}
+
+func (s *MemoryStorage) RetrieveSettlementsByIssuerID(_ context.Context, issuerID string) ([]fee.Settlement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var settlements []fee.Settlement
+	for _, st := range s.settlements {
+		if st.IssuerID == issuerID {
+			st.Items = nil
+			settlements = append(settlements, st)
+		}
+	}
+	sort.Slice(settlements, func(i, j int) bool { return settlements[i].InvoiceID < settlements[j].InvoiceID })
+
+	return settlements, nil
+}
+
+func (s *MemoryStorage) DebitRevenue(_ context.Context, amount currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revenue, ok := s.revenue[amount.CurrencyCode()]
+	if !ok {
+		return fmt.Errorf("could not debit revenue: %w", fee.ErrNotFound)
+	}
+
+	revenue, err := revenue.Sub(amount)
+	if err != nil {
+		return fmt.Errorf("could not debit revenue: %w", err)
+	}
+	s.revenue[amount.CurrencyCode()] = revenue
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveRevenue(_ context.Context) ([]currency.Amount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revenue := make([]currency.Amount, 0, len(s.revenue))
+	for _, balance := range s.revenue {
+		revenue = append(revenue, balance)
+	}
+	sort.Slice(revenue, func(i, j int) bool { return revenue[i].CurrencyCode() < revenue[j].CurrencyCode() })
+
+	return revenue, nil
+}
@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/fee"
+	"github.com/nerock/invoicebidder/internal/fee/storage/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) fee.Storage {
+		return NewMemoryStorage()
+	})
+}
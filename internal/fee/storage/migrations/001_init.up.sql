@@ -0,0 +1,30 @@
+CREATE TYPE amount AS (
+   number NUMERIC,
+   currency_code TEXT
+);
+
+CREATE TABLE settlements (
+    invoice_id CHAR(36) PRIMARY KEY,
+    issuer_id CHAR(36) NOT NULL,
+    amount amount NOT NULL,
+    issuer_net amount NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE settlement_items (
+    id SERIAL PRIMARY KEY,
+    invoice_id CHAR(36) NOT NULL REFERENCES settlements (invoice_id),
+    payer TEXT NOT NULL,
+    payer_id CHAR(36) NOT NULL,
+    kind TEXT NOT NULL,
+    rate TEXT NOT NULL DEFAULT '',
+    base amount NOT NULL,
+    amount amount NOT NULL
+);
+
+CREATE INDEX settlement_items_invoice_id_idx ON settlement_items (invoice_id);
+
+CREATE TABLE revenue (
+    currency_code TEXT PRIMARY KEY,
+    balance amount NOT NULL
+);
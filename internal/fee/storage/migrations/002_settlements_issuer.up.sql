@@ -0,0 +1 @@
+CREATE INDEX settlements_issuer_id_idx ON settlements (issuer_id);
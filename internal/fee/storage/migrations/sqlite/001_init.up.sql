@@ -0,0 +1,31 @@
+CREATE TABLE settlements (
+    invoice_id TEXT PRIMARY KEY,
+    issuer_id TEXT NOT NULL,
+    amount_number TEXT NOT NULL,
+    amount_currency TEXT NOT NULL,
+    issuer_net_number TEXT NOT NULL,
+    issuer_net_currency TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX settlements_issuer_id_idx ON settlements (issuer_id);
+
+CREATE TABLE settlement_items (
+    id INTEGER PRIMARY KEY,
+    invoice_id TEXT NOT NULL REFERENCES settlements (invoice_id),
+    payer TEXT NOT NULL,
+    payer_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    rate TEXT NOT NULL DEFAULT '',
+    base_number TEXT NOT NULL,
+    base_currency TEXT NOT NULL,
+    amount_number TEXT NOT NULL,
+    amount_currency TEXT NOT NULL
+);
+
+CREATE INDEX settlement_items_invoice_id_idx ON settlement_items (invoice_id);
+
+CREATE TABLE revenue (
+    currency_code TEXT PRIMARY KEY,
+    balance_number TEXT NOT NULL
+);
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bojanz/currency"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nerock/invoicebidder/internal/fee"
+)
+
+type Storage struct {
+	c *pgxpool.Pool
+}
+
+func New(c *pgxpool.Pool) *Storage {
+	return &Storage{c}
+}
+
+// SaveSettlement stores the settlement and credits its fees to the revenue
+// account, doing nothing if the invoice was already settled.
+func (s *Storage) SaveSettlement(ctx context.Context, st fee.Settlement) error {
+	const (
+		settlementQuery = `INSERT INTO settlements (invoice_id, issuer_id, amount, issuer_net, created_at)
+			VALUES ($1, $2, $3, $4, $5) ON CONFLICT (invoice_id) DO NOTHING`
+		itemQuery = `INSERT INTO settlement_items (invoice_id, payer, payer_id, kind, rate, base, amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		revenueQuery = `INSERT INTO revenue (currency_code, balance) VALUES ($1, $2)
+			ON CONFLICT (currency_code) DO UPDATE
+			SET balance = ROW((revenue.balance).number + (EXCLUDED.balance).number, $1)::amount`
+	)
+
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, settlementQuery, st.InvoiceID, st.IssuerID, st.Amount, st.IssuerNet, st.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("could not save settlement in db: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, item := range st.Items {
+		batch.Queue(itemQuery, st.InvoiceID, item.Payer, item.PayerID, item.Kind, item.Rate, item.Base, item.Amount)
+	}
+
+	if total := st.Total(fee.BOTH); !total.IsZero() {
+		batch.Queue(revenueQuery, total.CurrencyCode(), total)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("could not save settlement items in db: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrieveSettlement(ctx context.Context, invoiceID string) (fee.Settlement, error) {
+	const (
+		settlementQuery = `SELECT s.issuer_id, s.amount, s.issuer_net, s.created_at FROM settlements s WHERE s.invoice_id = $1`
+		itemsQuery      = `SELECT i.payer, i.payer_id, i.kind, i.rate, i.base, i.amount
+			FROM settlement_items i WHERE i.invoice_id = $1 ORDER BY i.id`
+	)
+
+	st := fee.Settlement{InvoiceID: invoiceID}
+	err := s.c.QueryRow(ctx, settlementQuery, invoiceID).Scan(&st.IssuerID, &st.Amount, &st.IssuerNet, &st.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return st, fmt.Errorf("could not retrieve settlement: %w", fee.ErrNotFound)
+	}
+	if err != nil {
+		return st, fmt.Errorf("could not retrieve settlement: %w", err)
+	}
+
+	rows, err := s.c.Query(ctx, itemsQuery, invoiceID)
+	if err != nil {
+		return st, fmt.Errorf("could not retrieve settlement items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item fee.Item
+		if err := rows.Scan(&item.Payer, &item.PayerID, &item.Kind, &item.Rate, &item.Base, &item.Amount); err != nil {
+			return st, fmt.Errorf("could not scan settlement items: %w", err)
+		}
+
+		st.Items = append(st.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return st, fmt.Errorf("could not read settlement items: %w", err)
+	}
+
+	return st, nil
+}
+
+// RetrieveSettlementsByIssuerID returns the settlements of an issuer without
+// their items
+func (s *Storage) RetrieveSettlementsByIssuerID(ctx context.Context, issuerID string) ([]fee.Settlement, error) {
+	const query = `SELECT s.invoice_id, s.amount, s.issuer_net, s.created_at FROM settlements s WHERE s.issuer_id = $1`
+
+	rows, err := s.c.Query(ctx, query, issuerID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve settlements: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []fee.Settlement
+	for rows.Next() {
+		st := fee.Settlement{IssuerID: issuerID}
+		if err := rows.Scan(&st.InvoiceID, &st.Amount, &st.IssuerNet, &st.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan settlements: %w", err)
+		}
+
+		settlements = append(settlements, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read settlements: %w", err)
+	}
+
+	return settlements, nil
+}
+
+func (s *Storage) DebitRevenue(ctx context.Context, amount currency.Amount) error {
+	const query = `UPDATE revenue SET balance = ROW((balance).number - $2::numeric, $1)::amount WHERE currency_code = $1`
+
+	tag, err := s.c.Exec(ctx, query, amount.CurrencyCode(), amount.Number())
+	if err != nil {
+		return fmt.Errorf("could not debit revenue in db: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("could not debit revenue: %w", fee.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrieveRevenue(ctx context.Context) ([]currency.Amount, error) {
+	const query = `SELECT r.balance FROM revenue r ORDER BY r.currency_code`
+
+	rows, err := s.c.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve revenue: %w", err)
+	}
+	defer rows.Close()
+
+	var revenue []currency.Amount
+	for rows.Next() {
+		var balance currency.Amount
+		if err := rows.Scan(&balance); err != nil {
+			return nil, fmt.Errorf("could not scan revenue: %w", err)
+		}
+
+		revenue = append(revenue, balance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read revenue: %w", err)
+	}
+
+	return revenue, nil
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/fee"
+	"github.com/nerock/invoicebidder/internal/fee/storage/storagetest"
+	"github.com/nerock/invoicebidder/internal/pgtest"
+)
+
+func TestPostgresStorage(t *testing.T) {
+	pool := pgtest.New(t, "migrations")
+
+	storagetest.Run(t, func(t *testing.T) fee.Storage {
+		pgtest.Truncate(t, pool)
+		return New(pool)
+	})
+}
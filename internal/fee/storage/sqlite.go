@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/fee"
+	"github.com/nerock/invoicebidder/internal/sqlite"
+)
+
+//go:embed migrations/sqlite/*.up.sql
+var sqliteMigrations embed.FS
+
+// SQLiteStorage keeps settlements in an embedded sqlite db. Revenue is added
+// up in go inside the settlement transaction, so balances stay exact
+// decimals.
+type SQLiteStorage struct {
+	c *sql.DB
+}
+
+func NewSQLiteStorage(c *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{c}
+}
+
+func (s *SQLiteStorage) Migrate(ctx context.Context) error {
+	migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	return sqlite.Migrate(ctx, s.c, migrations)
+}
+
+// SaveSettlement stores the settlement and credits its fees to the revenue
+// account, doing nothing if the invoice was already settled.
+func (s *SQLiteStorage) SaveSettlement(ctx context.Context, st fee.Settlement) error {
+	const (
+		settlementQuery = `INSERT INTO settlements (invoice_id, issuer_id, amount_number, amount_currency, issuer_net_number, issuer_net_currency, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (invoice_id) DO NOTHING`
+		itemQuery = `INSERT INTO settlement_items (invoice_id, payer, payer_id, kind, rate, base_number, base_currency, amount_number, amount_currency)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		balanceQuery = `SELECT (r.balance_number || ' ' || r.currency_code) FROM revenue r WHERE r.currency_code = ?`
+		revenueQuery = `INSERT INTO revenue (currency_code, balance_number) VALUES (?1, ?2)
+			ON CONFLICT (currency_code) DO UPDATE SET balance_number = ?2`
+	)
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, settlementQuery, st.InvoiceID, st.IssuerID, st.Amount.Number(), st.Amount.CurrencyCode(),
+		st.IssuerNet.Number(), st.IssuerNet.CurrencyCode(), sqlite.FormatTime(st.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("could not save settlement in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not save settlement in db: %w", err)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	for _, item := range st.Items {
+		if _, err := tx.ExecContext(ctx, itemQuery, st.InvoiceID, item.Payer, item.PayerID, item.Kind, item.Rate,
+			item.Base.Number(), item.Base.CurrencyCode(), item.Amount.Number(), item.Amount.CurrencyCode()); err != nil {
+			return fmt.Errorf("could not save settlement items in db: %w", err)
+		}
+	}
+
+	if total := st.Total(fee.BOTH); !total.IsZero() {
+		var revenue currency.Amount
+		err := tx.QueryRowContext(ctx, balanceQuery, total.CurrencyCode()).Scan(sqlite.Amount{A: &revenue})
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return fmt.Errorf("could not retrieve revenue: %w", err)
+		default:
+			if total, err = revenue.Add(total); err != nil {
+				return fmt.Errorf("could not credit revenue: %w", err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, revenueQuery, total.CurrencyCode(), total.Number()); err != nil {
+			return fmt.Errorf("could not save revenue in db: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveSettlement(ctx context.Context, invoiceID string) (fee.Settlement, error) {
+	const (
+		settlementQuery = `SELECT s.issuer_id, (s.amount_number || ' ' || s.amount_currency), (s.issuer_net_number || ' ' || s.issuer_net_currency), s.created_at
+			FROM settlements s WHERE s.invoice_id = ?`
+		itemsQuery = `SELECT i.payer, i.payer_id, i.kind, i.rate, (i.base_number || ' ' || i.base_currency), (i.amount_number || ' ' || i.amount_currency)
+			FROM settlement_items i WHERE i.invoice_id = ? ORDER BY i.id`
+	)
+
+	st := fee.Settlement{InvoiceID: invoiceID}
+	err := s.c.QueryRowContext(ctx, settlementQuery, invoiceID).Scan(&st.IssuerID, sqlite.Amount{A: &st.Amount}, sqlite.Amount{A: &st.IssuerNet},
+		sqlite.Time{T: &st.CreatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return st, fmt.Errorf("could not retrieve settlement: %w", fee.ErrNotFound)
+	}
+	if err != nil {
+		return st, fmt.Errorf("could not retrieve settlement: %w", err)
+	}
+
+	rows, err := s.c.QueryContext(ctx, itemsQuery, invoiceID)
+	if err != nil {
+		return st, fmt.Errorf("could not retrieve settlement items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item fee.Item
+		if err := rows.Scan(&item.Payer, &item.PayerID, &item.Kind, &item.Rate, sqlite.Amount{A: &item.Base}, sqlite.Amount{A: &item.Amount}); err != nil {
+			return st, fmt.Errorf("could not scan settlement items: %w", err)
+		}
+
+		st.Items = append(st.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return st, fmt.Errorf("could not read settlement items: %w", err)
+	}
+
+	return st, nil
+}
+
+// RetrieveSettlementsByIssuerID returns the settlements of an issuer without
+// their items
+func (s *SQLiteStorage) RetrieveSettlementsByIssuerID(ctx context.Context, issuerID string) ([]fee.Settlement, error) {
+	const query = `SELECT s.invoice_id, (s.amount_number || ' ' || s.amount_currency), (s.issuer_net_number || ' ' || s.issuer_net_currency), s.created_at
+		FROM settlements s WHERE s.issuer_id = ?`
+
+	rows, err := s.c.QueryContext(ctx, query, issuerID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve settlements: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []fee.Settlement
+	for rows.Next() {
+		st := fee.Settlement{IssuerID: issuerID}
+		if err := rows.Scan(&st.InvoiceID, sqlite.Amount{A: &st.Amount}, sqlite.Amount{A: &st.IssuerNet}, sqlite.Time{T: &st.CreatedAt}); err != nil {
+			return nil, fmt.Errorf("could not scan settlements: %w", err)
+		}
+
+		settlements = append(settlements, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read settlements: %w", err)
+	}
+
+	return settlements, nil
+}
+
+func (s *SQLiteStorage) DebitRevenue(ctx context.Context, amount currency.Amount) error {
+	const (
+		balanceQuery = `SELECT (r.balance_number || ' ' || r.currency_code) FROM revenue r WHERE r.currency_code = ?`
+		revenueQuery = `UPDATE revenue SET balance_number = ? WHERE currency_code = ?`
+	)
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var revenue currency.Amount
+	err = tx.QueryRowContext(ctx, balanceQuery, amount.CurrencyCode()).Scan(sqlite.Amount{A: &revenue})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("could not debit revenue: %w", fee.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("could not retrieve revenue: %w", err)
+	}
+
+	if revenue, err = revenue.Sub(amount); err != nil {
+		return fmt.Errorf("could not debit revenue: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, revenueQuery, revenue.Number(), revenue.CurrencyCode()); err != nil {
+		return fmt.Errorf("could not save revenue in db: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveRevenue(ctx context.Context) ([]currency.Amount, error) {
+	const query = `SELECT (r.balance_number || ' ' || r.currency_code) FROM revenue r ORDER BY r.currency_code`
+
+	rows, err := s.c.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve revenue: %w", err)
+	}
+	defer rows.Close()
+
+	var revenue []currency.Amount
+	for rows.Next() {
+		var balance currency.Amount
+		if err := rows.Scan(sqlite.Amount{A: &balance}); err != nil {
+			return nil, fmt.Errorf("could not scan revenue: %w", err)
+		}
+
+		revenue = append(revenue, balance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read revenue: %w", err)
+	}
+
+	return revenue, nil
+}
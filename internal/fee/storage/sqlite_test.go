@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/fee"
+	"github.com/nerock/invoicebidder/internal/fee/storage/storagetest"
+	"github.com/nerock/invoicebidder/internal/sqlite"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) fee.Storage {
+		db, err := sqlite.Open(filepath.Join(t.TempDir(), "fee.db"))
+		So(err, ShouldBeNil)
+		t.Cleanup(func() { db.Close() })
+
+		st := NewSQLiteStorage(db)
+		So(st.Migrate(context.Background()), ShouldBeNil)
+
+		return st
+	})
+}
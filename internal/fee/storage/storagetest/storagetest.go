@@ -0,0 +1,140 @@
+// Package storagetest holds the behaviour every fee.Storage must have, so
+// all the backends are tested alike.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/fee"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Run checks a fee.Storage, newStorage must return an empty storage on every
+// call.
+func Run(t *testing.T, newStorage func(*testing.T) fee.Storage) {
+	Convey("fee.Storage", t, func() {
+		ctx := context.Background()
+		st := newStorage(t)
+
+		created := time.Now().UTC().Truncate(time.Millisecond)
+		settlement := fee.Settlement{
+			InvoiceID: id(1),
+			IssuerID:  id(10),
+			Amount:    amount("1000", "EUR"),
+			IssuerNet: amount("990", "EUR"),
+			Items: []fee.Item{
+				{Payer: fee.ISSUER, PayerID: id(10), Kind: fee.PERCENTAGE, Rate: "1", Base: amount("1000", "EUR"), Amount: amount("10", "EUR")},
+				{Payer: fee.INVESTOR, PayerID: id(20), Kind: fee.FIXED, Base: amount("600", "EUR"), Amount: amount("2.5", "EUR")},
+			},
+			CreatedAt: created,
+		}
+		So(st.SaveSettlement(ctx, settlement), ShouldBeNil)
+
+		Convey("when a settlement is retrieved", func() {
+			stored, err := st.RetrieveSettlement(ctx, id(1))
+			So(err, ShouldBeNil)
+
+			Convey("return it with its items in order", func() {
+				So(stored.IssuerID, ShouldEqual, id(10))
+				So(stored.Amount.Equal(settlement.Amount), ShouldBeTrue)
+				So(stored.IssuerNet.Equal(settlement.IssuerNet), ShouldBeTrue)
+				So(stored.CreatedAt.Equal(created), ShouldBeTrue)
+				So(stored.Items, ShouldHaveLength, 2)
+				for i, item := range stored.Items {
+					So(item.Payer, ShouldEqual, settlement.Items[i].Payer)
+					So(item.PayerID, ShouldEqual, settlement.Items[i].PayerID)
+					So(item.Kind, ShouldEqual, settlement.Items[i].Kind)
+					So(item.Rate, ShouldEqual, settlement.Items[i].Rate)
+					So(item.Base.Equal(settlement.Items[i].Base), ShouldBeTrue)
+					So(item.Amount.Equal(settlement.Items[i].Amount), ShouldBeTrue)
+				}
+			})
+		})
+
+		Convey("when nothing was settled for an invoice", func() {
+			_, err := st.RetrieveSettlement(ctx, id(99))
+
+			Convey("return not found", func() {
+				So(errors.Is(err, fee.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when more trades are settled", func() {
+			So(st.SaveSettlement(ctx, fee.Settlement{InvoiceID: id(2), IssuerID: id(10), Amount: amount("500", "EUR"),
+				IssuerNet: amount("495", "EUR"), CreatedAt: created, Items: []fee.Item{
+					{Payer: fee.ISSUER, PayerID: id(10), Kind: fee.PERCENTAGE, Rate: "1", Base: amount("500", "EUR"), Amount: amount("5", "EUR")},
+				}}), ShouldBeNil)
+			So(st.SaveSettlement(ctx, fee.Settlement{InvoiceID: id(3), IssuerID: id(11), Amount: amount("100", "USD"),
+				IssuerNet: amount("99", "USD"), CreatedAt: created, Items: []fee.Item{
+					{Payer: fee.ISSUER, PayerID: id(11), Kind: fee.FIXED, Base: amount("100", "USD"), Amount: amount("1", "USD")},
+				}}), ShouldBeNil)
+
+			Convey("add their fees to the revenue of each currency", func() {
+				revenue, err := st.RetrieveRevenue(ctx)
+				So(err, ShouldBeNil)
+				So(revenue, ShouldHaveLength, 2)
+				So(revenue[0].Equal(amount("17.5", "EUR")), ShouldBeTrue)
+				So(revenue[1].Equal(amount("1", "USD")), ShouldBeTrue)
+			})
+
+			Convey("return the settlements of an issuer without items", func() {
+				settlements, err := st.RetrieveSettlementsByIssuerID(ctx, id(10))
+				So(err, ShouldBeNil)
+				So(settlements, ShouldHaveLength, 2)
+				for _, s := range settlements {
+					So(s.IssuerID, ShouldEqual, id(10))
+					So(s.Items, ShouldBeEmpty)
+				}
+			})
+		})
+
+		Convey("when a fee is taken back out of the revenue", func() {
+			So(st.DebitRevenue(ctx, amount("2.5", "EUR")), ShouldBeNil)
+
+			Convey("debit the revenue of its currency", func() {
+				revenue, err := st.RetrieveRevenue(ctx)
+				So(err, ShouldBeNil)
+				So(revenue, ShouldHaveLength, 1)
+				So(revenue[0].Equal(amount("10", "EUR")), ShouldBeTrue)
+			})
+
+			Convey("return not found for a currency without revenue", func() {
+				err := st.DebitRevenue(ctx, amount("1", "USD"))
+				So(errors.Is(err, fee.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when an invoice is settled again", func() {
+			settlement.IssuerNet = amount("900", "EUR")
+			So(st.SaveSettlement(ctx, settlement), ShouldBeNil)
+
+			Convey("keep the first settlement and credit its fees once", func() {
+				stored, err := st.RetrieveSettlement(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(stored.IssuerNet.Equal(amount("990", "EUR")), ShouldBeTrue)
+				So(stored.Items, ShouldHaveLength, 2)
+
+				revenue, err := st.RetrieveRevenue(ctx)
+				So(err, ShouldBeNil)
+				So(revenue, ShouldHaveLength, 1)
+				So(revenue[0].Equal(amount("12.5", "EUR")), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+// id builds the ids like uuids, as postgres stores them in fixed width
+func id(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+func amount(n, code string) currency.Amount {
+	a, err := currency.NewAmount(n, code)
+	So(err, ShouldBeNil)
+	return a
+}
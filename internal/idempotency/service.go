@@ -0,0 +1,114 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrConflict is returned when a key is reused for a different request
+	ErrConflict = errors.New("idempotency key was used for a different request")
+	// ErrInProgress is returned while the first request with a key runs
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+)
+
+// Record is a request made with an idempotency key and, once it finished,
+// its response. Keys are scoped to the subject that sent them.
+type Record struct {
+	Subject     string
+	Key         string
+	RequestHash string
+	Response    *Response
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+type Storage interface {
+	// ReserveKey saves the record unless its key is taken and not expired,
+	// in which case the record holding it is returned
+	ReserveKey(context.Context, Record) (Record, bool, error)
+	// SaveResponse stores the response of a key still without one and keeps
+	// it until the given expiration
+	SaveResponse(context.Context, string, string, Response, time.Time) error
+	DeleteKey(context.Context, string, string) error
+	DeleteExpiredKeys(context.Context, time.Time) (int64, error)
+}
+
+// lockLease is how long a key is held by a request without a response, so a
+// request that died while holding it does not block retries for the whole ttl
+const lockLease = time.Minute
+
+type Service struct {
+	st  Storage
+	ttl time.Duration
+}
+
+func NewService(st Storage, ttl time.Duration) *Service {
+	return &Service{
+		st:  st,
+		ttl: ttl,
+	}
+}
+
+// Begin reserves the key for the request for a short lease. The response of
+// a finished request with the same key and hash is returned to be replayed.
+func (s *Service) Begin(ctx context.Context, subject, key, requestHash string) (*Response, error) {
+	lease := lockLease
+	if s.ttl < lease {
+		lease = s.ttl
+	}
+
+	now := time.Now()
+	rec, reserved, err := s.st.ReserveKey(ctx, Record{
+		Subject:     subject,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(lease),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reserved {
+		return nil, nil
+	}
+
+	if rec.RequestHash != requestHash {
+		return nil, ErrConflict
+	}
+	if rec.Response == nil {
+		return nil, ErrInProgress
+	}
+
+	return rec.Response, nil
+}
+
+// Complete stores the response of the request, to be replayed until the key
+// expires
+func (s *Service) Complete(ctx context.Context, subject, key string, res Response) error {
+	return s.st.SaveResponse(ctx, subject, key, res, time.Now().Add(s.ttl))
+}
+
+// Release frees the key of a request that failed without a response worth
+// replaying, so it can be retried
+func (s *Service) Release(ctx context.Context, subject, key string) error {
+	return s.st.DeleteKey(ctx, subject, key)
+}
+
+func (s *Service) DeleteExpired(ctx context.Context) (int64, error) {
+	n, err := s.st.DeleteExpiredKeys(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("could not delete expired idempotency keys: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,156 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockStorage struct {
+	records map[string]Record
+}
+
+func (m *mockStorage) ReserveKey(_ context.Context, rec Record) (Record, bool, error) {
+	held, ok := m.records[rec.Subject+"/"+rec.Key]
+	if ok && held.ExpiresAt.After(rec.CreatedAt) {
+		return held, false, nil
+	}
+
+	m.records[rec.Subject+"/"+rec.Key] = rec
+	return rec, true, nil
+}
+
+func (m *mockStorage) SaveResponse(_ context.Context, subject, key string, res Response, expiresAt time.Time) error {
+	rec, ok := m.records[subject+"/"+key]
+	if !ok || rec.Response != nil {
+		return nil
+	}
+
+	rec.Response = &res
+	rec.ExpiresAt = expiresAt
+	m.records[subject+"/"+key] = rec
+	return nil
+}
+
+func (m *mockStorage) DeleteKey(_ context.Context, subject, key string) error {
+	if rec, ok := m.records[subject+"/"+key]; ok && rec.Response == nil {
+		delete(m.records, subject+"/"+key)
+	}
+	return nil
+}
+
+func (m *mockStorage) DeleteExpiredKeys(_ context.Context, now time.Time) (int64, error) {
+	var n int64
+	for k, rec := range m.records {
+		if !rec.ExpiresAt.After(now) {
+			delete(m.records, k)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func TestService(t *testing.T) {
+	Convey("idempotency", t, func() {
+		st := &mockStorage{records: map[string]Record{}}
+		svc := NewService(st, time.Hour)
+		ctx := context.Background()
+		created := Response{Status: http.StatusCreated, ContentType: "application/json", Body: []byte(`{"id":"bid-1"}`)}
+
+		Convey("when a key is new", func() {
+			res, err := svc.Begin(ctx, "user-1", "key-1", "hash-1")
+
+			Convey("reserve it for the request", func() {
+				So(err, ShouldBeNil)
+				So(res, ShouldBeNil)
+				So(st.records, ShouldContainKey, "user-1/key-1")
+			})
+		})
+
+		Convey("when the request with the key finished", func() {
+			_, _ = svc.Begin(ctx, "user-1", "key-1", "hash-1")
+			So(svc.Complete(ctx, "user-1", "key-1", created), ShouldBeNil)
+
+			Convey("keep it for the whole ttl", func() {
+				So(st.records["user-1/key-1"].ExpiresAt, ShouldHappenAfter, time.Now().Add(time.Hour-time.Minute))
+			})
+
+			Convey("not release it", func() {
+				So(svc.Release(ctx, "user-1", "key-1"), ShouldBeNil)
+				So(st.records, ShouldContainKey, "user-1/key-1")
+			})
+
+			Convey("return its response for the same request", func() {
+				res, err := svc.Begin(ctx, "user-1", "key-1", "hash-1")
+				So(err, ShouldBeNil)
+				So(*res, ShouldResemble, created)
+			})
+
+			Convey("reject a different request", func() {
+				_, err := svc.Begin(ctx, "user-1", "key-1", "hash-2")
+				So(errors.Is(err, ErrConflict), ShouldBeTrue)
+			})
+
+			Convey("keep the keys of other subjects apart", func() {
+				res, err := svc.Begin(ctx, "user-2", "key-1", "hash-2")
+				So(err, ShouldBeNil)
+				So(res, ShouldBeNil)
+			})
+		})
+
+		Convey("when the request with the key is running", func() {
+			_, _ = svc.Begin(ctx, "user-1", "key-1", "hash-1")
+			_, err := svc.Begin(ctx, "user-1", "key-1", "hash-1")
+
+			Convey("return in progress", func() {
+				So(errors.Is(err, ErrInProgress), ShouldBeTrue)
+			})
+
+			Convey("hold it for a short lease only", func() {
+				So(st.records["user-1/key-1"].ExpiresAt, ShouldHappenBefore, time.Now().Add(lockLease+time.Second))
+			})
+
+			Convey("let it be retried once the lease is over", func() {
+				rec := st.records["user-1/key-1"]
+				rec.ExpiresAt = time.Now().Add(-time.Second)
+				st.records["user-1/key-1"] = rec
+
+				res, err := svc.Begin(ctx, "user-1", "key-1", "hash-1")
+				So(err, ShouldBeNil)
+				So(res, ShouldBeNil)
+			})
+
+			Convey("let it be retried once released", func() {
+				So(svc.Release(ctx, "user-1", "key-1"), ShouldBeNil)
+
+				res, err := svc.Begin(ctx, "user-1", "key-1", "hash-1")
+				So(err, ShouldBeNil)
+				So(res, ShouldBeNil)
+			})
+		})
+
+		Convey("when keys expired", func() {
+			svc := NewService(st, -time.Minute)
+			_, _ = svc.Begin(ctx, "user-1", "key-1", "hash-1")
+			So(svc.Complete(ctx, "user-1", "key-1", created), ShouldBeNil)
+
+			Convey("reserve them again", func() {
+				res, err := svc.Begin(ctx, "user-1", "key-1", "hash-2")
+				So(err, ShouldBeNil)
+				So(res, ShouldBeNil)
+			})
+
+			Convey("delete them", func() {
+				n, err := svc.DeleteExpired(ctx)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 1)
+				So(st.records, ShouldBeEmpty)
+			})
+		})
+	})
+}
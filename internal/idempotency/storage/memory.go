@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/idempotency"
+)
+
+type recordKey struct {
+	subject string
+	key     string
+}
+
+// MemoryStorage keeps idempotency keys in memory. Expired keys are taken
+// over on reservation and otherwise stay until they are cleaned.
+type MemoryStorage struct {
+	mu      sync.Mutex
+	records map[recordKey]idempotency.Record
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{records: map[recordKey]idempotency.Record{}}
+}
+
+func (s *MemoryStorage) ReserveKey(_ context.Context, rec idempotency.Record) (idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := recordKey{rec.Subject, rec.Key}
+	if held, ok := s.records[k]; ok && held.ExpiresAt.After(rec.CreatedAt) {
+		return copyRecord(held), false, nil
+	}
+
+	rec.Response = nil
+	s.records[k] = rec
+
+	return rec, true, nil
+}
+
+func (s *MemoryStorage) SaveResponse(_ context.Context, subject, key string, res idempotency.Response, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := recordKey{subject, key}
+	rec, ok := s.records[k]
+	if !ok || rec.Response != nil {
+		return nil
+	}
+
+	res.Body = append([]byte(nil), res.Body...)
+	rec.Response = &res
+	rec.ExpiresAt = expiresAt
+	s.records[k] = rec
+
+	return nil
+}
+
+func (s *MemoryStorage) DeleteKey(_ context.Context, subject, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := recordKey{subject, key}
+	if rec, ok := s.records[k]; ok && rec.Response == nil {
+		delete(s.records, k)
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) DeleteExpiredKeys(_ context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for k, rec := range s.records {
+		if !rec.ExpiresAt.After(now) {
+			delete(s.records, k)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func copyRecord(rec idempotency.Record) idempotency.Record {
+	if rec.Response != nil {
+		res := *rec.Response
+		res.Body = append([]byte(nil), res.Body...)
+		rec.Response = &res
+	}
+
+	return rec
+}
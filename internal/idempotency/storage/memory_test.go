@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/idempotency"
+	"github.com/nerock/invoicebidder/internal/idempotency/storage/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) idempotency.Storage {
+		return NewMemoryStorage()
+	})
+}
@@ -0,0 +1,13 @@
+CREATE TABLE idempotency_keys (
+    subject TEXT NOT NULL,
+    key TEXT NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status INTEGER,
+    content_type TEXT,
+    body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (subject, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nerock/invoicebidder/internal/idempotency"
+)
+
+type Storage struct {
+	c *pgxpool.Pool
+}
+
+func New(c *pgxpool.Pool) *Storage {
+	return &Storage{c}
+}
+
+// ReserveKey inserts the record, taking over the key if it expired. When the
+// key is held, the existing record is returned.
+func (s *Storage) ReserveKey(ctx context.Context, rec idempotency.Record) (idempotency.Record, bool, error) {
+	const reserve = `INSERT INTO idempotency_keys (subject, key, request_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (subject, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status = NULL,
+			content_type = NULL,
+			body = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at`
+
+	tag, err := s.c.Exec(ctx, reserve, rec.Subject, rec.Key, rec.RequestHash, rec.CreatedAt, rec.ExpiresAt)
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("could not reserve idempotency key in db: %w", err)
+	}
+
+	if tag.RowsAffected() == 1 {
+		return rec, true, nil
+	}
+
+	const query = `SELECT k.subject, k.key, k.request_hash, k.status, COALESCE(k.content_type, ''), k.body, k.created_at, k.expires_at
+		FROM idempotency_keys k WHERE k.subject = $1 AND k.key = $2`
+
+	var (
+		held   idempotency.Record
+		status *int
+		res    idempotency.Response
+	)
+	err = s.c.QueryRow(ctx, query, rec.Subject, rec.Key).
+		Scan(&held.Subject, &held.Key, &held.RequestHash, &status, &res.ContentType, &res.Body, &held.CreatedAt, &held.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// released between both queries, so it is free to be reserved again
+		return s.ReserveKey(ctx, rec)
+	}
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("could not retrieve idempotency key: %w", err)
+	}
+
+	if status != nil {
+		res.Status = *status
+		held.Response = &res
+	}
+
+	return held, false, nil
+}
+
+func (s *Storage) SaveResponse(ctx context.Context, subject, key string, res idempotency.Response, expiresAt time.Time) error {
+	const query = `UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5, expires_at = $6
+		WHERE subject = $1 AND key = $2 AND status IS NULL`
+
+	if _, err := s.c.Exec(ctx, query, subject, key, res.Status, res.ContentType, res.Body, expiresAt); err != nil {
+		return fmt.Errorf("could not save idempotent response in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteKey(ctx context.Context, subject, key string) error {
+	const query = `DELETE FROM idempotency_keys WHERE subject = $1 AND key = $2 AND status IS NULL`
+
+	if _, err := s.c.Exec(ctx, query, subject, key); err != nil {
+		return fmt.Errorf("could not delete idempotency key from db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteExpiredKeys(ctx context.Context, now time.Time) (int64, error) {
+	const query = `DELETE FROM idempotency_keys WHERE expires_at <= $1`
+
+	tag, err := s.c.Exec(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete expired idempotency keys from db: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/idempotency"
+	"github.com/nerock/invoicebidder/internal/sqlite"
+)
+
+//go:embed migrations/sqlite/*.up.sql
+var sqliteMigrations embed.FS
+
+// SQLiteStorage keeps idempotency keys in an embedded sqlite db, times are
+// stored as fixed width text so expirations compare in the queries.
+type SQLiteStorage struct {
+	c *sql.DB
+}
+
+func NewSQLiteStorage(c *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{c}
+}
+
+func (s *SQLiteStorage) Migrate(ctx context.Context) error {
+	migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	return sqlite.Migrate(ctx, s.c, migrations)
+}
+
+// ReserveKey inserts the record, taking over the key if it expired. When the
+// key is held, the existing record is returned.
+func (s *SQLiteStorage) ReserveKey(ctx context.Context, rec idempotency.Record) (idempotency.Record, bool, error) {
+	const reserve = `INSERT INTO idempotency_keys (subject, key, request_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (subject, key) DO UPDATE SET
+			request_hash = excluded.request_hash,
+			status = NULL,
+			content_type = NULL,
+			body = NULL,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= excluded.created_at`
+
+	res, err := s.c.ExecContext(ctx, reserve, rec.Subject, rec.Key, rec.RequestHash, sqlite.FormatTime(rec.CreatedAt), sqlite.FormatTime(rec.ExpiresAt))
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("could not reserve idempotency key in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("could not reserve idempotency key in db: %w", err)
+	}
+	if n == 1 {
+		return rec, true, nil
+	}
+
+	const query = `SELECT k.subject, k.key, k.request_hash, k.status, COALESCE(k.content_type, ''), k.body, k.created_at, k.expires_at
+		FROM idempotency_keys k WHERE k.subject = ? AND k.key = ?`
+
+	var (
+		held   idempotency.Record
+		status sql.NullInt64
+		resp   idempotency.Response
+	)
+	err = s.c.QueryRowContext(ctx, query, rec.Subject, rec.Key).Scan(&held.Subject, &held.Key, &held.RequestHash, &status, &resp.ContentType,
+		&resp.Body, sqlite.Time{T: &held.CreatedAt}, sqlite.Time{T: &held.ExpiresAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		// released between both queries, so it is free to be reserved again
+		return s.ReserveKey(ctx, rec)
+	}
+	if err != nil {
+		return idempotency.Record{}, false, fmt.Errorf("could not retrieve idempotency key: %w", err)
+	}
+
+	if status.Valid {
+		resp.Status = int(status.Int64)
+		held.Response = &resp
+	}
+
+	return held, false, nil
+}
+
+func (s *SQLiteStorage) SaveResponse(ctx context.Context, subject, key string, res idempotency.Response, expiresAt time.Time) error {
+	const query = `UPDATE idempotency_keys SET status = ?, content_type = ?, body = ?, expires_at = ?
+		WHERE subject = ? AND key = ? AND status IS NULL`
+
+	if _, err := s.c.ExecContext(ctx, query, res.Status, res.ContentType, res.Body, sqlite.FormatTime(expiresAt), subject, key); err != nil {
+		return fmt.Errorf("could not save idempotent response in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteKey(ctx context.Context, subject, key string) error {
+	const query = `DELETE FROM idempotency_keys WHERE subject = ? AND key = ? AND status IS NULL`
+
+	if _, err := s.c.ExecContext(ctx, query, subject, key); err != nil {
+		return fmt.Errorf("could not delete idempotency key from db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteExpiredKeys(ctx context.Context, now time.Time) (int64, error) {
+	const query = `DELETE FROM idempotency_keys WHERE expires_at <= ?`
+
+	res, err := s.c.ExecContext(ctx, query, sqlite.FormatTime(now))
+	if err != nil {
+		return 0, fmt.Errorf("could not delete expired idempotency keys from db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("could not delete expired idempotency keys from db: %w", err)
+	}
+
+	return n, nil
+}
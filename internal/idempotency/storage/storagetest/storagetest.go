@@ -0,0 +1,131 @@
+// Package storagetest holds the behaviour every idempotency.Storage must
+// have, so all the backends are tested alike.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/idempotency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Run checks an idempotency.Storage, newStorage must return an empty storage
+// on every call.
+func Run(t *testing.T, newStorage func(*testing.T) idempotency.Storage) {
+	Convey("idempotency.Storage", t, func() {
+		ctx := context.Background()
+		st := newStorage(t)
+
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		rec := idempotency.Record{Subject: "user-1", Key: "key-1", RequestHash: hash("1"), CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+		held, reserved, err := st.ReserveKey(ctx, rec)
+		So(err, ShouldBeNil)
+		So(reserved, ShouldBeTrue)
+		So(held, ShouldResemble, rec)
+
+		res := idempotency.Response{Status: http.StatusCreated, ContentType: "application/json", Body: []byte(`{"id":"bid-1"}`)}
+
+		Convey("when a held key is reserved", func() {
+			held, reserved, err := st.ReserveKey(ctx, idempotency.Record{Subject: "user-1", Key: "key-1", RequestHash: hash("2"),
+				CreatedAt: now.Add(time.Second), ExpiresAt: now.Add(time.Hour)})
+
+			Convey("return the record holding it", func() {
+				So(err, ShouldBeNil)
+				So(reserved, ShouldBeFalse)
+				So(held.RequestHash, ShouldEqual, hash("1"))
+				So(held.Response, ShouldBeNil)
+				So(held.ExpiresAt.Equal(now.Add(time.Minute)), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the same key is reserved by another subject", func() {
+			_, reserved, err := st.ReserveKey(ctx, idempotency.Record{Subject: "user-2", Key: "key-1", RequestHash: hash("1"),
+				CreatedAt: now, ExpiresAt: now.Add(time.Minute)})
+
+			Convey("reserve it", func() {
+				So(err, ShouldBeNil)
+				So(reserved, ShouldBeTrue)
+			})
+		})
+
+		Convey("when the lease of a key is over", func() {
+			later := now.Add(2 * time.Minute)
+			held, reserved, err := st.ReserveKey(ctx, idempotency.Record{Subject: "user-1", Key: "key-1", RequestHash: hash("2"),
+				CreatedAt: later, ExpiresAt: later.Add(time.Minute)})
+
+			Convey("take it over", func() {
+				So(err, ShouldBeNil)
+				So(reserved, ShouldBeTrue)
+				So(held.RequestHash, ShouldEqual, hash("2"))
+			})
+		})
+
+		Convey("when the response is saved", func() {
+			So(st.SaveResponse(ctx, "user-1", "key-1", res, now.Add(time.Hour)), ShouldBeNil)
+			held, reserved, err := st.ReserveKey(ctx, idempotency.Record{Subject: "user-1", Key: "key-1", RequestHash: hash("1"),
+				CreatedAt: now.Add(2 * time.Minute), ExpiresAt: now.Add(3 * time.Minute)})
+			So(err, ShouldBeNil)
+
+			Convey("return it with the key until it expires", func() {
+				So(reserved, ShouldBeFalse)
+				So(held.Response, ShouldNotBeNil)
+				So(*held.Response, ShouldResemble, res)
+				So(held.ExpiresAt.Equal(now.Add(time.Hour)), ShouldBeTrue)
+			})
+
+			Convey("keep the first response", func() {
+				So(st.SaveResponse(ctx, "user-1", "key-1", idempotency.Response{Status: http.StatusConflict}, now.Add(time.Hour)), ShouldBeNil)
+
+				held, _, err := st.ReserveKey(ctx, rec)
+				So(err, ShouldBeNil)
+				So(held.Response.Status, ShouldEqual, http.StatusCreated)
+			})
+
+			Convey("not delete the key", func() {
+				So(st.DeleteKey(ctx, "user-1", "key-1"), ShouldBeNil)
+
+				_, reserved, err := st.ReserveKey(ctx, rec)
+				So(err, ShouldBeNil)
+				So(reserved, ShouldBeFalse)
+			})
+		})
+
+		Convey("when a key without response is deleted", func() {
+			So(st.DeleteKey(ctx, "user-1", "key-1"), ShouldBeNil)
+
+			Convey("reserve it again", func() {
+				_, reserved, err := st.ReserveKey(ctx, rec)
+				So(err, ShouldBeNil)
+				So(reserved, ShouldBeTrue)
+			})
+		})
+
+		Convey("when expired keys are deleted", func() {
+			_, _, err := st.ReserveKey(ctx, idempotency.Record{Subject: "user-2", Key: "key-2", RequestHash: hash("3"),
+				CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+			So(err, ShouldBeNil)
+
+			n, err := st.DeleteExpiredKeys(ctx, now.Add(time.Minute))
+
+			Convey("delete only those", func() {
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 1)
+
+				_, reserved, err := st.ReserveKey(ctx, idempotency.Record{Subject: "user-2", Key: "key-2", RequestHash: hash("3"),
+					CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+				So(err, ShouldBeNil)
+				So(reserved, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+// hash pads n to the width of a sha256 hex digest, as postgres stores them
+// in fixed width
+func hash(n string) string {
+	return fmt.Sprintf("%064s", n)
+}
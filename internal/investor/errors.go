@@ -0,0 +1,16 @@
+package investor
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by storages when an investor or payment does not exist
+	ErrNotFound          = errors.New("not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrCurrencyMismatch  = errors.New("currency mismatch")
+	ErrInvalidAmount     = errors.New("invalid amount")
+	// ErrNotOwner is returned when acting on a payment of someone else
+	ErrNotOwner = errors.New("not the owner")
+	// ErrRuleExhausted is returned when a bid would deploy more than the rule allows
+	ErrRuleExhausted = errors.New("rule max deployed reached")
+)
@@ -0,0 +1,36 @@
+package investor
+
+import (
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+type PaymentKind string
+
+const (
+	DEPOSIT    PaymentKind = "deposit"
+	WITHDRAWAL PaymentKind = "withdrawal"
+)
+
+type PaymentStatus string
+
+const (
+	PENDING PaymentStatus = "pending"
+	SETTLED PaymentStatus = "settled"
+	FAILED  PaymentStatus = "failed"
+)
+
+// Payment is an instruction to move money between the investor bank account
+// and its balance, which only changes once the payment settles.
+type Payment struct {
+	ID         string
+	InvestorID string
+	Kind       PaymentKind
+	Amount     currency.Amount
+	IBAN       string
+	Reference  string
+	Status     PaymentStatus
+	CreatedAt  time.Time
+	SettledAt  *time.Time
+}
@@ -0,0 +1,73 @@
+package investor
+
+import (
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+type Rule struct {
+	ID            string
+	InvestorID    string
+	Currency      string
+	MinRating     string
+	MaxDueDays    int
+	MaxPerInvoice currency.Amount
+	MaxDeployed   currency.Amount
+	Deployed      currency.Amount
+	Active        bool
+}
+
+type RuleBid struct {
+	RuleID    string
+	BidID     string
+	InvoiceID string
+	Amount    currency.Amount
+	CreatedAt time.Time
+}
+
+// Matches reports whether an invoice with the given currency, issuer rating
+// and due date falls within the rule criteria. Ratings go from A (best) to E,
+// so an unrated issuer only matches rules without a minimum rating.
+func (r Rule) Matches(currencyCode, rating string, dueDate, now time.Time) bool {
+	if !r.Active || r.Currency != currencyCode {
+		return false
+	}
+
+	if r.MinRating != "" && (rating == "" || rating > r.MinRating) {
+		return false
+	}
+
+	if r.MaxDueDays > 0 && dueDate.After(now.AddDate(0, 0, r.MaxDueDays)) {
+		return false
+	}
+
+	return true
+}
+
+// BidAmount returns how much the rule is willing to bid on an invoice with
+// the given remaining price, which is zero once the rule is fully deployed.
+func (r Rule) BidAmount(remaining currency.Amount) currency.Amount {
+	available, err := r.MaxDeployed.Sub(r.Deployed)
+	if err != nil || !available.IsPositive() {
+		return currency.Amount{}
+	}
+
+	amount := r.MaxPerInvoice
+	for _, limit := range []currency.Amount{available, remaining} {
+		cmp, err := amount.Cmp(limit)
+		if err != nil {
+			return currency.Amount{}
+		}
+
+		if cmp > 0 {
+			amount = limit
+		}
+	}
+
+	if !amount.IsPositive() {
+		return currency.Amount{}
+	}
+
+	return amount
+}
@@ -0,0 +1,86 @@
+package investor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRule_Matches(t *testing.T) {
+	Convey("Matches", t, func() {
+		now := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+		rule := Rule{
+			Currency:   "EUR",
+			MinRating:  "B",
+			MaxDueDays: 90,
+			Active:     true,
+		}
+
+		Convey("when the invoice fits every criteria", func() {
+			So(rule.Matches("EUR", "A", now.AddDate(0, 0, 90), now), ShouldBeTrue)
+			So(rule.Matches("EUR", "B", now.AddDate(0, 0, 30), now), ShouldBeTrue)
+		})
+
+		Convey("when the rule is not active", func() {
+			rule.Active = false
+			So(rule.Matches("EUR", "A", now, now), ShouldBeFalse)
+		})
+
+		Convey("when the currency is different", func() {
+			So(rule.Matches("USD", "A", now, now), ShouldBeFalse)
+		})
+
+		Convey("when the issuer rating is worse or missing", func() {
+			So(rule.Matches("EUR", "C", now, now), ShouldBeFalse)
+			So(rule.Matches("EUR", "", now, now), ShouldBeFalse)
+		})
+
+		Convey("when the rule has no minimum rating", func() {
+			rule.MinRating = ""
+			So(rule.Matches("EUR", "", now, now), ShouldBeTrue)
+		})
+
+		Convey("when the invoice is due too late", func() {
+			So(rule.Matches("EUR", "A", now.AddDate(0, 0, 91), now), ShouldBeFalse)
+		})
+	})
+}
+
+func TestRule_BidAmount(t *testing.T) {
+	Convey("BidAmount", t, func() {
+		amount := func(n string) currency.Amount {
+			a, _ := currency.NewAmount(n, "EUR")
+			return a
+		}
+		rule := Rule{
+			MaxPerInvoice: amount("500"),
+			MaxDeployed:   amount("1000"),
+			Deployed:      amount("0"),
+		}
+
+		Convey("when nothing limits it bid the max per invoice", func() {
+			So(rule.BidAmount(amount("2000")), ShouldEqual, amount("500"))
+		})
+
+		Convey("when the remaining price is lower bid the remaining price", func() {
+			So(rule.BidAmount(amount("200")), ShouldEqual, amount("200"))
+		})
+
+		Convey("when the rule is almost deployed bid what is left", func() {
+			rule.Deployed = amount("700")
+			So(rule.BidAmount(amount("2000")), ShouldEqual, amount("300"))
+		})
+
+		Convey("when the rule is fully deployed do not bid", func() {
+			rule.Deployed = amount("1000")
+			So(rule.BidAmount(amount("2000")).IsPositive(), ShouldBeFalse)
+		})
+
+		Convey("when the currencies do not match do not bid", func() {
+			usd, _ := currency.NewAmount("2000", "USD")
+			So(rule.BidAmount(usd).IsPositive(), ShouldBeFalse)
+		})
+	})
+}
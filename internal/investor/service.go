@@ -2,7 +2,9 @@ package investor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bojanz/currency"
 	"github.com/google/uuid"
@@ -14,6 +16,30 @@ type Storage interface {
 	RetrieveInvestors(context.Context, []string) ([]Investor, error)
 	UpdateBalance(context.Context, string, currency.Amount) error
 	UpdateBalances(context.Context, map[string]currency.Amount) error
+	// AddBalance adds the delta to the investor balance in a single update. A
+	// debit that would leave the balance negative fails with
+	// ErrInsufficientFunds.
+	AddBalance(context.Context, string, currency.Amount) error
+	// TransferBalance debits the amount from the first investor and credits it
+	// to the second in one transaction. A debit the payer cannot cover fails
+	// with ErrInsufficientFunds and leaves both balances untouched.
+	TransferBalance(context.Context, string, string, currency.Amount) error
+
+	CreateRule(context.Context, Rule) error
+	RetrieveRulesByInvestorID(context.Context, string) ([]Rule, error)
+	RetrieveActiveRules(context.Context, string, string) ([]Rule, error)
+	SaveRuleBid(context.Context, RuleBid) error
+	DeleteRuleBids(context.Context, []string) error
+	RetrieveRuleBidsByInvestorID(context.Context, string) ([]RuleBid, error)
+
+	SavePayment(context.Context, Payment) error
+	RetrievePayment(context.Context, string) (Payment, error)
+	RetrievePaymentsByInvestorID(context.Context, string) ([]Payment, error)
+	// SettlePayment completes a pending payment and adds the delta to the
+	// investor balance atomically. A debit that would leave the balance
+	// negative fails with ErrInsufficientFunds and keeps the payment pending.
+	SettlePayment(context.Context, Payment, currency.Amount) error
+	FailPayment(context.Context, Payment) error
 }
 
 type Service struct {
@@ -75,7 +101,7 @@ func (s *Service) Bid(ctx context.Context, id string, amount currency.Amount) er
 	}
 
 	if newBalance.IsNegative() {
-		return fmt.Errorf("insufficient funds")
+		return ErrInsufficientFunds
 	}
 
 	return s.st.UpdateBalance(ctx, id, newBalance)
@@ -95,6 +121,24 @@ func (s *Service) CancelBid(ctx context.Context, id string, amount currency.Amou
 	return s.st.UpdateBalance(ctx, id, newBalance)
 }
 
+// ChargeFee debits a platform fee, failing with ErrInsufficientFunds if the
+// balance cannot cover it.
+func (s *Service) ChargeFee(ctx context.Context, id string, amount currency.Amount) error {
+	debit, _ := amount.Mul("-1")
+
+	return s.st.AddBalance(ctx, id, debit)
+}
+
+// Transfer moves an amount from one investor balance to another in a single
+// transaction, failing if the payer does not have enough funds.
+func (s *Service) Transfer(ctx context.Context, fromID, toID string, amount currency.Amount) error {
+	if !amount.IsPositive() {
+		return fmt.Errorf("%w: transfer amount must be positive", ErrInvalidAmount)
+	}
+
+	return s.st.TransferBalance(ctx, fromID, toID, amount)
+}
+
 func (s *Service) CancelTrade(ctx context.Context, bids []Bid) error {
 	investorIDs := make([]string, 0, len(bids))
 	for _, b := range bids {
@@ -125,6 +169,191 @@ func (s *Service) CancelTrade(ctx context.Context, bids []Bid) error {
 	return s.st.UpdateBalances(ctx, newBalances)
 }
 
+func (s *Service) CreateRule(ctx context.Context, rule Rule) (Rule, error) {
+	if rule.MaxPerInvoice.CurrencyCode() != rule.Currency || rule.MaxDeployed.CurrencyCode() != rule.Currency {
+		return Rule{}, fmt.Errorf("%w: rule limits must be in %s", ErrCurrencyMismatch, rule.Currency)
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return Rule{}, fmt.Errorf("could not generate id: %w", err)
+	}
+
+	rule.ID = id.String()
+	rule.Deployed, _ = currency.NewAmount("0", rule.Currency)
+	rule.Active = true
+	if err := s.st.CreateRule(ctx, rule); err != nil {
+		return Rule{}, err
+	}
+
+	return rule, nil
+}
+
+func (s *Service) ListRules(ctx context.Context, investorID string) ([]Rule, error) {
+	return s.st.RetrieveRulesByInvestorID(ctx, investorID)
+}
+
+func (s *Service) ListRuleBids(ctx context.Context, investorID string) ([]RuleBid, error) {
+	return s.st.RetrieveRuleBidsByInvestorID(ctx, investorID)
+}
+
+// MatchRules returns the active rules that accept the invoice and have not
+// bid on it yet, so evaluating the same invoice twice does not bid twice.
+func (s *Service) MatchRules(ctx context.Context, invoiceID, currencyCode, rating string, dueDate time.Time) ([]Rule, error) {
+	rules, err := s.st.RetrieveActiveRules(ctx, currencyCode, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	matched := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		if r.Matches(currencyCode, rating, dueDate, now) {
+			matched = append(matched, r)
+		}
+	}
+
+	return matched, nil
+}
+
+// RecordRuleBid reserves the amount of a bid in the rule, it fails with
+// ErrRuleExhausted when the rule would deploy more than its maximum.
+func (s *Service) RecordRuleBid(ctx context.Context, ruleID, bidID, invoiceID string, amount currency.Amount) error {
+	return s.st.SaveRuleBid(ctx, RuleBid{
+		RuleID:    ruleID,
+		BidID:     bidID,
+		InvoiceID: invoiceID,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	})
+}
+
+// ReleaseRuleBids gives back to their rules the amount of the bids that were
+// rejected or could not be placed, bids not placed by a rule are ignored.
+func (s *Service) ReleaseRuleBids(ctx context.Context, bidIDs []string) error {
+	if len(bidIDs) == 0 {
+		return nil
+	}
+
+	return s.st.DeleteRuleBids(ctx, bidIDs)
+}
+
+func (s *Service) RequestDeposit(ctx context.Context, investorID string, amount currency.Amount, iban, reference string) (Payment, error) {
+	return s.requestPayment(ctx, investorID, DEPOSIT, amount, iban, reference)
+}
+
+// RequestWithdrawal records a withdrawal as long as it fits in the balance
+// left after the withdrawals that are still pending.
+func (s *Service) RequestWithdrawal(ctx context.Context, investorID string, amount currency.Amount, iban, reference string) (Payment, error) {
+	investor, err := s.GetInvestor(ctx, investorID)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	payments, err := s.st.RetrievePaymentsByInvestorID(ctx, investorID)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	debit, _ := amount.Mul("-1")
+	available, err := addBalance(investor.Balance, debit)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	for _, p := range payments {
+		if p.Kind != WITHDRAWAL || p.Status != PENDING {
+			continue
+		}
+
+		debit, _ := p.Amount.Mul("-1")
+		if available, err = addBalance(available, debit); err != nil {
+			return Payment{}, err
+		}
+	}
+
+	if available.IsNegative() {
+		return Payment{}, ErrInsufficientFunds
+	}
+
+	return s.requestPayment(ctx, investorID, WITHDRAWAL, amount, iban, reference)
+}
+
+func (s *Service) requestPayment(ctx context.Context, investorID string, kind PaymentKind, amount currency.Amount, iban, reference string) (Payment, error) {
+	if !amount.IsPositive() {
+		return Payment{}, fmt.Errorf("%w: payment amount must be positive", ErrInvalidAmount)
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return Payment{}, fmt.Errorf("could not generate id: %w", err)
+	}
+
+	payment := Payment{
+		ID:         id.String(),
+		InvestorID: investorID,
+		Kind:       kind,
+		Amount:     amount,
+		IBAN:       iban,
+		Reference:  reference,
+		Status:     PENDING,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.st.SavePayment(ctx, payment); err != nil {
+		return Payment{}, err
+	}
+
+	return payment, nil
+}
+
+func (s *Service) ListPayments(ctx context.Context, investorID string) ([]Payment, error) {
+	return s.st.RetrievePaymentsByInvestorID(ctx, investorID)
+}
+
+// SettlePayment completes a pending payment. Settled payments credit or debit
+// the investor balance, while failed ones leave it untouched. A withdrawal
+// that no longer fits in the balance fails instead of settling.
+func (s *Service) SettlePayment(ctx context.Context, investorID, id string, settled bool) (Payment, error) {
+	payment, err := s.st.RetrievePayment(ctx, id)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	if payment.InvestorID != investorID {
+		return Payment{}, fmt.Errorf("%w: payment does not belong to the investor", ErrNotOwner)
+	}
+
+	if payment.Status != PENDING {
+		return Payment{}, fmt.Errorf("%w: payment is already %s", ErrInvalidTransition, payment.Status)
+	}
+
+	now := time.Now()
+	payment.SettledAt = &now
+
+	if settled {
+		delta := payment.Amount
+		if payment.Kind == WITHDRAWAL {
+			delta, _ = delta.Mul("-1")
+		}
+
+		payment.Status = SETTLED
+		err := s.st.SettlePayment(ctx, payment, delta)
+		if err == nil {
+			return payment, nil
+		}
+		if !errors.Is(err, ErrInsufficientFunds) {
+			return Payment{}, err
+		}
+	}
+
+	payment.Status = FAILED
+	if err := s.st.FailPayment(ctx, payment); err != nil {
+		return Payment{}, err
+	}
+
+	return payment, nil
+}
+
 func addBalance(current, delta currency.Amount) (currency.Amount, error) {
 	if delta.CurrencyCode() != current.CurrencyCode() {
 		var err error
@@ -0,0 +1,177 @@
+package investor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bojanz/currency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockStorage struct {
+	Storage
+	payment Payment
+
+	settleErr error
+	settled   []currency.Amount
+	failed    []Payment
+
+	transferred []currency.Amount
+}
+
+func (m *mockStorage) RetrievePayment(_ context.Context, id string) (Payment, error) {
+	if id != m.payment.ID {
+		return Payment{}, ErrNotFound
+	}
+
+	return m.payment, nil
+}
+
+func (m *mockStorage) SettlePayment(_ context.Context, _ Payment, delta currency.Amount) error {
+	if m.settleErr != nil {
+		return m.settleErr
+	}
+
+	m.settled = append(m.settled, delta)
+	return nil
+}
+
+func (m *mockStorage) FailPayment(_ context.Context, p Payment) error {
+	m.failed = append(m.failed, p)
+	return nil
+}
+
+func (m *mockStorage) TransferBalance(_ context.Context, _, _ string, amount currency.Amount) error {
+	if m.settleErr != nil {
+		return m.settleErr
+	}
+
+	m.transferred = append(m.transferred, amount)
+	return nil
+}
+
+func TestService_SettlePayment(t *testing.T) {
+	Convey("SettlePayment", t, func() {
+		amount, _ := currency.NewAmount("250.75", "EUR")
+		st := &mockStorage{payment: Payment{ID: "payID", InvestorID: "invstID", Kind: DEPOSIT, Amount: amount, Status: PENDING}}
+		svc := NewService(st)
+		ctx := context.Background()
+
+		Convey("when the payment belongs to someone else", func() {
+			_, err := svc.SettlePayment(ctx, "other", "payID", true)
+
+			Convey("return not owner and leave it pending", func() {
+				So(errors.Is(err, ErrNotOwner), ShouldBeTrue)
+				So(st.settled, ShouldBeEmpty)
+				So(st.failed, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the payment is no longer pending", func() {
+			st.payment.Status = SETTLED
+			_, err := svc.SettlePayment(ctx, "invstID", "payID", true)
+
+			Convey("return invalid transition", func() {
+				So(errors.Is(err, ErrInvalidTransition), ShouldBeTrue)
+				So(st.settled, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when a deposit settles", func() {
+			p, err := svc.SettlePayment(ctx, "invstID", "payID", true)
+
+			Convey("credit its amount to the balance", func() {
+				So(err, ShouldBeNil)
+				So(p.Status, ShouldEqual, SETTLED)
+				So(p.SettledAt, ShouldNotBeNil)
+				So(st.settled, ShouldHaveLength, 1)
+				So(st.settled[0].Equal(amount), ShouldBeTrue)
+			})
+		})
+
+		Convey("when a withdrawal settles", func() {
+			st.payment.Kind = WITHDRAWAL
+			p, err := svc.SettlePayment(ctx, "invstID", "payID", true)
+
+			Convey("debit its amount from the balance", func() {
+				So(err, ShouldBeNil)
+				So(p.Status, ShouldEqual, SETTLED)
+				So(st.settled, ShouldHaveLength, 1)
+				So(st.settled[0].Number(), ShouldEqual, "-250.75")
+			})
+		})
+
+		Convey("when a withdrawal no longer fits in the balance", func() {
+			st.payment.Kind = WITHDRAWAL
+			st.settleErr = ErrInsufficientFunds
+			p, err := svc.SettlePayment(ctx, "invstID", "payID", true)
+
+			Convey("fail the payment", func() {
+				So(err, ShouldBeNil)
+				So(p.Status, ShouldEqual, FAILED)
+				So(st.failed, ShouldHaveLength, 1)
+				So(st.failed[0].Status, ShouldEqual, FAILED)
+			})
+		})
+
+		Convey("when the storage fails to settle", func() {
+			st.settleErr = errors.New("db down")
+			_, err := svc.SettlePayment(ctx, "invstID", "payID", true)
+
+			Convey("return the error and don't fail the payment", func() {
+				So(err, ShouldNotBeNil)
+				So(st.failed, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the payment is reported as failed", func() {
+			p, err := svc.SettlePayment(ctx, "invstID", "payID", false)
+
+			Convey("fail it without touching the balance", func() {
+				So(err, ShouldBeNil)
+				So(p.Status, ShouldEqual, FAILED)
+				So(p.SettledAt, ShouldNotBeNil)
+				So(st.settled, ShouldBeEmpty)
+				So(st.failed, ShouldHaveLength, 1)
+			})
+		})
+	})
+}
+
+func TestService_Transfer(t *testing.T) {
+	Convey("Transfer", t, func() {
+		st := &mockStorage{}
+		svc := NewService(st)
+		ctx := context.Background()
+
+		Convey("when the amount is not positive", func() {
+			amount, _ := currency.NewAmount("-10", "EUR")
+			err := svc.Transfer(ctx, "buyer", "seller", amount)
+
+			Convey("return invalid amount without moving anything", func() {
+				So(errors.Is(err, ErrInvalidAmount), ShouldBeTrue)
+				So(st.transferred, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the payer cannot cover it", func() {
+			st.settleErr = ErrInsufficientFunds
+			amount, _ := currency.NewAmount("10", "EUR")
+
+			Convey("return insufficient funds", func() {
+				So(errors.Is(svc.Transfer(ctx, "buyer", "seller", amount), ErrInsufficientFunds), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the payer has enough funds", func() {
+			amount, _ := currency.NewAmount("10", "EUR")
+
+			Convey("move the amount in a single storage call", func() {
+				So(svc.Transfer(ctx, "buyer", "seller", amount), ShouldBeNil)
+				So(st.transferred, ShouldHaveLength, 1)
+				So(st.transferred[0].Equal(amount), ShouldBeTrue)
+			})
+		})
+	})
+}
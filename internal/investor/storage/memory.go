@@ -0,0 +1,403 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/investor"
+)
+
+// MemoryStorage keeps investors, their rules and payments in memory. Rules
+// keep the order they were created in, as postgres returns them.
+type MemoryStorage struct {
+	mu        sync.RWMutex
+	investors map[string]investor.Investor
+	rules     map[string]investor.Rule
+	ruleOrder []string
+	ruleBids  []investor.RuleBid
+	payments  map[string]investor.Payment
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		investors: map[string]investor.Investor{},
+		rules:     map[string]investor.Rule{},
+		payments:  map[string]investor.Payment{},
+	}
+}
+
+func (s *MemoryStorage) CreateInvestor(_ context.Context, inv investor.Investor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.investors[inv.ID]; ok {
+		return fmt.Errorf("could not save investor: investor %s already exists", inv.ID)
+	}
+
+	inv.Bids = nil
+	s.investors[inv.ID] = inv
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveInvestor(_ context.Context, id string) (investor.Investor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	inv, ok := s.investors[id]
+	if !ok {
+		return investor.Investor{ID: id}, fmt.Errorf("could not retrieve investor: %w", investor.ErrNotFound)
+	}
+
+	return inv, nil
+}
+
+// RetrieveInvestors returns the investors with the given ids, or all of them
+// when no id is given.
+func (s *MemoryStorage) RetrieveInvestors(_ context.Context, ids []string) ([]investor.Investor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var investors []investor.Investor
+	if len(ids) == 0 {
+		for _, inv := range s.investors {
+			investors = append(investors, inv)
+		}
+		sort.Slice(investors, func(i, j int) bool { return investors[i].ID < investors[j].ID })
+
+		return investors, nil
+	}
+
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if inv, ok := s.investors[id]; ok && !seen[id] {
+			seen[id] = true
+			investors = append(investors, inv)
+		}
+	}
+
+	return investors, nil
+}
+
+func (s *MemoryStorage) UpdateBalance(_ context.Context, id string, balance currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.investors[id]; !ok {
+		return fmt.Errorf("could not replace current investor balance: %w", investor.ErrNotFound)
+	}
+	s.setBalance(id, balance)
+
+	return nil
+}
+
+func (s *MemoryStorage) UpdateBalances(_ context.Context, balances map[string]currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range balances {
+		if _, ok := s.investors[id]; !ok {
+			return fmt.Errorf("could not replace current investor balance: %w", investor.ErrNotFound)
+		}
+	}
+	for id, b := range balances {
+		s.setBalance(id, b)
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) CreateRule(_ context.Context, r investor.Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rules[r.ID]; ok {
+		return fmt.Errorf("could not save rule: rule %s already exists", r.ID)
+	}
+	if _, ok := s.investors[r.InvestorID]; !ok {
+		return fmt.Errorf("could not save rule: %w", investor.ErrNotFound)
+	}
+
+	s.rules[r.ID] = r
+	s.ruleOrder = append(s.ruleOrder, r.ID)
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveRulesByInvestorID(_ context.Context, investorID string) ([]investor.Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rules []investor.Rule
+	for _, id := range s.ruleOrder {
+		if r := s.rules[id]; r.InvestorID == investorID {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules, nil
+}
+
+// RetrieveActiveRules returns the active rules in the currency that have not
+// bid on the invoice yet.
+func (s *MemoryStorage) RetrieveActiveRules(_ context.Context, currencyCode, invoiceID string) ([]investor.Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bid := map[string]bool{}
+	for _, rb := range s.ruleBids {
+		if rb.InvoiceID == invoiceID {
+			bid[rb.RuleID] = true
+		}
+	}
+
+	var rules []investor.Rule
+	for _, id := range s.ruleOrder {
+		if r := s.rules[id]; r.Currency == currencyCode && r.Active && !bid[id] {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules, nil
+}
+
+// SaveRuleBid records the bid of a rule and adds its amount to what the rule
+// deployed, once per rule and invoice and within the max deployed.
+func (s *MemoryStorage) SaveRuleBid(_ context.Context, rb investor.RuleBid) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.rules[rb.RuleID]
+	if !ok {
+		return fmt.Errorf("could not save rule bid: %w", investor.ErrNotFound)
+	}
+
+	for _, saved := range s.ruleBids {
+		if saved.BidID == rb.BidID || (saved.RuleID == rb.RuleID && saved.InvoiceID == rb.InvoiceID) {
+			return fmt.Errorf("could not save rule bid: rule %s already bid on invoice %s", rb.RuleID, rb.InvoiceID)
+		}
+	}
+
+	// the deployed amount keeps the currency of the rule, like the postgres
+	// update that only adds the numbers
+	amount, err := currency.NewAmount(rb.Amount.Number(), r.Deployed.CurrencyCode())
+	if err != nil {
+		return fmt.Errorf("could not save rule bid: %w", err)
+	}
+	deployed, err := r.Deployed.Add(amount)
+	if err != nil {
+		return fmt.Errorf("could not save rule bid: %w", err)
+	}
+	if cmp, err := deployed.Cmp(r.MaxDeployed); err != nil || cmp > 0 {
+		return fmt.Errorf("could not save rule bid: %w", investor.ErrRuleExhausted)
+	}
+
+	r.Deployed = deployed
+	s.rules[r.ID] = r
+	s.ruleBids = append(s.ruleBids, rb)
+
+	return nil
+}
+
+func (s *MemoryStorage) DeleteRuleBids(_ context.Context, bidIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := make(map[string]bool, len(bidIDs))
+	for _, id := range bidIDs {
+		deleted[id] = true
+	}
+
+	ruleBids := make([]investor.RuleBid, 0, len(s.ruleBids))
+	for _, rb := range s.ruleBids {
+		if !deleted[rb.BidID] {
+			ruleBids = append(ruleBids, rb)
+			continue
+		}
+
+		r := s.rules[rb.RuleID]
+		amount, err := currency.NewAmount(rb.Amount.Number(), r.Deployed.CurrencyCode())
+		if err != nil {
+			return fmt.Errorf("could not delete rule bid: %w", err)
+		}
+		if r.Deployed, err = r.Deployed.Sub(amount); err != nil {
+			return fmt.Errorf("could not delete rule bid: %w", err)
+		}
+		s.rules[r.ID] = r
+	}
+	s.ruleBids = ruleBids
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveRuleBidsByInvestorID(_ context.Context, investorID string) ([]investor.RuleBid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ruleBids []investor.RuleBid
+	for _, rb := range s.ruleBids {
+		if s.rules[rb.RuleID].InvestorID == investorID {
+			ruleBids = append(ruleBids, rb)
+		}
+	}
+	sort.SliceStable(ruleBids, func(i, j int) bool { return ruleBids[i].CreatedAt.Before(ruleBids[j].CreatedAt) })
+
+	return ruleBids, nil
+}
+
+func (s *MemoryStorage) SavePayment(_ context.Context, p investor.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.payments[p.ID]; ok {
+		return fmt.Errorf("could not save payment: payment %s already exists", p.ID)
+	}
+	if _, ok := s.investors[p.InvestorID]; !ok {
+		return fmt.Errorf("could not save payment: %w", investor.ErrNotFound)
+	}
+
+	s.payments[p.ID] = p
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrievePayment(_ context.Context, id string) (investor.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.payments[id]
+	if !ok {
+		return investor.Payment{ID: id}, fmt.Errorf("could not retrieve payment: %w", investor.ErrNotFound)
+	}
+
+	return p, nil
+}
+
+func (s *MemoryStorage) RetrievePaymentsByInvestorID(_ context.Context, investorID string) ([]investor.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var payments []investor.Payment
+	for _, p := range s.payments {
+		if p.InvestorID == investorID {
+			payments = append(payments, p)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].CreatedAt.Before(payments[j].CreatedAt) })
+
+	return payments, nil
+}
+
+func (s *MemoryStorage) SettlePayment(_ context.Context, p investor.Payment, delta currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.payments[p.ID]
+	if !ok || stored.Status != investor.PENDING {
+		return fmt.Errorf("%w: payment is no longer pending", investor.ErrInvalidTransition)
+	}
+
+	if err := s.addBalance(p.InvestorID, delta); err != nil {
+		return err
+	}
+
+	return s.completePayment(p)
+}
+
+func (s *MemoryStorage) AddBalance(_ context.Context, id string, delta currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addBalance(id, delta)
+}
+
+func (s *MemoryStorage) TransferBalance(_ context.Context, fromID, toID string, amount currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.investors[toID]; !ok {
+		return fmt.Errorf("could not update investor balance: %w", investor.ErrNotFound)
+	}
+
+	debit, err := amount.Mul("-1")
+	if err != nil {
+		return fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	if err := s.addBalance(fromID, debit); err != nil {
+		return err
+	}
+
+	return s.addBalance(toID, amount)
+}
+
+func (s *MemoryStorage) FailPayment(_ context.Context, p investor.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.completePayment(p)
+}
+
+// completePayment moves a payment out of pending, failing if another request
+// completed it first so its balance is never applied twice.
+func (s *MemoryStorage) completePayment(p investor.Payment) error {
+	stored, ok := s.payments[p.ID]
+	if !ok || stored.Status != investor.PENDING {
+		return fmt.Errorf("%w: payment is no longer pending", investor.ErrInvalidTransition)
+	}
+
+	stored.Status = p.Status
+	stored.SettledAt = p.SettledAt
+	s.payments[p.ID] = stored
+
+	return nil
+}
+
+// addBalance adds the delta to the balance, failing with ErrInsufficientFunds
+// if a debit would leave it negative
+func (s *MemoryStorage) addBalance(id string, delta currency.Amount) error {
+	inv, ok := s.investors[id]
+	if !ok {
+		return fmt.Errorf("could not update investor balance: %w", investor.ErrNotFound)
+	}
+
+	balance, err := addNumber(inv.Balance, delta)
+	if err != nil {
+		return err
+	}
+
+	if delta.IsNegative() && balance.IsNegative() {
+		return investor.ErrInsufficientFunds
+	}
+
+	inv.Balance = balance
+	s.investors[id] = inv
+
+	return nil
+}
+
+func (s *MemoryStorage) setBalance(id string, balance currency.Amount) {
+	if inv, ok := s.investors[id]; ok {
+		inv.Balance = balance
+		s.investors[id] = inv
+	}
+}
+
+// addNumber adds the number of the delta to the balance in its own currency,
+// as the postgres storage does
+func addNumber(balance, delta currency.Amount) (currency.Amount, error) {
+	d, err := currency.NewAmount(delta.Number(), balance.CurrencyCode())
+	if err != nil {
+		return balance, fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	sum, err := balance.Add(d)
+	if err != nil {
+		return balance, fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	return sum, nil
+}
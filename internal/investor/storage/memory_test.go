@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/investor/storage/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) investor.Storage {
+		return NewMemoryStorage()
+	})
+}
@@ -0,0 +1,23 @@
+CREATE TABLE bid_rules (
+    id CHAR(36) PRIMARY KEY,
+    investor_id CHAR(36) NOT NULL REFERENCES investors (id),
+    currency_code TEXT NOT NULL,
+    min_rating TEXT NOT NULL,
+    max_due_days INTEGER NOT NULL,
+    max_per_invoice amount NOT NULL,
+    max_deployed amount NOT NULL,
+    deployed amount NOT NULL,
+    active BOOLEAN NOT NULL
+);
+
+CREATE INDEX bid_rules_currency_code_idx ON bid_rules (currency_code) WHERE active = true;
+
+CREATE TABLE rule_bids (
+    rule_id CHAR(36) NOT NULL REFERENCES bid_rules (id),
+    bid_id CHAR(36) PRIMARY KEY,
+    invoice_id CHAR(36) NOT NULL,
+    amount amount NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX rule_bids_rule_id_invoice_id_idx ON rule_bids (rule_id, invoice_id);
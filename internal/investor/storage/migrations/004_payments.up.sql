@@ -0,0 +1,13 @@
+CREATE TABLE payments (
+    id CHAR(36) PRIMARY KEY,
+    investor_id CHAR(36) NOT NULL REFERENCES investors (id),
+    kind TEXT NOT NULL,
+    amount amount NOT NULL,
+    iban TEXT NOT NULL,
+    reference TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    settled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX payments_investor_id_idx ON payments (investor_id, created_at);
@@ -0,0 +1,49 @@
+CREATE TABLE investors (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    balance_number TEXT NOT NULL,
+    balance_currency TEXT NOT NULL
+);
+
+CREATE TABLE bid_rules (
+    id TEXT PRIMARY KEY,
+    investor_id TEXT NOT NULL REFERENCES investors (id),
+    currency_code TEXT NOT NULL,
+    min_rating TEXT NOT NULL,
+    max_due_days INTEGER NOT NULL,
+    max_per_invoice_number TEXT NOT NULL,
+    max_per_invoice_currency TEXT NOT NULL,
+    max_deployed_number TEXT NOT NULL,
+    max_deployed_currency TEXT NOT NULL,
+    deployed_number TEXT NOT NULL,
+    deployed_currency TEXT NOT NULL,
+    active INTEGER NOT NULL
+);
+
+CREATE INDEX bid_rules_currency_code_idx ON bid_rules (currency_code) WHERE active = 1;
+
+CREATE TABLE rule_bids (
+    rule_id TEXT NOT NULL REFERENCES bid_rules (id),
+    bid_id TEXT PRIMARY KEY,
+    invoice_id TEXT NOT NULL,
+    amount_number TEXT NOT NULL,
+    amount_currency TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX rule_bids_rule_id_invoice_id_idx ON rule_bids (rule_id, invoice_id);
+
+CREATE TABLE payments (
+    id TEXT PRIMARY KEY,
+    investor_id TEXT NOT NULL REFERENCES investors (id),
+    kind TEXT NOT NULL,
+    amount_number TEXT NOT NULL,
+    amount_currency TEXT NOT NULL,
+    iban TEXT NOT NULL,
+    reference TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    settled_at TEXT
+);
+
+CREATE INDEX payments_investor_id_idx ON payments (investor_id, created_at);
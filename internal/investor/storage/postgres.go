@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
@@ -35,6 +36,9 @@ func (s *Storage) RetrieveInvestor(ctx context.Context, id string) (investor.Inv
 
 	inv := investor.Investor{ID: id}
 	err := s.c.QueryRow(ctx, query, id).Scan(&inv.FullName, &inv.Balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return inv, fmt.Errorf("could not retrieve investor: %w", investor.ErrNotFound)
+	}
 	if err != nil {
 		return inv, fmt.Errorf("could not retrieve investor: %w", err)
 	}
@@ -57,6 +61,7 @@ func (s *Storage) RetrieveInvestors(ctx context.Context, ids []string) ([]invest
 	if err != nil {
 		return nil, fmt.Errorf("could not retrieve investors: %w", err)
 	}
+	defer rows.Close()
 
 	var investors []investor.Investor
 	for rows.Next() {
@@ -67,6 +72,9 @@ func (s *Storage) RetrieveInvestors(ctx context.Context, ids []string) ([]invest
 
 		investors = append(investors, inv)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read investors: %w", err)
+	}
 
 	return investors, nil
 }
@@ -74,9 +82,13 @@ func (s *Storage) RetrieveInvestors(ctx context.Context, ids []string) ([]invest
 func (s *Storage) UpdateBalance(ctx context.Context, id string, balance currency.Amount) error {
 	const query = `UPDATE investors SET balance = $1 WHERE id = $2`
 
-	if _, err := s.c.Exec(ctx, query, balance, id); err != nil {
+	tag, err := s.c.Exec(ctx, query, balance, id)
+	if err != nil {
 		return fmt.Errorf("could not replace current investor balance in db: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("could not replace current investor balance: %w", investor.ErrNotFound)
+	}
 
 	return nil
 }
@@ -89,17 +101,251 @@ func (s *Storage) UpdateBalances(ctx context.Context, balances map[string]curren
 		return fmt.Errorf("could not initialize transaction: %w", err)
 	}
 
+	defer tx.Rollback(ctx)
+
 	batch := &pgx.Batch{}
 	for i, b := range balances {
 		batch.Queue(query, b, i)
 	}
 
-	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
-		err = fmt.Errorf("could not save investor balances in db: %w", err)
-		if errRB := tx.Rollback(ctx); err != nil {
-			err = fmt.Errorf("%w: %w", err, errRB)
+	results := tx.SendBatch(ctx, batch)
+	for range balances {
+		tag, err := results.Exec()
+		if err != nil {
+			results.Close()
+			return fmt.Errorf("could not save investor balances in db: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			results.Close()
+			return fmt.Errorf("could not save investor balances: %w", investor.ErrNotFound)
+		}
+	}
+	if err := results.Close(); err != nil {
+		return fmt.Errorf("could not save investor balances in db: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateRule(ctx context.Context, r investor.Rule) error {
+	const query = `INSERT INTO bid_rules (id, investor_id, currency_code, min_rating, max_due_days, max_per_invoice, max_deployed, deployed, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	if _, err := s.c.Exec(ctx, query, r.ID, r.InvestorID, r.Currency, r.MinRating, r.MaxDueDays, r.MaxPerInvoice, r.MaxDeployed, r.Deployed, r.Active); err != nil {
+		return fmt.Errorf("could not save rule in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrieveRulesByInvestorID(ctx context.Context, investorID string) ([]investor.Rule, error) {
+	const query = `SELECT r.id, r.investor_id, r.currency_code, r.min_rating, r.max_due_days, r.max_per_invoice, r.max_deployed, r.deployed, r.active
+		FROM bid_rules r WHERE r.investor_id = $1`
+
+	rows, err := s.c.Query(ctx, query, investorID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve rules: %w", err)
+	}
+
+	return scanRules(rows)
+}
+
+func (s *Storage) RetrieveActiveRules(ctx context.Context, currencyCode, invoiceID string) ([]investor.Rule, error) {
+	const query = `SELECT r.id, r.investor_id, r.currency_code, r.min_rating, r.max_due_days, r.max_per_invoice, r.max_deployed, r.deployed, r.active
+		FROM bid_rules r WHERE r.currency_code = $1 AND r.active = true
+		AND NOT EXISTS (SELECT 1 FROM rule_bids rb WHERE rb.rule_id = r.id AND rb.invoice_id = $2)`
+
+	rows, err := s.c.Query(ctx, query, currencyCode, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve rules: %w", err)
+	}
+
+	return scanRules(rows)
+}
+
+func scanRules(rows pgx.Rows) ([]investor.Rule, error) {
+	defer rows.Close()
+
+	var rules []investor.Rule
+	for rows.Next() {
+		var r investor.Rule
+		if err := rows.Scan(&r.ID, &r.InvestorID, &r.Currency, &r.MinRating, &r.MaxDueDays, &r.MaxPerInvoice, &r.MaxDeployed, &r.Deployed, &r.Active); err != nil {
+			return nil, fmt.Errorf("could not scan rules: %w", err)
+		}
+
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// SaveRuleBid records the bid of a rule and adds its amount to what the rule
+// deployed, the update only applies while it stays within the max deployed.
+func (s *Storage) SaveRuleBid(ctx context.Context, rb investor.RuleBid) error {
+	const insertQuery = `INSERT INTO rule_bids (rule_id, bid_id, invoice_id, amount, created_at) VALUES ($1, $2, $3, $4, $5)`
+	const deployQuery = `UPDATE bid_rules SET deployed = ROW((deployed).number + $2::numeric, (deployed).currency_code)
+		WHERE id = $1 AND (deployed).number + $2::numeric <= (max_deployed).number`
+
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, insertQuery, rb.RuleID, rb.BidID, rb.InvoiceID, rb.Amount, rb.CreatedAt); err != nil {
+		return fmt.Errorf("could not save rule bid in db: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, deployQuery, rb.RuleID, rb.Amount.Number())
+	if err != nil {
+		return fmt.Errorf("could not update deployed amount in db: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("could not save rule bid: %w", investor.ErrRuleExhausted)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRuleBids removes the bids and subtracts their amounts from what their
+// rules deployed
+func (s *Storage) DeleteRuleBids(ctx context.Context, bidIDs []string) error {
+	const query = `WITH deleted AS (
+			DELETE FROM rule_bids WHERE bid_id = ANY($1) RETURNING rule_id, (amount).number AS number
+		)
+		UPDATE bid_rules r SET deployed = ROW((r.deployed).number - d.number, (r.deployed).currency_code)
+		FROM (SELECT rule_id, SUM(number) AS number FROM deleted GROUP BY rule_id) d
+		WHERE r.id = d.rule_id`
+
+	if _, err := s.c.Exec(ctx, query, bidIDs); err != nil {
+		return fmt.Errorf("could not delete rule bids in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrieveRuleBidsByInvestorID(ctx context.Context, investorID string) ([]investor.RuleBid, error) {
+	const query = `SELECT rb.rule_id, rb.bid_id, rb.invoice_id, rb.amount, rb.created_at
+		FROM rule_bids rb JOIN bid_rules r ON r.id = rb.rule_id WHERE r.investor_id = $1 ORDER BY rb.created_at`
+
+	rows, err := s.c.Query(ctx, query, investorID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve rule bids: %w", err)
+	}
+	defer rows.Close()
+
+	var ruleBids []investor.RuleBid
+	for rows.Next() {
+		var rb investor.RuleBid
+		if err := rows.Scan(&rb.RuleID, &rb.BidID, &rb.InvoiceID, &rb.Amount, &rb.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan rule bids: %w", err)
+		}
+
+		ruleBids = append(ruleBids, rb)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve rule bids: %w", err)
+	}
+
+	return ruleBids, nil
+}
+
+func (s *Storage) SavePayment(ctx context.Context, p investor.Payment) error {
+	const query = `INSERT INTO payments (id, investor_id, kind, amount, iban, reference, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	if _, err := s.c.Exec(ctx, query, p.ID, p.InvestorID, p.Kind, p.Amount, p.IBAN, p.Reference, p.Status, p.CreatedAt); err != nil {
+		return fmt.Errorf("could not save payment in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrievePayment(ctx context.Context, id string) (investor.Payment, error) {
+	const query = `SELECT p.investor_id, p.kind, p.amount, p.iban, p.reference, p.status, p.created_at, p.settled_at
+		FROM payments p WHERE p.id = $1`
+
+	p := investor.Payment{ID: id}
+	err := s.c.QueryRow(ctx, query, id).Scan(&p.InvestorID, &p.Kind, &p.Amount, &p.IBAN, &p.Reference, &p.Status, &p.CreatedAt, &p.SettledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return p, fmt.Errorf("could not retrieve payment: %w", investor.ErrNotFound)
+	}
+	if err != nil {
+		return p, fmt.Errorf("could not retrieve payment: %w", err)
+	}
+
+	return p, nil
+}
+
+func (s *Storage) RetrievePaymentsByInvestorID(ctx context.Context, investorID string) ([]investor.Payment, error) {
+	const query = `SELECT p.id, p.kind, p.amount, p.iban, p.reference, p.status, p.created_at, p.settled_at
+		FROM payments p WHERE p.investor_id = $1 ORDER BY p.created_at`
+
+	rows, err := s.c.Query(ctx, query, investorID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []investor.Payment
+	for rows.Next() {
+		p := investor.Payment{InvestorID: investorID}
+		if err := rows.Scan(&p.ID, &p.Kind, &p.Amount, &p.IBAN, &p.Reference, &p.Status, &p.CreatedAt, &p.SettledAt); err != nil {
+			return nil, fmt.Errorf("could not scan payments: %w", err)
 		}
 
+		payments = append(payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// SettlePayment completes the payment and applies its delta to the balance in
+// the same transaction, leaving the payment pending if a debit does not fit
+func (s *Storage) SettlePayment(ctx context.Context, p investor.Payment, delta currency.Amount) error {
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := completePayment(ctx, tx, p); err != nil {
+		return err
+	}
+
+	if err := addBalance(ctx, tx, p.InvestorID, delta); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) AddBalance(ctx context.Context, id string, delta currency.Amount) error {
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := addBalance(ctx, tx, id, delta); err != nil {
 		return err
 	}
 
@@ -109,3 +355,98 @@ func (s *Storage) UpdateBalances(ctx context.Context, balances map[string]curren
 
 	return nil
 }
+
+func (s *Storage) TransferBalance(ctx context.Context, fromID, toID string, amount currency.Amount) error {
+	debit, err := amount.Mul("-1")
+	if err != nil {
+		return fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// the rows are updated in id order so opposite transfers never deadlock
+	ids, deltas := []string{fromID, toID}, []currency.Amount{debit, amount}
+	if toID < fromID {
+		ids[0], ids[1], deltas[0], deltas[1] = ids[1], ids[0], deltas[1], deltas[0]
+	}
+
+	for i, id := range ids {
+		if err := addBalance(ctx, tx, id, deltas[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// addBalance adds the delta to the balance in a single update, failing with
+// ErrInsufficientFunds if a debit would leave it negative
+func addBalance(ctx context.Context, tx pgx.Tx, id string, delta currency.Amount) error {
+	const (
+		updateQuery = `UPDATE investors SET balance = ROW((balance).number + $2::numeric, (balance).currency_code)::amount
+			WHERE id = $1 AND ($2::numeric >= 0 OR (balance).number + $2::numeric >= 0)`
+		existsQuery = `SELECT EXISTS (SELECT 1 FROM investors WHERE id = $1)`
+	)
+
+	tag, err := tx.Exec(ctx, updateQuery, id, delta.Number())
+	if err != nil {
+		return fmt.Errorf("could not update investor balance in db: %w", err)
+	}
+
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, existsQuery, id).Scan(&exists); err != nil {
+		return fmt.Errorf("could not retrieve investor: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("could not update investor balance: %w", investor.ErrNotFound)
+	}
+
+	return investor.ErrInsufficientFunds
+}
+
+func (s *Storage) FailPayment(ctx context.Context, p investor.Payment) error {
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := completePayment(ctx, tx, p); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// completePayment moves a payment out of pending, failing if another request
+// completed it first so its balance is never applied twice.
+func completePayment(ctx context.Context, tx pgx.Tx, p investor.Payment) error {
+	const query = `UPDATE payments SET status = $2, settled_at = $3 WHERE id = $1 AND status = 'pending'`
+
+	tag, err := tx.Exec(ctx, query, p.ID, p.Status, p.SettledAt)
+	if err != nil {
+		return fmt.Errorf("could not update payment status in db: %w", err)
+	}
+
+	if tag.RowsAffected() != 1 {
+		return fmt.Errorf("%w: payment is no longer pending", investor.ErrInvalidTransition)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/investor/storage/storagetest"
+	"github.com/nerock/invoicebidder/internal/pgtest"
+)
+
+func TestPostgresStorage(t *testing.T) {
+	pool := pgtest.New(t, "migrations")
+
+	storagetest.Run(t, func(t *testing.T) investor.Storage {
+		pgtest.Truncate(t, pool)
+		return New(pool)
+	})
+}
@@ -0,0 +1,496 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/sqlite"
+)
+
+//go:embed migrations/sqlite/*.up.sql
+var sqliteMigrations embed.FS
+
+// SQLiteStorage keeps investors in an embedded sqlite db, amounts are stored
+// as their decimal text and currency code.
+type SQLiteStorage struct {
+	c *sql.DB
+}
+
+func NewSQLiteStorage(c *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{c}
+}
+
+func (s *SQLiteStorage) Migrate(ctx context.Context) error {
+	migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	return sqlite.Migrate(ctx, s.c, migrations)
+}
+
+func (s *SQLiteStorage) CreateInvestor(ctx context.Context, inv investor.Investor) error {
+	const query = `INSERT INTO investors (id, name, balance_number, balance_currency) VALUES (?, ?, ?, ?)`
+
+	if _, err := s.c.ExecContext(ctx, query, inv.ID, inv.FullName, inv.Balance.Number(), inv.Balance.CurrencyCode()); err != nil {
+		return fmt.Errorf("could not save investor in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveInvestor(ctx context.Context, id string) (investor.Investor, error) {
+	const query = `SELECT i.name, (i.balance_number || ' ' || i.balance_currency) FROM investors i WHERE i.id = ?`
+
+	inv := investor.Investor{ID: id}
+	err := s.c.QueryRowContext(ctx, query, id).Scan(&inv.FullName, sqlite.Amount{A: &inv.Balance})
+	if errors.Is(err, sql.ErrNoRows) {
+		return inv, fmt.Errorf("could not retrieve investor: %w", investor.ErrNotFound)
+	}
+	if err != nil {
+		return inv, fmt.Errorf("could not retrieve investor: %w", err)
+	}
+
+	return inv, nil
+}
+
+func (s *SQLiteStorage) RetrieveInvestors(ctx context.Context, ids []string) ([]investor.Investor, error) {
+	query := `SELECT i.id, i.name, (i.balance_number || ' ' || i.balance_currency) FROM investors i`
+
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if len(ids) > 0 {
+		query = fmt.Sprintf("%s WHERE i.id IN (%s)", query, strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "))
+	}
+
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve investors: %w", err)
+	}
+	defer rows.Close()
+
+	var investors []investor.Investor
+	for rows.Next() {
+		var inv investor.Investor
+		if err := rows.Scan(&inv.ID, &inv.FullName, sqlite.Amount{A: &inv.Balance}); err != nil {
+			return nil, fmt.Errorf("could not scan investors: %w", err)
+		}
+
+		investors = append(investors, inv)
+	}
+
+	return investors, rows.Err()
+}
+
+func (s *SQLiteStorage) UpdateBalance(ctx context.Context, id string, balance currency.Amount) error {
+	return updateSQLiteBalance(ctx, s.c, id, balance)
+}
+
+func (s *SQLiteStorage) UpdateBalances(ctx context.Context, balances map[string]currency.Amount) error {
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, b := range balances {
+		if err := updateSQLiteBalance(ctx, tx, id, b); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) CreateRule(ctx context.Context, r investor.Rule) error {
+	const query = `INSERT INTO bid_rules (id, investor_id, currency_code, min_rating, max_due_days, max_per_invoice_number, max_per_invoice_currency,
+		max_deployed_number, max_deployed_currency, deployed_number, deployed_currency, active)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if _, err := s.c.ExecContext(ctx, query, r.ID, r.InvestorID, r.Currency, r.MinRating, r.MaxDueDays,
+		r.MaxPerInvoice.Number(), r.MaxPerInvoice.CurrencyCode(), r.MaxDeployed.Number(), r.MaxDeployed.CurrencyCode(),
+		r.Deployed.Number(), r.Deployed.CurrencyCode(), r.Active); err != nil {
+		return fmt.Errorf("could not save rule in db: %w", err)
+	}
+
+	return nil
+}
+
+const sqliteRuleColumns = `r.id, r.investor_id, r.currency_code, r.min_rating, r.max_due_days,
+	(r.max_per_invoice_number || ' ' || r.max_per_invoice_currency), (r.max_deployed_number || ' ' || r.max_deployed_currency),
+	(r.deployed_number || ' ' || r.deployed_currency), r.active`
+
+func (s *SQLiteStorage) RetrieveRulesByInvestorID(ctx context.Context, investorID string) ([]investor.Rule, error) {
+	query := fmt.Sprintf(`SELECT %s FROM bid_rules r WHERE r.investor_id = ? ORDER BY r.rowid`, sqliteRuleColumns)
+
+	return s.retrieveRules(ctx, query, investorID)
+}
+
+func (s *SQLiteStorage) RetrieveActiveRules(ctx context.Context, currencyCode, invoiceID string) ([]investor.Rule, error) {
+	query := fmt.Sprintf(`SELECT %s FROM bid_rules r WHERE r.currency_code = ? AND r.active = 1
+		AND NOT EXISTS (SELECT 1 FROM rule_bids rb WHERE rb.rule_id = r.id AND rb.invoice_id = ?) ORDER BY r.rowid`, sqliteRuleColumns)
+
+	return s.retrieveRules(ctx, query, currencyCode, invoiceID)
+}
+
+func (s *SQLiteStorage) retrieveRules(ctx context.Context, query string, args ...any) ([]investor.Rule, error) {
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []investor.Rule
+	for rows.Next() {
+		var r investor.Rule
+		if err := rows.Scan(&r.ID, &r.InvestorID, &r.Currency, &r.MinRating, &r.MaxDueDays, sqlite.Amount{A: &r.MaxPerInvoice},
+			sqlite.Amount{A: &r.MaxDeployed}, sqlite.Amount{A: &r.Deployed}, &r.Active); err != nil {
+			return nil, fmt.Errorf("could not scan rules: %w", err)
+		}
+
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// SaveRuleBid records the bid of a rule and adds its amount to what the rule
+// deployed within the max deployed. The sum is done in go, the decimal text
+// can't be added in sql without losing precision, the transaction holds the
+// write lock so no other bid changes the rule meanwhile.
+func (s *SQLiteStorage) SaveRuleBid(ctx context.Context, rb investor.RuleBid) error {
+	const (
+		insertQuery   = `INSERT INTO rule_bids (rule_id, bid_id, invoice_id, amount_number, amount_currency, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+		deployedQuery = `SELECT (r.deployed_number || ' ' || r.deployed_currency), (r.max_deployed_number || ' ' || r.max_deployed_currency)
+			FROM bid_rules r WHERE r.id = ?`
+		deployQuery = `UPDATE bid_rules SET deployed_number = ? WHERE id = ?`
+	)
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, insertQuery, rb.RuleID, rb.BidID, rb.InvoiceID, rb.Amount.Number(), rb.Amount.CurrencyCode(),
+		sqlite.FormatTime(rb.CreatedAt)); err != nil {
+		return fmt.Errorf("could not save rule bid in db: %w", err)
+	}
+
+	var deployed, maxDeployed currency.Amount
+	if err := tx.QueryRowContext(ctx, deployedQuery, rb.RuleID).Scan(sqlite.Amount{A: &deployed}, sqlite.Amount{A: &maxDeployed}); err != nil {
+		return fmt.Errorf("could not retrieve deployed amount: %w", err)
+	}
+
+	// the deployed amount keeps the currency of the rule, like the postgres
+	// update that only adds the numbers
+	amount, err := currency.NewAmount(rb.Amount.Number(), deployed.CurrencyCode())
+	if err != nil {
+		return fmt.Errorf("could not save rule bid: %w", err)
+	}
+	deployed, err = deployed.Add(amount)
+	if err != nil {
+		return fmt.Errorf("could not save rule bid: %w", err)
+	}
+	if cmp, err := deployed.Cmp(maxDeployed); err != nil || cmp > 0 {
+		return fmt.Errorf("could not save rule bid: %w", investor.ErrRuleExhausted)
+	}
+
+	if _, err := tx.ExecContext(ctx, deployQuery, deployed.Number(), rb.RuleID); err != nil {
+		return fmt.Errorf("could not update deployed amount in db: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRuleBids removes the bids and subtracts their amounts from what their
+// rules deployed, in go for the same reason as SaveRuleBid
+func (s *SQLiteStorage) DeleteRuleBids(ctx context.Context, bidIDs []string) error {
+	const (
+		deleteQuery   = `DELETE FROM rule_bids WHERE bid_id = ? RETURNING rule_id, amount_number`
+		deployedQuery = `SELECT (r.deployed_number || ' ' || r.deployed_currency) FROM bid_rules r WHERE r.id = ?`
+		deployQuery   = `UPDATE bid_rules SET deployed_number = ? WHERE id = ?`
+	)
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, bidID := range bidIDs {
+		var ruleID, number string
+		err := tx.QueryRowContext(ctx, deleteQuery, bidID).Scan(&ruleID, &number)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not delete rule bid in db: %w", err)
+		}
+
+		var deployed currency.Amount
+		if err := tx.QueryRowContext(ctx, deployedQuery, ruleID).Scan(sqlite.Amount{A: &deployed}); err != nil {
+			return fmt.Errorf("could not retrieve deployed amount: %w", err)
+		}
+
+		amount, err := currency.NewAmount(number, deployed.CurrencyCode())
+		if err != nil {
+			return fmt.Errorf("could not delete rule bid: %w", err)
+		}
+		if deployed, err = deployed.Sub(amount); err != nil {
+			return fmt.Errorf("could not delete rule bid: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, deployQuery, deployed.Number(), ruleID); err != nil {
+			return fmt.Errorf("could not update deployed amount in db: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveRuleBidsByInvestorID(ctx context.Context, investorID string) ([]investor.RuleBid, error) {
+	const query = `SELECT rb.rule_id, rb.bid_id, rb.invoice_id, (rb.amount_number || ' ' || rb.amount_currency), rb.created_at
+		FROM rule_bids rb JOIN bid_rules r ON r.id = rb.rule_id WHERE r.investor_id = ? ORDER BY rb.created_at`
+
+	rows, err := s.c.QueryContext(ctx, query, investorID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve rule bids: %w", err)
+	}
+	defer rows.Close()
+
+	var ruleBids []investor.RuleBid
+	for rows.Next() {
+		var rb investor.RuleBid
+		if err := rows.Scan(&rb.RuleID, &rb.BidID, &rb.InvoiceID, sqlite.Amount{A: &rb.Amount}, sqlite.Time{T: &rb.CreatedAt}); err != nil {
+			return nil, fmt.Errorf("could not scan rule bids: %w", err)
+		}
+
+		ruleBids = append(ruleBids, rb)
+	}
+
+	return ruleBids, rows.Err()
+}
+
+func (s *SQLiteStorage) SavePayment(ctx context.Context, p investor.Payment) error {
+	const query = `INSERT INTO payments (id, investor_id, kind, amount_number, amount_currency, iban, reference, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if _, err := s.c.ExecContext(ctx, query, p.ID, p.InvestorID, p.Kind, p.Amount.Number(), p.Amount.CurrencyCode(), p.IBAN, p.Reference,
+		p.Status, sqlite.FormatTime(p.CreatedAt)); err != nil {
+		return fmt.Errorf("could not save payment in db: %w", err)
+	}
+
+	return nil
+}
+
+const sqlitePaymentColumns = `p.id, p.investor_id, p.kind, (p.amount_number || ' ' || p.amount_currency), p.iban, p.reference, p.status, p.created_at, p.settled_at`
+
+func sqlitePaymentDest(p *investor.Payment) []any {
+	return []any{&p.ID, &p.InvestorID, &p.Kind, sqlite.Amount{A: &p.Amount}, &p.IBAN, &p.Reference, &p.Status,
+		sqlite.Time{T: &p.CreatedAt}, sqlite.NullTime{T: &p.SettledAt}}
+}
+
+func (s *SQLiteStorage) RetrievePayment(ctx context.Context, id string) (investor.Payment, error) {
+	query := fmt.Sprintf(`SELECT %s FROM payments p WHERE p.id = ?`, sqlitePaymentColumns)
+
+	p := investor.Payment{ID: id}
+	err := s.c.QueryRowContext(ctx, query, id).Scan(sqlitePaymentDest(&p)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, fmt.Errorf("could not retrieve payment: %w", investor.ErrNotFound)
+	}
+	if err != nil {
+		return p, fmt.Errorf("could not retrieve payment: %w", err)
+	}
+
+	return p, nil
+}
+
+func (s *SQLiteStorage) RetrievePaymentsByInvestorID(ctx context.Context, investorID string) ([]investor.Payment, error) {
+	query := fmt.Sprintf(`SELECT %s FROM payments p WHERE p.investor_id = ? ORDER BY p.created_at`, sqlitePaymentColumns)
+
+	rows, err := s.c.QueryContext(ctx, query, investorID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []investor.Payment
+	for rows.Next() {
+		var p investor.Payment
+		if err := rows.Scan(sqlitePaymentDest(&p)...); err != nil {
+			return nil, fmt.Errorf("could not scan payments: %w", err)
+		}
+
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+// SettlePayment completes the payment and applies its delta to the balance in
+// the same transaction, leaving the payment pending if a debit does not fit
+func (s *SQLiteStorage) SettlePayment(ctx context.Context, p investor.Payment, delta currency.Amount) error {
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := completeSQLitePayment(ctx, tx, p); err != nil {
+		return err
+	}
+
+	if err := addSQLiteBalance(ctx, tx, p.InvestorID, delta); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) AddBalance(ctx context.Context, id string, delta currency.Amount) error {
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := addSQLiteBalance(ctx, tx, id, delta); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) TransferBalance(ctx context.Context, fromID, toID string, amount currency.Amount) error {
+	debit, err := amount.Mul("-1")
+	if err != nil {
+		return fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := addSQLiteBalance(ctx, tx, fromID, debit); err != nil {
+		return err
+	}
+
+	if err := addSQLiteBalance(ctx, tx, toID, amount); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) FailPayment(ctx context.Context, p investor.Payment) error {
+	return completeSQLitePayment(ctx, s.c, p)
+}
+
+type sqlExecer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
+
+func updateSQLiteBalance(ctx context.Context, c sqlExecer, id string, balance currency.Amount) error {
+	const query = `UPDATE investors SET balance_number = ?, balance_currency = ? WHERE id = ?`
+
+	res, err := c.ExecContext(ctx, query, balance.Number(), balance.CurrencyCode(), id)
+	if err != nil {
+		return fmt.Errorf("could not replace current investor balance in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not replace current investor balance in db: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("could not replace current investor balance: %w", investor.ErrNotFound)
+	}
+
+	return nil
+}
+
+// addSQLiteBalance adds the delta to the balance within the transaction,
+// failing with ErrInsufficientFunds if a debit would leave it negative. The
+// sum is done in Go as sqlite would add the numbers as floats.
+func addSQLiteBalance(ctx context.Context, tx *sql.Tx, id string, delta currency.Amount) error {
+	const query = `SELECT (i.balance_number || ' ' || i.balance_currency) FROM investors i WHERE i.id = ?`
+
+	var balance currency.Amount
+	err := tx.QueryRowContext(ctx, query, id).Scan(sqlite.Amount{A: &balance})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("could not update investor balance: %w", investor.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("could not retrieve investor balance: %w", err)
+	}
+
+	d, err := currency.NewAmount(delta.Number(), balance.CurrencyCode())
+	if err != nil {
+		return fmt.Errorf("could not perform currency operation: %w", err)
+	}
+	balance, err = balance.Add(d)
+	if err != nil {
+		return fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	if delta.IsNegative() && balance.IsNegative() {
+		return investor.ErrInsufficientFunds
+	}
+
+	return updateSQLiteBalance(ctx, tx, id, balance)
+}
+
+func completeSQLitePayment(ctx context.Context, c sqlExecer, p investor.Payment) error {
+	const query = `UPDATE payments SET status = ?2, settled_at = ?3 WHERE id = ?1 AND status = 'pending'`
+
+	res, err := c.ExecContext(ctx, query, p.ID, p.Status, sqlite.FormatNullTime(p.SettledAt))
+	if err != nil {
+		return fmt.Errorf("could not update payment status in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update payment status in db: %w", err)
+	}
+	if n != 1 {
+		return fmt.Errorf("%w: payment is no longer pending", investor.ErrInvalidTransition)
+	}
+
+	return nil
+}
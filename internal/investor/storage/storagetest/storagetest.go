@@ -0,0 +1,586 @@
+// Package storagetest holds the behaviour every investor.Storage must have,
+// so all the backends are tested alike.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/investor"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Run checks an investor.Storage, newStorage must return an empty storage on
+// every call.
+func Run(t *testing.T, newStorage func(*testing.T) investor.Storage) {
+	Convey("investor.Storage", t, func() {
+		ctx := context.Background()
+		st := newStorage(t)
+
+		created := time.Now().UTC().Truncate(time.Millisecond)
+		So(st.CreateInvestor(ctx, investor.Investor{ID: id(1), FullName: "Ada", Balance: amount("1000.5", "EUR")}), ShouldBeNil)
+		So(st.CreateInvestor(ctx, investor.Investor{ID: id(2), FullName: "Grace", Balance: amount("200", "EUR")}), ShouldBeNil)
+		So(st.CreateInvestor(ctx, investor.Investor{ID: id(3), FullName: "Linus", Balance: amount("0", "USD")}), ShouldBeNil)
+
+		Convey("when an investor is retrieved", func() {
+			inv, err := st.RetrieveInvestor(ctx, id(1))
+			So(err, ShouldBeNil)
+
+			Convey("return it as it was saved", func() {
+				So(inv.FullName, ShouldEqual, "Ada")
+				So(inv.Balance.Equal(amount("1000.5", "EUR")), ShouldBeTrue)
+			})
+		})
+
+		Convey("when nothing was saved under an id", func() {
+			_, errInvestor := st.RetrieveInvestor(ctx, id(99))
+			_, errPayment := st.RetrievePayment(ctx, id(99))
+
+			Convey("return not found", func() {
+				So(errors.Is(errInvestor, investor.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errPayment, investor.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when investors are retrieved", func() {
+			Convey("by ids, return only the ones that exist", func() {
+				investors, err := st.RetrieveInvestors(ctx, []string{id(2), id(99)})
+				So(err, ShouldBeNil)
+				So(sortedIDs(investors), ShouldResemble, []string{id(2)})
+			})
+
+			Convey("without ids, return all of them", func() {
+				investors, err := st.RetrieveInvestors(ctx, nil)
+				So(err, ShouldBeNil)
+				So(sortedIDs(investors), ShouldResemble, []string{id(1), id(2), id(3)})
+			})
+		})
+
+		Convey("when balances are updated", func() {
+			So(st.UpdateBalance(ctx, id(3), amount("10", "USD")), ShouldBeNil)
+			So(st.UpdateBalances(ctx, map[string]currency.Amount{
+				id(1): amount("900.25", "EUR"),
+				id(2): amount("300", "EUR"),
+			}), ShouldBeNil)
+
+			Convey("replace every one of them", func() {
+				for investorID, balance := range map[string]currency.Amount{
+					id(1): amount("900.25", "EUR"),
+					id(2): amount("300", "EUR"),
+					id(3): amount("10", "USD"),
+				} {
+					inv, err := st.RetrieveInvestor(ctx, investorID)
+					So(err, ShouldBeNil)
+					So(inv.Balance.Equal(balance), ShouldBeTrue)
+				}
+			})
+
+			Convey("return not found for a missing investor, leaving the others", func() {
+				err := st.UpdateBalance(ctx, id(99), amount("10", "USD"))
+				So(errors.Is(err, investor.ErrNotFound), ShouldBeTrue)
+
+				err = st.UpdateBalances(ctx, map[string]currency.Amount{
+					id(1):  amount("1", "EUR"),
+					id(99): amount("1", "EUR"),
+				})
+				So(errors.Is(err, investor.ErrNotFound), ShouldBeTrue)
+
+				inv, err := st.RetrieveInvestor(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(inv.Balance.Equal(amount("900.25", "EUR")), ShouldBeTrue)
+			})
+		})
+
+		Convey("when a delta is added to a balance", func() {
+			Convey("add credits and debits that fit", func() {
+				So(st.AddBalance(ctx, id(2), amount("50.5", "EUR")), ShouldBeNil)
+				So(st.AddBalance(ctx, id(2), amount("-250.5", "EUR")), ShouldBeNil)
+
+				inv, err := st.RetrieveInvestor(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(inv.Balance.Equal(amount("0", "EUR")), ShouldBeTrue)
+			})
+
+			Convey("reject a debit over the balance and keep it", func() {
+				err := st.AddBalance(ctx, id(2), amount("-200.01", "EUR"))
+				So(errors.Is(err, investor.ErrInsufficientFunds), ShouldBeTrue)
+
+				inv, err := st.RetrieveInvestor(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(inv.Balance.Equal(amount("200", "EUR")), ShouldBeTrue)
+			})
+
+			Convey("reject a missing investor", func() {
+				err := st.AddBalance(ctx, id(99), amount("1", "EUR"))
+				So(errors.Is(err, investor.ErrNotFound), ShouldBeTrue)
+			})
+
+			Convey("never overdraw it when debited concurrently", func() {
+				debit := amount("-30", "EUR")
+				errs := make([]error, 10)
+				var wg sync.WaitGroup
+				for i := range errs {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						errs[i] = st.AddBalance(ctx, id(2), debit)
+					}(i)
+				}
+				wg.Wait()
+
+				var charged int
+				for _, err := range errs {
+					if err == nil {
+						charged++
+					} else {
+						So(errors.Is(err, investor.ErrInsufficientFunds), ShouldBeTrue)
+					}
+				}
+				So(charged, ShouldEqual, 6)
+
+				inv, err := st.RetrieveInvestor(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(inv.Balance.Equal(amount("20", "EUR")), ShouldBeTrue)
+			})
+		})
+
+		Convey("when an amount is transferred", func() {
+			Convey("move it from one balance to the other", func() {
+				So(st.TransferBalance(ctx, id(2), id(1), amount("150.25", "EUR")), ShouldBeNil)
+
+				from, err := st.RetrieveInvestor(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(from.Balance.Equal(amount("49.75", "EUR")), ShouldBeTrue)
+
+				to, err := st.RetrieveInvestor(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(to.Balance.Equal(amount("1150.75", "EUR")), ShouldBeTrue)
+			})
+
+			Convey("reject a transfer over the balance and keep both", func() {
+				err := st.TransferBalance(ctx, id(2), id(1), amount("200.01", "EUR"))
+				So(errors.Is(err, investor.ErrInsufficientFunds), ShouldBeTrue)
+
+				from, err := st.RetrieveInvestor(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(from.Balance.Equal(amount("200", "EUR")), ShouldBeTrue)
+
+				to, err := st.RetrieveInvestor(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(to.Balance.Equal(amount("1000.5", "EUR")), ShouldBeTrue)
+			})
+
+			Convey("reject a missing payee without debiting the payer", func() {
+				err := st.TransferBalance(ctx, id(2), id(99), amount("1", "EUR"))
+				So(errors.Is(err, investor.ErrNotFound), ShouldBeTrue)
+
+				from, err := st.RetrieveInvestor(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(from.Balance.Equal(amount("200", "EUR")), ShouldBeTrue)
+			})
+
+			Convey("never overdraw the payer when buying concurrently", func() {
+				price := amount("30", "EUR")
+				errs := make([]error, 10)
+				var wg sync.WaitGroup
+				for i := range errs {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						errs[i] = st.TransferBalance(ctx, id(2), id(1), price)
+					}(i)
+				}
+				wg.Wait()
+
+				var bought int
+				for _, err := range errs {
+					if err == nil {
+						bought++
+					} else {
+						So(errors.Is(err, investor.ErrInsufficientFunds), ShouldBeTrue)
+					}
+				}
+				So(bought, ShouldEqual, 6)
+
+				from, err := st.RetrieveInvestor(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(from.Balance.Equal(amount("20", "EUR")), ShouldBeTrue)
+
+				to, err := st.RetrieveInvestor(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(to.Balance.Equal(amount("1180.5", "EUR")), ShouldBeTrue)
+			})
+		})
+
+		Convey("when investors have bid rules", func() {
+			rule := investor.Rule{ID: id(11), InvestorID: id(1), Currency: "EUR", MinRating: "B", MaxDueDays: 90,
+				MaxPerInvoice: amount("100", "EUR"), MaxDeployed: amount("500", "EUR"), Deployed: amount("0", "EUR"), Active: true}
+			So(st.CreateRule(ctx, rule), ShouldBeNil)
+			So(st.CreateRule(ctx, investor.Rule{ID: id(12), InvestorID: id(1), Currency: "USD", MinRating: "A", MaxDueDays: 30,
+				MaxPerInvoice: amount("50", "USD"), MaxDeployed: amount("100", "USD"), Deployed: amount("0", "USD"), Active: true}), ShouldBeNil)
+			So(st.CreateRule(ctx, investor.Rule{ID: id(13), InvestorID: id(2), Currency: "EUR", MinRating: "C", MaxDueDays: 60,
+				MaxPerInvoice: amount("20", "EUR"), MaxDeployed: amount("40", "EUR"), Deployed: amount("0", "EUR"), Active: false}), ShouldBeNil)
+
+			Convey("return the rules of an investor as they were saved", func() {
+				rules, err := st.RetrieveRulesByInvestorID(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(rules, ShouldHaveLength, 2)
+
+				sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+				So(rules[0].Currency, ShouldEqual, "EUR")
+				So(rules[0].MinRating, ShouldEqual, "B")
+				So(rules[0].MaxDueDays, ShouldEqual, 90)
+				So(rules[0].MaxPerInvoice.Equal(rule.MaxPerInvoice), ShouldBeTrue)
+				So(rules[0].MaxDeployed.Equal(rule.MaxDeployed), ShouldBeTrue)
+				So(rules[0].Deployed.Equal(rule.Deployed), ShouldBeTrue)
+				So(rules[0].Active, ShouldBeTrue)
+			})
+
+			Convey("return only the active rules in the currency", func() {
+				rules, err := st.RetrieveActiveRules(ctx, "EUR", id(21))
+				So(err, ShouldBeNil)
+				So(rules, ShouldHaveLength, 1)
+				So(rules[0].ID, ShouldEqual, id(11))
+			})
+
+			Convey("when a rule bids", func() {
+				So(st.SaveRuleBid(ctx, investor.RuleBid{RuleID: id(11), BidID: id(31), InvoiceID: id(21), Amount: amount("75.5", "EUR"), CreatedAt: created}), ShouldBeNil)
+
+				Convey("add the amount to what it deployed", func() {
+					rules, err := st.RetrieveActiveRules(ctx, "EUR", id(22))
+					So(err, ShouldBeNil)
+					So(rules, ShouldHaveLength, 1)
+					So(rules[0].Deployed.Equal(amount("75.5", "EUR")), ShouldBeTrue)
+				})
+
+				Convey("not return it again for the same invoice", func() {
+					rules, err := st.RetrieveActiveRules(ctx, "EUR", id(21))
+					So(err, ShouldBeNil)
+					So(rules, ShouldBeEmpty)
+				})
+
+				Convey("reject a second bid on the same invoice", func() {
+					err := st.SaveRuleBid(ctx, investor.RuleBid{RuleID: id(11), BidID: id(32), InvoiceID: id(21), Amount: amount("10", "EUR"), CreatedAt: created})
+					So(err, ShouldNotBeNil)
+
+					rules, err := st.RetrieveActiveRules(ctx, "EUR", id(22))
+					So(err, ShouldBeNil)
+					So(rules[0].Deployed.Equal(amount("75.5", "EUR")), ShouldBeTrue)
+				})
+
+				Convey("reject a bid over the max deployed", func() {
+					err := st.SaveRuleBid(ctx, investor.RuleBid{RuleID: id(11), BidID: id(32), InvoiceID: id(22), Amount: amount("425", "EUR"), CreatedAt: created})
+					So(errors.Is(err, investor.ErrRuleExhausted), ShouldBeTrue)
+
+					rules, err := st.RetrieveActiveRules(ctx, "EUR", id(22))
+					So(err, ShouldBeNil)
+					So(rules[0].Deployed.Equal(amount("75.5", "EUR")), ShouldBeTrue)
+
+					ruleBids, err := st.RetrieveRuleBidsByInvestorID(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(ruleBids, ShouldHaveLength, 1)
+				})
+
+				Convey("when it is deleted", func() {
+					So(st.DeleteRuleBids(ctx, []string{id(31), id(99)}), ShouldBeNil)
+
+					Convey("subtract the amount from what it deployed", func() {
+						rules, err := st.RetrieveActiveRules(ctx, "EUR", id(21))
+						So(err, ShouldBeNil)
+						So(rules, ShouldHaveLength, 1)
+						So(rules[0].Deployed.Equal(amount("0", "EUR")), ShouldBeTrue)
+					})
+
+					Convey("do nothing when deleted again", func() {
+						So(st.DeleteRuleBids(ctx, []string{id(31)}), ShouldBeNil)
+
+						rules, err := st.RetrieveActiveRules(ctx, "EUR", id(21))
+						So(err, ShouldBeNil)
+						So(rules[0].Deployed.Equal(amount("0", "EUR")), ShouldBeTrue)
+
+						ruleBids, err := st.RetrieveRuleBidsByInvestorID(ctx, id(1))
+						So(err, ShouldBeNil)
+						So(ruleBids, ShouldBeEmpty)
+					})
+				})
+
+				Convey("return the bids of the investor in creation order", func() {
+					So(st.SaveRuleBid(ctx, investor.RuleBid{RuleID: id(11), BidID: id(32), InvoiceID: id(22), Amount: amount("10", "EUR"),
+						CreatedAt: created.Add(time.Second)}), ShouldBeNil)
+
+					ruleBids, err := st.RetrieveRuleBidsByInvestorID(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(ruleBids, ShouldHaveLength, 2)
+					So(ruleBids[0].BidID, ShouldEqual, id(31))
+					So(ruleBids[0].InvoiceID, ShouldEqual, id(21))
+					So(ruleBids[0].Amount.Equal(amount("75.5", "EUR")), ShouldBeTrue)
+					So(ruleBids[0].CreatedAt.Equal(created), ShouldBeTrue)
+					So(ruleBids[1].BidID, ShouldEqual, id(32))
+
+					ruleBids, err = st.RetrieveRuleBidsByInvestorID(ctx, id(2))
+					So(err, ShouldBeNil)
+					So(ruleBids, ShouldBeEmpty)
+				})
+			})
+
+			Convey("add up every bid when they are saved concurrently", func() {
+				const bids = 8
+
+				errs := make([]error, bids)
+				ruleBids := make([]investor.RuleBid, bids)
+				for i := range ruleBids {
+					ruleBids[i] = investor.RuleBid{RuleID: id(11), BidID: id(40 + i), InvoiceID: id(50 + i), Amount: amount("12.5", "EUR"), CreatedAt: created}
+				}
+
+				var wg sync.WaitGroup
+				for i := range ruleBids {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						errs[i] = st.SaveRuleBid(ctx, ruleBids[i])
+					}(i)
+				}
+				wg.Wait()
+
+				for _, err := range errs {
+					So(err, ShouldBeNil)
+				}
+
+				rules, err := st.RetrieveActiveRules(ctx, "EUR", id(21))
+				So(err, ShouldBeNil)
+				So(rules, ShouldHaveLength, 1)
+				So(rules[0].Deployed.Equal(amount("100", "EUR")), ShouldBeTrue)
+			})
+			Convey("never deploy more than the max when saved concurrently", func() {
+				const bids = 8
+
+				errs := make([]error, bids)
+				ruleBids := make([]investor.RuleBid, bids)
+				for i := range ruleBids {
+					ruleBids[i] = investor.RuleBid{RuleID: id(11), BidID: id(40 + i), InvoiceID: id(50 + i), Amount: amount("100", "EUR"), CreatedAt: created}
+				}
+
+				var wg sync.WaitGroup
+				for i := range ruleBids {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						errs[i] = st.SaveRuleBid(ctx, ruleBids[i])
+					}(i)
+				}
+				wg.Wait()
+
+				saved := 0
+				for _, err := range errs {
+					if err == nil {
+						saved++
+						continue
+					}
+					So(errors.Is(err, investor.ErrRuleExhausted), ShouldBeTrue)
+				}
+				So(saved, ShouldEqual, 5)
+
+				rules, err := st.RetrieveActiveRules(ctx, "EUR", id(21))
+				So(err, ShouldBeNil)
+				So(rules[0].Deployed.Equal(amount("500", "EUR")), ShouldBeTrue)
+			})
+		})
+
+		Convey("when payments are saved", func() {
+			So(st.SavePayment(ctx, investor.Payment{ID: id(61), InvestorID: id(1), Kind: investor.DEPOSIT, Amount: amount("250.75", "EUR"),
+				IBAN: "ES9121000418450200051332", Reference: "dep-1", Status: investor.PENDING, CreatedAt: created}), ShouldBeNil)
+			So(st.SavePayment(ctx, investor.Payment{ID: id(62), InvestorID: id(1), Kind: investor.WITHDRAWAL, Amount: amount("100", "EUR"),
+				IBAN: "ES9121000418450200051332", Reference: "wd-1", Status: investor.PENDING, CreatedAt: created.Add(time.Second)}), ShouldBeNil)
+
+			Convey("return them as they were saved in creation order", func() {
+				p, err := st.RetrievePayment(ctx, id(61))
+				So(err, ShouldBeNil)
+				So(p.InvestorID, ShouldEqual, id(1))
+				So(p.Kind, ShouldEqual, investor.DEPOSIT)
+				So(p.Amount.Equal(amount("250.75", "EUR")), ShouldBeTrue)
+				So(p.IBAN, ShouldEqual, "ES9121000418450200051332")
+				So(p.Reference, ShouldEqual, "dep-1")
+				So(p.Status, ShouldEqual, investor.PENDING)
+				So(p.CreatedAt.Equal(created), ShouldBeTrue)
+				So(p.SettledAt, ShouldBeNil)
+
+				payments, err := st.RetrievePaymentsByInvestorID(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(payments, ShouldHaveLength, 2)
+				So(payments[0].ID, ShouldEqual, id(61))
+				So(payments[1].ID, ShouldEqual, id(62))
+			})
+
+			Convey("reject a payment of a missing investor", func() {
+				err := st.SavePayment(ctx, investor.Payment{ID: id(63), InvestorID: id(99), Kind: investor.DEPOSIT, Amount: amount("1", "EUR"),
+					Status: investor.PENDING, CreatedAt: created})
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("when one is settled", func() {
+				settled := created.Add(time.Minute)
+				So(st.SettlePayment(ctx, investor.Payment{ID: id(61), InvestorID: id(1), Status: investor.SETTLED, SettledAt: &settled},
+					amount("250.75", "EUR")), ShouldBeNil)
+
+				Convey("update its status and the balance", func() {
+					p, err := st.RetrievePayment(ctx, id(61))
+					So(err, ShouldBeNil)
+					So(p.Status, ShouldEqual, investor.SETTLED)
+					So(p.SettledAt, ShouldNotBeNil)
+					So(p.SettledAt.Equal(settled), ShouldBeTrue)
+
+					inv, err := st.RetrieveInvestor(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(inv.Balance.Equal(amount("1251.25", "EUR")), ShouldBeTrue)
+				})
+
+				Convey("not settle or fail it again", func() {
+					err := st.SettlePayment(ctx, investor.Payment{ID: id(61), InvestorID: id(1), Status: investor.SETTLED, SettledAt: &settled},
+						amount("250.75", "EUR"))
+					So(errors.Is(err, investor.ErrInvalidTransition), ShouldBeTrue)
+
+					err = st.FailPayment(ctx, investor.Payment{ID: id(61), InvestorID: id(1), Status: investor.FAILED, SettledAt: &settled})
+					So(errors.Is(err, investor.ErrInvalidTransition), ShouldBeTrue)
+
+					inv, err := st.RetrieveInvestor(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(inv.Balance.Equal(amount("1251.25", "EUR")), ShouldBeTrue)
+				})
+			})
+
+			Convey("when a debit does not fit in the balance", func() {
+				settled := created.Add(time.Minute)
+				err := st.SettlePayment(ctx, investor.Payment{ID: id(62), InvestorID: id(1), Status: investor.SETTLED, SettledAt: &settled},
+					amount("-1000.51", "EUR"))
+
+				Convey("leave the payment pending and the balance untouched", func() {
+					So(errors.Is(err, investor.ErrInsufficientFunds), ShouldBeTrue)
+
+					p, err := st.RetrievePayment(ctx, id(62))
+					So(err, ShouldBeNil)
+					So(p.Status, ShouldEqual, investor.PENDING)
+
+					inv, err := st.RetrieveInvestor(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(inv.Balance.Equal(amount("1000.5", "EUR")), ShouldBeTrue)
+				})
+
+				Convey("settle it while it fits", func() {
+					So(st.SettlePayment(ctx, investor.Payment{ID: id(62), InvestorID: id(1), Status: investor.SETTLED, SettledAt: &settled},
+						amount("-1000.5", "EUR")), ShouldBeNil)
+
+					inv, err := st.RetrieveInvestor(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(inv.Balance.IsZero(), ShouldBeTrue)
+				})
+			})
+
+			Convey("when one fails", func() {
+				failed := created.Add(time.Minute)
+				So(st.FailPayment(ctx, investor.Payment{ID: id(62), InvestorID: id(1), Status: investor.FAILED, SettledAt: &failed}), ShouldBeNil)
+
+				Convey("keep the balance", func() {
+					p, err := st.RetrievePayment(ctx, id(62))
+					So(err, ShouldBeNil)
+					So(p.Status, ShouldEqual, investor.FAILED)
+
+					inv, err := st.RetrieveInvestor(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(inv.Balance.Equal(amount("1000.5", "EUR")), ShouldBeTrue)
+				})
+			})
+
+			Convey("apply a single settlement when settled concurrently", func() {
+				const settlers = 8
+
+				deltas := make([]currency.Amount, settlers)
+				for i := range deltas {
+					deltas[i] = amount(fmt.Sprint(1+i), "EUR")
+				}
+
+				errs := make([]error, settlers)
+				var wg sync.WaitGroup
+				for i := 0; i < settlers; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						settled := time.Now()
+						errs[i] = st.SettlePayment(ctx, investor.Payment{ID: id(61), InvestorID: id(1), Status: investor.SETTLED, SettledAt: &settled}, deltas[i])
+					}(i)
+				}
+				wg.Wait()
+
+				var succeeded []int
+				for i, err := range errs {
+					if err == nil {
+						succeeded = append(succeeded, i)
+						continue
+					}
+					So(errors.Is(err, investor.ErrInvalidTransition), ShouldBeTrue)
+				}
+				So(succeeded, ShouldHaveLength, 1)
+
+				inv, err := st.RetrieveInvestor(ctx, id(1))
+				So(err, ShouldBeNil)
+				want, _ := amount("1000.5", "EUR").Add(deltas[succeeded[0]])
+				So(inv.Balance.Equal(want), ShouldBeTrue)
+			})
+
+			Convey("apply every payment when different ones settle concurrently", func() {
+				const payments = 8
+
+				for i := 0; i < payments; i++ {
+					So(st.SavePayment(ctx, investor.Payment{ID: id(70 + i), InvestorID: id(1), Kind: investor.DEPOSIT, Amount: amount("10", "EUR"),
+						Status: investor.PENDING, CreatedAt: created}), ShouldBeNil)
+				}
+
+				deposit := amount("10", "EUR")
+				errs := make([]error, payments)
+				var wg sync.WaitGroup
+				for i := 0; i < payments; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						settled := time.Now()
+						errs[i] = st.SettlePayment(ctx, investor.Payment{ID: id(70 + i), InvestorID: id(1), Status: investor.SETTLED, SettledAt: &settled}, deposit)
+					}(i)
+				}
+				wg.Wait()
+
+				for _, err := range errs {
+					So(err, ShouldBeNil)
+				}
+
+				inv, err := st.RetrieveInvestor(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(inv.Balance.Equal(amount("1080.5", "EUR")), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+// id builds the ids like uuids, as postgres stores them in fixed width
+func id(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+func amount(n, code string) currency.Amount {
+	a, err := currency.NewAmount(n, code)
+	So(err, ShouldBeNil)
+	return a
+}
+
+func sortedIDs(investors []investor.Investor) []string {
+	var ids []string
+	for _, inv := range investors {
+		ids = append(ids, inv.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
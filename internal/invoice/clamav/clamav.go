@@ -0,0 +1,104 @@
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+// chunkSize must stay below the StreamMaxLength configured in clamd
+const chunkSize = 64 << 10
+
+// Scanner sends files to clamd over its INSTREAM command.
+type Scanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func New(network, address string, timeout time.Duration) *Scanner {
+	return &Scanner{
+		network: network,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (s *Scanner) Scan(ctx context.Context, r io.Reader) error {
+	d := net.Dialer{Timeout: s.timeout}
+	conn, err := d.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return fmt.Errorf("could not connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if s.timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+			return fmt.Errorf("could not set clamd deadline: %w", err)
+		}
+	}
+
+	if err := stream(conn, r); err != nil {
+		return fmt.Errorf("could not send file to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("could not read clamd reply: %w", err)
+	}
+
+	return result(strings.TrimRight(reply, "\x00\n"))
+}
+
+// stream writes the file as length prefixed chunks ended by an empty one
+func stream(w io.Writer, r io.Reader) error {
+	if _, err := w.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := w.Write(size); err != nil {
+				return err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(make([]byte, 4))
+	return err
+}
+
+// result parses replies like "stream: OK" or "stream: Eicar-Signature FOUND"
+func result(reply string) error {
+	status := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case status == "OK":
+		return nil
+	case strings.HasSuffix(status, " FOUND"):
+		return fmt.Errorf("%w: malware detected (%s)", invoice.ErrInvalidFile, strings.TrimSuffix(status, " FOUND"))
+	default:
+		return fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+}
@@ -0,0 +1,121 @@
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// stubClamd answers INSTREAM commands with the given reply once the stream
+// ends, handing over the received file
+func stubClamd(reply func([]byte) string) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	So(err, ShouldBeNil)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				r := bufio.NewReader(conn)
+				cmd, err := r.ReadString(0)
+				if err != nil || cmd != "zINSTREAM\x00" {
+					return
+				}
+
+				var file bytes.Buffer
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(r, size); err != nil {
+						return
+					}
+
+					n := binary.BigEndian.Uint32(size)
+					if n == 0 {
+						break
+					}
+
+					if _, err := io.CopyN(&file, r, int64(n)); err != nil {
+						return
+					}
+				}
+
+				_, _ = conn.Write([]byte(reply(file.Bytes()) + "\x00"))
+			}(conn)
+		}
+	}()
+
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func TestScanner_Scan(t *testing.T) {
+	Convey("Scan", t, func() {
+		var received []byte
+		addr, stop := stubClamd(func(file []byte) string {
+			received = file
+			if bytes.Contains(file, []byte("EICAR")) {
+				return "stream: Eicar-Test-Signature FOUND"
+			}
+			if bytes.Contains(file, []byte("BROKEN")) {
+				return "INSTREAM size limit exceeded. ERROR"
+			}
+
+			return "stream: OK"
+		})
+		defer stop()
+
+		s := New("tcp", addr, time.Second)
+
+		Convey("when the file is clean", func() {
+			content := strings.Repeat("%PDF-1.7 clean ", 10000)
+			err := s.Scan(context.Background(), strings.NewReader(content))
+
+			Convey("stream the whole file and return no error", func() {
+				So(err, ShouldBeNil)
+				So(string(received), ShouldEqual, content)
+			})
+		})
+
+		Convey("when the file is infected", func() {
+			err := s.Scan(context.Background(), strings.NewReader("EICAR"))
+
+			Convey("return an invalid file error naming the signature", func() {
+				So(errors.Is(err, invoice.ErrInvalidFile), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "Eicar-Test-Signature")
+			})
+		})
+
+		Convey("when clamd fails", func() {
+			err := s.Scan(context.Background(), strings.NewReader("BROKEN"))
+
+			Convey("return an error that is not an invalid file", func() {
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, invoice.ErrInvalidFile), ShouldBeFalse)
+			})
+		})
+
+		Convey("when clamd is unreachable", func() {
+			stop()
+			err := s.Scan(context.Background(), strings.NewReader("file"))
+
+			Convey("return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
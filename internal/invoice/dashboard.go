@@ -0,0 +1,100 @@
+package invoice
+
+import (
+	"sort"
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+type StatusSummary struct {
+	Count int
+	Value map[string]currency.Amount
+}
+
+type Dashboard struct {
+	Invoices           []Invoice
+	ByStatus           map[Status]StatusSummary
+	AverageFundingTime time.Duration
+	ApprovalRate       float64
+	CashReceived       map[string]currency.Amount
+	UpcomingMaturities []Invoice
+}
+
+// NewDashboard aggregates the invoices of an issuer. Funding time goes from
+// creation until the invoice is fully funded and the approval rate compares
+// the approved trades with every trade decision, rejections included.
+func NewDashboard(invoices []Invoice, now time.Time) Dashboard {
+	d := Dashboard{
+		Invoices:     invoices,
+		ByStatus:     make(map[Status]StatusSummary),
+		CashReceived: make(map[string]currency.Amount),
+	}
+
+	var funded int
+	var fundingTime time.Duration
+	var approved, decisions int
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, inv := range invoices {
+		summary, ok := d.ByStatus[inv.Status]
+		if !ok {
+			summary.Value = make(map[string]currency.Amount)
+		}
+		summary.Count++
+		addAmount(summary.Value, inv.Price)
+		d.ByStatus[inv.Status] = summary
+
+		if inv.FundedAt != nil {
+			funded++
+			fundingTime += inv.FundedAt.Sub(inv.CreatedAt)
+		}
+
+		decisions += inv.Rejections
+		if inv.Status == TRADED {
+			approved++
+			decisions++
+			addAmount(d.CashReceived, inv.Price)
+
+			if !inv.DueDate.Before(today) {
+				d.UpcomingMaturities = append(d.UpcomingMaturities, inv)
+			}
+		}
+	}
+
+	if funded > 0 {
+		d.AverageFundingTime = fundingTime / time.Duration(funded)
+	}
+
+	if decisions > 0 {
+		d.ApprovalRate = float64(approved) / float64(decisions)
+	}
+
+	sort.Slice(d.UpcomingMaturities, func(i, j int) bool {
+		return d.UpcomingMaturities[i].DueDate.Before(d.UpcomingMaturities[j].DueDate)
+	})
+
+	return d
+}
+
+// DeductFees takes the fees settled on traded invoices, keyed by invoice id,
+// out of the cash received, as the issuer only gets the trade net of them.
+func (d *Dashboard) DeductFees(fees map[string]currency.Amount) {
+	for _, inv := range d.Invoices {
+		fee, ok := fees[inv.ID]
+		if inv.Status != TRADED || !ok {
+			continue
+		}
+
+		if received, ok := d.CashReceived[fee.CurrencyCode()]; ok {
+			d.CashReceived[fee.CurrencyCode()], _ = received.Sub(fee)
+		}
+	}
+}
+
+func addAmount(totals map[string]currency.Amount, a currency.Amount) {
+	if current, ok := totals[a.CurrencyCode()]; ok {
+		totals[a.CurrencyCode()], _ = current.Add(a)
+	} else {
+		totals[a.CurrencyCode()] = a
+	}
+}
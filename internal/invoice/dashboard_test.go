@@ -0,0 +1,125 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewDashboard(t *testing.T) {
+	Convey("NewDashboard", t, func() {
+		now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+		amount := func(n, code string) currency.Amount {
+			a, _ := currency.NewAmount(n, code)
+			return a
+		}
+		at := func(d time.Duration) *time.Time {
+			t := now.Add(d)
+			return &t
+		}
+
+		Convey("when the issuer has no invoices", func() {
+			d := NewDashboard(nil, now)
+
+			Convey("return empty aggregates", func() {
+				So(d.ByStatus, ShouldBeEmpty)
+				So(d.AverageFundingTime, ShouldEqual, 0)
+				So(d.ApprovalRate, ShouldEqual, 0)
+				So(d.CashReceived, ShouldBeEmpty)
+				So(d.UpcomingMaturities, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the issuer has invoices in every status", func() {
+			invoices := []Invoice{
+				{
+					ID:        "open",
+					Price:     amount("1000", "EUR"),
+					Status:    OPEN,
+					DueDate:   now.AddDate(0, 2, 0),
+					CreatedAt: now,
+					Bids:      []Bid{{Amount: amount("250", "EUR")}},
+				},
+				{
+					ID:         "locked",
+					Price:      amount("500", "EUR"),
+					Status:     LOCKED,
+					DueDate:    now.AddDate(0, 3, 0),
+					CreatedAt:  now,
+					FundedAt:   at(10 * time.Hour),
+					Rejections: 1,
+				},
+				{
+					ID:        "late",
+					Price:     amount("300", "USD"),
+					Status:    TRADED,
+					DueDate:   now.AddDate(0, 1, 0),
+					CreatedAt: now,
+					FundedAt:  at(30 * time.Hour),
+				},
+				{
+					ID:        "soon",
+					Price:     amount("700", "EUR"),
+					Status:    TRADED,
+					DueDate:   now.AddDate(0, 0, 7),
+					CreatedAt: now,
+					FundedAt:  at(20 * time.Hour),
+				},
+				{
+					ID:        "matured",
+					Price:     amount("100", "EUR"),
+					Status:    TRADED,
+					DueDate:   now.AddDate(0, 0, -1),
+					CreatedAt: now,
+				},
+			}
+			d := NewDashboard(invoices, now)
+
+			Convey("return counts and value by status", func() {
+				So(d.ByStatus[OPEN].Count, ShouldEqual, 1)
+				So(d.ByStatus[LOCKED].Count, ShouldEqual, 1)
+				So(d.ByStatus[TRADED].Count, ShouldEqual, 3)
+				So(d.ByStatus[TRADED].Value["EUR"].Equal(amount("800", "EUR")), ShouldBeTrue)
+				So(d.ByStatus[TRADED].Value["USD"].Equal(amount("300", "USD")), ShouldBeTrue)
+			})
+
+			Convey("return the average funding time of funded invoices", func() {
+				So(d.AverageFundingTime, ShouldEqual, 20*time.Hour)
+			})
+
+			Convey("return the approval rate including rejected trades", func() {
+				So(d.ApprovalRate, ShouldAlmostEqual, 0.75)
+			})
+
+			Convey("return the cash received per currency", func() {
+				So(d.CashReceived["EUR"].Equal(amount("800", "EUR")), ShouldBeTrue)
+				So(d.CashReceived["USD"].Equal(amount("300", "USD")), ShouldBeTrue)
+			})
+
+			Convey("return the cash received net of the settled fees", func() {
+				d.DeductFees(map[string]currency.Amount{
+					"soon": amount("7", "EUR"),
+					"late": amount("3", "USD"),
+					// only traded invoices were paid
+					"locked": amount("5", "EUR"),
+				})
+
+				So(d.CashReceived["EUR"].Equal(amount("793", "EUR")), ShouldBeTrue)
+				So(d.CashReceived["USD"].Equal(amount("297", "USD")), ShouldBeTrue)
+			})
+
+			Convey("return the traded invoices not yet due sorted by due date", func() {
+				So(d.UpcomingMaturities, ShouldHaveLength, 2)
+				So(d.UpcomingMaturities[0].ID, ShouldEqual, "soon")
+				So(d.UpcomingMaturities[1].ID, ShouldEqual, "late")
+			})
+
+			Convey("return the funding progress of each invoice", func() {
+				So(d.Invoices[0].RemainingPrice().Equal(amount("750", "EUR")), ShouldBeTrue)
+				So(d.Invoices[0].FundedRatio(), ShouldAlmostEqual, 0.25)
+			})
+		})
+	})
+}
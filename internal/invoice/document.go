@@ -0,0 +1,46 @@
+package invoice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Document is an open invoice file.
+type Document struct {
+	io.ReadSeekCloser
+	Size    int64
+	ModTime time.Time
+}
+
+// ErrDuplicateFile is returned when the uploaded file already backs an invoice
+var ErrDuplicateFile = errors.New("invoice file was already uploaded")
+
+type IntegrityStatus string
+
+const (
+	INTACT   IntegrityStatus = "intact"
+	MISMATCH IntegrityStatus = "mismatch"
+	MISSING  IntegrityStatus = "missing"
+	UNHASHED IntegrityStatus = "unhashed"
+)
+
+// Integrity is the result of re-hashing a stored invoice file.
+type Integrity struct {
+	InvoiceID string
+	Expected  string
+	Actual    string
+	Status    IntegrityStatus
+}
+
+func hashFile(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("could not hash file: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
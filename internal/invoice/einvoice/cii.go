@@ -0,0 +1,81 @@
+package einvoice
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+const dateLayout = "2006-01-02"
+
+// ciiDateLayout is the 102 format, the only one Factur-X allows
+const ciiDateLayout = "20060102"
+
+type ciiParty struct {
+	Name  string   `xml:"Name"`
+	TaxID []string `xml:"SpecifiedTaxRegistration>ID"`
+}
+
+type ciiInvoice struct {
+	ID        string `xml:"ExchangedDocument>ID"`
+	IssueDate string `xml:"ExchangedDocument>IssueDateTime>DateTimeString"`
+	Lines     []struct {
+		ID       string `xml:"AssociatedDocumentLineDocument>LineID"`
+		Name     string `xml:"SpecifiedTradeProduct>Name"`
+		Quantity string `xml:"SpecifiedLineTradeDelivery>BilledQuantity"`
+		Amount   string `xml:"SpecifiedLineTradeSettlement>SpecifiedTradeSettlementLineMonetarySummation>LineTotalAmount"`
+	} `xml:"SupplyChainTradeTransaction>IncludedSupplyChainTradeLineItem"`
+	Seller     ciiParty `xml:"SupplyChainTradeTransaction>ApplicableHeaderTradeAgreement>SellerTradeParty"`
+	Buyer      ciiParty `xml:"SupplyChainTradeTransaction>ApplicableHeaderTradeAgreement>BuyerTradeParty"`
+	Settlement struct {
+		Currency  string `xml:"InvoiceCurrencyCode"`
+		DueDate   string `xml:"SpecifiedTradePaymentTerms>DueDateDateTime>DateTimeString"`
+		LineTotal string `xml:"SpecifiedTradeSettlementHeaderMonetarySummation>LineTotalAmount"`
+		Payable   string `xml:"SpecifiedTradeSettlementHeaderMonetarySummation>DuePayableAmount"`
+	} `xml:"SupplyChainTradeTransaction>ApplicableHeaderTradeSettlement"`
+}
+
+func parseCII(data []byte) (Document, error) {
+	var inv ciiInvoice
+	if err := xml.Unmarshal(data, &inv); err != nil {
+		return Document{}, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+
+	v := &validator{}
+	settlement := inv.Settlement
+	code := v.require(settlement.Currency, "InvoiceCurrencyCode")
+	doc := Document{
+		Format:    CII,
+		Number:    v.require(inv.ID, "ExchangedDocument/ID"),
+		IssueDate: v.date(inv.IssueDate, ciiDateLayout, "IssueDateTime"),
+		DueDate:   v.date(settlement.DueDate, ciiDateLayout, "DueDateDateTime"),
+		Total:     v.amount(settlement.Payable, code, "DuePayableAmount"),
+		Seller:    ciiPartyOf(v, inv.Seller, "SellerTradeParty"),
+		Buyer:     ciiPartyOf(v, inv.Buyer, "BuyerTradeParty"),
+	}
+
+	// The minimum and basic wl profiles carry no lines
+	for i, l := range inv.Lines {
+		name := fmt.Sprintf("IncludedSupplyChainTradeLineItem[%d]", i+1)
+		doc.Lines = append(doc.Lines, Line{
+			ID:          v.require(l.ID, name+"/LineID"),
+			Description: v.require(l.Name, name+"/SpecifiedTradeProduct/Name"),
+			Quantity:    l.Quantity,
+			Amount:      v.amount(l.Amount, code, name+"/LineTotalAmount"),
+		})
+	}
+
+	if settlement.LineTotal != "" {
+		v.checkLines(doc.Lines, v.amount(settlement.LineTotal, code, "LineTotalAmount"))
+	}
+
+	return doc, v.err()
+}
+
+func ciiPartyOf(v *validator, p ciiParty, name string) Party {
+	party := Party{Name: v.require(p.Name, name+"/Name")}
+	if len(p.TaxID) > 0 {
+		party.TaxID = p.TaxID[0]
+	}
+
+	return party
+}
@@ -0,0 +1,175 @@
+package einvoice
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+var (
+	// ErrUnsupported is returned for files that are not structured e-invoices
+	ErrUnsupported = errors.New("not a supported e-invoice")
+	// ErrInvalid is returned for e-invoices breaking the rules of their schema
+	ErrInvalid = errors.New("invalid e-invoice")
+)
+
+type Format string
+
+const (
+	UBL     Format = "ubl"
+	CII     Format = "cii"
+	FACTURX Format = "facturx"
+)
+
+const (
+	ublSpace = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	ciiSpace = "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100"
+)
+
+// Document holds the metadata of a structured e-invoice.
+type Document struct {
+	Format    Format
+	Number    string
+	IssueDate time.Time
+	DueDate   time.Time
+	Total     currency.Amount
+	Seller    Party
+	Buyer     Party
+	Lines     []Line
+}
+
+type Party struct {
+	Name  string
+	TaxID string
+}
+
+type Line struct {
+	ID          string
+	Description string
+	Quantity    string
+	Amount      currency.Amount
+}
+
+// Parse reads UBL 2.1 or CII xml, or the CII xml embedded in a Factur-X
+// pdf. Files of any other kind return ErrUnsupported.
+func Parse(data []byte) (Document, error) {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		xmlData, err := embeddedXML(data)
+		if err != nil {
+			return Document{}, err
+		}
+
+		doc, err := Parse(xmlData)
+		if err != nil {
+			return Document{}, err
+		}
+		if doc.Format != CII {
+			return Document{}, fmt.Errorf("%w: factur-x must embed cii xml", ErrInvalid)
+		}
+
+		doc.Format = FACTURX
+		return doc, nil
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return Document{}, ErrUnsupported
+	}
+
+	switch root {
+	case xml.Name{Space: ublSpace, Local: "Invoice"}:
+		return parseUBL(data)
+	case xml.Name{Space: ciiSpace, Local: "CrossIndustryInvoice"}:
+		return parseCII(data)
+	default:
+		return Document{}, ErrUnsupported
+	}
+}
+
+// rootElement reads the name of the root element only, so malformed
+// documents are still recognised by it.
+func rootElement(data []byte) (xml.Name, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// validator collects every rule a document breaks, so they are all
+// reported at once.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) require(value, name string) string {
+	if strings.TrimSpace(value) == "" {
+		v.problems = append(v.problems, name+" is required")
+	}
+
+	return strings.TrimSpace(value)
+}
+
+func (v *validator) date(value, layout, name string) time.Time {
+	if v.require(value, name) == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(layout, strings.TrimSpace(value))
+	if err != nil {
+		v.problems = append(v.problems, name+" is not a valid date")
+	}
+
+	return t
+}
+
+func (v *validator) amount(value, code, name string) currency.Amount {
+	if v.require(value, name) == "" {
+		return currency.Amount{}
+	}
+
+	a, err := currency.NewAmount(strings.TrimSpace(value), code)
+	if err != nil {
+		v.problems = append(v.problems, fmt.Sprintf("%s is not a valid amount in %q", name, code))
+	}
+
+	return a
+}
+
+// checkLines verifies the lines add up to the declared line total.
+func (v *validator) checkLines(lines []Line, total currency.Amount) {
+	if len(lines) == 0 || total.CurrencyCode() == "" {
+		return
+	}
+
+	acc, _ := currency.NewAmount("0", total.CurrencyCode())
+	for _, l := range lines {
+		var err error
+		if acc, err = acc.Add(l.Amount); err != nil {
+			return
+		}
+	}
+
+	if cmp, err := acc.Cmp(total); err == nil && cmp != 0 {
+		v.problems = append(v.problems, fmt.Sprintf("line amounts add up to %s, not %s", acc.Number(), total.Number()))
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(v.problems, "; "))
+}
@@ -0,0 +1,116 @@
+package einvoice
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice/pdftest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParse(t *testing.T) {
+	Convey("Parse", t, func() {
+		ubl, err := os.ReadFile("testdata/ubl.xml")
+		So(err, ShouldBeNil)
+		cii, err := os.ReadFile("testdata/cii.xml")
+		So(err, ShouldBeNil)
+
+		Convey("when the file is a UBL invoice", func() {
+			doc, err := Parse(ubl)
+
+			Convey("return its metadata", func() {
+				So(err, ShouldBeNil)
+				So(doc.Format, ShouldEqual, UBL)
+				So(doc.Number, ShouldEqual, "INV-2023-001")
+				So(doc.IssueDate, ShouldEqual, time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC))
+				So(doc.DueDate, ShouldEqual, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+				So(doc.Total.String(), ShouldEqual, "1210.00 EUR")
+				So(doc.Seller, ShouldResemble, Party{Name: "ACME Ltd", TaxID: "ES B12345678"})
+				So(doc.Buyer, ShouldResemble, Party{Name: "Buyer GmbH", TaxID: "DE811907980"})
+				So(doc.Lines, ShouldHaveLength, 2)
+				So(doc.Lines[0].Description, ShouldEqual, "Consulting")
+				So(doc.Lines[0].Quantity, ShouldEqual, "8")
+				So(doc.Lines[0].Amount.String(), ShouldEqual, "800.00 EUR")
+			})
+		})
+
+		Convey("when the file is a CII invoice", func() {
+			doc, err := Parse(cii)
+
+			Convey("return its metadata", func() {
+				So(err, ShouldBeNil)
+				So(doc.Format, ShouldEqual, CII)
+				So(doc.Number, ShouldEqual, "FX-42")
+				So(doc.DueDate, ShouldEqual, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+				So(doc.Total.String(), ShouldEqual, "600.00 EUR")
+				So(doc.Seller, ShouldResemble, Party{Name: "Vendeur SARL", TaxID: "FR32123456789"})
+				So(doc.Buyer, ShouldResemble, Party{Name: "Acheteur SA"})
+				So(doc.Lines, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("when the file is a Factur-X pdf", func() {
+			doc, err := Parse(pdftest.Attachment("factur-x.xml", cii))
+
+			Convey("return the metadata of the embedded xml", func() {
+				So(err, ShouldBeNil)
+				So(doc.Format, ShouldEqual, FACTURX)
+				So(doc.Number, ShouldEqual, "FX-42")
+				So(doc.Total.String(), ShouldEqual, "600.00 EUR")
+			})
+		})
+
+		Convey("when the file is not an e-invoice", func() {
+			for name, data := range map[string][]byte{
+				"plain pdf":   pdftest.Attachment("other.xml", cii),
+				"other xml":   []byte(`<?xml version="1.0"?><note>hi</note>`),
+				"not xml":     []byte("hello"),
+				"corrupt pdf": []byte("%PDF-1.7 broken"),
+			} {
+				Convey("return unsupported for "+name, func() {
+					_, err := Parse(data)
+					So(errors.Is(err, ErrUnsupported), ShouldBeTrue)
+				})
+			}
+		})
+
+		Convey("when the e-invoice breaks its schema", func() {
+			for name, data := range map[string]string{
+				"missing id":         strings.Replace(string(ubl), "<cbc:ID>INV-2023-001</cbc:ID>", "", 1),
+				"missing due date":   strings.Replace(string(ubl), "<cbc:DueDate>2023-12-31</cbc:DueDate>", "", 1),
+				"invalid date":       strings.Replace(string(ubl), "2023-11-01", "01/11/2023", 1),
+				"invalid currency":   strings.Replace(string(ubl), ">EUR<", ">XYZ<", 1),
+				"lines do not add":   strings.Replace(string(ubl), ">200.00</cbc:LineExtensionAmount>", ">300.00</cbc:LineExtensionAmount>", 1),
+				"no lines":           string(ubl[:bytes.Index(ubl, []byte("<cac:InvoiceLine>"))]) + "</Invoice>",
+				"missing cii seller": strings.Replace(string(cii), "<ram:Name>Vendeur SARL</ram:Name>", "", 1),
+				"malformed":          string(ubl[:len(ubl)-20]),
+			} {
+				Convey("return invalid for "+name, func() {
+					_, err := Parse([]byte(data))
+					So(errors.Is(err, ErrInvalid), ShouldBeTrue)
+				})
+			}
+		})
+
+		Convey("when a Factur-X pdf embeds too large an xml", func() {
+			padded := bytes.Replace(cii, []byte("?>"), append([]byte("?><!--"), append(bytes.Repeat([]byte(" "), maxEmbeddedSize), "-->"...)...), 1)
+			_, err := Parse(pdftest.Attachment("factur-x.xml", padded))
+
+			Convey("return invalid", func() {
+				So(errors.Is(err, ErrInvalid), ShouldBeTrue)
+			})
+		})
+
+		Convey("when a Factur-X pdf embeds UBL", func() {
+			_, err := Parse(pdftest.Attachment("factur-x.xml", ubl))
+
+			Convey("return invalid", func() {
+				So(errors.Is(err, ErrInvalid), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package einvoice
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// maxEmbeddedSize caps the xml read from a pdf, since its stream may be
+// compressed far below that size
+const maxEmbeddedSize = 4 << 20
+
+// embeddedNames are the attachment names Factur-X, ZUGFeRD and XRechnung
+// use for the invoice xml
+var embeddedNames = map[string]bool{
+	"factur-x.xml":        true,
+	"zugferd-invoice.xml": true,
+	"xrechnung.xml":       true,
+}
+
+// embeddedXML returns the invoice xml attached to a pdf, or ErrUnsupported
+// for pdfs without one.
+func embeddedXML(data []byte) (xmlData []byte, err error) {
+	// The pdf reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			xmlData, err = nil, ErrUnsupported
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	spec := findEmbedded(r.Trailer().Key("Root").Key("Names").Key("EmbeddedFiles"))
+	if spec.IsNull() {
+		return nil, ErrUnsupported
+	}
+
+	rc := spec.Key("EF").Key("F").Reader()
+	defer rc.Close()
+
+	if xmlData, err = io.ReadAll(io.LimitReader(rc, maxEmbeddedSize+1)); err != nil {
+		return nil, fmt.Errorf("%w: could not read embedded xml: %s", ErrInvalid, err)
+	}
+	if len(xmlData) > maxEmbeddedSize {
+		return nil, fmt.Errorf("%w: embedded xml is larger than %d bytes", ErrInvalid, maxEmbeddedSize)
+	}
+
+	return xmlData, nil
+}
+
+// findEmbedded walks the embedded files name tree looking for the invoice
+// xml file specification.
+func findEmbedded(node pdf.Value) pdf.Value {
+	names := node.Key("Names")
+	for i := 0; i+1 < names.Len(); i += 2 {
+		if embeddedNames[strings.ToLower(names.Index(i).Text())] {
+			return names.Index(i + 1)
+		}
+	}
+
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		if spec := findEmbedded(kids.Index(i)); !spec.IsNull() {
+			return spec
+		}
+	}
+
+	return pdf.Value{}
+}
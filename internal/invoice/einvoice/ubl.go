@@ -0,0 +1,99 @@
+package einvoice
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type ublAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"currencyID,attr"`
+}
+
+type ublParty struct {
+	Name         string `xml:"Party>PartyName>Name"`
+	Registration string `xml:"Party>PartyLegalEntity>RegistrationName"`
+	TaxID        string `xml:"Party>PartyTaxScheme>CompanyID"`
+}
+
+type ublInvoice struct {
+	ID             string    `xml:"ID"`
+	IssueDate      string    `xml:"IssueDate"`
+	DueDate        string    `xml:"DueDate"`
+	PaymentDueDate string    `xml:"PaymentMeans>PaymentDueDate"`
+	Currency       string    `xml:"DocumentCurrencyCode"`
+	Supplier       ublParty  `xml:"AccountingSupplierParty"`
+	Customer       ublParty  `xml:"AccountingCustomerParty"`
+	LineTotal      ublAmount `xml:"LegalMonetaryTotal>LineExtensionAmount"`
+	Payable        ublAmount `xml:"LegalMonetaryTotal>PayableAmount"`
+	Lines          []struct {
+		ID          string    `xml:"ID"`
+		Quantity    string    `xml:"InvoicedQuantity"`
+		Amount      ublAmount `xml:"LineExtensionAmount"`
+		Name        string    `xml:"Item>Name"`
+		Description string    `xml:"Item>Description"`
+	} `xml:"InvoiceLine"`
+}
+
+func parseUBL(data []byte) (Document, error) {
+	var inv ublInvoice
+	if err := xml.Unmarshal(data, &inv); err != nil {
+		return Document{}, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+
+	v := &validator{}
+	doc := Document{
+		Format:    UBL,
+		Number:    v.require(inv.ID, "ID"),
+		IssueDate: v.date(inv.IssueDate, dateLayout, "IssueDate"),
+		Seller:    ublPartyOf(v, inv.Supplier, "AccountingSupplierParty"),
+		Buyer:     ublPartyOf(v, inv.Customer, "AccountingCustomerParty"),
+	}
+
+	code := v.require(inv.Currency, "DocumentCurrencyCode")
+	dueDate := inv.DueDate
+	if dueDate == "" {
+		dueDate = inv.PaymentDueDate
+	}
+	doc.DueDate = v.date(dueDate, dateLayout, "DueDate")
+	doc.Total = v.amount(inv.Payable.Value, code, "LegalMonetaryTotal/PayableAmount")
+	if inv.Payable.Currency != "" && inv.Payable.Currency != code {
+		v.problems = append(v.problems, "PayableAmount currency must be the DocumentCurrencyCode")
+	}
+
+	if len(inv.Lines) == 0 {
+		v.problems = append(v.problems, "at least one InvoiceLine is required")
+	}
+	for i, l := range inv.Lines {
+		name := fmt.Sprintf("InvoiceLine[%d]", i+1)
+		description := l.Name
+		if description == "" {
+			description = l.Description
+		}
+
+		doc.Lines = append(doc.Lines, Line{
+			ID:          v.require(l.ID, name+"/ID"),
+			Description: v.require(description, name+"/Item/Name"),
+			Quantity:    l.Quantity,
+			Amount:      v.amount(l.Amount.Value, code, name+"/LineExtensionAmount"),
+		})
+	}
+
+	if inv.LineTotal.Value != "" {
+		v.checkLines(doc.Lines, v.amount(inv.LineTotal.Value, code, "LegalMonetaryTotal/LineExtensionAmount"))
+	}
+
+	return doc, v.err()
+}
+
+func ublPartyOf(v *validator, p ublParty, name string) Party {
+	partyName := p.Registration
+	if partyName == "" {
+		partyName = p.Name
+	}
+
+	return Party{
+		Name:  v.require(partyName, name+" name"),
+		TaxID: p.TaxID,
+	}
+}
@@ -0,0 +1,25 @@
+package invoice
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by storages when an invoice, bid, listing or extraction does not exist
+	ErrNotFound          = errors.New("not found")
+	ErrInvoiceNotOpen    = errors.New("invoice is not open")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrCurrencyMismatch  = errors.New("currency mismatch")
+	ErrInvalidAmount     = errors.New("invalid amount")
+	// ErrInvalidCursor is returned when a page cursor was not issued by a previous page
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrNotOwner is returned when acting on a position of someone else
+	ErrNotOwner = errors.New("not the owner")
+	// ErrEInvoiceMismatch is returned when the declared values of an invoice
+	// contradict its e-invoice file
+	ErrEInvoiceMismatch = errors.New("does not match the e-invoice")
+	// ErrMissingValue is returned when a value was neither declared nor read
+	// from an e-invoice file
+	ErrMissingValue = errors.New("missing value")
+	// ErrOnHold is returned when bidding on or trading an invoice whose file
+	// contradicts it until the extraction is reviewed
+	ErrOnHold = errors.New("invoice is on hold")
+)
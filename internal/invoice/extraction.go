@@ -0,0 +1,161 @@
+package invoice
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+// TextExtractor reads the text layer of an invoice file.
+type TextExtractor interface {
+	Text(io.Reader) (string, error)
+}
+
+type Mismatch string
+
+const (
+	TOTAL_MISMATCH    Mismatch = "total"
+	CURRENCY_MISMATCH Mismatch = "currency"
+	DUE_DATE_MISMATCH Mismatch = "due_date"
+)
+
+// Extraction holds the values found in the text of an invoice file. Values
+// that could not be found are left empty.
+type Extraction struct {
+	InvoiceID   string
+	Number      string
+	IssueDate   *time.Time
+	DueDate     *time.Time
+	Total       string
+	Currency    string
+	DebtorTaxID string
+	// Mismatches are the declared values the document contradicts
+	Mismatches  []Mismatch
+	ExtractedAt time.Time
+	// ReviewedAt is when the mismatches were accepted by a reviewer
+	ReviewedAt *time.Time
+}
+
+// Flagged tells if the invoice needs a review before it is trusted. A
+// flagged invoice is held, it takes no bids and cannot be traded.
+func (e Extraction) Flagged() bool {
+	return len(e.Mismatches) > 0 && e.ReviewedAt == nil
+}
+
+var (
+	numberRe = regexp.MustCompile(`(?i)invoice\s*(?:no\.?|number|nr\.?|#)\s*[:#]?\s*([A-Z0-9][A-Z0-9/_-]*)`)
+	issueRe  = regexp.MustCompile(`(?i)(?:invoice|issue)\s+date\s*:?\s*(` + dateExpr + `)`)
+	dueRe    = regexp.MustCompile(`(?i)(?:due\s+date|payment\s+due|due)\s*:?\s*(` + dateExpr + `)`)
+	totalRe  = regexp.MustCompile(`(?i)\btotal(?:\s+(?:due|amount|payable))?\s*:?\s*([A-Z]{3}|[€$£])?\s*(\d[\d.,' ]*\d|\d)\s*([A-Z]{3}\b|[€$£])?`)
+	codeRe   = regexp.MustCompile(`(?i)currency\s*:?\s*([A-Z]{3})\b`)
+	debtorRe = regexp.MustCompile(`(?i)(?:bill\s+to|buyer|customer|client|debtor)[\s\S]{0,200}?(?:vat|tax)\s*(?:id|no\.?|number|reg\.?\s*no\.?)?\s*:?\s*([A-Z]{2}\s?[A-Z0-9]{2,13}|\d[\d-]{5,})`)
+)
+
+const dateExpr = `\d{4}-\d{2}-\d{2}|\d{1,2}[./]\d{1,2}[./]\d{4}`
+
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "2/1/2006", "02.01.2006", "2.1.2006"}
+
+var currencySymbols = map[string]string{"€": "EUR", "$": "USD", "£": "GBP"}
+
+// ParseText pulls the invoice values out of the text of a document. The
+// last total is taken, as it is usually the grand total after taxes.
+func ParseText(text string) Extraction {
+	var e Extraction
+
+	if m := numberRe.FindStringSubmatch(text); m != nil {
+		e.Number = m[1]
+	}
+	if m := issueRe.FindStringSubmatch(text); m != nil {
+		e.IssueDate = parseDate(m[1])
+	}
+	if m := dueRe.FindStringSubmatch(text); m != nil {
+		e.DueDate = parseDate(m[1])
+	}
+	if m := debtorRe.FindStringSubmatch(text); m != nil {
+		e.DebtorTaxID = strings.ReplaceAll(strings.ToUpper(m[1]), " ", "")
+	}
+
+	if all := totalRe.FindAllStringSubmatch(text, -1); all != nil {
+		m := all[len(all)-1]
+		e.Total = parseNumber(m[2])
+		e.Currency = parseCurrency(m[1], m[3])
+	}
+	if e.Currency == "" {
+		if m := codeRe.FindStringSubmatch(text); m != nil {
+			e.Currency = parseCurrency(m[1])
+		}
+	}
+
+	return e
+}
+
+// compare flags the values of the document that differ from the declared
+// ones. The document total is what the debtor owes, so it is checked against
+// the face value, or the price when the invoice has none.
+func (e *Extraction) compare(inv Invoice) {
+	e.Mismatches = nil
+
+	if e.Currency != "" && e.Currency != inv.Price.CurrencyCode() {
+		e.Mismatches = append(e.Mismatches, CURRENCY_MISMATCH)
+	}
+
+	if e.Total != "" {
+		declared := inv.FaceValue
+		if declared.IsZero() {
+			declared = inv.Price
+		}
+
+		total, err := currency.NewAmount(e.Total, declared.CurrencyCode())
+		if cmp, _ := total.Cmp(declared); err != nil || cmp != 0 {
+			e.Mismatches = append(e.Mismatches, TOTAL_MISMATCH)
+		}
+	}
+
+	if e.DueDate != nil && !sameDay(*e.DueDate, inv.DueDate) {
+		e.Mismatches = append(e.Mismatches, DUE_DATE_MISMATCH)
+	}
+}
+
+func parseDate(s string) *time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+
+	return nil
+}
+
+// parseNumber normalises an amount written with any thousands separator.
+// The last dot or comma followed by one or two digits is the decimal point.
+func parseNumber(s string) string {
+	s = strings.NewReplacer(" ", "", "'", "").Replace(s)
+
+	dec := strings.LastIndexAny(s, ".,")
+	if dec == -1 || len(s)-dec-1 > 2 {
+		return strings.NewReplacer(".", "", ",", "").Replace(s)
+	}
+
+	whole := strings.NewReplacer(".", "", ",", "").Replace(s[:dec])
+	return whole + "." + s[dec+1:]
+}
+
+func parseCurrency(candidates ...string) string {
+	for _, c := range candidates {
+		if code, ok := currencySymbols[c]; ok {
+			return code
+		}
+		if code := strings.ToUpper(c); code != "" && currency.IsValid(code) {
+			return code
+		}
+	}
+
+	return ""
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
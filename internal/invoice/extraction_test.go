@@ -0,0 +1,207 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseText(t *testing.T) {
+	Convey("ParseText", t, func() {
+		Convey("when the document has every value", func() {
+			text := "ACME Ltd VAT: GB123456789\n" +
+				"Invoice No: INV-2023-001\n" +
+				"Invoice date: 01/11/2023\n" +
+				"Due date: 2023-12-31\n" +
+				"Bill to: Buyer Corp\n" +
+				"VAT ID: DE 811907980\n" +
+				"Subtotal: 1.000,00 €\n" +
+				"Total due: 1.190,00 €\n"
+
+			Convey("extract them all", func() {
+				e := ParseText(text)
+				So(e.Number, ShouldEqual, "INV-2023-001")
+				So(*e.IssueDate, ShouldEqual, time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC))
+				So(*e.DueDate, ShouldEqual, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+				So(e.Total, ShouldEqual, "1190.00")
+				So(e.Currency, ShouldEqual, "EUR")
+				So(e.DebtorTaxID, ShouldEqual, "DE811907980")
+			})
+		})
+
+		Convey("when the amounts use other separators", func() {
+			for text, total := range map[string]string{
+				"Total: USD 1,250.5":  "1250.5",
+				"TOTAL 12 500,00 CHF": "12500.00",
+				"Total: 1,250 GBP":    "1250",
+				"Total amount: 980":   "980",
+			} {
+				Convey("parse "+text, func() {
+					So(ParseText(text).Total, ShouldEqual, total)
+				})
+			}
+		})
+
+		Convey("when the document has no values", func() {
+			Convey("leave them empty", func() {
+				So(ParseText("hello world"), ShouldResemble, Extraction{})
+			})
+		})
+	})
+}
+
+func TestExtractionCompare(t *testing.T) {
+	Convey("compare", t, func() {
+		price, _ := currency.NewAmount("1190", "EUR")
+		faceValue, _ := currency.NewAmount("1250", "EUR")
+		due := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+		inv := Invoice{Price: price, FaceValue: faceValue, DueDate: due}
+
+		Convey("when the document matches the declared values", func() {
+			e := Extraction{Total: "1250.00", Currency: "EUR", DueDate: &due}
+			e.compare(inv)
+
+			Convey("do not flag it", func() {
+				So(e.Flagged(), ShouldBeFalse)
+			})
+		})
+
+		Convey("when the document contradicts the declared values", func() {
+			other := due.AddDate(0, 1, 0)
+			e := Extraction{Total: "1200", Currency: "USD", DueDate: &other}
+			e.compare(inv)
+
+			Convey("flag every mismatch", func() {
+				So(e.Flagged(), ShouldBeTrue)
+				So(e.Mismatches, ShouldResemble, []Mismatch{CURRENCY_MISMATCH, TOTAL_MISMATCH, DUE_DATE_MISMATCH})
+			})
+		})
+
+		Convey("when the document total is the discounted price", func() {
+			e := Extraction{Total: "1190.00", Currency: "EUR", DueDate: &due}
+			e.compare(inv)
+
+			Convey("flag it against the face value", func() {
+				So(e.Mismatches, ShouldResemble, []Mismatch{TOTAL_MISMATCH})
+			})
+		})
+
+		Convey("when the invoice has no face value", func() {
+			inv.FaceValue = currency.Amount{}
+			e := Extraction{Total: "1190.00", Currency: "EUR", DueDate: &due}
+			e.compare(inv)
+
+			Convey("check the total against the price", func() {
+				So(e.Flagged(), ShouldBeFalse)
+			})
+		})
+
+		Convey("when the mismatches were reviewed", func() {
+			reviewed := time.Now()
+			e := Extraction{Mismatches: []Mismatch{TOTAL_MISMATCH}, ReviewedAt: &reviewed}
+
+			Convey("do not flag it", func() {
+				So(e.Flagged(), ShouldBeFalse)
+			})
+		})
+
+		Convey("when the document has no values", func() {
+			e := Extraction{}
+			e.compare(inv)
+
+			Convey("do not flag it", func() {
+				So(e.Flagged(), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+type holdStorage struct {
+	Storage
+
+	invoice    Invoice
+	extraction *Extraction
+	bids       []Bid
+	statuses   []Status
+}
+
+func (m *holdStorage) RetrieveInvoice(context.Context, string) (Invoice, error) {
+	return m.invoice, nil
+}
+
+func (m *holdStorage) RetrieveExtraction(context.Context, string) (Extraction, error) {
+	if m.extraction == nil {
+		return Extraction{}, fmt.Errorf("could not retrieve extraction: %w", ErrNotFound)
+	}
+
+	return *m.extraction, nil
+}
+
+func (m *holdStorage) ReviewExtraction(_ context.Context, _ string, at time.Time) error {
+	m.extraction.ReviewedAt = &at
+	return nil
+}
+
+func (m *holdStorage) SaveBid(_ context.Context, b Bid) error {
+	m.bids = append(m.bids, b)
+	return nil
+}
+
+func (m *holdStorage) DisableBidsByInvoiceID(context.Context, string) error {
+	return nil
+}
+
+func (m *holdStorage) UpdateStatus(_ context.Context, _ string, status Status) error {
+	m.statuses = append(m.statuses, status)
+	return nil
+}
+
+func TestService_Hold(t *testing.T) {
+	Convey("Hold", t, func() {
+		price, _ := currency.NewAmount("100", "EUR")
+		bid, _ := currency.NewAmount("10", "EUR")
+		st := &holdStorage{invoice: Invoice{ID: "a", Price: price, Status: OPEN}}
+		svc := NewService(st, nil, UploadPolicy{})
+		ctx := context.Background()
+
+		Convey("when the invoice was not extracted yet", func() {
+			Convey("take bids", func() {
+				So(svc.PlaceBidWithID(ctx, "b", "a", "investor", bid), ShouldBeNil)
+				So(st.bids, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("when the extraction is flagged", func() {
+			st.extraction = &Extraction{InvoiceID: "a", Mismatches: []Mismatch{TOTAL_MISMATCH}}
+
+			Convey("refuse bids", func() {
+				err := svc.PlaceBidWithID(ctx, "b", "a", "investor", bid)
+				So(errors.Is(err, ErrOnHold), ShouldBeTrue)
+				So(st.bids, ShouldBeEmpty)
+			})
+
+			Convey("refuse to approve the trade but let it be rejected", func() {
+				st.invoice.Status = LOCKED
+
+				_, err := svc.ApproveTrade(ctx, "a", true)
+				So(errors.Is(err, ErrOnHold), ShouldBeTrue)
+				So(st.statuses, ShouldBeEmpty)
+
+				_, err = svc.ApproveTrade(ctx, "a", false)
+				So(err, ShouldBeNil)
+				So(st.statuses, ShouldResemble, []Status{OPEN})
+			})
+
+			Convey("release it once reviewed", func() {
+				So(svc.ReviewExtraction(ctx, "a"), ShouldBeNil)
+				So(svc.PlaceBidWithID(ctx, "b", "a", "investor", bid), ShouldBeNil)
+				So(st.bids, ShouldHaveLength, 1)
+			})
+		})
+	})
+}
@@ -1,6 +1,8 @@
 package invoice
 
 import (
+	"time"
+
 	"github.com/bojanz/currency"
 )
 
@@ -13,9 +15,49 @@ const (
 )
 
 type Invoice struct {
-	ID       string
-	IssuerID string
-	Price    currency.Amount
-	Bids     []Bid
-	Status   Status
+	ID        string
+	IssuerID  string
+	Price     currency.Amount
+	FaceValue currency.Amount
+	DueDate   time.Time
+	Bids      []Bid
+	Status    Status
+	CreatedAt time.Time
+	FundedAt  *time.Time
+	TradedAt  *time.Time
+	// Rejections counts the trades the issuer rejected, reopening the invoice
+	Rejections int
+	// FileHash is the SHA-256 of the invoice file, which is stored under it
+	FileHash string
+	// ContentType is the media type of the invoice file, found on upload
+	ContentType string
+}
+
+// fileKey names the stored file. Invoices created before files were content
+// addressed keep theirs under the invoice id.
+func (i Invoice) fileKey() string {
+	if i.FileHash == "" {
+		return i.ID + ".pdf"
+	}
+
+	return i.FileHash
+}
+
+func (i Invoice) RemainingPrice() currency.Amount {
+	remainingPrice := i.Price
+	for _, b := range i.Bids {
+		remainingPrice, _ = remainingPrice.Sub(b.Amount)
+	}
+
+	return remainingPrice
+}
+
+// FundedRatio returns the share of the price already covered by bids
+func (i Invoice) FundedRatio() float64 {
+	funded, err := i.Price.Sub(i.RemainingPrice())
+	if err != nil {
+		return 0
+	}
+
+	return ratio(funded, i.Price)
 }
@@ -0,0 +1,30 @@
+package invoice
+
+import (
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+type ListingStatus string
+
+const (
+	LISTED ListingStatus = "listed"
+	// RESERVED listings are being paid by a buyer, they are sold once the
+	// payment goes through or listed again otherwise
+	RESERVED  ListingStatus = "reserved"
+	SOLD      ListingStatus = "sold"
+	WITHDRAWN ListingStatus = "withdrawn"
+)
+
+type Listing struct {
+	ID        string
+	BidID     string
+	InvoiceID string
+	SellerID  string
+	BuyerID   string
+	Price     currency.Amount
+	Status    ListingStatus
+	CreatedAt time.Time
+	SoldAt    *time.Time
+}
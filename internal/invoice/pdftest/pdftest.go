@@ -0,0 +1,59 @@
+// Package pdftest builds the small pdfs the invoice tests read, so they don't
+// need binary fixtures.
+package pdftest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Text writes a single page pdf with one text line per row
+func Text(lines ...string) []byte {
+	var content strings.Builder
+	content.WriteString("BT /F1 12 Tf\n")
+	for i, l := range lines {
+		fmt.Fprintf(&content, "1 0 0 1 72 %d Tm (%s) Tj\n", 720-i*20, l)
+	}
+	content.WriteString("ET")
+
+	return build(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	)
+}
+
+// Attachment writes an empty page pdf with the xml attached under the given
+// name, like a Factur-X invoice
+func Attachment(name string, xmlData []byte) []byte {
+	return build(
+		"<< /Type /Catalog /Pages 2 0 R /Names << /EmbeddedFiles << /Names [("+name+") 4 0 R] >> >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		"<< /Type /Filespec /F ("+name+") /EF << /F 5 0 R >> >>",
+		fmt.Sprintf("<< /Type /EmbeddedFile /Subtype /text#2Fxml /Length %d >>\nstream\n%s\nendstream", len(xmlData), xmlData),
+	)
+}
+
+// build numbers the objects from 1 and writes them with their xref table
+func build(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
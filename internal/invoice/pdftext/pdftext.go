@@ -0,0 +1,62 @@
+package pdftext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Extractor reads the text layer of pdfs, row by row, so values stay on the
+// same line as their labels.
+type Extractor struct{}
+
+func New() *Extractor {
+	return &Extractor{}
+}
+
+func (x *Extractor) Text(r io.Reader) (text string, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("could not read pdf: %w", err)
+	}
+
+	// The pdf reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("could not parse pdf: %v", r)
+		}
+	}()
+
+	doc, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("could not parse pdf: %w", err)
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= doc.NumPage(); i++ {
+		page := doc.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			return "", fmt.Errorf("could not read page %d: %w", i, err)
+		}
+
+		for _, row := range rows {
+			words := make([]string, 0, len(row.Content))
+			for _, t := range row.Content {
+				words = append(words, t.S)
+			}
+
+			sb.WriteString(strings.Join(words, " "))
+			sb.WriteByte('\n')
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,34 @@
+package pdftext
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/invoice/pdftest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExtractor(t *testing.T) {
+	Convey("Extractor", t, func() {
+		x := New()
+
+		Convey("when the pdf has a text layer", func() {
+			data := pdftest.Text("Invoice No: INV-2023-001", "Due date: 2023-12-31", "Total: 1,250.00 EUR")
+
+			Convey("return its text row by row", func() {
+				text, err := x.Text(bytes.NewReader(data))
+				So(err, ShouldBeNil)
+				So(text, ShouldEqual, "Invoice No: INV-2023-001\nDue date: 2023-12-31\nTotal: 1,250.00 EUR\n")
+			})
+		})
+
+		Convey("when the file is not a pdf", func() {
+			_, err := x.Text(strings.NewReader("not a pdf"))
+
+			Convey("return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
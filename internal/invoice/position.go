@@ -0,0 +1,50 @@
+package invoice
+
+import (
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+// Position is a bid held by an investor together with its invoice and the
+// price the investor paid for it, which differs from the bid amount when the
+// position was bought in the secondary market.
+type Position struct {
+	Bid     Bid
+	Invoice Invoice
+	Cost    currency.Amount
+}
+
+type Sale struct {
+	Listing Listing
+	Cost    currency.Amount
+}
+
+func (s Sale) Return() currency.Amount {
+	r, _ := s.Listing.Price.Sub(s.Cost)
+	return r
+}
+
+// costBasis returns what the holder paid for a bid before the given time: the
+// price of the last listing they bought it through, or the bid amount if they
+// placed it themselves. Purchases are dated by when the listing was sold, not
+// when it was listed.
+func costBasis(bid Bid, listings []Listing, holderID string, before time.Time) currency.Amount {
+	cost := bid.Amount
+	var boughtAt time.Time
+	for _, l := range listings {
+		if l.Status != SOLD || l.BuyerID != holderID || l.SoldAt == nil {
+			continue
+		}
+		if !before.IsZero() && !l.SoldAt.Before(before) {
+			continue
+		}
+		if l.SoldAt.Before(boughtAt) {
+			continue
+		}
+
+		cost, boughtAt = l.Price, *l.SoldAt
+	}
+
+	return cost
+}
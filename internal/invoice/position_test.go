@@ -0,0 +1,49 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCostBasis(t *testing.T) {
+	Convey("costBasis", t, func() {
+		amount := func(n string) currency.Amount {
+			a, _ := currency.NewAmount(n, "EUR")
+			return a
+		}
+		at := func(day int) *time.Time {
+			t := time.Date(2023, 7, day, 0, 0, 0, 0, time.UTC)
+			return &t
+		}
+
+		bid := Bid{ID: "bid", InvestorID: "holder", Amount: amount("90")}
+
+		Convey("when the holder placed the bid", func() {
+			Convey("return the bid amount", func() {
+				So(costBasis(bid, nil, "holder", time.Time{}), ShouldEqual, amount("90"))
+			})
+		})
+
+		Convey("when the holder bought it in the market", func() {
+			listings := []Listing{
+				// listed before the holder's purchase but sold to them later
+				{BidID: "bid", BuyerID: "holder", Price: amount("95"), Status: SOLD, CreatedAt: *at(1), SoldAt: at(10)},
+				{BidID: "bid", BuyerID: "other", Price: amount("97"), Status: SOLD, CreatedAt: *at(12), SoldAt: at(13)},
+				{BidID: "bid", BuyerID: "holder", Price: amount("99"), Status: SOLD, CreatedAt: *at(14), SoldAt: at(20)},
+				{BidID: "bid", BuyerID: "holder", Price: amount("50"), Status: RESERVED, CreatedAt: *at(21)},
+			}
+
+			Convey("return the price of their last purchase", func() {
+				So(costBasis(bid, listings, "holder", time.Time{}), ShouldEqual, amount("99"))
+			})
+
+			Convey("return the price paid before a sale, by the time the listing was sold", func() {
+				So(costBasis(bid, listings, "holder", *at(12)), ShouldEqual, amount("95"))
+				So(costBasis(bid, listings, "holder", *at(10)), ShouldEqual, amount("90"))
+			})
+		})
+	})
+}
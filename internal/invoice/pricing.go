@@ -0,0 +1,99 @@
+package invoice
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+const daysInYear = 365
+
+type Pricing struct {
+	Discount       currency.Amount
+	DiscountRate   float64
+	Yield          float64
+	APR            float64
+	DaysToMaturity int
+}
+
+type BidPricing struct {
+	ExpectedPayout currency.Amount
+	ExpectedReturn currency.Amount
+	Yield          float64
+	APR            float64
+}
+
+// Pricing computes the return of buying the invoice at its price and
+// collecting its face value on the due date. The APR is annualised over at
+// least one day so overdue invoices do not divide by zero.
+func (i Invoice) Pricing(now time.Time) Pricing {
+	p := Pricing{DaysToMaturity: DaysToMaturity(i.DueDate, now)}
+
+	p.Discount, _ = i.FaceValue.Sub(i.Price)
+	p.DiscountRate = ratio(p.Discount, i.FaceValue)
+	p.Yield = ratio(p.Discount, i.Price)
+	p.APR = annualise(p.Yield, p.DaysToMaturity)
+
+	return p
+}
+
+// BidPricing computes the return of a bid, which collects the share of the
+// face value proportional to the share of the price it funded. The payout is
+// worked out in decimals so it matches what the bid will actually collect.
+func (i Invoice) BidPricing(b Bid, now time.Time) BidPricing {
+	pricing := i.Pricing(now)
+	p := BidPricing{Yield: pricing.Yield, APR: pricing.APR}
+
+	if !i.Price.IsPositive() {
+		return p
+	}
+
+	payout, err := b.Amount.Mul(i.FaceValue.Number())
+	if err != nil {
+		return p
+	}
+	payout, err = payout.Div(i.Price.Number())
+	if err != nil {
+		return p
+	}
+
+	p.ExpectedPayout = payout.Round()
+	p.ExpectedReturn, _ = p.ExpectedPayout.Sub(b.Amount)
+
+	return p
+}
+
+func DaysToMaturity(dueDate, now time.Time) int {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	due := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 0, 0, 0, 0, time.UTC)
+
+	days := int(due.Sub(today).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+
+	return days
+}
+
+func annualise(yield float64, days int) float64 {
+	if days < 1 {
+		days = 1
+	}
+
+	return yield * daysInYear / float64(days)
+}
+
+func ratio(a, b currency.Amount) float64 {
+	num, err := strconv.ParseFloat(a.Number(), 64)
+	if err != nil {
+		return 0
+	}
+
+	den, err := strconv.ParseFloat(b.Number(), 64)
+	if err != nil || den == 0 {
+		return 0
+	}
+
+	return num / den
+}
@@ -0,0 +1,81 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInvoice_Pricing(t *testing.T) {
+	Convey("Pricing", t, func() {
+		now := time.Date(2023, 7, 1, 15, 30, 0, 0, time.UTC)
+		price, _ := currency.NewAmount("950", "EUR")
+		faceValue, _ := currency.NewAmount("1000", "EUR")
+		inv := Invoice{
+			Price:     price,
+			FaceValue: faceValue,
+			DueDate:   time.Date(2023, 9, 29, 0, 0, 0, 0, time.UTC),
+		}
+
+		Convey("when the invoice is due in the future", func() {
+			p := inv.Pricing(now)
+
+			Convey("return the discount, yields and days to maturity", func() {
+				discount, _ := currency.NewAmount("50", "EUR")
+				So(p.Discount, ShouldEqual, discount)
+				So(p.DiscountRate, ShouldAlmostEqual, 0.05)
+				So(p.Yield, ShouldAlmostEqual, 50.0/950)
+				So(p.APR, ShouldAlmostEqual, 50.0/950*365/90)
+				So(p.DaysToMaturity, ShouldEqual, 90)
+			})
+		})
+
+		Convey("when the invoice is overdue", func() {
+			inv.DueDate = now.AddDate(0, 0, -10)
+			p := inv.Pricing(now)
+
+			Convey("return no days to maturity and annualise over a single day", func() {
+				So(p.DaysToMaturity, ShouldEqual, 0)
+				So(p.APR, ShouldAlmostEqual, 50.0/950*365)
+			})
+		})
+
+		Convey("when a bid funds part of the invoice", func() {
+			amount, _ := currency.NewAmount("475", "EUR")
+			p := inv.BidPricing(Bid{Amount: amount}, now)
+
+			Convey("return its share of the face value", func() {
+				payout, _ := currency.NewAmount("500", "EUR")
+				ret, _ := currency.NewAmount("25", "EUR")
+				So(p.ExpectedPayout.Equal(payout), ShouldBeTrue)
+				So(p.ExpectedReturn.Equal(ret), ShouldBeTrue)
+				So(p.APR, ShouldAlmostEqual, inv.Pricing(now).APR)
+			})
+		})
+
+		Convey("when the payout falls on half a cent", func() {
+			inv.Price, _ = currency.NewAmount("0.06", "EUR")
+			inv.FaceValue, _ = currency.NewAmount("0.69", "EUR")
+			amount, _ := currency.NewAmount("0.01", "EUR")
+			p := inv.BidPricing(Bid{Amount: amount}, now)
+
+			Convey("round it from the exact decimal", func() {
+				So(p.ExpectedPayout.Number(), ShouldEqual, "0.12")
+			})
+		})
+
+		Convey("when the bid is large", func() {
+			amount, _ := currency.NewAmount("123456789012.34", "EUR")
+			inv.Price = amount
+			inv.FaceValue, _ = currency.NewAmount("123456789012.35", "EUR")
+			p := inv.BidPricing(Bid{Amount: amount}, now)
+
+			Convey("keep every cent", func() {
+				ret, _ := currency.NewAmount("0.01", "EUR")
+				So(p.ExpectedReturn.Equal(ret), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package invoice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type SortField string
+
+const (
+	SortDueDate SortField = "due_date"
+	SortPrice   SortField = "price"
+	SortAPR     SortField = "apr"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+const cursorDateLayout = "2006-01-02"
+
+var decimalRe = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+type Query struct {
+	Status    Status
+	Currency  string
+	MinPrice  string
+	MaxPrice  string
+	IssuerID  string
+	DueAfter  time.Time
+	DueBefore time.Time
+	MinAPR    *float64
+	MaxAPR    *float64
+	Sort      SortField
+	Desc      bool
+	After     *Cursor
+	Limit     int
+}
+
+// FileQuery pages through the files of the invoices created from From until
+// To, in id order after the id After.
+type FileQuery struct {
+	From  time.Time
+	To    time.Time
+	After string
+	Limit int
+}
+
+// Cursor points to the last invoice of a page by its sort value and ID, so
+// the next page can be retrieved with a keyset condition instead of an offset.
+type Cursor struct {
+	Sort  SortField `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Value string    `json:"v"`
+	ID    string    `json:"id"`
+}
+
+func (c Cursor) String() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(js, &c); err != nil {
+		return Cursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	if err := c.Validate(); err != nil {
+		return Cursor{}, err
+	}
+
+	return c, nil
+}
+
+// Validate checks the value of the cursor can be compared with its sort
+// field, as storages use it in their queries.
+func (c Cursor) Validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("%w: missing id", ErrInvalidCursor)
+	}
+
+	switch c.Sort {
+	case SortDueDate:
+		if _, err := time.Parse(cursorDateLayout, c.Value); err != nil {
+			return fmt.Errorf("%w: invalid due date %q", ErrInvalidCursor, c.Value)
+		}
+	case SortPrice, SortAPR:
+		if !ValidDecimal(c.Value) {
+			return fmt.Errorf("%w: invalid number %q", ErrInvalidCursor, c.Value)
+		}
+	default:
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalidCursor, c.Sort)
+	}
+
+	return nil
+}
+
+// ValidDecimal reports whether s is a plain decimal number, rejecting the
+// exponents, hex and special values strconv would accept
+func ValidDecimal(s string) bool {
+	return decimalRe.MatchString(s)
+}
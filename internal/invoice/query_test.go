@@ -0,0 +1,54 @@
+package invoice
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseCursor(t *testing.T) {
+	Convey("ParseCursor", t, func() {
+		Convey("when the cursor round trips", func() {
+			c := Cursor{Sort: SortPrice, Desc: true, Value: "950.50", ID: "id"}
+			parsed, err := ParseCursor(c.String())
+
+			Convey("return the same cursor", func() {
+				So(err, ShouldBeNil)
+				So(parsed, ShouldResemble, c)
+			})
+		})
+
+		Convey("when the cursor is malformed", func() {
+			for _, s := range []string{
+				"not base64!",
+				"bm90IGpzb24",
+				Cursor{Sort: SortDueDate, Value: "2023-09-29"}.String(),
+				Cursor{Sort: SortDueDate, Value: "yesterday", ID: "id"}.String(),
+				Cursor{Sort: SortPrice, Value: "0x10", ID: "id"}.String(),
+				Cursor{Sort: SortAPR, Value: "NaN", ID: "id"}.String(),
+				Cursor{Sort: "face_value", Value: "10", ID: "id"}.String(),
+			} {
+				_, err := ParseCursor(s)
+
+				So(errors.Is(err, ErrInvalidCursor), ShouldBeTrue)
+			}
+		})
+	})
+}
+
+func TestValidDecimal(t *testing.T) {
+	Convey("ValidDecimal", t, func() {
+		Convey("accept plain decimals", func() {
+			for _, s := range []string{"0", "10", "-3", "950.50", "0.00000000000000000012"} {
+				So(ValidDecimal(s), ShouldBeTrue)
+			}
+		})
+
+		Convey("reject what strconv would also parse", func() {
+			for _, s := range []string{"", "1e3", "0x10", "0x1p-2", "Inf", "+Inf", "NaN", "1_000", ".5", "5."} {
+				So(ValidDecimal(s), ShouldBeFalse)
+			}
+		})
+	})
+}
@@ -0,0 +1,258 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StoredFile is a file found in the file storage, either committed under its
+// key or staged under an invoice id.
+type StoredFile struct {
+	Key     string
+	ModTime time.Time
+}
+
+// Reconciliation reports what a sweep did to bring invoices and files back
+// in line after interrupted creations.
+type Reconciliation struct {
+	// Committed are invoices whose staged file was committed
+	Committed []string
+	// Removed are open invoices without bids removed as they had no file
+	Removed []string
+	// Unresolved are invoices without a file that cannot be removed
+	Unresolved []string
+	// Discarded are staged files no invoice is waiting for
+	Discarded []string
+	// Deleted are committed files no invoice refers to
+	Deleted []string
+}
+
+// fileBatch is how many invoices or files are checked against the storage
+// at once.
+const fileBatch = 500
+
+// Reconcile rolls interrupted invoice creations forward or back. Only the
+// invoices created from since until cutoff are checked and only the files
+// older than cutoff are touched, so creations still in progress are left
+// alone. A zero since also looks for committed files no invoice refers to,
+// which lists the whole file storage.
+func (s *Service) Reconcile(ctx context.Context, since, cutoff time.Time) (Reconciliation, error) {
+	var rec Reconciliation
+
+	staged, err := s.fst.ListStaged()
+	if err != nil {
+		return rec, err
+	}
+
+	pending := make(map[string]bool, len(staged))
+	var ids []string
+	for _, f := range staged {
+		pending[f.Key] = true
+		if !f.ModTime.After(cutoff) {
+			ids = append(ids, f.Key)
+		}
+	}
+
+	var errs []error
+	if len(ids) > 0 {
+		invoices, err := s.st.RetrieveInvoicesByIDs(ctx, ids)
+		if err != nil {
+			return rec, err
+		}
+
+		saved := make(map[string]Invoice, len(invoices))
+		for _, inv := range invoices {
+			saved[inv.ID] = inv
+		}
+
+		for _, id := range ids {
+			inv, ok := saved[id]
+			if !ok {
+				if err := s.fst.DiscardFile(id); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+
+				delete(pending, id)
+				rec.Discarded = append(rec.Discarded, id)
+				continue
+			}
+
+			if inv.FileHash == "" {
+				continue
+			}
+
+			if err := s.fst.CommitFile(id, inv.FileHash); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			delete(pending, id)
+			rec.Committed = append(rec.Committed, id)
+		}
+	}
+
+	err = s.eachInvoiceFile(ctx, since, cutoff, func(inv Invoice) error {
+		if pending[inv.ID] {
+			return nil
+		}
+
+		missing, err := s.fileMissing(inv.fileKey())
+		if err != nil {
+			errs = append(errs, err)
+			return ctx.Err()
+		}
+		if !missing {
+			return nil
+		}
+
+		removed, err := s.st.DeleteOrphanedInvoice(ctx, inv.ID)
+		if err != nil {
+			errs = append(errs, err)
+			return ctx.Err()
+		}
+
+		if removed {
+			rec.Removed = append(rec.Removed, inv.ID)
+		} else {
+			rec.Unresolved = append(rec.Unresolved, inv.ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return rec, errors.Join(append(errs, err)...)
+	}
+
+	if since.IsZero() {
+		if err := s.deleteUnreferenced(ctx, cutoff, &rec); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return rec, errors.Join(errs...)
+}
+
+// eachInvoiceFile calls fn with every invoice created from since until
+// cutoff, loading them in batches.
+func (s *Service) eachInvoiceFile(ctx context.Context, since, cutoff time.Time, fn func(Invoice) error) error {
+	q := FileQuery{From: since, To: cutoff, Limit: fileBatch}
+	for {
+		invoices, err := s.st.RetrieveInvoiceFiles(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		for _, inv := range invoices {
+			if err := fn(inv); err != nil {
+				return err
+			}
+		}
+
+		if len(invoices) < q.Limit {
+			return nil
+		}
+		q.After = invoices[len(invoices)-1].ID
+	}
+}
+
+// fileMissing looks the file up on its own, so an invoice is only removed
+// once its file is confirmed gone and never because a listing missed it.
+func (s *Service) fileMissing(key string) (bool, error) {
+	doc, err := s.fst.OpenFile(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return false, doc.Close()
+}
+
+// deleteUnreferenced removes the committed files older than cutoff that no
+// invoice refers to. Files are listed before the invoices are looked up, and
+// creation saves the invoice before committing its file, so a listed file
+// always has its invoice.
+func (s *Service) deleteUnreferenced(ctx context.Context, cutoff time.Time, rec *Reconciliation) error {
+	files, err := s.fst.ListFiles()
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for _, f := range files {
+		if !f.ModTime.After(cutoff) {
+			keys = append(keys, f.Key)
+		}
+	}
+
+	var errs []error
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > fileBatch {
+			batch = batch[:fileBatch]
+		}
+		keys = keys[len(batch):]
+
+		referenced, err := s.referencedFiles(ctx, batch)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		for _, key := range batch {
+			if referenced[key] {
+				continue
+			}
+
+			if err := s.fst.DeleteFile(key, cutoff); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			rec.Deleted = append(rec.Deleted, key)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Service) referencedFiles(ctx context.Context, keys []string) (map[string]bool, error) {
+	var ids, hashes []string
+	for _, key := range keys {
+		id, ok := strings.CutSuffix(key, ".pdf")
+		if !ok {
+			hashes = append(hashes, key)
+			continue
+		}
+
+		if _, err := uuid.Parse(id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	invoices, err := s.st.RetrieveInvoicesByFileHashes(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) > 0 {
+		legacy, err := s.st.RetrieveInvoicesByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, legacy...)
+	}
+
+	referenced := make(map[string]bool, len(invoices))
+	for _, inv := range invoices {
+		referenced[inv.fileKey()] = true
+	}
+
+	return referenced, nil
+}
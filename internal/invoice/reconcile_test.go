@@ -0,0 +1,428 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockStorage struct {
+	Storage
+
+	invoices []Invoice
+	deleted  []string
+	bids     map[string]bool
+	saveErr  error
+	pages    int
+
+	einvoices   map[string]einvoice.Document
+	einvoiceErr error
+}
+
+func (m *mockStorage) SaveInvoice(_ context.Context, inv Invoice) error {
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+
+	m.invoices = append(m.invoices, inv)
+	return nil
+}
+
+func (m *mockStorage) SaveEInvoice(_ context.Context, id string, doc einvoice.Document) error {
+	if m.einvoiceErr != nil {
+		return m.einvoiceErr
+	}
+
+	m.einvoices[id] = doc
+	return nil
+}
+
+func (m *mockStorage) RetrieveInvoiceFiles(_ context.Context, q FileQuery) ([]Invoice, error) {
+	m.pages++
+
+	var res []Invoice
+	for _, inv := range m.invoices {
+		if inv.CreatedAt.Before(q.From) || !inv.CreatedAt.Before(q.To) || inv.ID <= q.After {
+			continue
+		}
+		res = append(res, inv)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+
+	if len(res) > q.Limit {
+		res = res[:q.Limit]
+	}
+	return res, nil
+}
+
+func (m *mockStorage) RetrieveInvoicesByIDs(_ context.Context, ids []string) ([]Invoice, error) {
+	var res []Invoice
+	for _, inv := range m.invoices {
+		for _, id := range ids {
+			if inv.ID == id {
+				res = append(res, inv)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (m *mockStorage) RetrieveInvoicesByFileHashes(_ context.Context, hashes []string) ([]Invoice, error) {
+	var res []Invoice
+	for _, inv := range m.invoices {
+		for _, hash := range hashes {
+			if inv.FileHash != "" && inv.FileHash == hash {
+				res = append(res, inv)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (m *mockStorage) DeleteOrphanedInvoice(_ context.Context, id string) (bool, error) {
+	if m.bids[id] {
+		return false, nil
+	}
+
+	m.deleted = append(m.deleted, id)
+	return true, nil
+}
+
+type mockFileStorage struct {
+	FileStorage
+
+	files     map[string]time.Time
+	staged    map[string]time.Time
+	commitErr error
+	openErr   error
+	listed    int
+}
+
+func (m *mockFileStorage) StageFile(id string, _ io.Reader) (string, error) {
+	m.staged[id] = time.Now()
+	return "hash-" + id, nil
+}
+
+func (m *mockFileStorage) CommitFile(id, hash string) error {
+	if m.commitErr != nil {
+		return m.commitErr
+	}
+
+	m.files[hash] = m.staged[id]
+	delete(m.staged, id)
+	return nil
+}
+
+func (m *mockFileStorage) DiscardFile(id string) error {
+	delete(m.staged, id)
+	return nil
+}
+
+func (m *mockFileStorage) OpenFile(key string) (Document, error) {
+	if m.openErr != nil {
+		return Document{}, m.openErr
+	}
+
+	if _, ok := m.files[key]; !ok {
+		return Document{}, fmt.Errorf("could not open file: %w", fs.ErrNotExist)
+	}
+
+	return Document{ReadSeekCloser: nopSeekCloser{strings.NewReader("")}}, nil
+}
+
+func (m *mockFileStorage) ListFiles() ([]StoredFile, error) {
+	m.listed++
+	return list(m.files), nil
+}
+
+func (m *mockFileStorage) ListStaged() ([]StoredFile, error) {
+	return list(m.staged), nil
+}
+
+func (m *mockFileStorage) DeleteFile(key string, _ time.Time) error {
+	delete(m.files, key)
+	return nil
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func list(files map[string]time.Time) []StoredFile {
+	var res []StoredFile
+	for k, t := range files {
+		res = append(res, StoredFile{Key: k, ModTime: t})
+	}
+
+	return res
+}
+
+func TestReconcile(t *testing.T) {
+	Convey("Reconcile", t, func() {
+		now := time.Now()
+		old := now.Add(-2 * time.Hour)
+		recent := now
+		cutoff := now.Add(-time.Hour)
+
+		st := &mockStorage{bids: map[string]bool{}}
+		fst := &mockFileStorage{files: map[string]time.Time{}, staged: map[string]time.Time{}}
+		svc := NewService(st, fst, UploadPolicy{})
+
+		Convey("when everything is consistent", func() {
+			st.invoices = []Invoice{{ID: "a", FileHash: "hash-a", Status: OPEN, CreatedAt: old}}
+			fst.files["hash-a"] = old
+
+			Convey("do nothing", func() {
+				rec, err := svc.Reconcile(context.Background(), time.Time{}, cutoff)
+				So(err, ShouldBeNil)
+				So(rec, ShouldResemble, Reconciliation{})
+			})
+		})
+
+		Convey("when an old invoice has its file still staged", func() {
+			st.invoices = []Invoice{{ID: "a", FileHash: "hash-a", Status: OPEN, CreatedAt: old}}
+			fst.staged["a"] = old
+
+			Convey("commit the file", func() {
+				rec, err := svc.Reconcile(context.Background(), time.Time{}, cutoff)
+				So(err, ShouldBeNil)
+				So(rec.Committed, ShouldResemble, []string{"a"})
+				So(fst.files, ShouldContainKey, "hash-a")
+				So(fst.staged, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when old invoices have no file at all", func() {
+			st.invoices = []Invoice{
+				{ID: "a", FileHash: "hash-a", Status: OPEN, CreatedAt: old},
+				{ID: "b", FileHash: "hash-b", Status: OPEN, CreatedAt: old},
+			}
+			st.bids["b"] = true
+
+			Convey("remove the ones without bids and report the rest", func() {
+				rec, err := svc.Reconcile(context.Background(), time.Time{}, cutoff)
+				So(err, ShouldBeNil)
+				So(rec.Removed, ShouldResemble, []string{"a"})
+				So(rec.Unresolved, ShouldResemble, []string{"b"})
+				So(st.deleted, ShouldResemble, []string{"a"})
+			})
+		})
+
+		Convey("when a file cannot be looked up", func() {
+			st.invoices = []Invoice{{ID: "a", FileHash: "hash-a", Status: OPEN, CreatedAt: old}}
+			fst.openErr = errors.New("storage down")
+
+			Convey("keep the invoice and report the error", func() {
+				rec, err := svc.Reconcile(context.Background(), time.Time{}, cutoff)
+				So(err, ShouldNotBeNil)
+				So(rec.Removed, ShouldBeEmpty)
+				So(st.deleted, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when there are orphaned files", func() {
+			fst.staged["gone"] = old
+			fst.staged["new"] = recent
+			fst.files["hash-gone"] = old
+			fst.files["hash-new"] = recent
+
+			Convey("remove only the ones older than the grace period", func() {
+				rec, err := svc.Reconcile(context.Background(), time.Time{}, cutoff)
+				So(err, ShouldBeNil)
+				So(rec.Discarded, ShouldResemble, []string{"gone"})
+				So(rec.Deleted, ShouldResemble, []string{"hash-gone"})
+				So(fst.staged, ShouldContainKey, "new")
+				So(fst.files, ShouldContainKey, "hash-new")
+			})
+
+			Convey("keep the committed files a traded invoice refers to", func() {
+				st.invoices = []Invoice{{ID: "t", FileHash: "hash-gone", Status: TRADED, CreatedAt: old}}
+
+				rec, err := svc.Reconcile(context.Background(), time.Time{}, cutoff)
+				So(err, ShouldBeNil)
+				So(rec.Deleted, ShouldBeEmpty)
+				So(fst.files, ShouldContainKey, "hash-gone")
+			})
+
+			Convey("leave the committed files alone on an incremental sweep", func() {
+				rec, err := svc.Reconcile(context.Background(), cutoff.Add(-time.Hour), cutoff)
+				So(err, ShouldBeNil)
+				So(rec.Discarded, ShouldResemble, []string{"gone"})
+				So(rec.Deleted, ShouldBeEmpty)
+				So(fst.listed, ShouldEqual, 0)
+			})
+		})
+
+		Convey("when an incremental sweep runs", func() {
+			st.invoices = []Invoice{
+				{ID: "a", FileHash: "hash-a", Status: OPEN, CreatedAt: old.Add(-time.Hour)},
+				{ID: "b", FileHash: "hash-b", Status: OPEN, CreatedAt: old},
+			}
+
+			Convey("check only the invoices created since the last one", func() {
+				rec, err := svc.Reconcile(context.Background(), old, cutoff)
+				So(err, ShouldBeNil)
+				So(rec.Removed, ShouldResemble, []string{"b"})
+			})
+		})
+
+		Convey("when there are more invoices than a batch", func() {
+			for i := 0; i < fileBatch+1; i++ {
+				id := fmt.Sprintf("%04d", i)
+				st.invoices = append(st.invoices, Invoice{ID: id, FileHash: "hash-" + id, Status: OPEN, CreatedAt: old})
+				fst.files["hash-"+id] = old
+			}
+
+			Convey("load them page by page", func() {
+				rec, err := svc.Reconcile(context.Background(), time.Time{}, cutoff)
+				So(err, ShouldBeNil)
+				So(rec, ShouldResemble, Reconciliation{})
+				So(st.pages, ShouldEqual, 2)
+			})
+		})
+
+		Convey("when an invoice is still being created", func() {
+			st.invoices = []Invoice{{ID: "a", FileHash: "hash-a", Status: OPEN, CreatedAt: recent}}
+			fst.staged["a"] = recent
+
+			Convey("leave it alone", func() {
+				rec, err := svc.Reconcile(context.Background(), time.Time{}, cutoff)
+				So(err, ShouldBeNil)
+				So(rec, ShouldResemble, Reconciliation{})
+				So(fst.staged, ShouldContainKey, "a")
+			})
+		})
+	})
+}
+
+func TestCreateInvoiceCommit(t *testing.T) {
+	Convey("CreateInvoice", t, func() {
+		st := &mockStorage{bids: map[string]bool{}, einvoices: map[string]einvoice.Document{}}
+		fst := &mockFileStorage{files: map[string]time.Time{}, staged: map[string]time.Time{}}
+		svc := NewService(st, fst, UploadPolicy{})
+		price, _ := currency.NewAmount("100", "EUR")
+
+		Convey("when the file is a UBL e-invoice", func() {
+			ubl, err := os.ReadFile("einvoice/testdata/ubl.xml")
+			So(err, ShouldBeNil)
+
+			inv, err := svc.CreateInvoice(context.Background(), "issuer", currency.Amount{}, currency.Amount{}, time.Time{}, bytes.NewReader(ubl))
+			So(err, ShouldBeNil)
+
+			Convey("keep its content type and save the e-invoice", func() {
+				So(inv.ContentType, ShouldEqual, "application/xml")
+				So(st.einvoices, ShouldContainKey, inv.ID)
+				So(st.einvoices[inv.ID].Seller.Name, ShouldEqual, "ACME Ltd")
+			})
+		})
+
+		Convey("when only the price is declared for an e-invoice", func() {
+			ubl, err := os.ReadFile("einvoice/testdata/ubl.xml")
+			So(err, ShouldBeNil)
+			discounted, _ := currency.NewAmount("1150", "EUR")
+
+			inv, err := svc.CreateInvoice(context.Background(), "issuer", discounted, currency.Amount{}, time.Time{}, bytes.NewReader(ubl))
+			So(err, ShouldBeNil)
+
+			Convey("fill the face value and due date from it", func() {
+				So(inv.Price.String(), ShouldEqual, "1150 EUR")
+				So(inv.FaceValue.String(), ShouldEqual, "1210.00 EUR")
+				So(inv.DueDate, ShouldEqual, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+			})
+		})
+
+		Convey("when the declared values contradict the e-invoice", func() {
+			ubl, err := os.ReadFile("einvoice/testdata/ubl.xml")
+			So(err, ShouldBeNil)
+			usd, _ := currency.NewAmount("0", "USD")
+			lower, _ := currency.NewAmount("1000", "EUR")
+
+			Convey("reject them", func() {
+				for _, declared := range []struct {
+					price, faceValue currency.Amount
+					dueDate          time.Time
+				}{
+					{price: usd},
+					{faceValue: lower},
+					{dueDate: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+				} {
+					_, err := svc.CreateInvoice(context.Background(), "issuer", declared.price, declared.faceValue, declared.dueDate, bytes.NewReader(ubl))
+					So(errors.Is(err, ErrEInvoiceMismatch), ShouldBeTrue)
+				}
+				So(st.invoices, ShouldBeEmpty)
+				So(fst.staged, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when a value is missing and the file is not an e-invoice", func() {
+			_, err := svc.CreateInvoice(context.Background(), "issuer", price, price, time.Time{}, strings.NewReader(validPDF))
+
+			Convey("reject it", func() {
+				So(errors.Is(err, ErrMissingValue), ShouldBeTrue)
+				So(st.invoices, ShouldBeEmpty)
+				So(fst.staged, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the e-invoice cannot be saved", func() {
+			ubl, err := os.ReadFile("einvoice/testdata/ubl.xml")
+			So(err, ShouldBeNil)
+			st.einvoiceErr = errors.New("db down")
+
+			Convey("remove the invoice and the staged file", func() {
+				_, err := svc.CreateInvoice(context.Background(), "issuer", currency.Amount{}, currency.Amount{}, time.Time{}, bytes.NewReader(ubl))
+				So(err, ShouldNotBeNil)
+				So(st.deleted, ShouldHaveLength, 1)
+				So(fst.staged, ShouldBeEmpty)
+				So(fst.files, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the file is xml but not an e-invoice", func() {
+			_, err := svc.CreateInvoice(context.Background(), "issuer", price, price, time.Now(), strings.NewReader(`<?xml version="1.0"?><Invoice/>`))
+
+			Convey("reject it", func() {
+				So(errors.Is(err, ErrInvalidFile), ShouldBeTrue)
+				So(st.invoices, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the file cannot be committed", func() {
+			fst.commitErr = errors.New("storage down")
+
+			Convey("remove the invoice and the staged file", func() {
+				_, err := svc.CreateInvoice(context.Background(), "issuer", price, price, time.Now(), strings.NewReader(validPDF))
+				So(err, ShouldNotBeNil)
+				So(st.deleted, ShouldHaveLength, 1)
+				So(fst.staged, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the file already backs an active invoice", func() {
+			st.saveErr = fmt.Errorf("could not save invoice: %w", ErrDuplicateFile)
+
+			Convey("reject it as a duplicate and discard the staged file", func() {
+				_, err := svc.CreateInvoice(context.Background(), "issuer", price, price, time.Now(), strings.NewReader(validPDF))
+				So(errors.Is(err, ErrDuplicateFile), ShouldBeTrue)
+				So(st.invoices, ShouldBeEmpty)
+				So(st.deleted, ShouldBeEmpty)
+				So(fst.staged, ShouldBeEmpty)
+				So(fst.files, ShouldBeEmpty)
+			})
+		})
+	})
+}
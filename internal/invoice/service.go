@@ -1,11 +1,16 @@
 package invoice
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
 
 	"github.com/bojanz/currency"
 )
@@ -13,31 +18,74 @@ import (
 type Storage interface {
 	SaveInvoice(context.Context, Invoice) error
 	RetrieveInvoice(context.Context, string) (Invoice, error)
+	RetrieveInvoicesByIDs(context.Context, []string) ([]Invoice, error)
 	RetrieveInvoicesByIssuerID(context.Context, string) ([]Invoice, error)
+	RetrieveInvoices(context.Context, Query) ([]Invoice, *Cursor, error)
+	RetrieveInvoiceFiles(context.Context, FileQuery) ([]Invoice, error)
+	RetrieveInvoicesByFileHashes(context.Context, []string) ([]Invoice, error)
+	DeleteOrphanedInvoice(context.Context, string) (bool, error)
 	RetrieveBidsByIDs(context.Context, []string) ([]Bid, error)
 	UpdateStatus(context.Context, string, Status) error
 
 	SaveBid(context.Context, Bid) error
+	RetrieveBid(context.Context, string) (Bid, error)
+	RetrieveBidsByInvestorID(context.Context, string) ([]Bid, error)
 	RetrieveActiveBidsByInvoiceID(context.Context, string) ([]Bid, error)
 	DisableBidsByInvoiceID(context.Context, string) error
+
+	SaveListing(context.Context, Listing) error
+	RetrieveListing(context.Context, string) (Listing, error)
+	RetrieveListingsByStatus(context.Context, ListingStatus) ([]Listing, error)
+	RetrieveListingsByBidIDs(context.Context, []string) ([]Listing, error)
+	RetrieveListingsBySellerID(context.Context, string, ListingStatus) ([]Listing, error)
+	UpdateListingStatus(context.Context, string, ListingStatus, ListingStatus) error
+	ReserveListing(context.Context, string, string) error
+	ReleaseListing(context.Context, string, string) error
+	TransferListing(context.Context, string, string) error
+
+	SaveExtraction(context.Context, Extraction) error
+	RetrieveExtraction(context.Context, string) (Extraction, error)
+	RetrieveFlaggedExtractions(context.Context) ([]Extraction, error)
+	ReviewExtraction(context.Context, string, time.Time) error
+
+	SaveEInvoice(context.Context, string, einvoice.Document) error
+	RetrieveEInvoice(context.Context, string) (einvoice.Document, error)
 }
 
+// FileStorage stores files content addressed, under their SHA-256 hash.
+// Files are staged under the invoice id first and only committed once the
+// invoice has been saved.
 type FileStorage interface {
-	SaveFile(string, io.Reader) error
+	StageFile(string, io.Reader) (string, error)
+	CommitFile(string, string) error
+	DiscardFile(string) error
+	OpenFile(string) (Document, error)
+	ListFiles() ([]StoredFile, error)
+	ListStaged() ([]StoredFile, error)
+	DeleteFile(string, time.Time) error
 }
 
 type Service struct {
-	st  Storage
-	fst FileStorage
+	st        Storage
+	fst       FileStorage
+	policy    UploadPolicy
+	extractor TextExtractor
 }
 
-func NewService(st Storage, fst FileStorage) *Service {
+func NewService(st Storage, fst FileStorage, policy UploadPolicy) *Service {
 	return &Service{
-		st:  st,
-		fst: fst,
+		st:     st,
+		fst:    fst,
+		policy: policy,
 	}
 }
 
+// WithExtractor enables reading the values of uploaded invoice files.
+func (s *Service) WithExtractor(x TextExtractor) *Service {
+	s.extractor = x
+	return s
+}
+
 func (s *Service) GetInvoice(ctx context.Context, id string) (Invoice, error) {
 	return s.st.RetrieveInvoice(ctx, id)
 }
@@ -46,65 +94,366 @@ func (s *Service) GetByIssuerID(ctx context.Context, issID string) ([]Invoice, e
 	return s.st.RetrieveInvoicesByIssuerID(ctx, issID)
 }
 
+func (s *Service) GetIssuerDashboard(ctx context.Context, issID string) (Dashboard, error) {
+	invoices, err := s.st.RetrieveInvoicesByIssuerID(ctx, issID)
+	if err != nil {
+		return Dashboard{}, err
+	}
+
+	return NewDashboard(invoices, time.Now()), nil
+}
+
+func (s *Service) ListInvoices(ctx context.Context, q Query) ([]Invoice, *Cursor, error) {
+	if q.Sort == "" {
+		q.Sort = SortDueDate
+	}
+
+	if q.Limit <= 0 || q.Limit > MaxLimit {
+		q.Limit = DefaultLimit
+	}
+
+	if q.After != nil {
+		if err := q.After.Validate(); err != nil {
+			return nil, nil, err
+		}
+		if q.After.Sort != q.Sort || q.After.Desc != q.Desc {
+			return nil, nil, fmt.Errorf("%w: cursor does not match the requested sort", ErrInvalidCursor)
+		}
+	}
+
+	return s.st.RetrieveInvoices(ctx, q)
+}
+
 func (s *Service) ListBidsByIDs(ctx context.Context, bidsIDs []string) ([]Bid, error) {
 	return s.st.RetrieveBidsByIDs(ctx, bidsIDs)
 }
 
-func (s *Service) CreateInvoice(ctx context.Context, issuerID string, price currency.Amount, file io.Reader) (Invoice, error) {
+// CreateInvoice saves an invoice and its file. The values left zero are taken
+// from the e-invoice of the file, if it is one, and the declared ones must
+// match it.
+func (s *Service) CreateInvoice(ctx context.Context, issuerID string, price, faceValue currency.Amount, dueDate time.Time, file io.Reader) (Invoice, error) {
 	id, err := uuid.NewUUID()
 	if err != nil {
 		return Invoice{}, fmt.Errorf("could not generate id: %w", err)
 	}
 
-	invoice := Invoice{
-		ID:       id.String(),
-		IssuerID: issuerID,
-		Price:    price,
-		Status:   OPEN,
+	data, err := s.policy.read(file)
+	if err != nil {
+		return Invoice{}, err
 	}
-	if err := s.st.SaveInvoice(ctx, invoice); err != nil {
+
+	contentType, err := s.policy.validate(ctx, data)
+	if err != nil {
 		return Invoice{}, err
 	}
 
-	if err := s.fst.SaveFile(id.String(), file); err != nil {
+	einv, err := parseEInvoice(data, contentType)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	if price, faceValue, dueDate, err = declaredValues(einv, price, faceValue, dueDate); err != nil {
+		return Invoice{}, err
+	}
+
+	if cmp, err := faceValue.Cmp(price); err != nil {
+		return Invoice{}, fmt.Errorf("%w: face value must be in %s", ErrCurrencyMismatch, price.CurrencyCode())
+	} else if cmp < 0 {
+		return Invoice{}, fmt.Errorf("%w: face value cannot be lower than the price", ErrInvalidAmount)
+	}
+
+	hash, err := s.fst.StageFile(id.String(), bytes.NewReader(data))
+	if err != nil {
 		return Invoice{}, err
 	}
 
+	invoice := Invoice{
+		ID:          id.String(),
+		IssuerID:    issuerID,
+		Price:       price,
+		FaceValue:   faceValue,
+		DueDate:     dueDate,
+		Status:      OPEN,
+		CreatedAt:   time.Now(),
+		FileHash:    hash,
+		ContentType: contentType,
+	}
+	if err := s.st.SaveInvoice(ctx, invoice); err != nil {
+		return Invoice{}, errors.Join(err, s.fst.DiscardFile(invoice.ID))
+	}
+
+	if einv != nil {
+		if err := s.st.SaveEInvoice(ctx, invoice.ID, *einv); err != nil {
+			_, delErr := s.st.DeleteOrphanedInvoice(ctx, invoice.ID)
+			return Invoice{}, errors.Join(err, delErr, s.fst.DiscardFile(invoice.ID))
+		}
+	}
+
+	// Without its file the invoice must not stay on the market
+	if err := s.fst.CommitFile(invoice.ID, hash); err != nil {
+		_, delErr := s.st.DeleteOrphanedInvoice(ctx, invoice.ID)
+		return Invoice{}, errors.Join(err, delErr, s.fst.DiscardFile(invoice.ID))
+	}
+
 	return invoice, nil
 }
 
-func (s *Service) PlaceBid(ctx context.Context, invoiceID string, investorID string, amount currency.Amount) (string, error) {
-	invoice, err := s.GetInvoice(ctx, invoiceID)
+// declaredValues checks the declared values against the e-invoice, filling
+// the zero ones from it: the face value from its total, the price from the
+// face value and the due date. Without an e-invoice the price and due date
+// are required and the face value defaults to the price.
+func declaredValues(einv *einvoice.Document, price, faceValue currency.Amount, dueDate time.Time) (currency.Amount, currency.Amount, time.Time, error) {
+	if einv == nil {
+		if price.IsZero() {
+			return price, faceValue, dueDate, fmt.Errorf("%w: price cannot be empty", ErrMissingValue)
+		}
+		if dueDate.IsZero() {
+			return price, faceValue, dueDate, fmt.Errorf("%w: due date cannot be empty", ErrMissingValue)
+		}
+		if faceValue.IsZero() {
+			faceValue = price
+		}
+
+		return price, faceValue, dueDate, nil
+	}
+
+	total := einv.Total
+	for _, declared := range []currency.Amount{price, faceValue} {
+		if code := declared.CurrencyCode(); code != "" && code != total.CurrencyCode() {
+			return price, faceValue, dueDate, fmt.Errorf("currency %w currency %s", ErrEInvoiceMismatch, total.CurrencyCode())
+		}
+	}
+
+	if faceValue.IsZero() {
+		faceValue = total
+	} else if cmp, _ := faceValue.Cmp(total); cmp != 0 {
+		return price, faceValue, dueDate, fmt.Errorf("face value %w total %s", ErrEInvoiceMismatch, total.Number())
+	}
+
+	if price.IsZero() {
+		price = faceValue
+	}
+
+	if dueDate.IsZero() {
+		dueDate = einv.DueDate
+	} else if !dueDate.Equal(einv.DueDate) {
+		return price, faceValue, dueDate, fmt.Errorf("due date %w due date %s", ErrEInvoiceMismatch, einv.DueDate.Format(time.DateOnly))
+	}
+
+	return price, faceValue, dueDate, nil
+}
+
+// GetEInvoice returns the e-invoice read from the file of an invoice, or
+// ErrNotFound if its file is not one.
+func (s *Service) GetEInvoice(ctx context.Context, id string) (einvoice.Document, error) {
+	return s.st.RetrieveEInvoice(ctx, id)
+}
+
+// GetDocument opens the file of an existing invoice, which must be closed by
+// the caller.
+func (s *Service) GetDocument(ctx context.Context, id string) (Invoice, Document, error) {
+	invoice, err := s.GetInvoice(ctx, id)
 	if err != nil {
-		return "", err
+		return Invoice{}, Document{}, err
 	}
 
-	if invoice.Status != OPEN {
-		return "", fmt.Errorf("can only place bids in open invoices")
+	doc, err := s.fst.OpenFile(invoice.fileKey())
+	if err != nil {
+		return Invoice{}, Document{}, err
+	}
+
+	return invoice, doc, nil
+}
+
+// VerifyFile re-hashes the stored file of an invoice against its recorded hash.
+func (s *Service) VerifyFile(ctx context.Context, id string) (Integrity, error) {
+	invoice, err := s.GetInvoice(ctx, id)
+	if err != nil {
+		return Integrity{}, err
+	}
+
+	return s.verify(invoice)
+}
+
+// VerifyFiles re-hashes the stored files of every invoice, stopping early if
+// the context is cancelled.
+func (s *Service) VerifyFiles(ctx context.Context) ([]Integrity, error) {
+	results := []Integrity{}
+	err := s.eachInvoiceFile(ctx, time.Time{}, time.Now(), func(invoice Invoice) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("could not verify invoice files: %w", err)
+		}
+
+		integrity, err := s.verify(invoice)
+		if err != nil {
+			return err
+		}
+
+		results = append(results, integrity)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *Service) verify(invoice Invoice) (Integrity, error) {
+	integrity := Integrity{InvoiceID: invoice.ID, Expected: invoice.FileHash}
+
+	doc, err := s.fst.OpenFile(invoice.fileKey())
+	if errors.Is(err, fs.ErrNotExist) {
+		integrity.Status = MISSING
+		return integrity, nil
+	}
+	if err != nil {
+		return Integrity{}, err
+	}
+	defer doc.Close()
+
+	if integrity.Actual, err = hashFile(doc); err != nil {
+		return Integrity{}, err
+	}
+
+	switch {
+	case integrity.Expected == "":
+		integrity.Status = UNHASHED
+	case integrity.Expected == integrity.Actual:
+		integrity.Status = INTACT
+	default:
+		integrity.Status = MISMATCH
+	}
+
+	return integrity, nil
+}
+
+// ExtractInvoice reads the values of the invoice file and flags the ones
+// that do not match what the issuer declared. Without an extractor, or for
+// files other than pdfs, there is nothing to extract.
+func (s *Service) ExtractInvoice(ctx context.Context, id string) (Extraction, error) {
+	invoice, doc, err := s.GetDocument(ctx, id)
+	if err != nil {
+		return Extraction{}, err
+	}
+	defer doc.Close()
+
+	if s.extractor == nil {
+		return Extraction{}, nil
+	}
+
+	header := make([]byte, headerWindow)
+	n, err := io.ReadFull(doc, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Extraction{}, fmt.Errorf("could not read file: %w", err)
+	}
+	if !bytes.Contains(header[:n], pdfMagic) {
+		return Extraction{}, nil
+	}
+
+	if _, err := doc.Seek(0, io.SeekStart); err != nil {
+		return Extraction{}, fmt.Errorf("could not read file: %w", err)
+	}
+
+	text, err := s.extractor.Text(doc)
+	if err != nil {
+		return Extraction{}, err
+	}
+
+	extraction := ParseText(text)
+	extraction.InvoiceID = invoice.ID
+	extraction.ExtractedAt = time.Now()
+	extraction.compare(invoice)
+
+	if err := s.st.SaveExtraction(ctx, extraction); err != nil {
+		return Extraction{}, err
+	}
+
+	return extraction, nil
+}
+
+func (s *Service) GetExtraction(ctx context.Context, id string) (Extraction, error) {
+	return s.st.RetrieveExtraction(ctx, id)
+}
+
+func (s *Service) ListFlaggedExtractions(ctx context.Context) ([]Extraction, error) {
+	return s.st.RetrieveFlaggedExtractions(ctx)
+}
+
+// ReviewExtraction accepts the mismatches of an extraction, releasing the
+// hold on its invoice.
+func (s *Service) ReviewExtraction(ctx context.Context, id string) error {
+	return s.st.ReviewExtraction(ctx, id, time.Now())
+}
+
+// checkHold fails with ErrOnHold while the extraction of an invoice is
+// flagged. Invoices not extracted yet are not held.
+func (s *Service) checkHold(ctx context.Context, id string) error {
+	extraction, err := s.st.RetrieveExtraction(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
+	if extraction.Flagged() {
+		return fmt.Errorf("%w: the file does not match the declared %v", ErrOnHold, extraction.Mismatches)
+	}
+
+	return nil
+}
+
+func (s *Service) PlaceBid(ctx context.Context, invoiceID string, investorID string, amount currency.Amount) (string, error) {
 	id, err := uuid.NewUUID()
 	if err != nil {
 		return "", fmt.Errorf("could not generate id: %w", err)
 	}
 
+	if err := s.PlaceBidWithID(ctx, id.String(), invoiceID, investorID, amount); err != nil {
+		return "", err
+	}
+
+	return id.String(), nil
+}
+
+// PlaceBidWithID places a bid under an id chosen by the caller, so it can be
+// referenced before the bid exists.
+func (s *Service) PlaceBidWithID(ctx context.Context, id, invoiceID, investorID string, amount currency.Amount) error {
+	invoice, err := s.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+
+	if invoice.Status != OPEN {
+		return fmt.Errorf("%w: can only place bids in open invoices", ErrInvoiceNotOpen)
+	}
+
+	if amount.CurrencyCode() != invoice.Price.CurrencyCode() {
+		return fmt.Errorf("%w: bids must be in %s", ErrCurrencyMismatch, invoice.Price.CurrencyCode())
+	}
+
+	if err := s.checkHold(ctx, invoiceID); err != nil {
+		return err
+	}
+
 	if err := s.st.SaveBid(ctx, Bid{
-		ID:         id.String(),
+		ID:         id,
 		InvestorID: investorID,
 		InvoiceID:  invoiceID,
 		Amount:     amount,
 		Active:     true,
 	}); err != nil {
-		return "", err
+		return err
 	}
 
-	if remaining, _ := s.getRemainingPrice(invoice).Sub(amount); remaining.IsZero() {
+	if remaining, _ := invoice.RemainingPrice().Sub(amount); remaining.IsZero() {
 		if err := s.st.UpdateStatus(ctx, invoiceID, LOCKED); err != nil {
-			return "", err
+			return err
 		}
 	}
 
-	return id.String(), nil
+	return nil
 }
 
 func (s *Service) ApproveTrade(ctx context.Context, id string, approved bool) ([]string, error) {
@@ -114,10 +463,14 @@ func (s *Service) ApproveTrade(ctx context.Context, id string, approved bool) ([
 	}
 
 	if invoice.Status != LOCKED {
-		return nil, fmt.Errorf("cannot close trade if the status is not locked")
+		return nil, fmt.Errorf("%w: cannot close trade if the status is not locked", ErrInvalidTransition)
 	}
 
 	if approved {
+		if err := s.checkHold(ctx, id); err != nil {
+			return nil, err
+		}
+
 		if err := s.st.UpdateStatus(ctx, id, TRADED); err != nil {
 			return nil, err
 		}
@@ -145,14 +498,252 @@ func (s *Service) GetRemainingPrice(ctx context.Context, id string) (currency.Am
 		return currency.Amount{}, err
 	}
 
-	return s.getRemainingPrice(invoice), nil
+	return invoice.RemainingPrice(), nil
 }
 
-func (s *Service) getRemainingPrice(invoice Invoice) currency.Amount {
-	remainingPrice := invoice.Price
-	for _, b := range invoice.Bids {
-		remainingPrice, _ = remainingPrice.Sub(b.Amount)
+func (s *Service) ListPosition(ctx context.Context, bidID, sellerID string, price currency.Amount) (Listing, error) {
+	bid, err := s.st.RetrieveBid(ctx, bidID)
+	if err != nil {
+		return Listing{}, err
+	}
+
+	if !bid.Active || bid.InvestorID != sellerID {
+		return Listing{}, fmt.Errorf("%w: bid is not a position held by the investor", ErrNotOwner)
+	}
+
+	if bid.Amount.CurrencyCode() != price.CurrencyCode() {
+		return Listing{}, fmt.Errorf("%w: listing price must be in %s", ErrCurrencyMismatch, bid.Amount.CurrencyCode())
+	}
+
+	if !price.IsPositive() {
+		return Listing{}, fmt.Errorf("%w: listing price must be positive", ErrInvalidAmount)
+	}
+
+	invoice, err := s.GetInvoice(ctx, bid.InvoiceID)
+	if err != nil {
+		return Listing{}, err
+	}
+
+	if invoice.Status != TRADED {
+		return Listing{}, fmt.Errorf("%w: can only list positions in traded invoices", ErrInvalidTransition)
+	}
+
+	listings, err := s.st.RetrieveListingsByBidIDs(ctx, []string{bidID})
+	if err != nil {
+		return Listing{}, err
+	}
+
+	for _, l := range listings {
+		if l.Status == LISTED || l.Status == RESERVED {
+			return Listing{}, fmt.Errorf("%w: position is already listed", ErrInvalidTransition)
+		}
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return Listing{}, fmt.Errorf("could not generate id: %w", err)
+	}
+
+	listing := Listing{
+		ID:        id.String(),
+		BidID:     bidID,
+		InvoiceID: bid.InvoiceID,
+		SellerID:  sellerID,
+		Price:     price,
+		Status:    LISTED,
+		CreatedAt: time.Now(),
+	}
+	if err := s.st.SaveListing(ctx, listing); err != nil {
+		return Listing{}, err
+	}
+
+	return listing, nil
+}
+
+func (s *Service) GetListing(ctx context.Context, id string) (Listing, error) {
+	return s.st.RetrieveListing(ctx, id)
+}
+
+func (s *Service) ListOpenListings(ctx context.Context) ([]Listing, error) {
+	return s.st.RetrieveListingsByStatus(ctx, LISTED)
+}
+
+// ReserveListing holds a listing for the buyer so no one else can buy it while
+// it is paid, it must be followed by BuyListing or ReleaseListing.
+func (s *Service) ReserveListing(ctx context.Context, id, buyerID string) (Listing, error) {
+	listing, err := s.GetListing(ctx, id)
+	if err != nil {
+		return Listing{}, err
+	}
+
+	if listing.Status != LISTED {
+		return Listing{}, fmt.Errorf("%w: listing is no longer available", ErrInvalidTransition)
+	}
+
+	if listing.SellerID == buyerID {
+		return Listing{}, fmt.Errorf("%w: cannot buy your own listing", ErrInvalidTransition)
+	}
+
+	if err := s.st.ReserveListing(ctx, id, buyerID); err != nil {
+		return Listing{}, err
+	}
+
+	listing.BuyerID = buyerID
+	listing.Status = RESERVED
+
+	return listing, nil
+}
+
+// ReleaseListing lists again a listing the buyer could not pay
+func (s *Service) ReleaseListing(ctx context.Context, id, buyerID string) error {
+	return s.st.ReleaseListing(ctx, id, buyerID)
+}
+
+// BuyListing transfers the ownership of the bid of a listing reserved by the
+// buyer, so any repayment of the invoice goes to the current holder of the
+// position.
+func (s *Service) BuyListing(ctx context.Context, id, buyerID string) (Listing, error) {
+	listing, err := s.GetListing(ctx, id)
+	if err != nil {
+		return Listing{}, err
+	}
+
+	if listing.Status != RESERVED || listing.BuyerID != buyerID {
+		return Listing{}, fmt.Errorf("%w: listing is not reserved by the buyer", ErrInvalidTransition)
+	}
+
+	if err := s.st.TransferListing(ctx, id, buyerID); err != nil {
+		return Listing{}, err
+	}
+
+	listing.Status = SOLD
+
+	return listing, nil
+}
+
+func (s *Service) WithdrawListing(ctx context.Context, id, sellerID string) error {
+	listing, err := s.GetListing(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if listing.SellerID != sellerID {
+		return fmt.Errorf("%w: only the seller can withdraw a listing", ErrNotOwner)
+	}
+
+	if listing.Status != LISTED {
+		return fmt.Errorf("%w: listing is no longer available", ErrInvalidTransition)
+	}
+
+	return s.st.UpdateListingStatus(ctx, id, LISTED, WITHDRAWN)
+}
+
+func (s *Service) GetPositions(ctx context.Context, investorID string) ([]Position, error) {
+	bids, err := s.st.RetrieveBidsByInvestorID(ctx, investorID)
+	if err != nil {
+		return nil, err
+	}
+	if len(bids) == 0 {
+		return []Position{}, nil
+	}
+
+	bidIDs := make([]string, 0, len(bids))
+	invoiceIDs := make([]string, 0, len(bids))
+	for _, b := range bids {
+		bidIDs = append(bidIDs, b.ID)
+		invoiceIDs = append(invoiceIDs, b.InvoiceID)
+	}
+
+	invoices, err := s.st.RetrieveInvoicesByIDs(ctx, invoiceIDs)
+	if err != nil {
+		return nil, err
+	}
+	invoicesByID := make(map[string]Invoice, len(invoices))
+	for _, inv := range invoices {
+		invoicesByID[inv.ID] = inv
+	}
+
+	listings, err := s.listingsByBidID(ctx, bidIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]Position, 0, len(bids))
+	for _, b := range bids {
+		inv, ok := invoicesByID[b.InvoiceID]
+		if !ok {
+			return nil, fmt.Errorf("could not retrieve invoice %s: %w", b.InvoiceID, ErrNotFound)
+		}
+
+		positions = append(positions, Position{
+			Bid:     b,
+			Invoice: inv,
+			Cost:    costBasis(b, listings[b.ID], investorID, time.Time{}),
+		})
+	}
+
+	return positions, nil
+}
+
+func (s *Service) GetSales(ctx context.Context, investorID string) ([]Sale, error) {
+	sold, err := s.st.RetrieveListingsBySellerID(ctx, investorID, SOLD)
+	if err != nil {
+		return nil, err
+	}
+	if len(sold) == 0 {
+		return []Sale{}, nil
+	}
+
+	bidIDs := make([]string, 0, len(sold))
+	for _, l := range sold {
+		bidIDs = append(bidIDs, l.BidID)
+	}
+
+	bids, err := s.st.RetrieveBidsByIDs(ctx, bidIDs)
+	if err != nil {
+		return nil, err
+	}
+	bidsByID := make(map[string]Bid, len(bids))
+	for _, b := range bids {
+		bidsByID[b.ID] = b
+	}
+
+	listings, err := s.listingsByBidID(ctx, bidIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	sales := make([]Sale, 0, len(sold))
+	for _, l := range sold {
+		bid, ok := bidsByID[l.BidID]
+		if !ok {
+			return nil, fmt.Errorf("could not retrieve bid %s: %w", l.BidID, ErrNotFound)
+		}
+
+		soldAt := l.CreatedAt
+		if l.SoldAt != nil {
+			soldAt = *l.SoldAt
+		}
+
+		sales = append(sales, Sale{
+			Listing: l,
+			Cost:    costBasis(bid, listings[l.BidID], investorID, soldAt),
+		})
+	}
+
+	return sales, nil
+}
+
+func (s *Service) listingsByBidID(ctx context.Context, bidIDs []string) (map[string][]Listing, error) {
+	listings, err := s.st.RetrieveListingsByBidIDs(ctx, bidIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byBid := make(map[string][]Listing, len(bidIDs))
+	for _, l := range listings {
+		byBid[l.BidID] = append(byBid[l.BidID], l)
 	}
 
-	return remainingPrice
+	return byBid, nil
 }
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+)
+
+// einvoiceLine is how the sql storages keep the lines of an e-invoice, as a
+// json array next to the rest of it
+type einvoiceLine struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Quantity    string `json:"quantity,omitempty"`
+	Amount      string `json:"amount"`
+	Currency    string `json:"currency"`
+}
+
+func marshalLines(lines []einvoice.Line) ([]byte, error) {
+	res := make([]einvoiceLine, 0, len(lines))
+	for _, l := range lines {
+		res = append(res, einvoiceLine{
+			ID:          l.ID,
+			Description: l.Description,
+			Quantity:    l.Quantity,
+			Amount:      l.Amount.Number(),
+			Currency:    l.Amount.CurrencyCode(),
+		})
+	}
+
+	js, err := json.Marshal(res)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal e-invoice lines: %w", err)
+	}
+
+	return js, nil
+}
+
+func unmarshalLines(js []byte) ([]einvoice.Line, error) {
+	var stored []einvoiceLine
+	if err := json.Unmarshal(js, &stored); err != nil {
+		return nil, fmt.Errorf("could not unmarshal e-invoice lines: %w", err)
+	}
+
+	var lines []einvoice.Line
+	for _, l := range stored {
+		amount, err := currency.NewAmount(l.Amount, l.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("could not read e-invoice line amount: %w", err)
+		}
+
+		lines = append(lines, einvoice.Line{ID: l.ID, Description: l.Description, Quantity: l.Quantity, Amount: amount})
+	}
+
+	return lines, nil
+}
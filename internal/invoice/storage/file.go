@@ -1,11 +1,25 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	iofs "io/fs"
 	"os"
+	"regexp"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
 )
 
+const stagingDir = "staging"
+
+// fileKeyRe matches the keys of stored files, hashes or legacy <id>.pdf, as
+// the base path may be shared with files that are not ours
+var fileKeyRe = regexp.MustCompile(`^([0-9a-f]{64}|[0-9a-f-]{36}\.pdf)$`)
+
 type FileStorage struct {
 	basePath string
 }
@@ -14,16 +28,133 @@ func NewFileStorage(basePath string) *FileStorage {
 	return &FileStorage{basePath}
 }
 
-func (fs *FileStorage) SaveFile(id string, src io.Reader) error {
-	dst, err := os.Create(fmt.Sprintf("%s/%s.pdf", fs.basePath, id))
+// StageFile writes the file to the staging area under the invoice id and
+// returns its SHA-256 hash.
+func (fs *FileStorage) StageFile(id string, src io.Reader) (string, error) {
+	if err := os.MkdirAll(fs.path(stagingDir), 0o755); err != nil {
+		return "", fmt.Errorf("could not create staging dir: %w", err)
+	}
+
+	dst, err := os.Create(fs.stagedPath(id))
 	if err != nil {
-		return fmt.Errorf("could not create file: %w", err)
+		return "", fmt.Errorf("could not create file: %w", err)
 	}
 	defer dst.Close()
 
-	if _, err := io.Copy(dst, src); err != nil {
-		return fmt.Errorf("could not save file: %w", err)
+	h := sha256.New()
+	if _, err := io.Copy(dst, io.TeeReader(src, h)); err != nil {
+		return "", fmt.Errorf("could not save file: %w", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		return "", fmt.Errorf("could not save file: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CommitFile moves a staged file to its content addressed location. The
+// rename is atomic, so a partial file never takes the place of a stored one.
+func (fs *FileStorage) CommitFile(id, hash string) error {
+	if err := os.Rename(fs.stagedPath(id), fs.path(hash)); err != nil {
+		return fmt.Errorf("could not commit file: %w", err)
+	}
+
+	return nil
+}
+
+func (fs *FileStorage) DiscardFile(id string) error {
+	if err := os.Remove(fs.stagedPath(id)); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return fmt.Errorf("could not discard file: %w", err)
+	}
+
+	return nil
+}
+
+func (fs *FileStorage) OpenFile(key string) (invoice.Document, error) {
+	f, err := os.Open(fs.path(key))
+	if err != nil {
+		return invoice.Document{}, fmt.Errorf("could not open file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return invoice.Document{}, fmt.Errorf("could not stat file: %w", err)
+	}
+
+	return invoice.Document{
+		ReadSeekCloser: f,
+		Size:           info.Size(),
+		ModTime:        info.ModTime(),
+	}, nil
+}
+
+func (fs *FileStorage) ListFiles() ([]invoice.StoredFile, error) {
+	return fs.list(fs.basePath, func(name string) bool { return fileKeyRe.MatchString(name) })
+}
+
+func (fs *FileStorage) ListStaged() ([]invoice.StoredFile, error) {
+	files, err := fs.list(fs.path(stagingDir), func(string) bool { return true })
+	if errors.Is(err, iofs.ErrNotExist) {
+		return nil, nil
+	}
+
+	return files, err
+}
+
+// DeleteFile removes a stored file unless it was modified after the given
+// time, which means it was written again since it was listed.
+func (fs *FileStorage) DeleteFile(key string, olderThan time.Time) error {
+	info, err := os.Stat(fs.path(key))
+	if errors.Is(err, iofs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not stat file: %w", err)
+	}
+
+	if info.ModTime().After(olderThan) {
+		return nil
+	}
+
+	if err := os.Remove(fs.path(key)); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return fmt.Errorf("could not delete file: %w", err)
 	}
 
 	return nil
 }
+
+func (fs *FileStorage) list(dir string, match func(string) bool) ([]invoice.StoredFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not list files: %w", err)
+	}
+
+	var files []invoice.StoredFile
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !match(e.Name()) {
+			continue
+		}
+
+		info, err := e.Info()
+		if errors.Is(err, iofs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not stat file: %w", err)
+		}
+
+		files = append(files, invoice.StoredFile{Key: e.Name(), ModTime: info.ModTime()})
+	}
+
+	return files, nil
+}
+
+func (fs *FileStorage) path(key string) string {
+	return fmt.Sprintf("%s/%s", fs.basePath, key)
+}
+
+func (fs *FileStorage) stagedPath(id string) string {
+	return fs.path(stagingDir + "/" + id)
+}
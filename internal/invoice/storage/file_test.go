@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/storage/storagetest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testID   = "0b4c3a3e-6d0f-11ee-b962-0242ac120002"
+	testHash = "f3717db969604d941a6e1ef823cc278ab25d01809231ac1fe47a87199931d4f7"
+)
+
+func TestFileStorage(t *testing.T) {
+	Convey("FileStorage", t, func() {
+		dir := t.TempDir()
+		fst := NewFileStorage(dir)
+
+		Convey("when the file was staged", func() {
+			hash, err := fst.StageFile(testID, strings.NewReader("%PDF-1.7 invoice"))
+			So(err, ShouldBeNil)
+			So(hash, ShouldEqual, testHash)
+
+			Convey("list it as staged but not as stored", func() {
+				staged, err := fst.ListStaged()
+				So(err, ShouldBeNil)
+				So(staged, ShouldHaveLength, 1)
+				So(staged[0].Key, ShouldEqual, testID)
+
+				files, err := fst.ListFiles()
+				So(err, ShouldBeNil)
+				So(files, ShouldBeEmpty)
+			})
+
+			Convey("discarding it removes it from staging", func() {
+				So(fst.DiscardFile(testID), ShouldBeNil)
+
+				staged, err := fst.ListStaged()
+				So(err, ShouldBeNil)
+				So(staged, ShouldBeEmpty)
+			})
+
+			Convey("when it was committed", func() {
+				So(fst.CommitFile(testID, hash), ShouldBeNil)
+
+				Convey("store it under its hash only", func() {
+					staged, err := fst.ListStaged()
+					So(err, ShouldBeNil)
+					So(staged, ShouldBeEmpty)
+
+					files, err := fst.ListFiles()
+					So(err, ShouldBeNil)
+					So(files, ShouldHaveLength, 1)
+					So(files[0].Key, ShouldEqual, hash)
+				})
+
+				Convey("open it by its hash", func() {
+					doc, err := fst.OpenFile(hash)
+					So(err, ShouldBeNil)
+					defer doc.Close()
+
+					content, err := io.ReadAll(doc)
+					So(err, ShouldBeNil)
+					So(string(content), ShouldEqual, "%PDF-1.7 invoice")
+					So(doc.Size, ShouldEqual, 16)
+				})
+
+				Convey("keep it if it was modified after the given time", func() {
+					So(fst.DeleteFile(hash, time.Now().Add(-time.Hour)), ShouldBeNil)
+
+					_, err := os.Stat(filepath.Join(dir, hash))
+					So(err, ShouldBeNil)
+				})
+
+				Convey("delete it if it is older than the given time", func() {
+					So(fst.DeleteFile(hash, time.Now().Add(time.Hour)), ShouldBeNil)
+
+					_, err := os.Stat(filepath.Join(dir, hash))
+					So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+				})
+			})
+		})
+
+		Convey("when the base path has other files", func() {
+			So(os.WriteFile(filepath.Join(dir, "other.txt"), nil, 0o644), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(dir, testID+".pdf"), nil, 0o644), ShouldBeNil)
+
+			Convey("only list invoice files", func() {
+				files, err := fst.ListFiles()
+				So(err, ShouldBeNil)
+				So(files, ShouldHaveLength, 1)
+				So(files[0].Key, ShouldEqual, testID+".pdf")
+			})
+		})
+
+		Convey("when the file does not exist", func() {
+			_, err := fst.OpenFile("missing")
+
+			Convey("return a not exist error", func() {
+				So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+			})
+
+			Convey("committing it fails", func() {
+				So(fst.CommitFile("missing", testHash), ShouldNotBeNil)
+			})
+
+			Convey("discarding it succeeds", func() {
+				So(fst.DiscardFile("missing"), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestFileStorageSuite(t *testing.T) {
+	storagetest.RunFiles(t, func(t *testing.T) invoice.FileStorage {
+		return NewFileStorage(t.TempDir())
+	})
+}
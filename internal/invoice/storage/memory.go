@@ -0,0 +1,534 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+)
+
+// MemoryStorage keeps invoices, bids and listings in memory. A single lock
+// stands in for the transactions of the postgres storage, so bids and trades
+// are applied as a whole.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	invoices    map[string]invoice.Invoice
+	bids        map[string]invoice.Bid
+	bidOrder    []string
+	listings    map[string]invoice.Listing
+	extractions map[string]invoice.Extraction
+	einvoices   map[string]einvoice.Document
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		invoices:    map[string]invoice.Invoice{},
+		bids:        map[string]invoice.Bid{},
+		listings:    map[string]invoice.Listing{},
+		extractions: map[string]invoice.Extraction{},
+		einvoices:   map[string]einvoice.Document{},
+	}
+}
+
+func (s *MemoryStorage) SaveInvoice(_ context.Context, i invoice.Invoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invoices[i.ID]; ok {
+		return fmt.Errorf("could not save invoice: invoice %s already exists", i.ID)
+	}
+
+	// a file can back a new invoice once the one it backed is traded
+	if i.FileHash != "" {
+		for _, inv := range s.invoices {
+			if inv.FileHash == i.FileHash && inv.Status != invoice.TRADED {
+				return fmt.Errorf("could not save invoice: %w", invoice.ErrDuplicateFile)
+			}
+		}
+	}
+
+	i.Bids = nil
+	s.invoices[i.ID] = i
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveInvoiceFiles(_ context.Context, q invoice.FileQuery) ([]invoice.Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var invoices []invoice.Invoice
+	for _, inv := range s.invoices {
+		if inv.CreatedAt.Before(q.From) || !inv.CreatedAt.Before(q.To) || inv.ID <= q.After {
+			continue
+		}
+		invoices = append(invoices, inv)
+	}
+	sort.Slice(invoices, func(i, j int) bool { return invoices[i].ID < invoices[j].ID })
+
+	if len(invoices) > q.Limit {
+		invoices = invoices[:q.Limit]
+	}
+
+	return invoices, nil
+}
+
+func (s *MemoryStorage) RetrieveInvoicesByFileHashes(_ context.Context, hashes []string) ([]invoice.Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		wanted[hash] = true
+	}
+
+	var invoices []invoice.Invoice
+	for _, inv := range s.invoices {
+		if inv.FileHash != "" && wanted[inv.FileHash] {
+			invoices = append(invoices, inv)
+		}
+	}
+
+	return invoices, nil
+}
+
+// DeleteOrphanedInvoice removes an invoice as long as it is still open
+// without bids, so no money ever depended on it.
+func (s *MemoryStorage) DeleteOrphanedInvoice(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invoices[id]
+	if !ok || inv.Status != invoice.OPEN {
+		return false, nil
+	}
+
+	for _, b := range s.bids {
+		if b.InvoiceID == id {
+			return false, nil
+		}
+	}
+
+	delete(s.invoices, id)
+	delete(s.extractions, id)
+
+	return true, nil
+}
+
+func (s *MemoryStorage) RetrieveInvoice(_ context.Context, id string) (invoice.Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	inv, ok := s.invoices[id]
+	if !ok {
+		return invoice.Invoice{ID: id}, fmt.Errorf("could not retrieve invoice: %w", invoice.ErrNotFound)
+	}
+	inv.Bids = s.activeBids(id)
+
+	return inv, nil
+}
+
+func (s *MemoryStorage) RetrieveInvoicesByIDs(_ context.Context, ids []string) ([]invoice.Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var invoices []invoice.Invoice
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		inv, ok := s.invoices[id]
+		if !ok || seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		inv.Bids = s.activeBids(id)
+		invoices = append(invoices, inv)
+	}
+
+	return invoices, nil
+}
+
+func (s *MemoryStorage) RetrieveInvoicesByIssuerID(_ context.Context, issID string) ([]invoice.Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var invoices []invoice.Invoice
+	for _, inv := range s.invoices {
+		if inv.IssuerID == issID {
+			inv.Bids = s.activeBids(inv.ID)
+			invoices = append(invoices, inv)
+		}
+	}
+
+	return invoices, nil
+}
+
+func (s *MemoryStorage) RetrieveInvoices(_ context.Context, q invoice.Query) ([]invoice.Invoice, *invoice.Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make([]invoice.Invoice, 0, len(s.invoices))
+	for _, inv := range s.invoices {
+		all = append(all, inv)
+	}
+
+	invoices, next, err := pageInvoices(all, q, date(time.Now()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+
+	for i := range invoices {
+		invoices[i].Bids = s.activeBids(invoices[i].ID)
+	}
+
+	return invoices, next, nil
+}
+
+func (s *MemoryStorage) RetrieveBidsByIDs(_ context.Context, bidsIDs []string) ([]invoice.Bid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var bids []invoice.Bid
+	for _, id := range s.bidOrder {
+		for _, want := range bidsIDs {
+			if id == want {
+				bids = append(bids, s.bids[id])
+				break
+			}
+		}
+	}
+
+	return bids, nil
+}
+
+func (s *MemoryStorage) UpdateStatus(_ context.Context, id string, status invoice.Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invoices[id]
+	if !ok {
+		return fmt.Errorf("could not update invoice status: %w", invoice.ErrNotFound)
+	}
+
+	now := time.Now()
+	switch {
+	case status == invoice.LOCKED:
+		inv.FundedAt = &now
+	case status == invoice.TRADED:
+		inv.TradedAt = &now
+	case status == invoice.OPEN && inv.Status == invoice.LOCKED:
+		inv.Rejections++
+	}
+	inv.Status = status
+	s.invoices[id] = inv
+
+	return nil
+}
+
+func (s *MemoryStorage) SaveBid(_ context.Context, b invoice.Bid) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bids[b.ID]; ok {
+		return fmt.Errorf("could not save bid: bid %s already exists", b.ID)
+	}
+	if _, ok := s.invoices[b.InvoiceID]; !ok {
+		return fmt.Errorf("could not save bid: %w", invoice.ErrNotFound)
+	}
+
+	s.bids[b.ID] = b
+	s.bidOrder = append(s.bidOrder, b.ID)
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveBid(_ context.Context, id string) (invoice.Bid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bid, ok := s.bids[id]
+	if !ok {
+		return invoice.Bid{ID: id}, fmt.Errorf("could not retrieve bid: %w", invoice.ErrNotFound)
+	}
+
+	return bid, nil
+}
+
+func (s *MemoryStorage) RetrieveBidsByInvestorID(_ context.Context, investorID string) ([]invoice.Bid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var bids []invoice.Bid
+	for _, id := range s.bidOrder {
+		if b := s.bids[id]; b.InvestorID == investorID && b.Active {
+			bids = append(bids, b)
+		}
+	}
+
+	return bids, nil
+}
+
+func (s *MemoryStorage) RetrieveActiveBidsByInvoiceID(_ context.Context, invoiceID string) ([]invoice.Bid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.activeBids(invoiceID), nil
+}
+
+func (s *MemoryStorage) DisableBidsByInvoiceID(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for bidID, b := range s.bids {
+		if b.InvoiceID == id && b.Active {
+			b.Active = false
+			s.bids[bidID] = b
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) SaveListing(_ context.Context, l invoice.Listing) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.listings[l.ID]; ok {
+		return fmt.Errorf("could not save listing: listing %s already exists", l.ID)
+	}
+	if _, ok := s.bids[l.BidID]; !ok {
+		return fmt.Errorf("could not save listing: %w", invoice.ErrNotFound)
+	}
+	if _, ok := s.invoices[l.InvoiceID]; !ok {
+		return fmt.Errorf("could not save listing: %w", invoice.ErrNotFound)
+	}
+
+	if l.Status == invoice.LISTED {
+		for _, listed := range s.listings {
+			if listed.BidID == l.BidID && listed.Status == invoice.LISTED {
+				return fmt.Errorf("could not save listing: bid %s is already listed", l.BidID)
+			}
+		}
+	}
+
+	s.listings[l.ID] = l
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveListing(_ context.Context, id string) (invoice.Listing, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok := s.listings[id]
+	if !ok {
+		return invoice.Listing{ID: id}, fmt.Errorf("could not retrieve listing: %w", invoice.ErrNotFound)
+	}
+
+	return l, nil
+}
+
+func (s *MemoryStorage) RetrieveListingsByStatus(_ context.Context, status invoice.ListingStatus) ([]invoice.Listing, error) {
+	return s.listingsWhere(func(l invoice.Listing) bool { return l.Status == status }), nil
+}
+
+func (s *MemoryStorage) RetrieveListingsByBidIDs(_ context.Context, bidIDs []string) ([]invoice.Listing, error) {
+	ids := make(map[string]bool, len(bidIDs))
+	for _, id := range bidIDs {
+		ids[id] = true
+	}
+
+	return s.listingsWhere(func(l invoice.Listing) bool { return ids[l.BidID] }), nil
+}
+
+func (s *MemoryStorage) RetrieveListingsBySellerID(_ context.Context, sellerID string, status invoice.ListingStatus) ([]invoice.Listing, error) {
+	return s.listingsWhere(func(l invoice.Listing) bool { return l.SellerID == sellerID && l.Status == status }), nil
+}
+
+func (s *MemoryStorage) UpdateListingStatus(_ context.Context, id string, from, to invoice.ListingStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.listings[id]
+	if !ok {
+		return fmt.Errorf("could not update listing status: %w", invoice.ErrNotFound)
+	}
+	if l.Status != from {
+		return fmt.Errorf("%w: listing is no longer %s", invoice.ErrInvalidTransition, from)
+	}
+
+	l.Status = to
+	s.listings[id] = l
+
+	return nil
+}
+
+func (s *MemoryStorage) ReserveListing(_ context.Context, id, buyerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.listings[id]
+	if !ok || l.Status != invoice.LISTED {
+		return fmt.Errorf("%w: listing is no longer available", invoice.ErrInvalidTransition)
+	}
+
+	l.Status = invoice.RESERVED
+	l.BuyerID = buyerID
+	s.listings[id] = l
+
+	return nil
+}
+
+func (s *MemoryStorage) ReleaseListing(_ context.Context, id, buyerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.listings[id]
+	if !ok || l.Status != invoice.RESERVED || l.BuyerID != buyerID {
+		return fmt.Errorf("%w: listing is not reserved by the buyer", invoice.ErrInvalidTransition)
+	}
+
+	l.Status = invoice.LISTED
+	l.BuyerID = ""
+	s.listings[id] = l
+
+	return nil
+}
+
+func (s *MemoryStorage) TransferListing(_ context.Context, id, buyerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.listings[id]
+	if !ok || l.Status != invoice.RESERVED || l.BuyerID != buyerID {
+		return fmt.Errorf("%w: listing is not reserved by the buyer", invoice.ErrInvalidTransition)
+	}
+
+	now := time.Now()
+	l.Status = invoice.SOLD
+	l.SoldAt = &now
+	s.listings[id] = l
+
+	if b, ok := s.bids[l.BidID]; ok {
+		b.InvestorID = buyerID
+		s.bids[l.BidID] = b
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) SaveExtraction(_ context.Context, e invoice.Extraction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invoices[e.InvoiceID]; !ok {
+		return fmt.Errorf("could not save extraction: %w", invoice.ErrNotFound)
+	}
+
+	e.Mismatches = append([]invoice.Mismatch(nil), e.Mismatches...)
+	s.extractions[e.InvoiceID] = e
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveExtraction(_ context.Context, invoiceID string) (invoice.Extraction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.extractions[invoiceID]
+	if !ok {
+		return invoice.Extraction{}, fmt.Errorf("could not retrieve extraction: %w", invoice.ErrNotFound)
+	}
+	e.Mismatches = append([]invoice.Mismatch(nil), e.Mismatches...)
+
+	return e, nil
+}
+
+func (s *MemoryStorage) RetrieveFlaggedExtractions(_ context.Context) ([]invoice.Extraction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var extractions []invoice.Extraction
+	for _, e := range s.extractions {
+		if e.Flagged() {
+			e.Mismatches = append([]invoice.Mismatch(nil), e.Mismatches...)
+			extractions = append(extractions, e)
+		}
+	}
+	sort.Slice(extractions, func(i, j int) bool { return extractions[i].ExtractedAt.Before(extractions[j].ExtractedAt) })
+
+	return extractions, nil
+}
+
+func (s *MemoryStorage) ReviewExtraction(_ context.Context, invoiceID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.extractions[invoiceID]
+	if !ok {
+		return fmt.Errorf("could not review extraction: %w", invoice.ErrNotFound)
+	}
+
+	e.ReviewedAt = &at
+	s.extractions[invoiceID] = e
+
+	return nil
+}
+
+func (s *MemoryStorage) SaveEInvoice(_ context.Context, invoiceID string, doc einvoice.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invoices[invoiceID]; !ok {
+		return fmt.Errorf("could not save e-invoice: %w", invoice.ErrNotFound)
+	}
+
+	doc.Lines = append([]einvoice.Line(nil), doc.Lines...)
+	s.einvoices[invoiceID] = doc
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveEInvoice(_ context.Context, invoiceID string) (einvoice.Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.einvoices[invoiceID]
+	if !ok {
+		return einvoice.Document{}, fmt.Errorf("could not retrieve e-invoice: %w", invoice.ErrNotFound)
+	}
+	doc.Lines = append([]einvoice.Line(nil), doc.Lines...)
+
+	return doc, nil
+}
+
+// activeBids must be called holding the lock
+func (s *MemoryStorage) activeBids(invoiceID string) []invoice.Bid {
+	var bids []invoice.Bid
+	for _, id := range s.bidOrder {
+		if b := s.bids[id]; b.InvoiceID == invoiceID && b.Active {
+			bids = append(bids, b)
+		}
+	}
+
+	return bids
+}
+
+func (s *MemoryStorage) listingsWhere(match func(invoice.Listing) bool) []invoice.Listing {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var listings []invoice.Listing
+	for _, l := range s.listings {
+		if match(l) {
+			listings = append(listings, l)
+		}
+	}
+	sort.Slice(listings, func(i, j int) bool { return listings[i].CreatedAt.Before(listings[j].CreatedAt) })
+
+	return listings
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+type memoryFile struct {
+	content []byte
+	modTime time.Time
+}
+
+// MemoryFileStorage keeps invoice files in memory, staged and committed like
+// the local file storage.
+type MemoryFileStorage struct {
+	mu     sync.RWMutex
+	files  map[string]memoryFile
+	staged map[string]memoryFile
+}
+
+func NewMemoryFileStorage() *MemoryFileStorage {
+	return &MemoryFileStorage{
+		files:  map[string]memoryFile{},
+		staged: map[string]memoryFile{},
+	}
+}
+
+// StageFile keeps the file under the invoice id and returns its SHA-256 hash.
+func (fs *MemoryFileStorage) StageFile(id string, src io.Reader) (string, error) {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return "", fmt.Errorf("could not save file: %w", err)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.staged[id] = memoryFile{content: content, modTime: time.Now()}
+
+	h := sha256.Sum256(content)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func (fs *MemoryFileStorage) CommitFile(id, hash string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, ok := fs.staged[id]
+	if !ok {
+		return fmt.Errorf("could not commit file: %w", iofs.ErrNotExist)
+	}
+
+	delete(fs.staged, id)
+	f.modTime = time.Now()
+	fs.files[hash] = f
+
+	return nil
+}
+
+func (fs *MemoryFileStorage) DiscardFile(id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	delete(fs.staged, id)
+
+	return nil
+}
+
+func (fs *MemoryFileStorage) OpenFile(key string) (invoice.Document, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	f, ok := fs.files[key]
+	if !ok {
+		return invoice.Document{}, fmt.Errorf("could not open file: %w", iofs.ErrNotExist)
+	}
+
+	return invoice.Document{
+		ReadSeekCloser: nopCloser{bytes.NewReader(f.content)},
+		Size:           int64(len(f.content)),
+		ModTime:        f.modTime,
+	}, nil
+}
+
+func (fs *MemoryFileStorage) ListFiles() ([]invoice.StoredFile, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return listMemoryFiles(fs.files), nil
+}
+
+func (fs *MemoryFileStorage) ListStaged() ([]invoice.StoredFile, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return listMemoryFiles(fs.staged), nil
+}
+
+// DeleteFile removes a stored file unless it was modified after the given
+// time, which means it was written again since it was listed.
+func (fs *MemoryFileStorage) DeleteFile(key string, olderThan time.Time) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if f, ok := fs.files[key]; ok && !f.modTime.After(olderThan) {
+		delete(fs.files, key)
+	}
+
+	return nil
+}
+
+func listMemoryFiles(files map[string]memoryFile) []invoice.StoredFile {
+	var stored []invoice.StoredFile
+	for key, f := range files {
+		stored = append(stored, invoice.StoredFile{Key: key, ModTime: f.modTime})
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Key < stored[j].Key })
+
+	return stored
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/storage/storagetest"
+)
+
+func TestMemoryFileStorage(t *testing.T) {
+	storagetest.RunFiles(t, func(*testing.T) invoice.FileStorage {
+		return NewMemoryFileStorage()
+	})
+}
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) invoice.Storage {
+		return NewMemoryStorage()
+	})
+}
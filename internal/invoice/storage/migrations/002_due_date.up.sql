@@ -0,0 +1,5 @@
+ALTER TABLE invoices
+ADD COLUMN due_date DATE NOT NULL DEFAULT CURRENT_DATE;
+
+ALTER TABLE invoices
+ALTER COLUMN due_date DROP DEFAULT;
@@ -0,0 +1,13 @@
+CREATE TABLE listings (
+    id CHAR(36) PRIMARY KEY,
+    bid_id CHAR(36) NOT NULL REFERENCES bids (id),
+    invoice_id CHAR(36) NOT NULL REFERENCES invoices (id),
+    seller_id CHAR(36) NOT NULL,
+    buyer_id CHAR(36),
+    price price NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX listings_bid_id_listed_idx ON listings (bid_id) WHERE status = 'listed';
+CREATE INDEX listings_status_idx ON listings (status);
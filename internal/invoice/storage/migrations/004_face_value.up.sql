@@ -0,0 +1,7 @@
+ALTER TABLE invoices
+ADD COLUMN face_value price;
+
+UPDATE invoices SET face_value = price;
+
+ALTER TABLE invoices
+ALTER COLUMN face_value SET NOT NULL;
@@ -0,0 +1,6 @@
+CREATE INDEX invoices_status_due_date_idx ON invoices (status, due_date, id);
+CREATE INDEX invoices_issuer_id_idx ON invoices (issuer_id);
+CREATE INDEX invoices_currency_code_idx ON invoices (((price).currency_code), status);
+CREATE INDEX invoices_price_idx ON invoices (((price).number), id);
+
+CREATE INDEX bids_invoice_id_idx ON bids (invoice_id) WHERE active = true;
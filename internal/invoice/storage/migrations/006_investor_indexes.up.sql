@@ -0,0 +1,2 @@
+CREATE INDEX bids_investor_id_idx ON bids (investor_id) WHERE active = true;
+CREATE INDEX listings_seller_id_idx ON listings (seller_id, status);
@@ -0,0 +1,5 @@
+ALTER TABLE invoices
+ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+ADD COLUMN funded_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN traded_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN rejections INTEGER NOT NULL DEFAULT 0;
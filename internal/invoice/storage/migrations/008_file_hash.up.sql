@@ -0,0 +1,4 @@
+ALTER TABLE invoices
+ADD COLUMN file_hash TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX invoices_file_hash_idx ON invoices (file_hash) WHERE file_hash <> '';
@@ -0,0 +1,13 @@
+CREATE TABLE extractions (
+    invoice_id CHAR(36) PRIMARY KEY REFERENCES invoices (id) ON DELETE CASCADE,
+    number TEXT NOT NULL,
+    issue_date DATE,
+    due_date DATE,
+    total TEXT NOT NULL,
+    currency_code TEXT NOT NULL,
+    debtor_tax_id TEXT NOT NULL,
+    mismatches TEXT[] NOT NULL,
+    extracted_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX extractions_flagged_idx ON extractions (extracted_at) WHERE cardinality(mismatches) > 0;
@@ -0,0 +1,6 @@
+ALTER TABLE invoices ADD COLUMN apr NUMERIC;
+ALTER TABLE invoices ADD COLUMN apr_date DATE;
+
+CREATE INDEX invoices_status_apr_idx ON invoices (status, apr, id);
+CREATE INDEX invoices_apr_idx ON invoices (apr, id);
+CREATE INDEX invoices_apr_date_idx ON invoices (apr_date);
@@ -0,0 +1,5 @@
+ALTER TABLE extractions ADD COLUMN reviewed_at TIMESTAMP WITH TIME ZONE;
+
+DROP INDEX extractions_flagged_idx;
+
+CREATE INDEX extractions_flagged_idx ON extractions (extracted_at) WHERE cardinality(mismatches) > 0 AND reviewed_at IS NULL;
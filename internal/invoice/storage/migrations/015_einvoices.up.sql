@@ -0,0 +1,15 @@
+ALTER TABLE invoices ADD COLUMN content_type TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE einvoices (
+    invoice_id CHAR(36) PRIMARY KEY REFERENCES invoices (id) ON DELETE CASCADE,
+    format TEXT NOT NULL,
+    number TEXT NOT NULL,
+    issue_date DATE NOT NULL,
+    due_date DATE NOT NULL,
+    total price NOT NULL,
+    seller_name TEXT NOT NULL,
+    seller_tax_id TEXT NOT NULL,
+    buyer_name TEXT NOT NULL,
+    buyer_tax_id TEXT NOT NULL,
+    lines JSONB NOT NULL
+);
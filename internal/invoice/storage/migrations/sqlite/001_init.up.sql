@@ -0,0 +1,62 @@
+CREATE TABLE invoices (
+    id TEXT PRIMARY KEY,
+    issuer_id TEXT NOT NULL,
+    price_number TEXT NOT NULL,
+    price_currency TEXT NOT NULL,
+    face_value_number TEXT NOT NULL,
+    face_value_currency TEXT NOT NULL,
+    due_date TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    funded_at TEXT,
+    traded_at TEXT,
+    rejections INTEGER NOT NULL DEFAULT 0,
+    file_hash TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX invoices_status_due_date_idx ON invoices (status, due_date, id);
+CREATE INDEX invoices_issuer_id_idx ON invoices (issuer_id);
+CREATE INDEX invoices_currency_code_idx ON invoices (price_currency, status);
+CREATE UNIQUE INDEX invoices_file_hash_idx ON invoices (file_hash) WHERE file_hash <> '';
+
+CREATE TABLE bids (
+    id TEXT PRIMARY KEY,
+    invoice_id TEXT NOT NULL REFERENCES invoices (id),
+    investor_id TEXT NOT NULL,
+    amount_number TEXT NOT NULL,
+    amount_currency TEXT NOT NULL,
+    active INTEGER NOT NULL
+);
+
+CREATE INDEX bids_invoice_id_idx ON bids (invoice_id) WHERE active = 1;
+CREATE INDEX bids_investor_id_idx ON bids (investor_id) WHERE active = 1;
+
+CREATE TABLE listings (
+    id TEXT PRIMARY KEY,
+    bid_id TEXT NOT NULL REFERENCES bids (id),
+    invoice_id TEXT NOT NULL REFERENCES invoices (id),
+    seller_id TEXT NOT NULL,
+    buyer_id TEXT,
+    price_number TEXT NOT NULL,
+    price_currency TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX listings_bid_id_listed_idx ON listings (bid_id) WHERE status = 'listed';
+CREATE INDEX listings_status_idx ON listings (status);
+CREATE INDEX listings_seller_id_idx ON listings (seller_id, status);
+
+CREATE TABLE extractions (
+    invoice_id TEXT PRIMARY KEY REFERENCES invoices (id) ON DELETE CASCADE,
+    number TEXT NOT NULL,
+    issue_date TEXT,
+    due_date TEXT,
+    total TEXT NOT NULL,
+    currency_code TEXT NOT NULL,
+    debtor_tax_id TEXT NOT NULL,
+    mismatches TEXT NOT NULL,
+    extracted_at TEXT NOT NULL
+);
+
+CREATE INDEX extractions_flagged_idx ON extractions (extracted_at) WHERE mismatches <> '[]';
@@ -0,0 +1,4 @@
+ALTER TABLE listings ADD COLUMN sold_at TEXT;
+UPDATE listings SET sold_at = created_at WHERE status = 'sold';
+
+CREATE INDEX listings_bid_id_idx ON listings (bid_id);
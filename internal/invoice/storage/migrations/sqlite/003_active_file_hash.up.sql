@@ -0,0 +1,3 @@
+DROP INDEX invoices_file_hash_idx;
+
+CREATE UNIQUE INDEX invoices_file_hash_idx ON invoices (file_hash) WHERE file_hash <> '' AND status IN ('open', 'locked');
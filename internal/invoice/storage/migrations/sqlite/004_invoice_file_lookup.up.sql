@@ -0,0 +1,3 @@
+CREATE INDEX invoices_created_at_idx ON invoices (created_at);
+
+CREATE INDEX invoices_file_hash_lookup_idx ON invoices (file_hash) WHERE file_hash <> '';
@@ -0,0 +1,16 @@
+ALTER TABLE invoices ADD COLUMN content_type TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE einvoices (
+    invoice_id TEXT PRIMARY KEY REFERENCES invoices (id) ON DELETE CASCADE,
+    format TEXT NOT NULL,
+    number TEXT NOT NULL,
+    issue_date TEXT NOT NULL,
+    due_date TEXT NOT NULL,
+    total_number TEXT NOT NULL,
+    total_currency TEXT NOT NULL,
+    seller_name TEXT NOT NULL,
+    seller_tax_id TEXT NOT NULL,
+    buyer_name TEXT NOT NULL,
+    buyer_tax_id TEXT NOT NULL,
+    lines TEXT NOT NULL
+);
@@ -0,0 +1,8 @@
+ALTER TABLE invoices ADD COLUMN price_units INTEGER NOT NULL DEFAULT 0;
+
+-- millionths of the price, parsed from its decimal text so no float is involved
+UPDATE invoices SET price_units =
+    CAST(CASE WHEN instr(price_number, '.') = 0 THEN price_number ELSE substr(price_number, 1, instr(price_number, '.') - 1) END AS INTEGER) * 1000000
+    + CAST(substr(CASE WHEN instr(price_number, '.') = 0 THEN '' ELSE substr(price_number, instr(price_number, '.') + 1) END || '000000', 1, 6) AS INTEGER);
+
+CREATE INDEX invoices_price_units_idx ON invoices (price_units, id);
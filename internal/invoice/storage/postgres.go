@@ -2,13 +2,21 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
 )
 
+const uniqueViolation = "23505"
+
 type Storage struct {
 	c *pgxpool.Pool
 }
@@ -18,20 +26,81 @@ func New(c *pgxpool.Pool) *Storage {
 }
 
 func (s *Storage) SaveInvoice(ctx context.Context, i invoice.Invoice) error {
-	const query = `INSERT INTO invoices (id, issuer_id, price, status) VALUES ($1, $2, $3, $4)`
+	const query = `INSERT INTO invoices (id, issuer_id, price, face_value, due_date, status, created_at, file_hash, content_type)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	if _, err := s.c.Exec(ctx, query, i.ID, i.IssuerID, i.Price, i.FaceValue, i.DueDate, i.Status, i.CreatedAt, i.FileHash, i.ContentType); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "invoices_file_hash_idx" {
+			return fmt.Errorf("could not save invoice in db: %w", invoice.ErrDuplicateFile)
+		}
 
-	if _, err := s.c.Exec(ctx, query, i.ID, i.IssuerID, i.Price, i.Status); err != nil {
 		return fmt.Errorf("could not save invoice in db: %w", err)
 	}
 
 	return nil
 }
 
+func (s *Storage) RetrieveInvoiceFiles(ctx context.Context, q invoice.FileQuery) ([]invoice.Invoice, error) {
+	const query = `SELECT i.id, i.file_hash, i.status, i.created_at FROM invoices i
+		WHERE i.created_at >= $1 AND i.created_at < $2 AND i.id > $3 ORDER BY i.id LIMIT $4`
+
+	return s.retrieveInvoiceFiles(ctx, query, q.From, q.To, q.After, q.Limit)
+}
+
+func (s *Storage) RetrieveInvoicesByFileHashes(ctx context.Context, hashes []string) ([]invoice.Invoice, error) {
+	const query = `SELECT i.id, i.file_hash, i.status, i.created_at FROM invoices i WHERE i.file_hash = any($1)`
+
+	return s.retrieveInvoiceFiles(ctx, query, hashes)
+}
+
+func (s *Storage) retrieveInvoiceFiles(ctx context.Context, query string, args ...any) ([]invoice.Invoice, error) {
+	rows, err := s.c.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve invoice files: %w", err)
+	}
+	defer rows.Close()
+
+	var invoices []invoice.Invoice
+	for rows.Next() {
+		var inv invoice.Invoice
+		if err := rows.Scan(&inv.ID, &inv.FileHash, &inv.Status, &inv.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan invoice files: %w", err)
+		}
+
+		invoices = append(invoices, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read invoice files: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// DeleteOrphanedInvoice removes an invoice as long as it is still open
+// without bids, so no money ever depended on it.
+func (s *Storage) DeleteOrphanedInvoice(ctx context.Context, id string) (bool, error) {
+	const query = `DELETE FROM invoices i WHERE i.id = $1 AND i.status = 'open'
+		AND NOT EXISTS (SELECT 1 FROM bids b WHERE b.invoice_id = i.id)`
+
+	tag, err := s.c.Exec(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("could not delete invoice from db: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
 func (s *Storage) RetrieveInvoice(ctx context.Context, id string) (invoice.Invoice, error) {
-	const query = `SELECT i.issuer_id, i.price, i.status FROM invoices i WHERE i.id = $1`
+	const query = `SELECT i.issuer_id, i.price, i.face_value, i.due_date, i.status, i.created_at, i.funded_at, i.traded_at, i.rejections, i.file_hash, i.content_type
+		FROM invoices i WHERE i.id = $1`
 
 	inv := invoice.Invoice{ID: id}
-	err := s.c.QueryRow(ctx, query, id).Scan(&inv.IssuerID, &inv.Price, &inv.Status)
+	err := s.c.QueryRow(ctx, query, id).Scan(&inv.IssuerID, &inv.Price, &inv.FaceValue, &inv.DueDate, &inv.Status,
+		&inv.CreatedAt, &inv.FundedAt, &inv.TradedAt, &inv.Rejections, &inv.FileHash, &inv.ContentType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return inv, fmt.Errorf("could not retrieve invoice: %w", invoice.ErrNotFound)
+	}
 	if err != nil {
 		return inv, fmt.Errorf("could not retrieve invoice: %w", err)
 	}
@@ -44,32 +113,229 @@ func (s *Storage) RetrieveInvoice(ctx context.Context, id string) (invoice.Invoi
 	return inv, nil
 }
 
+func (s *Storage) RetrieveInvoicesByIDs(ctx context.Context, ids []string) ([]invoice.Invoice, error) {
+	const query = `SELECT i.id, i.issuer_id, i.price, i.face_value, i.due_date, i.status, i.created_at, i.funded_at, i.traded_at, i.rejections, i.file_hash, i.content_type
+		FROM invoices i WHERE i.id = any($1)`
+
+	rows, err := s.c.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var invoices []invoice.Invoice
+	for rows.Next() {
+		var inv invoice.Invoice
+		if err := rows.Scan(&inv.ID, &inv.IssuerID, &inv.Price, &inv.FaceValue, &inv.DueDate, &inv.Status,
+			&inv.CreatedAt, &inv.FundedAt, &inv.TradedAt, &inv.Rejections, &inv.FileHash, &inv.ContentType); err != nil {
+			return nil, fmt.Errorf("could not scan invoice: %w", err)
+		}
+
+		invoices = append(invoices, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read invoices: %w", err)
+	}
+
+	if err := s.attachActiveBids(ctx, invoices); err != nil {
+		return nil, err
+	}
+
+	return invoices, nil
+}
+
 func (s *Storage) RetrieveInvoicesByIssuerID(ctx context.Context, issID string) ([]invoice.Invoice, error) {
-	const query = `SELECT i.id, i.price, i.status FROM invoices i WHERE i.issuer_id = $1`
+	const query = `SELECT i.id, i.price, i.face_value, i.due_date, i.status, i.created_at, i.funded_at, i.traded_at, i.rejections, i.file_hash, i.content_type
+		FROM invoices i WHERE i.issuer_id = $1`
 
 	rows, err := s.c.Query(ctx, query, issID)
 	if err != nil {
 		return nil, fmt.Errorf("could not retrieve bids: %w", err)
 	}
+	defer rows.Close()
 
 	var invoices []invoice.Invoice
 	for rows.Next() {
 		inv := invoice.Invoice{IssuerID: issID}
 
-		err := rows.Scan(&inv.ID, &inv.Price, &inv.Status)
+		err := rows.Scan(&inv.ID, &inv.Price, &inv.FaceValue, &inv.DueDate, &inv.Status,
+			&inv.CreatedAt, &inv.FundedAt, &inv.TradedAt, &inv.Rejections, &inv.FileHash, &inv.ContentType)
 		if err != nil {
 			return nil, fmt.Errorf("could not scan invoice: %w", err)
 		}
 
-		inv.Bids, err = s.RetrieveActiveBidsByInvoiceID(ctx, inv.ID)
-		if err != nil {
-			return nil, fmt.Errorf("could not retrieve invoice bids: %w", err)
+		invoices = append(invoices, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read invoices: %w", err)
+	}
+
+	if err := s.attachActiveBids(ctx, invoices); err != nil {
+		return nil, err
+	}
+
+	return invoices, nil
+}
+
+const aprExpr = `((i.face_value).number - (i.price).number) / NULLIF((i.price).number, 0) * 365 / GREATEST(i.due_date - CURRENT_DATE, 1)`
+
+// the apr depends on the current date so it can't be indexed as an expression,
+// it is stored in the apr column and recomputed once a day before it is used.
+// Invoices already due keep the same apr and don't need a refresh.
+const refreshAPRQuery = `UPDATE invoices i SET apr = ` + aprExpr + `, apr_date = CURRENT_DATE
+	WHERE i.apr_date IS NULL OR (i.apr_date < CURRENT_DATE AND i.due_date > i.apr_date + 1)`
+
+var sortColumns = map[invoice.SortField]struct {
+	expr string
+	typ  string
+}{
+	invoice.SortDueDate: {"i.due_date", "date"},
+	invoice.SortPrice:   {"(i.price).number", "numeric"},
+	invoice.SortAPR:     {"i.apr", "numeric"},
+}
+
+func (s *Storage) RetrieveInvoices(ctx context.Context, q invoice.Query) ([]invoice.Invoice, *invoice.Cursor, error) {
+	sortCol, ok := sortColumns[q.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown sort field %q", q.Sort)
+	}
+
+	if q.Sort == invoice.SortAPR || q.MinAPR != nil || q.MaxAPR != nil {
+		if _, err := s.c.Exec(ctx, refreshAPRQuery); err != nil {
+			return nil, nil, fmt.Errorf("could not refresh invoices apr: %w", err)
+		}
+	}
+
+	var conds []string
+	var args []any
+	where := func(cond string, params ...any) {
+		placeholders := make([]any, 0, len(params))
+		for _, p := range params {
+			args = append(args, p)
+			placeholders = append(placeholders, len(args))
+		}
+		conds = append(conds, fmt.Sprintf(cond, placeholders...))
+	}
+
+	if q.Status != "" {
+		where("i.status = $%d", q.Status)
+	}
+	if q.Currency != "" {
+		where("(i.price).currency_code = $%d", q.Currency)
+	}
+	if q.MinPrice != "" {
+		where("(i.price).number >= $%d::numeric", q.MinPrice)
+	}
+	if q.MaxPrice != "" {
+		where("(i.price).number <= $%d::numeric", q.MaxPrice)
+	}
+	if q.IssuerID != "" {
+		where("i.issuer_id = $%d", q.IssuerID)
+	}
+	if !q.DueAfter.IsZero() {
+		where("i.due_date >= $%d", q.DueAfter)
+	}
+	if !q.DueBefore.IsZero() {
+		where("i.due_date <= $%d", q.DueBefore)
+	}
+	if q.MinAPR != nil {
+		where("i.apr >= $%d", *q.MinAPR)
+	}
+	if q.MaxAPR != nil {
+		where("i.apr <= $%d", *q.MaxAPR)
+	}
+
+	cmp, dir := ">", "ASC"
+	if q.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	if q.After != nil {
+		where(fmt.Sprintf("(%s, i.id) %s ($%%d::%s, $%%d)", sortCol.expr, cmp, sortCol.typ), q.After.Value, q.After.ID)
+	}
+
+	query := fmt.Sprintf(`SELECT i.id, i.issuer_id, i.price, i.face_value, i.due_date, i.status, i.created_at, i.funded_at, i.traded_at, i.rejections, i.file_hash, i.content_type, (%s)::text
+		FROM invoices i`, sortCol.expr)
+	if len(conds) > 0 {
+		query = fmt.Sprintf("%s WHERE %s", query, strings.Join(conds, " AND "))
+	}
+	query = fmt.Sprintf("%s ORDER BY %s %s, i.id %s LIMIT %d", query, sortCol.expr, dir, dir, q.Limit+1)
+
+	rows, err := s.c.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var invoices []invoice.Invoice
+	var sortValues []string
+	for rows.Next() {
+		var inv invoice.Invoice
+		var sortValue string
+		if err := rows.Scan(&inv.ID, &inv.IssuerID, &inv.Price, &inv.FaceValue, &inv.DueDate, &inv.Status,
+			&inv.CreatedAt, &inv.FundedAt, &inv.TradedAt, &inv.Rejections, &inv.FileHash, &inv.ContentType, &sortValue); err != nil {
+			return nil, nil, fmt.Errorf("could not scan invoice: %w", err)
 		}
 
 		invoices = append(invoices, inv)
+		sortValues = append(sortValues, sortValue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read invoices: %w", err)
 	}
 
-	return invoices, nil
+	var next *invoice.Cursor
+	if len(invoices) > q.Limit {
+		invoices = invoices[:q.Limit]
+		next = &invoice.Cursor{
+			Sort:  q.Sort,
+			Desc:  q.Desc,
+			Value: sortValues[q.Limit-1],
+			ID:    invoices[q.Limit-1].ID,
+		}
+	}
+
+	if err := s.attachActiveBids(ctx, invoices); err != nil {
+		return nil, nil, err
+	}
+
+	return invoices, next, nil
+}
+
+// attachActiveBids loads the active bids of all the invoices in one query
+func (s *Storage) attachActiveBids(ctx context.Context, invoices []invoice.Invoice) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(invoices))
+	byID := make(map[string]int, len(invoices))
+	for i, inv := range invoices {
+		ids[i] = inv.ID
+		byID[inv.ID] = i
+	}
+
+	const query = `SELECT b.id, b.invoice_id, b.investor_id, b.amount FROM bids b WHERE b.invoice_id = any($1) AND b.active = true`
+
+	rows, err := s.c.Query(ctx, query, ids)
+	if err != nil {
+		return fmt.Errorf("could not retrieve invoice bids: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bid := invoice.Bid{Active: true}
+		if err := rows.Scan(&bid.ID, &bid.InvoiceID, &bid.InvestorID, &bid.Amount); err != nil {
+			return fmt.Errorf("could not scan bids: %w", err)
+		}
+
+		i := byID[bid.InvoiceID]
+		invoices[i].Bids = append(invoices[i].Bids, bid)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not read invoice bids: %w", err)
+	}
+
+	return nil
 }
 
 func (s *Storage) RetrieveBidsByIDs(ctx context.Context, bidsIDs []string) ([]invoice.Bid, error) {
@@ -79,6 +345,7 @@ func (s *Storage) RetrieveBidsByIDs(ctx context.Context, bidsIDs []string) ([]in
 	if err != nil {
 		return nil, fmt.Errorf("could not retrieve bids: %w", err)
 	}
+	defer rows.Close()
 
 	var bids []invoice.Bid
 	for rows.Next() {
@@ -91,16 +358,27 @@ func (s *Storage) RetrieveBidsByIDs(ctx context.Context, bidsIDs []string) ([]in
 
 		bids = append(bids, bid)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve bids: %w", err)
+	}
 
 	return bids, nil
 }
 
 func (s *Storage) UpdateStatus(ctx context.Context, id string, status invoice.Status) error {
-	const query = `UPDATE invoices SET status = $2 WHERE id = $1`
+	const query = `UPDATE invoices SET status = $2,
+		funded_at = CASE WHEN $2 = 'locked' THEN now() ELSE funded_at END,
+		traded_at = CASE WHEN $2 = 'traded' THEN now() ELSE traded_at END,
+		rejections = CASE WHEN $2 = 'open' AND status = 'locked' THEN rejections + 1 ELSE rejections END
+		WHERE id = $1`
 
-	if _, err := s.c.Exec(ctx, query, id, status); err != nil {
+	tag, err := s.c.Exec(ctx, query, id, status)
+	if err != nil {
 		return fmt.Errorf("could not update invoice status in db: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("could not update invoice status: %w", invoice.ErrNotFound)
+	}
 
 	return nil
 }
@@ -115,6 +393,46 @@ func (s *Storage) SaveBid(ctx context.Context, b invoice.Bid) error {
 	return nil
 }
 
+func (s *Storage) RetrieveBid(ctx context.Context, id string) (invoice.Bid, error) {
+	const query = `SELECT b.invoice_id, b.investor_id, b.amount, b.active FROM bids b WHERE b.id = $1`
+
+	bid := invoice.Bid{ID: id}
+	err := s.c.QueryRow(ctx, query, id).Scan(&bid.InvoiceID, &bid.InvestorID, &bid.Amount, &bid.Active)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return bid, fmt.Errorf("could not retrieve bid: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return bid, fmt.Errorf("could not retrieve bid: %w", err)
+	}
+
+	return bid, nil
+}
+
+func (s *Storage) RetrieveBidsByInvestorID(ctx context.Context, investorID string) ([]invoice.Bid, error) {
+	const query = `SELECT b.id, b.invoice_id, b.amount FROM bids b WHERE b.investor_id = $1 AND b.active = true`
+
+	rows, err := s.c.Query(ctx, query, investorID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve bids: %w", err)
+	}
+	defer rows.Close()
+
+	var bids []invoice.Bid
+	for rows.Next() {
+		bid := invoice.Bid{InvestorID: investorID, Active: true}
+		if err := rows.Scan(&bid.ID, &bid.InvoiceID, &bid.Amount); err != nil {
+			return nil, fmt.Errorf("could not scan bids: %w", err)
+		}
+
+		bids = append(bids, bid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve bids: %w", err)
+	}
+
+	return bids, nil
+}
+
 func (s *Storage) RetrieveActiveBidsByInvoiceID(ctx context.Context, invoiceID string) ([]invoice.Bid, error) {
 	const query = `SELECT b.id, b.investor_id, b.amount FROM bids b WHERE b.invoice_id = $1 AND b.active = true`
 
@@ -122,6 +440,7 @@ func (s *Storage) RetrieveActiveBidsByInvoiceID(ctx context.Context, invoiceID s
 	if err != nil {
 		return nil, fmt.Errorf("could not retrieve bids: %w", err)
 	}
+	defer rows.Close()
 
 	var bids []invoice.Bid
 	for rows.Next() {
@@ -132,6 +451,9 @@ func (s *Storage) RetrieveActiveBidsByInvoiceID(ctx context.Context, invoiceID s
 
 		bids = append(bids, bid)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve bids: %w", err)
+	}
 
 	return bids, nil
 }
@@ -145,3 +467,299 @@ func (s *Storage) DisableBidsByInvoiceID(ctx context.Context, id string) error {
 
 	return nil
 }
+
+func (s *Storage) SaveListing(ctx context.Context, l invoice.Listing) error {
+	const query = `INSERT INTO listings (id, bid_id, invoice_id, seller_id, price, status, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	if _, err := s.c.Exec(ctx, query, l.ID, l.BidID, l.InvoiceID, l.SellerID, l.Price, l.Status, l.CreatedAt); err != nil {
+		return fmt.Errorf("could not save listing in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrieveListing(ctx context.Context, id string) (invoice.Listing, error) {
+	const query = `SELECT l.bid_id, l.invoice_id, l.seller_id, COALESCE(l.buyer_id, ''), l.price, l.status, l.created_at, l.sold_at
+		FROM listings l WHERE l.id = $1`
+
+	l := invoice.Listing{ID: id}
+	err := s.c.QueryRow(ctx, query, id).Scan(&l.BidID, &l.InvoiceID, &l.SellerID, &l.BuyerID, &l.Price, &l.Status, &l.CreatedAt, &l.SoldAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return l, fmt.Errorf("could not retrieve listing: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return l, fmt.Errorf("could not retrieve listing: %w", err)
+	}
+
+	return l, nil
+}
+
+func (s *Storage) RetrieveListingsByStatus(ctx context.Context, status invoice.ListingStatus) ([]invoice.Listing, error) {
+	const query = `SELECT l.id, l.bid_id, l.invoice_id, l.seller_id, COALESCE(l.buyer_id, ''), l.price, l.status, l.created_at, l.sold_at
+		FROM listings l WHERE l.status = $1 ORDER BY l.created_at`
+
+	rows, err := s.c.Query(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve listings: %w", err)
+	}
+
+	return scanListings(rows)
+}
+
+func (s *Storage) RetrieveListingsByBidIDs(ctx context.Context, bidIDs []string) ([]invoice.Listing, error) {
+	const query = `SELECT l.id, l.bid_id, l.invoice_id, l.seller_id, COALESCE(l.buyer_id, ''), l.price, l.status, l.created_at, l.sold_at
+		FROM listings l WHERE l.bid_id = any($1) ORDER BY l.created_at`
+
+	rows, err := s.c.Query(ctx, query, bidIDs)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve listings: %w", err)
+	}
+
+	return scanListings(rows)
+}
+
+func (s *Storage) RetrieveListingsBySellerID(ctx context.Context, sellerID string, status invoice.ListingStatus) ([]invoice.Listing, error) {
+	const query = `SELECT l.id, l.bid_id, l.invoice_id, l.seller_id, COALESCE(l.buyer_id, ''), l.price, l.status, l.created_at, l.sold_at
+		FROM listings l WHERE l.seller_id = $1 AND l.status = $2 ORDER BY l.created_at`
+
+	rows, err := s.c.Query(ctx, query, sellerID, status)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve listings: %w", err)
+	}
+
+	return scanListings(rows)
+}
+
+func scanListings(rows pgx.Rows) ([]invoice.Listing, error) {
+	defer rows.Close()
+
+	var listings []invoice.Listing
+	for rows.Next() {
+		var l invoice.Listing
+		if err := rows.Scan(&l.ID, &l.BidID, &l.InvoiceID, &l.SellerID, &l.BuyerID, &l.Price, &l.Status, &l.CreatedAt, &l.SoldAt); err != nil {
+			return nil, fmt.Errorf("could not scan listings: %w", err)
+		}
+
+		listings = append(listings, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read listings: %w", err)
+	}
+
+	return listings, nil
+}
+
+// UpdateListingStatus moves a listing from one status to another, failing if
+// it is no longer in the expected status
+func (s *Storage) UpdateListingStatus(ctx context.Context, id string, from, to invoice.ListingStatus) error {
+	const (
+		query       = `UPDATE listings SET status = $3 WHERE id = $1 AND status = $2`
+		existsQuery = `SELECT EXISTS (SELECT 1 FROM listings l WHERE l.id = $1)`
+	)
+
+	tag, err := s.c.Exec(ctx, query, id, from, to)
+	if err != nil {
+		return fmt.Errorf("could not update listing status in db: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := s.c.QueryRow(ctx, existsQuery, id).Scan(&exists); err != nil {
+		return fmt.Errorf("could not retrieve listing: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("could not update listing status: %w", invoice.ErrNotFound)
+	}
+
+	return fmt.Errorf("%w: listing is no longer %s", invoice.ErrInvalidTransition, from)
+}
+
+// ReserveListing holds a listed position for the buyer while it is paid
+func (s *Storage) ReserveListing(ctx context.Context, id, buyerID string) error {
+	const query = `UPDATE listings SET status = 'reserved', buyer_id = $2 WHERE id = $1 AND status = 'listed'`
+
+	tag, err := s.c.Exec(ctx, query, id, buyerID)
+	if err != nil {
+		return fmt.Errorf("could not reserve listing in db: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: listing is no longer available", invoice.ErrInvalidTransition)
+	}
+
+	return nil
+}
+
+// ReleaseListing lists again a position reserved by the buyer
+func (s *Storage) ReleaseListing(ctx context.Context, id, buyerID string) error {
+	const query = `UPDATE listings SET status = 'listed', buyer_id = NULL WHERE id = $1 AND status = 'reserved' AND buyer_id = $2`
+
+	tag, err := s.c.Exec(ctx, query, id, buyerID)
+	if err != nil {
+		return fmt.Errorf("could not release listing in db: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: listing is not reserved by the buyer", invoice.ErrInvalidTransition)
+	}
+
+	return nil
+}
+
+// TransferListing sells a position reserved by the buyer and gives it the bid
+func (s *Storage) TransferListing(ctx context.Context, id, buyerID string) error {
+	const sellQuery = `UPDATE listings SET status = 'sold', sold_at = now() WHERE id = $1 AND status = 'reserved' AND buyer_id = $2 RETURNING bid_id`
+	const transferQuery = `UPDATE bids SET investor_id = $2 WHERE id = $1`
+
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var bidID string
+	if err := tx.QueryRow(ctx, sellQuery, id, buyerID).Scan(&bidID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: listing is not reserved by the buyer", invoice.ErrInvalidTransition)
+		}
+
+		return fmt.Errorf("could not sell listing in db: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, transferQuery, bidID, buyerID); err != nil {
+		return fmt.Errorf("could not transfer bid in db: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveExtraction(ctx context.Context, e invoice.Extraction) error {
+	const query = `INSERT INTO extractions (invoice_id, number, issue_date, due_date, total, currency_code, debtor_tax_id, mismatches, extracted_at, reviewed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (invoice_id) DO UPDATE SET number = $2, issue_date = $3, due_date = $4, total = $5,
+			currency_code = $6, debtor_tax_id = $7, mismatches = $8, extracted_at = $9, reviewed_at = $10`
+
+	mismatches := make([]string, 0, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		mismatches = append(mismatches, string(m))
+	}
+
+	if _, err := s.c.Exec(ctx, query, e.InvoiceID, e.Number, e.IssueDate, e.DueDate, e.Total, e.Currency, e.DebtorTaxID, mismatches, e.ExtractedAt, e.ReviewedAt); err != nil {
+		return fmt.Errorf("could not save extraction in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrieveExtraction(ctx context.Context, invoiceID string) (invoice.Extraction, error) {
+	const query = `SELECT e.invoice_id, e.number, e.issue_date, e.due_date, e.total, e.currency_code, e.debtor_tax_id, e.mismatches, e.extracted_at, e.reviewed_at
+		FROM extractions e WHERE e.invoice_id = $1`
+
+	e, err := scanExtraction(s.c.QueryRow(ctx, query, invoiceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return e, fmt.Errorf("could not retrieve extraction: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return e, fmt.Errorf("could not retrieve extraction: %w", err)
+	}
+
+	return e, nil
+}
+
+func (s *Storage) RetrieveFlaggedExtractions(ctx context.Context) ([]invoice.Extraction, error) {
+	const query = `SELECT e.invoice_id, e.number, e.issue_date, e.due_date, e.total, e.currency_code, e.debtor_tax_id, e.mismatches, e.extracted_at, e.reviewed_at
+		FROM extractions e WHERE cardinality(e.mismatches) > 0 AND e.reviewed_at IS NULL ORDER BY e.extracted_at`
+
+	rows, err := s.c.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve flagged extractions: %w", err)
+	}
+	defer rows.Close()
+
+	var extractions []invoice.Extraction
+	for rows.Next() {
+		e, err := scanExtraction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan extraction: %w", err)
+		}
+
+		extractions = append(extractions, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read flagged extractions: %w", err)
+	}
+
+	return extractions, nil
+}
+
+func (s *Storage) ReviewExtraction(ctx context.Context, invoiceID string, at time.Time) error {
+	const query = `UPDATE extractions SET reviewed_at = $2 WHERE invoice_id = $1`
+
+	res, err := s.c.Exec(ctx, query, invoiceID, at)
+	if err != nil {
+		return fmt.Errorf("could not review extraction in db: %w", err)
+	}
+
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("could not review extraction: %w", invoice.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveEInvoice(ctx context.Context, invoiceID string, doc einvoice.Document) error {
+	const query = `INSERT INTO einvoices (invoice_id, format, number, issue_date, due_date, total, seller_name, seller_tax_id, buyer_name, buyer_tax_id, lines)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	lines, err := marshalLines(doc.Lines)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.c.Exec(ctx, query, invoiceID, doc.Format, doc.Number, doc.IssueDate, doc.DueDate, doc.Total,
+		doc.Seller.Name, doc.Seller.TaxID, doc.Buyer.Name, doc.Buyer.TaxID, lines); err != nil {
+		return fmt.Errorf("could not save e-invoice in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrieveEInvoice(ctx context.Context, invoiceID string) (einvoice.Document, error) {
+	const query = `SELECT e.format, e.number, e.issue_date, e.due_date, e.total, e.seller_name, e.seller_tax_id, e.buyer_name, e.buyer_tax_id, e.lines
+		FROM einvoices e WHERE e.invoice_id = $1`
+
+	var doc einvoice.Document
+	var lines []byte
+	err := s.c.QueryRow(ctx, query, invoiceID).Scan(&doc.Format, &doc.Number, &doc.IssueDate, &doc.DueDate, &doc.Total,
+		&doc.Seller.Name, &doc.Seller.TaxID, &doc.Buyer.Name, &doc.Buyer.TaxID, &lines)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return doc, fmt.Errorf("could not retrieve e-invoice: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return doc, fmt.Errorf("could not retrieve e-invoice: %w", err)
+	}
+
+	if doc.Lines, err = unmarshalLines(lines); err != nil {
+		return doc, err
+	}
+
+	return doc, nil
+}
+
+func scanExtraction(row pgx.Row) (invoice.Extraction, error) {
+	var e invoice.Extraction
+	var mismatches []string
+	if err := row.Scan(&e.InvoiceID, &e.Number, &e.IssueDate, &e.DueDate, &e.Total, &e.Currency, &e.DebtorTaxID, &mismatches, &e.ExtractedAt, &e.ReviewedAt); err != nil {
+		return e, err
+	}
+
+	for _, m := range mismatches {
+		e.Mismatches = append(e.Mismatches, invoice.Mismatch(m))
+	}
+
+	return e, nil
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/storage/storagetest"
+	"github.com/nerock/invoicebidder/internal/pgtest"
+)
+
+func TestPostgresStorage(t *testing.T) {
+	pool := pgtest.New(t, "migrations")
+
+	storagetest.Run(t, func(t *testing.T) invoice.Storage {
+		pgtest.Truncate(t, pool)
+		return New(pool)
+	})
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+// pageInvoices filters, sorts and cuts a page of invoices in go, for the
+// storages that cannot compare prices or aprs in their queries
+func pageInvoices(invoices []invoice.Invoice, q invoice.Query, today time.Time) ([]invoice.Invoice, *invoice.Cursor, error) {
+	sortValue, ok := sortValues[q.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown sort field %q", q.Sort)
+	}
+	cmp := compareNumbers
+	if q.Sort == invoice.SortDueDate {
+		cmp = compareDates
+	}
+
+	type sorted struct {
+		inv   invoice.Invoice
+		value string
+	}
+
+	var matches []sorted
+	for _, inv := range invoices {
+		ok, err := matchesQuery(inv, q, today)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		value := sortValue(inv, today)
+		if q.After != nil {
+			c, err := cmp(value, q.After.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			if c == 0 {
+				c = compareStrings(inv.ID, q.After.ID)
+			}
+			if (!q.Desc && c <= 0) || (q.Desc && c >= 0) {
+				continue
+			}
+		}
+
+		matches = append(matches, sorted{inv, value})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		c, _ := cmp(matches[i].value, matches[j].value)
+		if c == 0 {
+			c = compareStrings(matches[i].inv.ID, matches[j].inv.ID)
+		}
+		if q.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	var next *invoice.Cursor
+	if len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+		next = &invoice.Cursor{
+			Sort:  q.Sort,
+			Desc:  q.Desc,
+			Value: matches[q.Limit-1].value,
+			ID:    matches[q.Limit-1].inv.ID,
+		}
+	}
+
+	page := make([]invoice.Invoice, 0, len(matches))
+	for _, m := range matches {
+		page = append(page, m.inv)
+	}
+
+	return page, next, nil
+}
+
+// sortValues render the sort value of an invoice like postgres casts it to
+// text, so cursors keep the same shape on every storage
+var sortValues = map[invoice.SortField]func(invoice.Invoice, time.Time) string{
+	invoice.SortDueDate: func(inv invoice.Invoice, _ time.Time) string { return inv.DueDate.Format(time.DateOnly) },
+	invoice.SortPrice:   func(inv invoice.Invoice, _ time.Time) string { return inv.Price.Number() },
+	invoice.SortAPR: func(inv invoice.Invoice, today time.Time) string {
+		apr := invoiceAPR(inv, today)
+		if apr == nil {
+			return "0"
+		}
+		return apr.FloatString(20)
+	},
+}
+
+func matchesQuery(inv invoice.Invoice, q invoice.Query, today time.Time) (bool, error) {
+	if q.Status != "" && inv.Status != q.Status {
+		return false, nil
+	}
+	if q.Currency != "" && inv.Price.CurrencyCode() != q.Currency {
+		return false, nil
+	}
+	if q.IssuerID != "" && inv.IssuerID != q.IssuerID {
+		return false, nil
+	}
+	if !q.DueAfter.IsZero() && date(inv.DueDate).Before(date(q.DueAfter)) {
+		return false, nil
+	}
+	if !q.DueBefore.IsZero() && date(inv.DueDate).After(date(q.DueBefore)) {
+		return false, nil
+	}
+
+	for _, bound := range []struct {
+		limit string
+		keep  func(int) bool
+	}{
+		{q.MinPrice, func(c int) bool { return c >= 0 }},
+		{q.MaxPrice, func(c int) bool { return c <= 0 }},
+	} {
+		if bound.limit == "" {
+			continue
+		}
+
+		c, err := compareNumbers(inv.Price.Number(), bound.limit)
+		if err != nil {
+			return false, err
+		}
+		if !bound.keep(c) {
+			return false, nil
+		}
+	}
+
+	if q.MinAPR != nil || q.MaxAPR != nil {
+		apr := invoiceAPR(inv, today)
+		if apr == nil {
+			return false, nil
+		}
+		if q.MinAPR != nil && apr.Cmp(new(big.Rat).SetFloat64(*q.MinAPR)) < 0 {
+			return false, nil
+		}
+		if q.MaxAPR != nil && apr.Cmp(new(big.Rat).SetFloat64(*q.MaxAPR)) > 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// invoiceAPR annualizes the discount of the invoice over the days left to its
+// due date, nil when it has no price like the NULLIF of the postgres query
+func invoiceAPR(inv invoice.Invoice, today time.Time) *big.Rat {
+	price, ok := new(big.Rat).SetString(inv.Price.Number())
+	if !ok || price.Sign() == 0 {
+		return nil
+	}
+	faceValue, ok := new(big.Rat).SetString(inv.FaceValue.Number())
+	if !ok {
+		return nil
+	}
+
+	days := int64(date(inv.DueDate).Sub(today).Hours() / 24)
+	if days < 1 {
+		days = 1
+	}
+
+	apr := new(big.Rat).Sub(faceValue, price)
+	apr.Quo(apr, price)
+	apr.Mul(apr, big.NewRat(365, days))
+
+	return apr
+}
+
+func compareNumbers(a, b string) (int, error) {
+	x, ok := new(big.Rat).SetString(a)
+	if !ok {
+		return 0, fmt.Errorf("invalid number %q", a)
+	}
+	y, ok := new(big.Rat).SetString(b)
+	if !ok {
+		return 0, fmt.Errorf("invalid number %q", b)
+	}
+
+	return x.Cmp(y), nil
+}
+
+func compareDates(a, b string) (int, error) {
+	x, err := time.Parse(time.DateOnly, a)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q", a)
+	}
+	y, err := time.Parse(time.DateOnly, b)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q", b)
+	}
+
+	return x.Compare(y), nil
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func date(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+// S3FileStorage keeps invoice files in a bucket of an S3 compatible object
+// store, so they are shared between replicas and survive restarts.
+type S3FileStorage struct {
+	c      *minio.Client
+	bucket string
+}
+
+func NewS3FileStorage(c *minio.Client, bucket string) *S3FileStorage {
+	return &S3FileStorage{c, bucket}
+}
+
+// StageFile uploads the file under the staging prefix with the invoice id
+// and returns its SHA-256 hash. The upload is spooled to a temporary file
+// first, so the object is put with a known size.
+func (fs *S3FileStorage) StageFile(id string, src io.Reader) (string, error) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return "", fmt.Errorf("could not create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(tmp, io.TeeReader(src, h))
+	if err != nil {
+		return "", fmt.Errorf("could not save file: %w", err)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("could not save file: %w", err)
+	}
+
+	if _, err := fs.c.PutObject(context.Background(), fs.bucket, stagedKey(id), tmp, size, minio.PutObjectOptions{}); err != nil {
+		return "", fmt.Errorf("could not save file: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CommitFile copies the staged object to its hash and removes it from
+// staging. Object stores have no rename, but the copy is atomic.
+func (fs *S3FileStorage) CommitFile(id, hash string) error {
+	ctx := context.Background()
+	if _, err := fs.c.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: fs.bucket, Object: hash},
+		minio.CopySrcOptions{Bucket: fs.bucket, Object: stagedKey(id)},
+	); err != nil {
+		return fmt.Errorf("could not commit file: %w", notExist(err))
+	}
+
+	return fs.DiscardFile(id)
+}
+
+func (fs *S3FileStorage) DiscardFile(id string) error {
+	if err := fs.c.RemoveObject(context.Background(), fs.bucket, stagedKey(id), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("could not discard file: %w", err)
+	}
+
+	return nil
+}
+
+func (fs *S3FileStorage) OpenFile(key string) (invoice.Document, error) {
+	obj, err := fs.c.GetObject(context.Background(), fs.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return invoice.Document{}, fmt.Errorf("could not open file: %w", err)
+	}
+
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return invoice.Document{}, fmt.Errorf("could not stat file: %w", notExist(err))
+	}
+
+	return invoice.Document{
+		ReadSeekCloser: obj,
+		Size:           info.Size,
+		ModTime:        info.LastModified,
+	}, nil
+}
+
+func (fs *S3FileStorage) ListFiles() ([]invoice.StoredFile, error) {
+	return fs.list("", func(key string) bool { return fileKeyRe.MatchString(key) })
+}
+
+func (fs *S3FileStorage) ListStaged() ([]invoice.StoredFile, error) {
+	return fs.list(stagingDir+"/", func(string) bool { return true })
+}
+
+// DeleteFile removes a stored object unless it was modified after the given
+// time, which means it was uploaded again since it was listed.
+func (fs *S3FileStorage) DeleteFile(key string, olderThan time.Time) error {
+	ctx := context.Background()
+	info, err := fs.c.StatObject(ctx, fs.bucket, key, minio.StatObjectOptions{})
+	if err := notExist(err); errors.Is(err, iofs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not stat file: %w", err)
+	}
+
+	if info.LastModified.After(olderThan) {
+		return nil
+	}
+
+	if err := fs.c.RemoveObject(ctx, fs.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("could not delete file: %w", err)
+	}
+
+	return nil
+}
+
+func (fs *S3FileStorage) list(prefix string, match func(string) bool) ([]invoice.StoredFile, error) {
+	var files []invoice.StoredFile
+	for obj := range fs.c.ListObjects(context.Background(), fs.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("could not list files: %w", obj.Err)
+		}
+
+		key := strings.TrimPrefix(obj.Key, prefix)
+		if strings.Contains(key, "/") || !match(key) {
+			continue
+		}
+
+		files = append(files, invoice.StoredFile{Key: key, ModTime: obj.LastModified})
+	}
+
+	return files, nil
+}
+
+func stagedKey(id string) string {
+	return stagingDir + "/" + id
+}
+
+// notExist translates missing objects into fs.ErrNotExist like local files
+func notExist(err error) error {
+	if err != nil && minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", iofs.ErrNotExist, err)
+	}
+
+	return err
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/storage/storagetest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestS3FileStorage(t *testing.T) {
+	Convey("S3FileStorage", t, func() {
+		backend := s3mem.New()
+		So(backend.CreateBucket("invoices"), ShouldBeNil)
+
+		srv := httptest.NewServer(gofakes3.New(backend).Server())
+		defer srv.Close()
+
+		u, _ := url.Parse(srv.URL)
+		// The fake server does not decode v4 streaming uploads, so sign with v2
+		c, err := minio.New(u.Host, &minio.Options{
+			Creds: credentials.NewStaticV2("key", "secret", ""),
+		})
+		So(err, ShouldBeNil)
+
+		fst := NewS3FileStorage(c, "invoices")
+
+		Convey("when the file was staged and committed", func() {
+			hash, err := fst.StageFile(testID, strings.NewReader("%PDF-1.7 invoice"))
+			So(err, ShouldBeNil)
+			So(hash, ShouldEqual, testHash)
+			So(fst.CommitFile(testID, hash), ShouldBeNil)
+
+			Convey("open it by its hash with its size", func() {
+				doc, err := fst.OpenFile(hash)
+				So(err, ShouldBeNil)
+				defer doc.Close()
+
+				So(doc.Size, ShouldEqual, 16)
+
+				_, err = doc.Seek(9, io.SeekStart)
+				So(err, ShouldBeNil)
+				content, err := io.ReadAll(doc)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "invoice")
+			})
+
+			Convey("list it as stored and no longer as staged", func() {
+				files, err := fst.ListFiles()
+				So(err, ShouldBeNil)
+				So(files, ShouldHaveLength, 1)
+				So(files[0].Key, ShouldEqual, hash)
+
+				staged, err := fst.ListStaged()
+				So(err, ShouldBeNil)
+				So(staged, ShouldBeEmpty)
+			})
+
+			Convey("delete it if it is older than the given time", func() {
+				So(fst.DeleteFile(hash, time.Now().Add(time.Hour)), ShouldBeNil)
+
+				_, err := fst.OpenFile(hash)
+				So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the file was staged only", func() {
+			_, err := fst.StageFile(testID, strings.NewReader("%PDF-1.7 invoice"))
+			So(err, ShouldBeNil)
+
+			Convey("list it as staged", func() {
+				staged, err := fst.ListStaged()
+				So(err, ShouldBeNil)
+				So(staged, ShouldHaveLength, 1)
+				So(staged[0].Key, ShouldEqual, testID)
+			})
+
+			Convey("discarding it removes it from staging", func() {
+				So(fst.DiscardFile(testID), ShouldBeNil)
+
+				staged, err := fst.ListStaged()
+				So(err, ShouldBeNil)
+				So(staged, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the file does not exist", func() {
+			_, err := fst.OpenFile("missing")
+
+			Convey("return a not exist error", func() {
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the bucket does not exist", func() {
+			fst := NewS3FileStorage(c, "missing")
+
+			Convey("fail to save the file", func() {
+				_, err := fst.StageFile(testID, strings.NewReader("invoice"))
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestS3FileStorageSuite(t *testing.T) {
+	storagetest.RunFiles(t, func(t *testing.T) invoice.FileStorage {
+		backend := s3mem.New()
+		So(backend.CreateBucket("invoices"), ShouldBeNil)
+
+		srv := httptest.NewServer(gofakes3.New(backend).Server())
+		t.Cleanup(srv.Close)
+
+		u, _ := url.Parse(srv.URL)
+		c, err := minio.New(u.Host, &minio.Options{
+			Creds: credentials.NewStaticV2("key", "secret", ""),
+		})
+		So(err, ShouldBeNil)
+
+		return NewS3FileStorage(c, "invoices")
+	})
+}
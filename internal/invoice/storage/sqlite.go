@@ -0,0 +1,863 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+	"github.com/nerock/invoicebidder/internal/sqlite"
+)
+
+//go:embed migrations/sqlite/*.up.sql
+var sqliteMigrations embed.FS
+
+// SQLiteStorage keeps invoices in an embedded sqlite db, amounts are stored
+// as their decimal text and currency code. Prices are also kept in integer
+// units, so they are compared without floats.
+type SQLiteStorage struct {
+	c *sql.DB
+}
+
+func NewSQLiteStorage(c *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{c}
+}
+
+func (s *SQLiteStorage) Migrate(ctx context.Context) error {
+	migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	return sqlite.Migrate(ctx, s.c, migrations)
+}
+
+const sqliteInvoiceColumns = `i.id, i.issuer_id, (i.price_number || ' ' || i.price_currency), (i.face_value_number || ' ' || i.face_value_currency),
+	i.due_date, i.status, i.created_at, i.funded_at, i.traded_at, i.rejections, i.file_hash, i.content_type`
+
+func sqliteInvoiceDest(inv *invoice.Invoice) []any {
+	return []any{&inv.ID, &inv.IssuerID, sqlite.Amount{A: &inv.Price}, sqlite.Amount{A: &inv.FaceValue}, sqlite.Date{T: &inv.DueDate},
+		&inv.Status, sqlite.Time{T: &inv.CreatedAt}, sqlite.NullTime{T: &inv.FundedAt}, sqlite.NullTime{T: &inv.TradedAt}, &inv.Rejections, &inv.FileHash, &inv.ContentType}
+}
+
+func (s *SQLiteStorage) SaveInvoice(ctx context.Context, i invoice.Invoice) error {
+	const query = `INSERT INTO invoices (id, issuer_id, price_number, price_currency, price_units, face_value_number, face_value_currency, due_date, status,
+		created_at, file_hash, content_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	units, err := sqlite.Units(i.Price.Number(), false)
+	if err != nil {
+		return fmt.Errorf("could not save invoice in db: %w", err)
+	}
+
+	if _, err := s.c.ExecContext(ctx, query, i.ID, i.IssuerID, i.Price.Number(), i.Price.CurrencyCode(), units, i.FaceValue.Number(), i.FaceValue.CurrencyCode(),
+		sqlite.FormatDate(i.DueDate), i.Status, sqlite.FormatTime(i.CreatedAt), i.FileHash, i.ContentType); err != nil {
+		if sqlite.IsUniqueViolation(err, "invoices.file_hash") {
+			return fmt.Errorf("could not save invoice in db: %w", invoice.ErrDuplicateFile)
+		}
+
+		return fmt.Errorf("could not save invoice in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveInvoiceFiles(ctx context.Context, q invoice.FileQuery) ([]invoice.Invoice, error) {
+	const query = `SELECT i.id, i.file_hash, i.status, i.created_at FROM invoices i
+		WHERE i.created_at >= ? AND i.created_at < ? AND i.id > ? ORDER BY i.id LIMIT ?`
+
+	return s.retrieveInvoiceFiles(ctx, query, sqlite.FormatTime(q.From), sqlite.FormatTime(q.To), q.After, q.Limit)
+}
+
+func (s *SQLiteStorage) RetrieveInvoicesByFileHashes(ctx context.Context, hashes []string) ([]invoice.Invoice, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`SELECT i.id, i.file_hash, i.status, i.created_at FROM invoices i WHERE i.file_hash IN (%s)`, placeholders(len(hashes)))
+
+	args := make([]any, len(hashes))
+	for i, hash := range hashes {
+		args[i] = hash
+	}
+
+	return s.retrieveInvoiceFiles(ctx, query, args...)
+}
+
+func (s *SQLiteStorage) retrieveInvoiceFiles(ctx context.Context, query string, args ...any) ([]invoice.Invoice, error) {
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve invoice files: %w", err)
+	}
+	defer rows.Close()
+
+	var invoices []invoice.Invoice
+	for rows.Next() {
+		var inv invoice.Invoice
+		if err := rows.Scan(&inv.ID, &inv.FileHash, &inv.Status, sqlite.Time{T: &inv.CreatedAt}); err != nil {
+			return nil, fmt.Errorf("could not scan invoice files: %w", err)
+		}
+
+		invoices = append(invoices, inv)
+	}
+
+	return invoices, rows.Err()
+}
+
+// DeleteOrphanedInvoice removes an invoice as long as it is still open
+// without bids, so no money ever depended on it.
+func (s *SQLiteStorage) DeleteOrphanedInvoice(ctx context.Context, id string) (bool, error) {
+	const query = `DELETE FROM invoices WHERE id = ? AND status = 'open'
+		AND NOT EXISTS (SELECT 1 FROM bids b WHERE b.invoice_id = invoices.id)`
+
+	res, err := s.c.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("could not delete invoice from db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not delete invoice from db: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *SQLiteStorage) RetrieveInvoice(ctx context.Context, id string) (invoice.Invoice, error) {
+	query := fmt.Sprintf(`SELECT %s FROM invoices i WHERE i.id = ?`, sqliteInvoiceColumns)
+
+	inv := invoice.Invoice{ID: id}
+	err := s.c.QueryRowContext(ctx, query, id).Scan(sqliteInvoiceDest(&inv)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return inv, fmt.Errorf("could not retrieve invoice: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return inv, fmt.Errorf("could not retrieve invoice: %w", err)
+	}
+
+	inv.Bids, err = s.RetrieveActiveBidsByInvoiceID(ctx, id)
+	if err != nil {
+		return inv, fmt.Errorf("could not retrieve invoice bids: %w", err)
+	}
+
+	return inv, nil
+}
+
+func (s *SQLiteStorage) RetrieveInvoicesByIDs(ctx context.Context, ids []string) ([]invoice.Invoice, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM invoices i WHERE i.id IN (%s)`, sqliteInvoiceColumns, placeholders(len(ids)))
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	return s.retrieveInvoices(ctx, query, args...)
+}
+
+func (s *SQLiteStorage) RetrieveInvoicesByIssuerID(ctx context.Context, issID string) ([]invoice.Invoice, error) {
+	query := fmt.Sprintf(`SELECT %s FROM invoices i WHERE i.issuer_id = ?`, sqliteInvoiceColumns)
+
+	return s.retrieveInvoices(ctx, query, issID)
+}
+
+func (s *SQLiteStorage) retrieveInvoices(ctx context.Context, query string, args ...any) ([]invoice.Invoice, error) {
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+
+	var invoices []invoice.Invoice
+	for rows.Next() {
+		var inv invoice.Invoice
+		if err := rows.Scan(sqliteInvoiceDest(&inv)...); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("could not scan invoice: %w", err)
+		}
+
+		invoices = append(invoices, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+
+	// bids are retrieved once the rows are closed, as the db has a single
+	// connection
+	if err := s.withActiveBids(ctx, invoices); err != nil {
+		return nil, err
+	}
+
+	return invoices, nil
+}
+
+// sqliteSortColumns sort by expr and put the sort value in cursors as text,
+// prices are compared on their integer units so no float is involved
+var sqliteSortColumns = map[invoice.SortField]struct {
+	expr string
+	text string
+}{
+	invoice.SortDueDate: {"i.due_date", "i.due_date"},
+	invoice.SortPrice:   {"i.price_units", "i.price_number"},
+}
+
+func (s *SQLiteStorage) RetrieveInvoices(ctx context.Context, q invoice.Query) ([]invoice.Invoice, *invoice.Cursor, error) {
+	sortCol, ok := sqliteSortColumns[q.Sort]
+	if !ok && q.Sort != invoice.SortAPR {
+		return nil, nil, fmt.Errorf("unknown sort field %q", q.Sort)
+	}
+
+	var conds []string
+	var args []any
+	where := func(cond string, params ...any) {
+		conds = append(conds, cond)
+		args = append(args, params...)
+	}
+
+	if q.Status != "" {
+		where("i.status = ?", q.Status)
+	}
+	if q.Currency != "" {
+		where("i.price_currency = ?", q.Currency)
+	}
+	if q.MinPrice != "" {
+		units, err := sqlite.Units(q.MinPrice, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+		}
+		where("i.price_units >= ?", units)
+	}
+	if q.MaxPrice != "" {
+		units, err := sqlite.Units(q.MaxPrice, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+		}
+		where("i.price_units <= ?", units)
+	}
+	if q.IssuerID != "" {
+		where("i.issuer_id = ?", q.IssuerID)
+	}
+	if !q.DueAfter.IsZero() {
+		where("i.due_date >= ?", sqlite.FormatDate(q.DueAfter))
+	}
+	if !q.DueBefore.IsZero() {
+		where("i.due_date <= ?", sqlite.FormatDate(q.DueBefore))
+	}
+
+	// the apr is a ratio over the days left, so it is filtered and sorted in
+	// go over the invoices matching the rest of the query
+	if q.Sort == invoice.SortAPR || q.MinAPR != nil || q.MaxAPR != nil {
+		return s.retrieveInvoicesByAPR(ctx, q, conds, args)
+	}
+
+	cmp, dir := ">", "ASC"
+	if q.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	if q.After != nil {
+		after := any(q.After.Value)
+		if q.Sort == invoice.SortPrice {
+			units, err := sqlite.Units(q.After.Value, false)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+			}
+			after = units
+		}
+		where(fmt.Sprintf("(%s, i.id) %s (?, ?)", sortCol.expr, cmp), after, q.After.ID)
+	}
+
+	query := fmt.Sprintf(`SELECT %s, %s FROM invoices i`, sqliteInvoiceColumns, sortCol.text)
+	if len(conds) > 0 {
+		query = fmt.Sprintf("%s WHERE %s", query, strings.Join(conds, " AND "))
+	}
+	query = fmt.Sprintf("%s ORDER BY %s %s, i.id %s LIMIT %d", query, sortCol.expr, dir, dir, q.Limit+1)
+
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+
+	var invoices []invoice.Invoice
+	var sortValues []string
+	for rows.Next() {
+		var inv invoice.Invoice
+		var sortValue string
+		if err := rows.Scan(append(sqliteInvoiceDest(&inv), &sortValue)...); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("could not scan invoice: %w", err)
+		}
+
+		invoices = append(invoices, inv)
+		sortValues = append(sortValues, sortValue)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+
+	var next *invoice.Cursor
+	if len(invoices) > q.Limit {
+		invoices = invoices[:q.Limit]
+		next = &invoice.Cursor{
+			Sort:  q.Sort,
+			Desc:  q.Desc,
+			Value: sortValues[q.Limit-1],
+			ID:    invoices[q.Limit-1].ID,
+		}
+	}
+
+	if err := s.withActiveBids(ctx, invoices); err != nil {
+		return nil, nil, err
+	}
+
+	return invoices, next, nil
+}
+
+func (s *SQLiteStorage) retrieveInvoicesByAPR(ctx context.Context, q invoice.Query, conds []string, args []any) ([]invoice.Invoice, *invoice.Cursor, error) {
+	query := fmt.Sprintf(`SELECT %s FROM invoices i`, sqliteInvoiceColumns)
+	if len(conds) > 0 {
+		query = fmt.Sprintf("%s WHERE %s", query, strings.Join(conds, " AND "))
+	}
+
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+
+	var all []invoice.Invoice
+	for rows.Next() {
+		var inv invoice.Invoice
+		if err := rows.Scan(sqliteInvoiceDest(&inv)...); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("could not scan invoice: %w", err)
+		}
+
+		all = append(all, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+
+	invoices, next, err := pageInvoices(all, q, date(time.Now()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not retrieve invoices: %w", err)
+	}
+
+	if err := s.withActiveBids(ctx, invoices); err != nil {
+		return nil, nil, err
+	}
+
+	return invoices, next, nil
+}
+
+// withActiveBids loads the active bids of every invoice in one query
+func (s *SQLiteStorage) withActiveBids(ctx context.Context, invoices []invoice.Invoice) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(`SELECT b.id, b.invoice_id, b.investor_id, (b.amount_number || ' ' || b.amount_currency) FROM bids b
+		WHERE b.invoice_id IN (%s) AND b.active = 1`, placeholders(len(invoices)))
+
+	args := make([]any, len(invoices))
+	for i, inv := range invoices {
+		args[i] = inv.ID
+	}
+
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("could not retrieve invoice bids: %w", err)
+	}
+	defer rows.Close()
+
+	bids := make(map[string][]invoice.Bid, len(invoices))
+	for rows.Next() {
+		bid := invoice.Bid{Active: true}
+		if err := rows.Scan(&bid.ID, &bid.InvoiceID, &bid.InvestorID, sqlite.Amount{A: &bid.Amount}); err != nil {
+			return fmt.Errorf("could not scan bids: %w", err)
+		}
+
+		bids[bid.InvoiceID] = append(bids[bid.InvoiceID], bid)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not retrieve invoice bids: %w", err)
+	}
+
+	for i := range invoices {
+		invoices[i].Bids = bids[invoices[i].ID]
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveBidsByIDs(ctx context.Context, bidsIDs []string) ([]invoice.Bid, error) {
+	if len(bidsIDs) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`SELECT b.id, b.investor_id, (b.amount_number || ' ' || b.amount_currency) FROM bids b WHERE b.id IN (%s)`, placeholders(len(bidsIDs)))
+
+	args := make([]any, 0, len(bidsIDs))
+	for _, id := range bidsIDs {
+		args = append(args, id)
+	}
+
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve bids: %w", err)
+	}
+	defer rows.Close()
+
+	var bids []invoice.Bid
+	for rows.Next() {
+		var bid invoice.Bid
+		if err := rows.Scan(&bid.ID, &bid.InvestorID, sqlite.Amount{A: &bid.Amount}); err != nil {
+			return nil, fmt.Errorf("could not scan bids: %w", err)
+		}
+
+		bids = append(bids, bid)
+	}
+
+	return bids, rows.Err()
+}
+
+func (s *SQLiteStorage) UpdateStatus(ctx context.Context, id string, status invoice.Status) error {
+	const query = `UPDATE invoices SET status = ?2,
+		funded_at = CASE WHEN ?2 = 'locked' THEN ?3 ELSE funded_at END,
+		traded_at = CASE WHEN ?2 = 'traded' THEN ?3 ELSE traded_at END,
+		rejections = CASE WHEN ?2 = 'open' AND status = 'locked' THEN rejections + 1 ELSE rejections END
+		WHERE id = ?1`
+
+	res, err := s.c.ExecContext(ctx, query, id, status, sqlite.FormatTime(time.Now()))
+	if err != nil {
+		return fmt.Errorf("could not update invoice status in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update invoice status in db: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("could not update invoice status: %w", invoice.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) SaveBid(ctx context.Context, b invoice.Bid) error {
+	const query = `INSERT INTO bids (id, invoice_id, investor_id, amount_number, amount_currency, active) VALUES (?, ?, ?, ?, ?, ?)`
+
+	if _, err := s.c.ExecContext(ctx, query, b.ID, b.InvoiceID, b.InvestorID, b.Amount.Number(), b.Amount.CurrencyCode(), b.Active); err != nil {
+		return fmt.Errorf("could not save bid in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveBid(ctx context.Context, id string) (invoice.Bid, error) {
+	const query = `SELECT b.invoice_id, b.investor_id, (b.amount_number || ' ' || b.amount_currency), b.active FROM bids b WHERE b.id = ?`
+
+	bid := invoice.Bid{ID: id}
+	err := s.c.QueryRowContext(ctx, query, id).Scan(&bid.InvoiceID, &bid.InvestorID, sqlite.Amount{A: &bid.Amount}, &bid.Active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return bid, fmt.Errorf("could not retrieve bid: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return bid, fmt.Errorf("could not retrieve bid: %w", err)
+	}
+
+	return bid, nil
+}
+
+func (s *SQLiteStorage) RetrieveBidsByInvestorID(ctx context.Context, investorID string) ([]invoice.Bid, error) {
+	const query = `SELECT b.id, b.invoice_id, (b.amount_number || ' ' || b.amount_currency) FROM bids b WHERE b.investor_id = ? AND b.active = 1`
+
+	rows, err := s.c.QueryContext(ctx, query, investorID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve bids: %w", err)
+	}
+	defer rows.Close()
+
+	var bids []invoice.Bid
+	for rows.Next() {
+		bid := invoice.Bid{InvestorID: investorID, Active: true}
+		if err := rows.Scan(&bid.ID, &bid.InvoiceID, sqlite.Amount{A: &bid.Amount}); err != nil {
+			return nil, fmt.Errorf("could not scan bids: %w", err)
+		}
+
+		bids = append(bids, bid)
+	}
+
+	return bids, rows.Err()
+}
+
+func (s *SQLiteStorage) RetrieveActiveBidsByInvoiceID(ctx context.Context, invoiceID string) ([]invoice.Bid, error) {
+	const query = `SELECT b.id, b.investor_id, (b.amount_number || ' ' || b.amount_currency) FROM bids b WHERE b.invoice_id = ? AND b.active = 1`
+
+	rows, err := s.c.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve bids: %w", err)
+	}
+	defer rows.Close()
+
+	var bids []invoice.Bid
+	for rows.Next() {
+		bid := invoice.Bid{InvoiceID: invoiceID, Active: true}
+		if err := rows.Scan(&bid.ID, &bid.InvestorID, sqlite.Amount{A: &bid.Amount}); err != nil {
+			return nil, fmt.Errorf("could not scan bids: %w", err)
+		}
+
+		bids = append(bids, bid)
+	}
+
+	return bids, rows.Err()
+}
+
+func (s *SQLiteStorage) DisableBidsByInvoiceID(ctx context.Context, id string) error {
+	const query = `UPDATE bids SET active = 0 WHERE invoice_id = ? AND active = 1`
+
+	if _, err := s.c.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("could not disable bids in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) SaveListing(ctx context.Context, l invoice.Listing) error {
+	const query = `INSERT INTO listings (id, bid_id, invoice_id, seller_id, price_number, price_currency, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if _, err := s.c.ExecContext(ctx, query, l.ID, l.BidID, l.InvoiceID, l.SellerID, l.Price.Number(), l.Price.CurrencyCode(), l.Status, sqlite.FormatTime(l.CreatedAt)); err != nil {
+		return fmt.Errorf("could not save listing in db: %w", err)
+	}
+
+	return nil
+}
+
+const sqliteListingColumns = `l.id, l.bid_id, l.invoice_id, l.seller_id, COALESCE(l.buyer_id, ''), (l.price_number || ' ' || l.price_currency), l.status, l.created_at, l.sold_at`
+
+func (s *SQLiteStorage) RetrieveListing(ctx context.Context, id string) (invoice.Listing, error) {
+	query := fmt.Sprintf(`SELECT %s FROM listings l WHERE l.id = ?`, sqliteListingColumns)
+
+	l := invoice.Listing{ID: id}
+	err := s.c.QueryRowContext(ctx, query, id).Scan(sqliteListingDest(&l)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return l, fmt.Errorf("could not retrieve listing: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return l, fmt.Errorf("could not retrieve listing: %w", err)
+	}
+
+	return l, nil
+}
+
+func (s *SQLiteStorage) RetrieveListingsByStatus(ctx context.Context, status invoice.ListingStatus) ([]invoice.Listing, error) {
+	query := fmt.Sprintf(`SELECT %s FROM listings l WHERE l.status = ? ORDER BY l.created_at`, sqliteListingColumns)
+
+	return s.retrieveListings(ctx, query, status)
+}
+
+func (s *SQLiteStorage) RetrieveListingsByBidIDs(ctx context.Context, bidIDs []string) ([]invoice.Listing, error) {
+	if len(bidIDs) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM listings l WHERE l.bid_id IN (%s) ORDER BY l.created_at`, sqliteListingColumns, placeholders(len(bidIDs)))
+
+	args := make([]any, len(bidIDs))
+	for i, id := range bidIDs {
+		args[i] = id
+	}
+
+	return s.retrieveListings(ctx, query, args...)
+}
+
+func (s *SQLiteStorage) RetrieveListingsBySellerID(ctx context.Context, sellerID string, status invoice.ListingStatus) ([]invoice.Listing, error) {
+	query := fmt.Sprintf(`SELECT %s FROM listings l WHERE l.seller_id = ? AND l.status = ? ORDER BY l.created_at`, sqliteListingColumns)
+
+	return s.retrieveListings(ctx, query, sellerID, status)
+}
+
+func (s *SQLiteStorage) retrieveListings(ctx context.Context, query string, args ...any) ([]invoice.Listing, error) {
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve listings: %w", err)
+	}
+	defer rows.Close()
+
+	var listings []invoice.Listing
+	for rows.Next() {
+		var l invoice.Listing
+		if err := rows.Scan(sqliteListingDest(&l)...); err != nil {
+			return nil, fmt.Errorf("could not scan listings: %w", err)
+		}
+
+		listings = append(listings, l)
+	}
+
+	return listings, rows.Err()
+}
+
+func sqliteListingDest(l *invoice.Listing) []any {
+	return []any{&l.ID, &l.BidID, &l.InvoiceID, &l.SellerID, &l.BuyerID, sqlite.Amount{A: &l.Price}, &l.Status, sqlite.Time{T: &l.CreatedAt}, sqlite.NullTime{T: &l.SoldAt}}
+}
+
+// UpdateListingStatus moves a listing from one status to another, failing if
+// it is no longer in the expected status
+func (s *SQLiteStorage) UpdateListingStatus(ctx context.Context, id string, from, to invoice.ListingStatus) error {
+	const (
+		query       = `UPDATE listings SET status = ? WHERE id = ? AND status = ?`
+		existsQuery = `SELECT EXISTS (SELECT 1 FROM listings l WHERE l.id = ?)`
+	)
+
+	res, err := s.c.ExecContext(ctx, query, to, id, from)
+	if err != nil {
+		return fmt.Errorf("could not update listing status in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update listing status in db: %w", err)
+	}
+	if n == 1 {
+		return nil
+	}
+
+	var exists bool
+	if err := s.c.QueryRowContext(ctx, existsQuery, id).Scan(&exists); err != nil {
+		return fmt.Errorf("could not retrieve listing: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("could not update listing status: %w", invoice.ErrNotFound)
+	}
+
+	return fmt.Errorf("%w: listing is no longer %s", invoice.ErrInvalidTransition, from)
+}
+
+// ReserveListing holds a listed position for the buyer while it is paid
+func (s *SQLiteStorage) ReserveListing(ctx context.Context, id, buyerID string) error {
+	const query = `UPDATE listings SET status = 'reserved', buyer_id = ? WHERE id = ? AND status = 'listed'`
+
+	res, err := s.c.ExecContext(ctx, query, buyerID, id)
+	if err != nil {
+		return fmt.Errorf("could not reserve listing in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not reserve listing in db: %w", err)
+	}
+	if n != 1 {
+		return fmt.Errorf("%w: listing is no longer available", invoice.ErrInvalidTransition)
+	}
+
+	return nil
+}
+
+// ReleaseListing lists again a position reserved by the buyer
+func (s *SQLiteStorage) ReleaseListing(ctx context.Context, id, buyerID string) error {
+	const query = `UPDATE listings SET status = 'listed', buyer_id = NULL WHERE id = ? AND status = 'reserved' AND buyer_id = ?`
+
+	res, err := s.c.ExecContext(ctx, query, id, buyerID)
+	if err != nil {
+		return fmt.Errorf("could not release listing in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not release listing in db: %w", err)
+	}
+	if n != 1 {
+		return fmt.Errorf("%w: listing is not reserved by the buyer", invoice.ErrInvalidTransition)
+	}
+
+	return nil
+}
+
+// TransferListing sells a position reserved by the buyer and gives it the bid
+func (s *SQLiteStorage) TransferListing(ctx context.Context, id, buyerID string) error {
+	const sellQuery = `UPDATE listings SET status = 'sold', sold_at = ? WHERE id = ? AND status = 'reserved' AND buyer_id = ? RETURNING bid_id`
+	const transferQuery = `UPDATE bids SET investor_id = ? WHERE id = ?`
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var bidID string
+	if err := tx.QueryRowContext(ctx, sellQuery, sqlite.FormatTime(time.Now()), id, buyerID).Scan(&bidID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: listing is not reserved by the buyer", invoice.ErrInvalidTransition)
+		}
+
+		return fmt.Errorf("could not sell listing in db: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, transferQuery, buyerID, bidID); err != nil {
+		return fmt.Errorf("could not transfer bid in db: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) SaveExtraction(ctx context.Context, e invoice.Extraction) error {
+	const query = `INSERT INTO extractions (invoice_id, number, issue_date, due_date, total, currency_code, debtor_tax_id, mismatches, extracted_at, reviewed_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10)
+		ON CONFLICT (invoice_id) DO UPDATE SET number = ?2, issue_date = ?3, due_date = ?4, total = ?5,
+			currency_code = ?6, debtor_tax_id = ?7, mismatches = ?8, extracted_at = ?9, reviewed_at = ?10`
+
+	mismatches := make([]string, 0, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		mismatches = append(mismatches, string(m))
+	}
+
+	js, err := json.Marshal(mismatches)
+	if err != nil {
+		return fmt.Errorf("could not marshal mismatches: %w", err)
+	}
+
+	if _, err := s.c.ExecContext(ctx, query, e.InvoiceID, e.Number, sqlite.FormatNullDate(e.IssueDate), sqlite.FormatNullDate(e.DueDate),
+		e.Total, e.Currency, e.DebtorTaxID, string(js), sqlite.FormatTime(e.ExtractedAt), sqlite.FormatNullTime(e.ReviewedAt)); err != nil {
+		return fmt.Errorf("could not save extraction in db: %w", err)
+	}
+
+	return nil
+}
+
+const sqliteExtractionColumns = `e.invoice_id, e.number, e.issue_date, e.due_date, e.total, e.currency_code, e.debtor_tax_id, e.mismatches, e.extracted_at, e.reviewed_at`
+
+func (s *SQLiteStorage) RetrieveExtraction(ctx context.Context, invoiceID string) (invoice.Extraction, error) {
+	query := fmt.Sprintf(`SELECT %s FROM extractions e WHERE e.invoice_id = ?`, sqliteExtractionColumns)
+
+	e, err := scanSQLiteExtraction(s.c.QueryRowContext(ctx, query, invoiceID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, fmt.Errorf("could not retrieve extraction: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return e, fmt.Errorf("could not retrieve extraction: %w", err)
+	}
+
+	return e, nil
+}
+
+func (s *SQLiteStorage) RetrieveFlaggedExtractions(ctx context.Context) ([]invoice.Extraction, error) {
+	query := fmt.Sprintf(`SELECT %s FROM extractions e WHERE e.mismatches <> '[]' AND e.reviewed_at IS NULL ORDER BY e.extracted_at`, sqliteExtractionColumns)
+
+	rows, err := s.c.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve flagged extractions: %w", err)
+	}
+	defer rows.Close()
+
+	var extractions []invoice.Extraction
+	for rows.Next() {
+		e, err := scanSQLiteExtraction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan extraction: %w", err)
+		}
+
+		extractions = append(extractions, e)
+	}
+
+	return extractions, rows.Err()
+}
+
+func (s *SQLiteStorage) ReviewExtraction(ctx context.Context, invoiceID string, at time.Time) error {
+	const query = `UPDATE extractions SET reviewed_at = ? WHERE invoice_id = ?`
+
+	res, err := s.c.ExecContext(ctx, query, sqlite.FormatTime(at), invoiceID)
+	if err != nil {
+		return fmt.Errorf("could not review extraction in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not review extraction in db: %w", err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("could not review extraction: %w", invoice.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) SaveEInvoice(ctx context.Context, invoiceID string, doc einvoice.Document) error {
+	const query = `INSERT INTO einvoices (invoice_id, format, number, issue_date, due_date, total_number, total_currency,
+		seller_name, seller_tax_id, buyer_name, buyer_tax_id, lines) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	lines, err := marshalLines(doc.Lines)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.c.ExecContext(ctx, query, invoiceID, doc.Format, doc.Number, sqlite.FormatDate(doc.IssueDate), sqlite.FormatDate(doc.DueDate),
+		doc.Total.Number(), doc.Total.CurrencyCode(), doc.Seller.Name, doc.Seller.TaxID, doc.Buyer.Name, doc.Buyer.TaxID, string(lines)); err != nil {
+		return fmt.Errorf("could not save e-invoice in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveEInvoice(ctx context.Context, invoiceID string) (einvoice.Document, error) {
+	const query = `SELECT e.format, e.number, e.issue_date, e.due_date, (e.total_number || ' ' || e.total_currency),
+		e.seller_name, e.seller_tax_id, e.buyer_name, e.buyer_tax_id, e.lines FROM einvoices e WHERE e.invoice_id = ?`
+
+	var doc einvoice.Document
+	var lines string
+	err := s.c.QueryRowContext(ctx, query, invoiceID).Scan(&doc.Format, &doc.Number, sqlite.Date{T: &doc.IssueDate}, sqlite.Date{T: &doc.DueDate},
+		sqlite.Amount{A: &doc.Total}, &doc.Seller.Name, &doc.Seller.TaxID, &doc.Buyer.Name, &doc.Buyer.TaxID, &lines)
+	if errors.Is(err, sql.ErrNoRows) {
+		return doc, fmt.Errorf("could not retrieve e-invoice: %w", invoice.ErrNotFound)
+	}
+	if err != nil {
+		return doc, fmt.Errorf("could not retrieve e-invoice: %w", err)
+	}
+
+	if doc.Lines, err = unmarshalLines([]byte(lines)); err != nil {
+		return doc, err
+	}
+
+	return doc, nil
+}
+
+type sqliteRow interface {
+	Scan(...any) error
+}
+
+func scanSQLiteExtraction(row sqliteRow) (invoice.Extraction, error) {
+	var e invoice.Extraction
+	var mismatches string
+	if err := row.Scan(&e.InvoiceID, &e.Number, sqlite.NullDate{T: &e.IssueDate}, sqlite.NullDate{T: &e.DueDate}, &e.Total, &e.Currency,
+		&e.DebtorTaxID, &mismatches, sqlite.Time{T: &e.ExtractedAt}, sqlite.NullTime{T: &e.ReviewedAt}); err != nil {
+		return e, err
+	}
+
+	var ms []string
+	if err := json.Unmarshal([]byte(mismatches), &ms); err != nil {
+		return e, fmt.Errorf("could not unmarshal mismatches: %w", err)
+	}
+	for _, m := range ms {
+		e.Mismatches = append(e.Mismatches, invoice.Mismatch(m))
+	}
+
+	return e, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
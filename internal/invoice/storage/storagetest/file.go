@@ -0,0 +1,111 @@
+package storagetest
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// RunFiles checks an invoice.FileStorage, newStorage must return an empty
+// storage on every call.
+func RunFiles(t *testing.T, newStorage func(*testing.T) invoice.FileStorage) {
+	const (
+		uploadID = "0b4c3a3e-6d0f-11ee-b962-0242ac120002"
+		content  = "%PDF-1.7 invoice"
+		hash     = "f3717db969604d941a6e1ef823cc278ab25d01809231ac1fe47a87199931d4f7"
+	)
+
+	Convey("invoice.FileStorage", t, func() {
+		fst := newStorage(t)
+
+		Convey("when a file is staged", func() {
+			staged, err := fst.StageFile(uploadID, strings.NewReader(content))
+			So(err, ShouldBeNil)
+
+			Convey("return the hash of its content", func() {
+				So(staged, ShouldEqual, hash)
+			})
+
+			Convey("list it as staged but not as stored", func() {
+				files, err := fst.ListStaged()
+				So(err, ShouldBeNil)
+				So(files, ShouldHaveLength, 1)
+				So(files[0].Key, ShouldEqual, uploadID)
+
+				files, err = fst.ListFiles()
+				So(err, ShouldBeNil)
+				So(files, ShouldBeEmpty)
+			})
+
+			Convey("remove it from staging when discarded", func() {
+				So(fst.DiscardFile(uploadID), ShouldBeNil)
+
+				files, err := fst.ListStaged()
+				So(err, ShouldBeNil)
+				So(files, ShouldBeEmpty)
+			})
+
+			Convey("when it is committed", func() {
+				So(fst.CommitFile(uploadID, hash), ShouldBeNil)
+
+				Convey("store it under its hash only", func() {
+					files, err := fst.ListStaged()
+					So(err, ShouldBeNil)
+					So(files, ShouldBeEmpty)
+
+					files, err = fst.ListFiles()
+					So(err, ShouldBeNil)
+					So(files, ShouldHaveLength, 1)
+					So(files[0].Key, ShouldEqual, hash)
+				})
+
+				Convey("open it by its hash", func() {
+					doc, err := fst.OpenFile(hash)
+					So(err, ShouldBeNil)
+					defer doc.Close()
+
+					So(doc.Size, ShouldEqual, len(content))
+					read, err := io.ReadAll(doc)
+					So(err, ShouldBeNil)
+					So(string(read), ShouldEqual, content)
+				})
+
+				Convey("keep it if it was modified after the given time", func() {
+					So(fst.DeleteFile(hash, time.Now().Add(-time.Hour)), ShouldBeNil)
+
+					files, err := fst.ListFiles()
+					So(err, ShouldBeNil)
+					So(files, ShouldHaveLength, 1)
+				})
+
+				Convey("delete it if it is older than the given time", func() {
+					So(fst.DeleteFile(hash, time.Now().Add(time.Hour)), ShouldBeNil)
+
+					_, err := fst.OpenFile(hash)
+					So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+				})
+			})
+		})
+
+		Convey("when the file does not exist", func() {
+			Convey("opening it returns not exist", func() {
+				_, err := fst.OpenFile(hash)
+				So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+			})
+
+			Convey("committing it fails", func() {
+				So(fst.CommitFile(uploadID, hash), ShouldNotBeNil)
+			})
+
+			Convey("discarding it succeeds", func() {
+				So(fst.DiscardFile(uploadID), ShouldBeNil)
+			})
+		})
+	})
+}
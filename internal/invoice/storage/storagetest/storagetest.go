@@ -0,0 +1,575 @@
+// Package storagetest holds the behaviour every invoice.Storage and
+// invoice.FileStorage must have, so all the backends are tested alike.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Run checks an invoice.Storage, newStorage must return an empty storage on
+// every call.
+func Run(t *testing.T, newStorage func(*testing.T) invoice.Storage) {
+	Convey("invoice.Storage", t, func() {
+		ctx := context.Background()
+		st := newStorage(t)
+
+		created := time.Now().UTC().Truncate(time.Millisecond)
+		due := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 1, 0)
+		for _, inv := range []invoice.Invoice{
+			{ID: id(1), IssuerID: id(101), Price: amount("90", "EUR"), FaceValue: amount("100", "EUR"), DueDate: due, Status: invoice.OPEN, CreatedAt: created},
+			{ID: id(2), IssuerID: id(101), Price: amount("50", "EUR"), FaceValue: amount("60", "EUR"), DueDate: due, Status: invoice.OPEN, CreatedAt: created},
+			{ID: id(3), IssuerID: id(102), Price: amount("90", "EUR"), FaceValue: amount("95.125", "EUR"), DueDate: due.AddDate(0, 0, 1), Status: invoice.OPEN, CreatedAt: created,
+				ContentType: "application/xml"},
+			{ID: id(4), IssuerID: id(102), Price: amount("10", "USD"), FaceValue: amount("20", "USD"), DueDate: due, Status: invoice.TRADED, CreatedAt: created},
+		} {
+			So(st.SaveInvoice(ctx, inv), ShouldBeNil)
+		}
+
+		Convey("when an invoice is retrieved", func() {
+			inv, err := st.RetrieveInvoice(ctx, id(3))
+			So(err, ShouldBeNil)
+
+			Convey("return it as it was saved", func() {
+				So(inv.IssuerID, ShouldEqual, id(102))
+				So(inv.Price.Equal(amount("90", "EUR")), ShouldBeTrue)
+				So(inv.FaceValue.Equal(amount("95.125", "EUR")), ShouldBeTrue)
+				So(inv.DueDate.Equal(due.AddDate(0, 0, 1)), ShouldBeTrue)
+				So(inv.Status, ShouldEqual, invoice.OPEN)
+				So(inv.CreatedAt.Equal(created), ShouldBeTrue)
+				So(inv.FundedAt, ShouldBeNil)
+				So(inv.TradedAt, ShouldBeNil)
+				So(inv.Rejections, ShouldEqual, 0)
+				So(inv.ContentType, ShouldEqual, "application/xml")
+				So(inv.Bids, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when nothing was saved under an id", func() {
+			_, errInvoice := st.RetrieveInvoice(ctx, id(99))
+			_, errBid := st.RetrieveBid(ctx, id(99))
+			_, errListing := st.RetrieveListing(ctx, id(99))
+			_, errExtraction := st.RetrieveExtraction(ctx, id(99))
+			_, errEInvoice := st.RetrieveEInvoice(ctx, id(99))
+			errStatus := st.UpdateStatus(ctx, id(99), invoice.LOCKED)
+			errListingStatus := st.UpdateListingStatus(ctx, id(99), invoice.LISTED, invoice.WITHDRAWN)
+
+			Convey("return not found", func() {
+				So(errors.Is(errInvoice, invoice.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errBid, invoice.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errListing, invoice.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errExtraction, invoice.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errEInvoice, invoice.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errStatus, invoice.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errListingStatus, invoice.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when an e-invoice is saved", func() {
+			issued := due.AddDate(0, -1, 0)
+			So(st.SaveEInvoice(ctx, id(3), einvoice.Document{Format: einvoice.UBL, Number: "INV-1", IssueDate: issued, DueDate: due,
+				Total: amount("95.125", "EUR"), Seller: einvoice.Party{Name: "ACME", TaxID: "ES123"}, Buyer: einvoice.Party{Name: "Globex", TaxID: "DE456"},
+				Lines: []einvoice.Line{
+					{ID: "1", Description: "Anvils", Quantity: "2", Amount: amount("90", "EUR")},
+					{ID: "2", Description: "Shipping", Amount: amount("5.125", "EUR")},
+				}}), ShouldBeNil)
+
+			Convey("return it as it was saved", func() {
+				doc, err := st.RetrieveEInvoice(ctx, id(3))
+				So(err, ShouldBeNil)
+				So(doc.Format, ShouldEqual, einvoice.UBL)
+				So(doc.Number, ShouldEqual, "INV-1")
+				So(doc.IssueDate.Equal(issued), ShouldBeTrue)
+				So(doc.DueDate.Equal(due), ShouldBeTrue)
+				So(doc.Total.Equal(amount("95.125", "EUR")), ShouldBeTrue)
+				So(doc.Seller, ShouldResemble, einvoice.Party{Name: "ACME", TaxID: "ES123"})
+				So(doc.Buyer, ShouldResemble, einvoice.Party{Name: "Globex", TaxID: "DE456"})
+				So(doc.Lines, ShouldHaveLength, 2)
+				So(doc.Lines[0].Description, ShouldEqual, "Anvils")
+				So(doc.Lines[0].Quantity, ShouldEqual, "2")
+				So(doc.Lines[0].Amount.Equal(amount("90", "EUR")), ShouldBeTrue)
+				So(doc.Lines[1].Quantity, ShouldEqual, "")
+				So(doc.Lines[1].Amount.Equal(amount("5.125", "EUR")), ShouldBeTrue)
+			})
+
+			Convey("reject one for a missing invoice", func() {
+				So(st.SaveEInvoice(ctx, id(99), einvoice.Document{Total: amount("1", "EUR")}), ShouldNotBeNil)
+			})
+		})
+
+		Convey("when invoice files are retrieved", func() {
+			So(st.SaveInvoice(ctx, invoice.Invoice{ID: id(5), IssuerID: id(101), Price: amount("90", "EUR"), FaceValue: amount("100", "EUR"),
+				DueDate: due, Status: invoice.OPEN, CreatedAt: created.Add(-time.Hour), FileHash: "hash-5"}), ShouldBeNil)
+			So(st.SaveInvoice(ctx, invoice.Invoice{ID: id(6), IssuerID: id(101), Price: amount("90", "EUR"), FaceValue: amount("100", "EUR"),
+				DueDate: due, Status: invoice.TRADED, CreatedAt: created.Add(time.Hour), FileHash: "hash-6"}), ShouldBeNil)
+
+			Convey("page them in id order within the creation period", func() {
+				files, err := st.RetrieveInvoiceFiles(ctx, invoice.FileQuery{From: created, To: created.Add(time.Hour), Limit: 2})
+				So(err, ShouldBeNil)
+				So(sortedIDs(files), ShouldResemble, []string{id(1), id(2)})
+				So(files[0].CreatedAt.Equal(created), ShouldBeTrue)
+
+				files, err = st.RetrieveInvoiceFiles(ctx, invoice.FileQuery{From: created, To: created.Add(time.Hour), After: id(2), Limit: 2})
+				So(err, ShouldBeNil)
+				So(sortedIDs(files), ShouldResemble, []string{id(3), id(4)})
+
+				files, err = st.RetrieveInvoiceFiles(ctx, invoice.FileQuery{To: created.Add(2 * time.Hour), After: id(4), Limit: 10})
+				So(err, ShouldBeNil)
+				So(sortedIDs(files), ShouldResemble, []string{id(5), id(6)})
+			})
+
+			Convey("find them by file hash whatever their status", func() {
+				files, err := st.RetrieveInvoicesByFileHashes(ctx, []string{"hash-5", "hash-6", "hash-99"})
+				So(err, ShouldBeNil)
+				So(sortedIDs(files), ShouldResemble, []string{id(5), id(6)})
+				for _, f := range files {
+					So(f.FileHash, ShouldEqual, "hash-"+f.ID[len(f.ID)-1:])
+				}
+
+				files, err = st.RetrieveInvoicesByFileHashes(ctx, nil)
+				So(err, ShouldBeNil)
+				So(files, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when invoices are retrieved by issuer", func() {
+			invoices, err := st.RetrieveInvoicesByIssuerID(ctx, id(101))
+			So(err, ShouldBeNil)
+
+			Convey("return only the ones of the issuer", func() {
+				So(sortedIDs(invoices), ShouldResemble, []string{id(1), id(2)})
+			})
+		})
+
+		Convey("when invoices are paged", func() {
+			Convey("by price, break ties by id", func() {
+				ids, cursors := page(st, invoice.Query{Status: invoice.OPEN, Sort: invoice.SortPrice, Limit: 2})
+				So(ids, ShouldResemble, []string{id(2), id(1), id(3)})
+				So(cursors, ShouldEqual, 1)
+			})
+
+			Convey("by due date descending", func() {
+				ids, _ := page(st, invoice.Query{Status: invoice.OPEN, Sort: invoice.SortDueDate, Desc: true, Limit: 1})
+				So(ids, ShouldResemble, []string{id(3), id(2), id(1)})
+			})
+
+			Convey("by apr descending", func() {
+				ids, _ := page(st, invoice.Query{Status: invoice.OPEN, Sort: invoice.SortAPR, Desc: true, Limit: 1})
+				So(ids, ShouldResemble, []string{id(2), id(1), id(3)})
+			})
+
+			Convey("with filters", func() {
+				minAPR := 1.0
+				ids, _ := page(st, invoice.Query{Currency: "USD", Sort: invoice.SortPrice, Limit: 10})
+				So(ids, ShouldResemble, []string{id(4)})
+
+				ids, _ = page(st, invoice.Query{Status: invoice.OPEN, MinPrice: "60", MaxPrice: "90", Sort: invoice.SortPrice, Limit: 10})
+				So(ids, ShouldResemble, []string{id(1), id(3)})
+
+				ids, _ = page(st, invoice.Query{Status: invoice.OPEN, MinAPR: &minAPR, Sort: invoice.SortPrice, Limit: 1})
+				So(ids, ShouldResemble, []string{id(2), id(1)})
+
+				ids, _ = page(st, invoice.Query{IssuerID: id(102), DueAfter: due.AddDate(0, 0, 1), Sort: invoice.SortDueDate, Limit: 10})
+				So(ids, ShouldResemble, []string{id(3)})
+			})
+
+			Convey("with price bounds finer than a double", func() {
+				ids, _ := page(st, invoice.Query{Status: invoice.OPEN, MinPrice: "50.00000000000000001", Sort: invoice.SortPrice, Limit: 10})
+				So(ids, ShouldResemble, []string{id(1), id(3)})
+
+				ids, _ = page(st, invoice.Query{Status: invoice.OPEN, MaxPrice: "89.99999999999999999", Sort: invoice.SortPrice, Limit: 10})
+				So(ids, ShouldResemble, []string{id(2)})
+			})
+		})
+
+		Convey("when an invoice changes status", func() {
+			So(st.UpdateStatus(ctx, id(1), invoice.LOCKED), ShouldBeNil)
+			locked, err := st.RetrieveInvoice(ctx, id(1))
+			So(err, ShouldBeNil)
+
+			Convey("record when it was funded", func() {
+				So(locked.Status, ShouldEqual, invoice.LOCKED)
+				So(locked.FundedAt, ShouldNotBeNil)
+			})
+
+			Convey("count the rejection when it reopens", func() {
+				So(st.UpdateStatus(ctx, id(1), invoice.OPEN), ShouldBeNil)
+				inv, err := st.RetrieveInvoice(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(inv.Status, ShouldEqual, invoice.OPEN)
+				So(inv.Rejections, ShouldEqual, 1)
+			})
+
+			Convey("record when it was traded", func() {
+				So(st.UpdateStatus(ctx, id(1), invoice.TRADED), ShouldBeNil)
+				inv, err := st.RetrieveInvoice(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(inv.Status, ShouldEqual, invoice.TRADED)
+				So(inv.TradedAt, ShouldNotBeNil)
+				So(inv.Rejections, ShouldEqual, 0)
+			})
+		})
+
+		Convey("when invoices have bids", func() {
+			So(st.SaveBid(ctx, invoice.Bid{ID: id(11), InvoiceID: id(1), InvestorID: id(201), Amount: amount("45", "EUR"), Active: true}), ShouldBeNil)
+			So(st.SaveBid(ctx, invoice.Bid{ID: id(12), InvoiceID: id(1), InvestorID: id(202), Amount: amount("45.5", "EUR"), Active: true}), ShouldBeNil)
+			So(st.SaveBid(ctx, invoice.Bid{ID: id(13), InvoiceID: id(2), InvestorID: id(201), Amount: amount("50", "EUR"), Active: false}), ShouldBeNil)
+
+			Convey("return a bid as it was saved", func() {
+				bid, err := st.RetrieveBid(ctx, id(12))
+				So(err, ShouldBeNil)
+				So(bid.InvoiceID, ShouldEqual, id(1))
+				So(bid.InvestorID, ShouldEqual, id(202))
+				So(bid.Amount.Equal(amount("45.5", "EUR")), ShouldBeTrue)
+				So(bid.Active, ShouldBeTrue)
+			})
+
+			Convey("return only the active bids of an invoice", func() {
+				bids, err := st.RetrieveActiveBidsByInvoiceID(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(sortedBidIDs(bids), ShouldResemble, []string{id(11), id(12)})
+
+				bids, err = st.RetrieveActiveBidsByInvoiceID(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(bids, ShouldBeEmpty)
+
+				inv, err := st.RetrieveInvoice(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(inv.Bids, ShouldHaveLength, 2)
+			})
+
+			Convey("return only the active bids of an investor", func() {
+				bids, err := st.RetrieveBidsByInvestorID(ctx, id(201))
+				So(err, ShouldBeNil)
+				So(sortedBidIDs(bids), ShouldResemble, []string{id(11)})
+			})
+
+			Convey("return invoices by id with their active bids", func() {
+				invoices, err := st.RetrieveInvoicesByIDs(ctx, []string{id(1), id(1), id(3), id(99)})
+				So(err, ShouldBeNil)
+				So(sortedIDs(invoices), ShouldResemble, []string{id(1), id(3)})
+				for _, inv := range invoices {
+					if inv.ID == id(1) {
+						So(inv.Bids, ShouldHaveLength, 2)
+					}
+				}
+			})
+
+			Convey("return bids by id whether active or not", func() {
+				bids, err := st.RetrieveBidsByIDs(ctx, []string{id(11), id(13), id(99)})
+				So(err, ShouldBeNil)
+				So(sortedBidIDs(bids), ShouldResemble, []string{id(11), id(13)})
+			})
+
+			Convey("disable the bids of an invoice", func() {
+				So(st.DisableBidsByInvoiceID(ctx, id(1)), ShouldBeNil)
+
+				bids, err := st.RetrieveActiveBidsByInvoiceID(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(bids, ShouldBeEmpty)
+
+				bid, err := st.RetrieveBid(ctx, id(11))
+				So(err, ShouldBeNil)
+				So(bid.Active, ShouldBeFalse)
+			})
+
+			Convey("reject a bid on a missing invoice", func() {
+				err := st.SaveBid(ctx, invoice.Bid{ID: id(14), InvoiceID: id(99), InvestorID: id(201), Amount: amount("1", "EUR"), Active: true})
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("delete only the open invoices without bids", func() {
+				deleted, err := st.DeleteOrphanedInvoice(ctx, id(3))
+				So(err, ShouldBeNil)
+				So(deleted, ShouldBeTrue)
+
+				_, err = st.RetrieveInvoice(ctx, id(3))
+				So(errors.Is(err, invoice.ErrNotFound), ShouldBeTrue)
+
+				for _, kept := range []string{id(1), id(2), id(4)} {
+					deleted, err := st.DeleteOrphanedInvoice(ctx, kept)
+					So(err, ShouldBeNil)
+					So(deleted, ShouldBeFalse)
+				}
+
+				files, err := st.RetrieveInvoiceFiles(ctx, invoice.FileQuery{To: created.Add(time.Second), Limit: 10})
+				So(err, ShouldBeNil)
+				So(sortedIDs(files), ShouldResemble, []string{id(1), id(2), id(4)})
+			})
+		})
+
+		Convey("when a bid is listed", func() {
+			So(st.SaveBid(ctx, invoice.Bid{ID: id(11), InvoiceID: id(1), InvestorID: id(201), Amount: amount("90", "EUR"), Active: true}), ShouldBeNil)
+			So(st.SaveListing(ctx, invoice.Listing{ID: id(21), BidID: id(11), InvoiceID: id(1), SellerID: id(201),
+				Price: amount("95", "EUR"), Status: invoice.LISTED, CreatedAt: created}), ShouldBeNil)
+
+			Convey("find it by status, bid and seller", func() {
+				listings, err := st.RetrieveListingsByStatus(ctx, invoice.LISTED)
+				So(err, ShouldBeNil)
+				So(listings, ShouldHaveLength, 1)
+				So(listings[0].Price.Equal(amount("95", "EUR")), ShouldBeTrue)
+				So(listings[0].BuyerID, ShouldBeEmpty)
+
+				listings, err = st.RetrieveListingsByBidIDs(ctx, []string{id(11), id(99)})
+				So(err, ShouldBeNil)
+				So(listings, ShouldHaveLength, 1)
+				So(listings[0].SoldAt, ShouldBeNil)
+
+				listings, err = st.RetrieveListingsBySellerID(ctx, id(201), invoice.LISTED)
+				So(err, ShouldBeNil)
+				So(listings, ShouldHaveLength, 1)
+
+				listings, err = st.RetrieveListingsBySellerID(ctx, id(201), invoice.SOLD)
+				So(err, ShouldBeNil)
+				So(listings, ShouldBeEmpty)
+			})
+
+			Convey("when it is reserved", func() {
+				So(st.ReserveListing(ctx, id(21), id(202)), ShouldBeNil)
+
+				Convey("hold it for the buyer", func() {
+					l, err := st.RetrieveListing(ctx, id(21))
+					So(err, ShouldBeNil)
+					So(l.Status, ShouldEqual, invoice.RESERVED)
+					So(l.BuyerID, ShouldEqual, id(202))
+
+					err = st.ReserveListing(ctx, id(21), id(203))
+					So(errors.Is(err, invoice.ErrInvalidTransition), ShouldBeTrue)
+				})
+
+				Convey("move the bid to the buyer when sold", func() {
+					err := st.TransferListing(ctx, id(21), id(203))
+					So(errors.Is(err, invoice.ErrInvalidTransition), ShouldBeTrue)
+
+					So(st.TransferListing(ctx, id(21), id(202)), ShouldBeNil)
+
+					l, err := st.RetrieveListing(ctx, id(21))
+					So(err, ShouldBeNil)
+					So(l.Status, ShouldEqual, invoice.SOLD)
+					So(l.BuyerID, ShouldEqual, id(202))
+					So(l.SoldAt, ShouldNotBeNil)
+					So(l.SoldAt.Before(l.CreatedAt), ShouldBeFalse)
+
+					bid, err := st.RetrieveBid(ctx, id(11))
+					So(err, ShouldBeNil)
+					So(bid.InvestorID, ShouldEqual, id(202))
+
+					err = st.TransferListing(ctx, id(21), id(202))
+					So(errors.Is(err, invoice.ErrInvalidTransition), ShouldBeTrue)
+				})
+
+				Convey("list it again when released by the buyer", func() {
+					err := st.ReleaseListing(ctx, id(21), id(203))
+					So(errors.Is(err, invoice.ErrInvalidTransition), ShouldBeTrue)
+
+					So(st.ReleaseListing(ctx, id(21), id(202)), ShouldBeNil)
+
+					l, err := st.RetrieveListing(ctx, id(21))
+					So(err, ShouldBeNil)
+					So(l.Status, ShouldEqual, invoice.LISTED)
+					So(l.BuyerID, ShouldBeEmpty)
+
+					bid, err := st.RetrieveBid(ctx, id(11))
+					So(err, ShouldBeNil)
+					So(bid.InvestorID, ShouldEqual, id(201))
+				})
+
+				Convey("not withdraw it", func() {
+					err := st.UpdateListingStatus(ctx, id(21), invoice.LISTED, invoice.WITHDRAWN)
+					So(errors.Is(err, invoice.ErrInvalidTransition), ShouldBeTrue)
+				})
+			})
+
+			Convey("not reserve it once withdrawn", func() {
+				So(st.UpdateListingStatus(ctx, id(21), invoice.LISTED, invoice.WITHDRAWN), ShouldBeNil)
+
+				l, err := st.RetrieveListing(ctx, id(21))
+				So(err, ShouldBeNil)
+				So(l.Status, ShouldEqual, invoice.WITHDRAWN)
+
+				err = st.ReserveListing(ctx, id(21), id(202))
+				So(errors.Is(err, invoice.ErrInvalidTransition), ShouldBeTrue)
+
+				err = st.UpdateListingStatus(ctx, id(21), invoice.LISTED, invoice.WITHDRAWN)
+				So(errors.Is(err, invoice.ErrInvalidTransition), ShouldBeTrue)
+			})
+
+			Convey("reserve it for a single buyer when bought concurrently", func() {
+				const buyers = 8
+
+				errs := make([]error, buyers)
+				var wg sync.WaitGroup
+				for i := 0; i < buyers; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						errs[i] = st.ReserveListing(ctx, id(21), id(300+i))
+					}(i)
+				}
+				wg.Wait()
+
+				winner := -1
+				for i, err := range errs {
+					if err == nil {
+						So(winner, ShouldEqual, -1)
+						winner = i
+						continue
+					}
+					So(errors.Is(err, invoice.ErrInvalidTransition), ShouldBeTrue)
+				}
+				So(winner, ShouldNotEqual, -1)
+
+				l, err := st.RetrieveListing(ctx, id(21))
+				So(err, ShouldBeNil)
+				So(l.BuyerID, ShouldEqual, id(300+winner))
+			})
+		})
+
+		Convey("when extractions are saved", func() {
+			extractedDue := due.AddDate(0, 0, 2)
+			So(st.SaveExtraction(ctx, invoice.Extraction{InvoiceID: id(1), Number: "F-1", DueDate: &extractedDue, Total: "91.00", Currency: "EUR",
+				Mismatches: []invoice.Mismatch{invoice.TOTAL_MISMATCH, invoice.DUE_DATE_MISMATCH}, ExtractedAt: created}), ShouldBeNil)
+			So(st.SaveExtraction(ctx, invoice.Extraction{InvoiceID: id(2), Number: "F-2", Total: "50.00", Currency: "EUR", ExtractedAt: created}), ShouldBeNil)
+
+			Convey("return them as they were saved", func() {
+				e, err := st.RetrieveExtraction(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(e.Number, ShouldEqual, "F-1")
+				So(e.IssueDate, ShouldBeNil)
+				So(e.DueDate, ShouldNotBeNil)
+				So(e.DueDate.Equal(extractedDue), ShouldBeTrue)
+				So(e.Total, ShouldEqual, "91.00")
+				So(e.Mismatches, ShouldResemble, []invoice.Mismatch{invoice.TOTAL_MISMATCH, invoice.DUE_DATE_MISMATCH})
+				So(e.ExtractedAt.Equal(created), ShouldBeTrue)
+			})
+
+			Convey("flag only the ones with mismatches", func() {
+				flagged, err := st.RetrieveFlaggedExtractions(ctx)
+				So(err, ShouldBeNil)
+				So(flagged, ShouldHaveLength, 1)
+				So(flagged[0].InvoiceID, ShouldEqual, id(1))
+			})
+
+			Convey("replace them when extracted again", func() {
+				So(st.SaveExtraction(ctx, invoice.Extraction{InvoiceID: id(1), Number: "F-1", Total: "90.00", Currency: "EUR", ExtractedAt: created}), ShouldBeNil)
+
+				flagged, err := st.RetrieveFlaggedExtractions(ctx)
+				So(err, ShouldBeNil)
+				So(flagged, ShouldBeEmpty)
+
+				e, err := st.RetrieveExtraction(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(e.Total, ShouldEqual, "90.00")
+				So(e.DueDate, ShouldBeNil)
+			})
+
+			Convey("stop flagging them once reviewed", func() {
+				reviewed := created.Add(time.Minute)
+				So(st.ReviewExtraction(ctx, id(1), reviewed), ShouldBeNil)
+
+				flagged, err := st.RetrieveFlaggedExtractions(ctx)
+				So(err, ShouldBeNil)
+				So(flagged, ShouldBeEmpty)
+
+				e, err := st.RetrieveExtraction(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(e.ReviewedAt, ShouldNotBeNil)
+				So(e.ReviewedAt.Equal(reviewed), ShouldBeTrue)
+				So(e.Mismatches, ShouldHaveLength, 2)
+				So(e.Flagged(), ShouldBeFalse)
+			})
+
+			Convey("reject reviewing a missing extraction", func() {
+				err := st.ReviewExtraction(ctx, id(3), created)
+				So(errors.Is(err, invoice.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the file was already uploaded", func() {
+			hash := "f3717db969604d941a6e1ef823cc278ab25d01809231ac1fe47a87199931d4f7"
+			So(st.SaveInvoice(ctx, invoice.Invoice{ID: id(5), IssuerID: id(101), Price: amount("1", "EUR"), FaceValue: amount("2", "EUR"),
+				DueDate: due, Status: invoice.OPEN, CreatedAt: created, FileHash: hash}), ShouldBeNil)
+			err := st.SaveInvoice(ctx, invoice.Invoice{ID: id(6), IssuerID: id(101), Price: amount("1", "EUR"), FaceValue: amount("2", "EUR"),
+				DueDate: due, Status: invoice.OPEN, CreatedAt: created, FileHash: hash})
+
+			Convey("return duplicate file", func() {
+				So(errors.Is(err, invoice.ErrDuplicateFile), ShouldBeTrue)
+			})
+
+			Convey("while the invoice it backs is locked", func() {
+				So(st.UpdateStatus(ctx, id(5), invoice.LOCKED), ShouldBeNil)
+				err := st.SaveInvoice(ctx, invoice.Invoice{ID: id(6), IssuerID: id(101), Price: amount("1", "EUR"), FaceValue: amount("2", "EUR"),
+					DueDate: due, Status: invoice.OPEN, CreatedAt: created, FileHash: hash})
+
+				So(errors.Is(err, invoice.ErrDuplicateFile), ShouldBeTrue)
+			})
+
+			Convey("but not once the invoice it backs is traded", func() {
+				So(st.UpdateStatus(ctx, id(5), invoice.LOCKED), ShouldBeNil)
+				So(st.UpdateStatus(ctx, id(5), invoice.TRADED), ShouldBeNil)
+
+				So(st.SaveInvoice(ctx, invoice.Invoice{ID: id(6), IssuerID: id(101), Price: amount("1", "EUR"), FaceValue: amount("2", "EUR"),
+					DueDate: due, Status: invoice.OPEN, CreatedAt: created, FileHash: hash}), ShouldBeNil)
+			})
+		})
+	})
+}
+
+// page follows the cursors until the last page, returning the ids in order
+// and how many cursors were followed
+func page(st invoice.Storage, q invoice.Query) ([]string, int) {
+	var ids []string
+	var cursors int
+	for {
+		invoices, next, err := st.RetrieveInvoices(context.Background(), q)
+		So(err, ShouldBeNil)
+		for _, inv := range invoices {
+			ids = append(ids, inv.ID)
+		}
+
+		if next == nil {
+			return ids, cursors
+		}
+		cursors++
+		q.After = next
+	}
+}
+
+// id builds the ids like uuids, as postgres stores them in fixed width
+func id(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+func amount(n, code string) currency.Amount {
+	a, err := currency.NewAmount(n, code)
+	So(err, ShouldBeNil)
+	return a
+}
+
+func sortedIDs(invoices []invoice.Invoice) []string {
+	var ids []string
+	for _, inv := range invoices {
+		ids = append(ids, inv.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedBidIDs(bids []invoice.Bid) []string {
+	var ids []string
+	for _, b := range bids {
+		ids = append(ids, b.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
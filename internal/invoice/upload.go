@@ -0,0 +1,153 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+)
+
+var (
+	ErrInvalidFile  = errors.New("invalid invoice file")
+	ErrFileTooLarge = errors.New("invoice file is too large")
+)
+
+// Scanner checks uploaded files for malware before they are stored,
+// returning ErrInvalidFile for infected files.
+type Scanner interface {
+	Scan(context.Context, io.Reader) error
+}
+
+// UploadPolicy restricts the invoice files accepted on upload.
+type UploadPolicy struct {
+	MaxSize     int64
+	AllowImages bool
+	Scanner     Scanner
+}
+
+var (
+	pdfMagic  = []byte("%PDF-")
+	pdfEOF    = []byte("%%EOF")
+	pdfXref   = []byte("startxref")
+	pdfCrypt  = regexp.MustCompile(`/Encrypt[\s/<\d]`)
+	pngMagic  = []byte("\x89PNG\r\n\x1a\n")
+	jpegMagic = []byte("\xff\xd8\xff")
+	xmlMagic  = []byte("<?xml")
+	utf8BOM   = []byte("\xef\xbb\xbf")
+)
+
+const (
+	pdfType  = "application/pdf"
+	pngType  = "image/png"
+	jpegType = "image/jpeg"
+	xmlType  = "application/xml"
+)
+
+// headerWindow is how far into the file the pdf header may start, and
+// trailerWindow how close to the end the end of file marker must be
+const (
+	headerWindow  = 1024
+	trailerWindow = 1024
+)
+
+// read loads the upload up to the maximum size so it can be checked as a
+// whole before it is stored.
+func (p UploadPolicy) read(r io.Reader) ([]byte, error) {
+	if p.MaxSize <= 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not read file: %w", err)
+		}
+
+		return data, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, p.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %w", err)
+	}
+
+	if int64(len(data)) > p.MaxSize {
+		return nil, fmt.Errorf("%w: maximum size is %d bytes", ErrFileTooLarge, p.MaxSize)
+	}
+
+	return data, nil
+}
+
+// validate checks the upload and returns its content type
+func (p UploadPolicy) validate(ctx context.Context, data []byte) (string, error) {
+	var contentType string
+	switch {
+	case bytes.Contains(head(data, headerWindow), pdfMagic):
+		if err := validatePDF(data); err != nil {
+			return "", err
+		}
+		contentType = pdfType
+	case p.AllowImages && bytes.HasPrefix(data, pngMagic):
+		contentType = pngType
+	case p.AllowImages && bytes.HasPrefix(data, jpegMagic):
+		contentType = jpegType
+	case isXML(data):
+		contentType = xmlType
+	default:
+		return "", fmt.Errorf("%w: unsupported file type", ErrInvalidFile)
+	}
+
+	if p.Scanner != nil {
+		if err := p.Scanner.Scan(ctx, bytes.NewReader(data)); err != nil {
+			return "", err
+		}
+	}
+
+	return contentType, nil
+}
+
+// parseEInvoice reads the e-invoice of the upload, if any. XML files must be
+// UBL or CII e-invoices and pdfs embedding a broken Factur-X are rejected.
+func parseEInvoice(data []byte, contentType string) (*einvoice.Document, error) {
+	doc, err := einvoice.Parse(data)
+	switch {
+	case err == nil:
+		return &doc, nil
+	case errors.Is(err, einvoice.ErrUnsupported) && contentType != xmlType:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+}
+
+func validatePDF(data []byte) error {
+	if !bytes.Contains(tail(data, trailerWindow), pdfEOF) || !bytes.Contains(data, pdfXref) {
+		return fmt.Errorf("%w: corrupt or truncated pdf", ErrInvalidFile)
+	}
+
+	if pdfCrypt.Match(data) {
+		return fmt.Errorf("%w: encrypted pdfs are not accepted", ErrInvalidFile)
+	}
+
+	return nil
+}
+
+func isXML(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n"), xmlMagic)
+}
+
+func head(data []byte, n int) []byte {
+	if len(data) < n {
+		return data
+	}
+
+	return data[:n]
+}
+
+func tail(data []byte, n int) []byte {
+	if len(data) < n {
+		return data
+	}
+
+	return data[len(data)-n:]
+}
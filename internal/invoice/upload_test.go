@@ -0,0 +1,140 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type scannerFunc func(context.Context, io.Reader) error
+
+func (f scannerFunc) Scan(ctx context.Context, r io.Reader) error {
+	return f(ctx, r)
+}
+
+const validPDF = "%PDF-1.7\n1 0 obj << /Type /Catalog >> endobj\ntrailer << /Root 1 0 R >>\nstartxref\n9\n%%EOF\n"
+
+func TestUploadPolicy(t *testing.T) {
+	Convey("UploadPolicy", t, func() {
+		p := UploadPolicy{MaxSize: 1024}
+		ctx := context.Background()
+
+		Convey("when the file exceeds the maximum size", func() {
+			_, err := p.read(strings.NewReader(strings.Repeat("x", 1025)))
+
+			Convey("return a file too large error", func() {
+				So(errors.Is(err, ErrFileTooLarge), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the file fits the maximum size", func() {
+			data, err := p.read(strings.NewReader(validPDF))
+
+			Convey("return its content", func() {
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, validPDF)
+			})
+		})
+
+		Convey("reject files that are not valid pdfs", func() {
+			for _, content := range []string{
+				"just some text",
+				"\x89PNG\r\n\x1a\nimage",
+				"%PDF-1.7\n1 0 obj << /Type /Catalog >> endobj\n",
+				"%PDF-1.7\n1 0 obj << /Type /Catalog >> endobj\n%%EOF\n",
+				strings.Replace(validPDF, "/Root 1 0 R", "/Root 1 0 R /Encrypt 2 0 R", 1),
+			} {
+				_, err := p.validate(ctx, []byte(content))
+				So(errors.Is(err, ErrInvalidFile), ShouldBeTrue)
+			}
+		})
+
+		Convey("accept valid pdfs", func() {
+			contentType, err := p.validate(ctx, []byte(validPDF))
+			So(err, ShouldBeNil)
+			So(contentType, ShouldEqual, "application/pdf")
+		})
+
+		Convey("accept xml files as xml", func() {
+			for _, content := range []string{"<?xml version=\"1.0\"?><Invoice/>", "\xef\xbb\xbf\n<?xml version=\"1.0\"?><Invoice/>"} {
+				contentType, err := p.validate(ctx, []byte(content))
+				So(err, ShouldBeNil)
+				So(contentType, ShouldEqual, "application/xml")
+			}
+		})
+
+		Convey("when images are allowed", func() {
+			p.AllowImages = true
+
+			Convey("accept png and jpeg files", func() {
+				contentType, err := p.validate(ctx, []byte("\x89PNG\r\n\x1a\nimage"))
+				So(err, ShouldBeNil)
+				So(contentType, ShouldEqual, "image/png")
+
+				contentType, err = p.validate(ctx, []byte("\xff\xd8\xff\xe0image"))
+				So(err, ShouldBeNil)
+				So(contentType, ShouldEqual, "image/jpeg")
+			})
+		})
+
+		Convey("when a scanner is configured", func() {
+			var scanned string
+			p.Scanner = scannerFunc(func(ctx context.Context, r io.Reader) error {
+				content, _ := io.ReadAll(r)
+				scanned = string(content)
+				return ErrInvalidFile
+			})
+
+			Convey("scan valid files and return its error", func() {
+				_, err := p.validate(ctx, []byte(validPDF))
+				So(errors.Is(err, ErrInvalidFile), ShouldBeTrue)
+				So(scanned, ShouldEqual, validPDF)
+			})
+
+			Convey("do not scan invalid files", func() {
+				_, err := p.validate(ctx, []byte("text"))
+				So(err, ShouldNotBeNil)
+				So(scanned, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestParseEInvoice(t *testing.T) {
+	Convey("parseEInvoice", t, func() {
+		ubl, err := os.ReadFile("einvoice/testdata/ubl.xml")
+		So(err, ShouldBeNil)
+
+		Convey("return the e-invoice of an xml file", func() {
+			for _, content := range [][]byte{ubl, append([]byte("\xef\xbb\xbf"), ubl...)} {
+				doc, err := parseEInvoice(content, "application/xml")
+				So(err, ShouldBeNil)
+				So(doc, ShouldNotBeNil)
+				So(doc.Format, ShouldEqual, einvoice.UBL)
+			}
+		})
+
+		Convey("when the xml is not an e-invoice", func() {
+			_, err := parseEInvoice([]byte("<?xml version=\"1.0\"?><Invoice/>"), "application/xml")
+
+			Convey("reject it", func() {
+				So(errors.Is(err, ErrInvalidFile), ShouldBeTrue)
+			})
+		})
+
+		Convey("when a pdf has no e-invoice", func() {
+			doc, err := parseEInvoice([]byte(validPDF), "application/pdf")
+
+			Convey("return none", func() {
+				So(err, ShouldBeNil)
+				So(doc, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -7,5 +7,12 @@ import (
 type Issuer struct {
 	ID       string
 	FullName string
+	Rating   string
 	Balance  currency.Amount
 }
+
+var ratings = map[string]bool{"A": true, "B": true, "C": true, "D": true, "E": true}
+
+func ValidRating(rating string) bool {
+	return rating == "" || ratings[rating]
+}
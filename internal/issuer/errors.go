@@ -0,0 +1,15 @@
+package issuer
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by storages when an issuer, payout account or payout does not exist
+	ErrNotFound          = errors.New("not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrInvalidRating     = errors.New("invalid rating")
+	// ErrNotOwner is returned when acting on a payout or account of someone
+	// else
+	ErrNotOwner = errors.New("not the owner")
+)
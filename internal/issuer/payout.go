@@ -0,0 +1,40 @@
+package issuer
+
+import (
+	"time"
+
+	"github.com/bojanz/currency"
+)
+
+// PayoutAccount is a bank account where an issuer receives its proceeds.
+// Trade proceeds are paid out automatically to the account flagged as Auto.
+type PayoutAccount struct {
+	ID        string
+	IssuerID  string
+	IBAN      string
+	Holder    string
+	Auto      bool
+	CreatedAt time.Time
+}
+
+type PayoutStatus string
+
+const (
+	PENDING PayoutStatus = "pending"
+	SENT    PayoutStatus = "sent"
+	FAILED  PayoutStatus = "failed"
+)
+
+// Payout moves money from the issuer balance to one of its payout accounts.
+// The balance is deducted once the payout is sent and restored if it fails
+// afterwards.
+type Payout struct {
+	ID        string
+	IssuerID  string
+	AccountID string
+	Amount    currency.Amount
+	Status    PayoutStatus
+	CreatedAt time.Time
+	SentAt    *time.Time
+	FailedAt  *time.Time
+}
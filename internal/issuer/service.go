@@ -2,7 +2,9 @@ package issuer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -12,7 +14,26 @@ import (
 type Storage interface {
 	CreateIssuer(context.Context, Issuer) error
 	RetrieveIssuer(context.Context, string) (Issuer, error)
+	RetrieveIssuers(context.Context, []string) ([]Issuer, error)
 	UpdateBalance(context.Context, string, currency.Amount) error
+	// CreditTrade adds the proceeds of an invoice trade to the issuer balance
+	// once, doing nothing if the invoice was already credited.
+	CreditTrade(context.Context, string, string, currency.Amount) error
+	SavePayoutAccount(context.Context, PayoutAccount) error
+	RetrievePayoutAccount(context.Context, string) (PayoutAccount, error)
+	RetrievePayoutAccountsByIssuerID(context.Context, string) ([]PayoutAccount, error)
+	SavePayout(context.Context, Payout) error
+	// SavePendingPayout saves a pending payout, failing with
+	// ErrInsufficientFunds if it does not fit in the balance left after the
+	// payouts that are still pending.
+	SavePendingPayout(context.Context, Payout) error
+	RetrievePayout(context.Context, string) (Payout, error)
+	RetrievePayoutsByIssuerID(context.Context, string) ([]Payout, error)
+	UpdatePayoutStatus(context.Context, Payout, PayoutStatus) error
+	// UpdatePayoutAndBalance moves the payout out of the given status and adds
+	// the amount to the balance, failing with ErrInsufficientFunds if a debit
+	// would leave it negative.
+	UpdatePayoutAndBalance(context.Context, Payout, PayoutStatus, currency.Amount) error
 }
 
 func NewService(st Storage) *Service {
@@ -25,7 +46,11 @@ type Service struct {
 	st Storage
 }
 
-func (s *Service) CreateIssuer(ctx context.Context, name string) (Issuer, error) {
+func (s *Service) CreateIssuer(ctx context.Context, name, rating string) (Issuer, error) {
+	if !ValidRating(rating) {
+		return Issuer{}, fmt.Errorf("%w %q", ErrInvalidRating, rating)
+	}
+
 	id, err := uuid.NewUUID()
 	if err != nil {
 		return Issuer{}, fmt.Errorf("could not generate id: %w", err)
@@ -35,6 +60,7 @@ func (s *Service) CreateIssuer(ctx context.Context, name string) (Issuer, error)
 	issuer := Issuer{
 		ID:       id.String(),
 		FullName: name,
+		Rating:   rating,
 		Balance:  b,
 	}
 	if err := s.st.CreateIssuer(ctx, issuer); err != nil {
@@ -48,21 +74,229 @@ func (s *Service) GetIssuer(ctx context.Context, id string) (Issuer, error) {
 	return s.st.RetrieveIssuer(ctx, id)
 }
 
-func (s *Service) ApproveTrade(ctx context.Context, id string, amount currency.Amount) error {
-	issuer, err := s.GetIssuer(ctx, id)
+func (s *Service) ListIssuers(ctx context.Context, ids []string) (map[string]Issuer, error) {
+	issuers, err := s.st.RetrieveIssuers(ctx, ids)
 	if err != nil {
+		return nil, err
+	}
+
+	issuersMap := make(map[string]Issuer, len(issuers))
+	for _, iss := range issuers {
+		issuersMap[iss.ID] = iss
+	}
+
+	return issuersMap, nil
+}
+
+// ApproveTrade credits the proceeds of an invoice trade to the issuer, keyed
+// on the invoice so a retried trade is never credited twice.
+func (s *Service) ApproveTrade(ctx context.Context, id, invoiceID string, amount currency.Amount) error {
+	return s.st.CreditTrade(ctx, id, invoiceID, amount)
+}
+
+// AddPayoutAccount registers a bank account for the issuer. Flagging it as
+// auto makes it the only account receiving trade proceeds automatically.
+func (s *Service) AddPayoutAccount(ctx context.Context, issuerID, iban, holder string, auto bool) (PayoutAccount, error) {
+	if _, err := s.GetIssuer(ctx, issuerID); err != nil {
+		return PayoutAccount{}, err
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return PayoutAccount{}, fmt.Errorf("could not generate id: %w", err)
+	}
+
+	account := PayoutAccount{
+		ID:        id.String(),
+		IssuerID:  issuerID,
+		IBAN:      iban,
+		Holder:    holder,
+		Auto:      auto,
+		CreatedAt: time.Now(),
+	}
+	if err := s.st.SavePayoutAccount(ctx, account); err != nil {
+		return PayoutAccount{}, err
+	}
+
+	return account, nil
+}
+
+func (s *Service) ListPayoutAccounts(ctx context.Context, issuerID string) ([]PayoutAccount, error) {
+	return s.st.RetrievePayoutAccountsByIssuerID(ctx, issuerID)
+}
+
+// RequestPayout creates a pending payout as long as it fits in the balance
+// left after the payouts that are still pending.
+func (s *Service) RequestPayout(ctx context.Context, issuerID, accountID string, amount currency.Amount) (Payout, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return Payout{}, fmt.Errorf("could not generate id: %w", err)
+	}
+
+	return s.requestPayout(ctx, id.String(), issuerID, accountID, amount)
+}
+
+func (s *Service) requestPayout(ctx context.Context, id, issuerID, accountID string, amount currency.Amount) (Payout, error) {
+	if !amount.IsPositive() {
+		return Payout{}, fmt.Errorf("%w: payout amount must be positive", ErrInvalidAmount)
+	}
+
+	account, err := s.st.RetrievePayoutAccount(ctx, accountID)
+	if err != nil {
+		return Payout{}, err
+	}
+
+	if account.IssuerID != issuerID {
+		return Payout{}, fmt.Errorf("%w: payout account does not belong to the issuer", ErrNotOwner)
+	}
+
+	return s.createPayout(ctx, id, account, amount)
+}
+
+// AutoPayout requests a payout of the given amount to the automatic payout
+// account of the issuer, if it has one. The payout is created under the given
+// id, so retrying it never pays out twice.
+func (s *Service) AutoPayout(ctx context.Context, issuerID, payoutID string, amount currency.Amount) error {
+	if _, err := s.st.RetrievePayout(ctx, payoutID); !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	account, ok, err := s.autoPayoutAccount(ctx, issuerID)
+	if err != nil || !ok {
+		return err
+	}
+
+	if _, err := s.requestPayout(ctx, payoutID, issuerID, account.ID, amount); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FailAutoPayout gives up on an automatic payout that could not be requested,
+// recording it as failed so the issuer can see the proceeds were kept in its
+// balance.
+func (s *Service) FailAutoPayout(ctx context.Context, issuerID, payoutID string, amount currency.Amount) error {
+	// a payout that made it to the storage is handled like any other
+	if _, err := s.st.RetrievePayout(ctx, payoutID); !errors.Is(err, ErrNotFound) {
 		return err
 	}
 
-	if issuer.Balance.IsZero() {
-		return s.st.UpdateBalance(ctx, id, amount)
+	account, ok, err := s.autoPayoutAccount(ctx, issuerID)
+	if err != nil || !ok {
+		return err
 	}
 
-	convertedAmount, err := amount.Convert(issuer.Balance.CurrencyCode(), "1")
+	now := time.Now()
+	return s.st.SavePayout(ctx, Payout{
+		ID:        payoutID,
+		IssuerID:  issuerID,
+		AccountID: account.ID,
+		Amount:    amount,
+		Status:    FAILED,
+		CreatedAt: now,
+		FailedAt:  &now,
+	})
+}
+
+func (s *Service) autoPayoutAccount(ctx context.Context, issuerID string) (PayoutAccount, bool, error) {
+	accounts, err := s.st.RetrievePayoutAccountsByIssuerID(ctx, issuerID)
 	if err != nil {
-		return fmt.Errorf("could not convert amount: %w", err)
+		return PayoutAccount{}, false, err
+	}
+
+	for _, account := range accounts {
+		if account.Auto {
+			return account, true, nil
+		}
+	}
+
+	return PayoutAccount{}, false, nil
+}
+
+func (s *Service) createPayout(ctx context.Context, id string, account PayoutAccount, amount currency.Amount) (Payout, error) {
+	payout := Payout{
+		ID:        id,
+		IssuerID:  account.IssuerID,
+		AccountID: account.ID,
+		Amount:    amount,
+		Status:    PENDING,
+		CreatedAt: time.Now(),
+	}
+	if err := s.st.SavePendingPayout(ctx, payout); err != nil {
+		return Payout{}, err
+	}
+
+	return payout, nil
+}
+
+func (s *Service) ListPayouts(ctx context.Context, issuerID string) ([]Payout, error) {
+	return s.st.RetrievePayoutsByIssuerID(ctx, issuerID)
+}
+
+// SendPayout marks a pending payout as sent and deducts it from the balance.
+func (s *Service) SendPayout(ctx context.Context, issuerID, id string) (Payout, error) {
+	payout, err := s.getPayout(ctx, issuerID, id)
+	if err != nil {
+		return Payout{}, err
+	}
+
+	if payout.Status != PENDING {
+		return Payout{}, fmt.Errorf("%w: payout is already %s", ErrInvalidTransition, payout.Status)
+	}
+
+	debit, err := payout.Amount.Mul("-1")
+	if err != nil {
+		return Payout{}, fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	now := time.Now()
+	payout.Status = SENT
+	payout.SentAt = &now
+	if err := s.st.UpdatePayoutAndBalance(ctx, payout, PENDING, debit); err != nil {
+		return Payout{}, err
+	}
+
+	return payout, nil
+}
+
+// FailPayout marks a payout as failed. A payout that was already sent gives
+// its amount back to the issuer balance.
+func (s *Service) FailPayout(ctx context.Context, issuerID, id string) (Payout, error) {
+	payout, err := s.getPayout(ctx, issuerID, id)
+	if err != nil {
+		return Payout{}, err
+	}
+
+	from := payout.Status
+	now := time.Now()
+	payout.Status = FAILED
+	payout.FailedAt = &now
+
+	switch from {
+	case PENDING:
+		err = s.st.UpdatePayoutStatus(ctx, payout, from)
+	case SENT:
+		err = s.st.UpdatePayoutAndBalance(ctx, payout, from, payout.Amount)
+	default:
+		return Payout{}, fmt.Errorf("%w: payout is already %s", ErrInvalidTransition, from)
+	}
+	if err != nil {
+		return Payout{}, err
+	}
+
+	return payout, nil
+}
+
+func (s *Service) getPayout(ctx context.Context, issuerID, id string) (Payout, error) {
+	payout, err := s.st.RetrievePayout(ctx, id)
+	if err != nil {
+		return Payout{}, err
+	}
+
+	if payout.IssuerID != issuerID {
+		return Payout{}, fmt.Errorf("%w: payout does not belong to the issuer", ErrNotOwner)
 	}
 
-	total, _ := convertedAmount.Add(issuer.Balance)
-	return s.st.UpdateBalance(ctx, id, total)
+	return payout, nil
 }
@@ -12,9 +12,20 @@ import (
 )
 
 type mockStorage struct {
-	createIssuerFunc   func(context.Context, Issuer) error
-	retrieveIssuerFunc func(context.Context, string) (Issuer, error)
-	updateBalanceFunc  func(context.Context, string, currency.Amount) error
+	createIssuerFunc    func(context.Context, Issuer) error
+	retrieveIssuerFunc  func(context.Context, string) (Issuer, error)
+	retrieveIssuersFunc func(context.Context, []string) ([]Issuer, error)
+	updateBalanceFunc   func(context.Context, string, currency.Amount) error
+	creditTradeFunc     func(context.Context, string, string, currency.Amount) error
+
+	retrievePayoutAccountFunc  func(context.Context, string) (PayoutAccount, error)
+	retrievePayoutAccountsFunc func(context.Context, string) ([]PayoutAccount, error)
+	retrievePayoutsFunc        func(context.Context, string) ([]Payout, error)
+	savePayoutFunc             func(context.Context, Payout) error
+	savePendingPayoutFunc      func(context.Context, Payout) error
+	retrievePayoutFunc         func(context.Context, string) (Payout, error)
+	updatePayoutStatusFunc     func(context.Context, Payout, PayoutStatus) error
+	updatePayoutAndBalanceFunc func(context.Context, Payout, PayoutStatus, currency.Amount) error
 }
 
 func (m *mockStorage) CreateIssuer(ctx context.Context, issuer Issuer) error {
@@ -25,10 +36,54 @@ func (m *mockStorage) RetrieveIssuer(ctx context.Context, s string) (Issuer, err
 	return m.retrieveIssuerFunc(ctx, s)
 }
 
+func (m *mockStorage) RetrieveIssuers(ctx context.Context, ids []string) ([]Issuer, error) {
+	return m.retrieveIssuersFunc(ctx, ids)
+}
+
 func (m *mockStorage) UpdateBalance(ctx context.Context, s string, amount currency.Amount) error {
 	return m.updateBalanceFunc(ctx, s, amount)
 }
 
+func (m *mockStorage) CreditTrade(ctx context.Context, id, invoiceID string, amount currency.Amount) error {
+	return m.creditTradeFunc(ctx, id, invoiceID, amount)
+}
+
+func (m *mockStorage) SavePayoutAccount(ctx context.Context, a PayoutAccount) error {
+	panic("implement me")
+}
+
+func (m *mockStorage) RetrievePayoutAccount(ctx context.Context, id string) (PayoutAccount, error) {
+	return m.retrievePayoutAccountFunc(ctx, id)
+}
+
+func (m *mockStorage) RetrievePayoutAccountsByIssuerID(ctx context.Context, issuerID string) ([]PayoutAccount, error) {
+	return m.retrievePayoutAccountsFunc(ctx, issuerID)
+}
+
+func (m *mockStorage) SavePayout(ctx context.Context, p Payout) error {
+	return m.savePayoutFunc(ctx, p)
+}
+
+func (m *mockStorage) SavePendingPayout(ctx context.Context, p Payout) error {
+	return m.savePendingPayoutFunc(ctx, p)
+}
+
+func (m *mockStorage) RetrievePayout(ctx context.Context, id string) (Payout, error) {
+	return m.retrievePayoutFunc(ctx, id)
+}
+
+func (m *mockStorage) RetrievePayoutsByIssuerID(ctx context.Context, issuerID string) ([]Payout, error) {
+	return m.retrievePayoutsFunc(ctx, issuerID)
+}
+
+func (m *mockStorage) UpdatePayoutStatus(ctx context.Context, p Payout, from PayoutStatus) error {
+	return m.updatePayoutStatusFunc(ctx, p, from)
+}
+
+func (m *mockStorage) UpdatePayoutAndBalance(ctx context.Context, p Payout, from PayoutStatus, amount currency.Amount) error {
+	return m.updatePayoutAndBalanceFunc(ctx, p, from, amount)
+}
+
 func TestService_CreateIssuer(t *testing.T) {
 	Convey("CreateIssuer", t, func() {
 		st := &mockStorage{}
@@ -40,7 +95,7 @@ func TestService_CreateIssuer(t *testing.T) {
 			}
 
 			Convey("return an empty issuer and an error", func() {
-				iss, err := svc.CreateIssuer(context.Background(), "name")
+				iss, err := svc.CreateIssuer(context.Background(), "name", "B")
 				So(err, ShouldNotBeNil)
 				So(iss, ShouldEqual, Issuer{})
 			})
@@ -53,9 +108,10 @@ func TestService_CreateIssuer(t *testing.T) {
 			}
 
 			Convey("return a valid issuer and no error", func() {
-				iss, err := svc.CreateIssuer(context.Background(), "name")
+				iss, err := svc.CreateIssuer(context.Background(), "name", "B")
 				So(err, ShouldBeNil)
 				So(iss.FullName, ShouldEqual, "name")
+				So(iss.Rating, ShouldEqual, "B")
 				So(iss.Balance.IsZero(), ShouldBeTrue)
 				_, err = uuid.Parse(iss.ID)
 				So(err, ShouldBeNil)
@@ -68,57 +124,295 @@ func TestService_ApproveTrade(t *testing.T) {
 	Convey("ApproveTrade", t, func() {
 		st := &mockStorage{}
 		svc := NewService(st)
+		amount, _ := currency.NewAmount("1000", "EUR")
 
-		Convey("when storage fails to retrieve the issuer", func() {
-			st.retrieveIssuerFunc = func(ctx context.Context, id string) (Issuer, error) {
-				So(id, ShouldEqual, "id")
-				return Issuer{}, errors.New("some error")
+		Convey("when storage fails to credit the trade", func() {
+			st.creditTradeFunc = func(ctx context.Context, id, invoiceID string, a currency.Amount) error {
+				return errors.New("some error")
 			}
 
 			Convey("return an error", func() {
-				err := svc.ApproveTrade(context.Background(), "id", currency.Amount{})
+				err := svc.ApproveTrade(context.Background(), "id", "invoiceID", amount)
 				So(err, ShouldNotBeNil)
 			})
 		})
 
-		Convey("when storage successfully retrieves the issuer", func() {
-			st.retrieveIssuerFunc = func(ctx context.Context, id string) (Issuer, error) {
+		Convey("when storage credits the trade", func() {
+			st.creditTradeFunc = func(ctx context.Context, id, invoiceID string, a currency.Amount) error {
 				So(id, ShouldEqual, "id")
-				b, _ := currency.NewAmount("1000", "EUR")
-				return Issuer{
-					ID:       id,
-					FullName: "name",
-					Balance:  b,
-				}, nil
-			}
-			amount, _ := currency.NewAmount("1000", "EUR")
-
-			Convey("when storage fails to update issuer balance", func() {
-				st.updateBalanceFunc = func(ctx context.Context, id string, a currency.Amount) error {
-					So(id, ShouldEqual, "id")
-					amount, _ := currency.NewAmount("2000", "EUR")
-					So(a, ShouldEqual, amount)
-					return errors.New("some error")
-				}
-
-				Convey("return no error", func() {
-					err := svc.ApproveTrade(context.Background(), "id", amount)
-					So(err, ShouldNotBeNil)
-				})
-			})
-
-			Convey("when storage successfully updates issuer balance", func() {
-				st.updateBalanceFunc = func(ctx context.Context, id string, a currency.Amount) error {
-					So(id, ShouldEqual, "id")
-					amount, _ := currency.NewAmount("2000", "EUR")
-					So(a, ShouldEqual, amount)
-					return nil
-				}
-
-				Convey("return no error", func() {
-					err := svc.ApproveTrade(context.Background(), "id", amount)
-					So(err, ShouldBeNil)
-				})
+				So(invoiceID, ShouldEqual, "invoiceID")
+				So(a, ShouldEqual, amount)
+				return nil
+			}
+
+			Convey("return no error", func() {
+				err := svc.ApproveTrade(context.Background(), "id", "invoiceID", amount)
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestService_RequestPayout(t *testing.T) {
+	Convey("RequestPayout", t, func() {
+		st := &mockStorage{}
+		svc := NewService(st)
+
+		st.retrievePayoutAccountFunc = func(ctx context.Context, id string) (PayoutAccount, error) {
+			return PayoutAccount{ID: id, IssuerID: "issuer"}, nil
+		}
+
+		Convey("when the account belongs to another issuer", func() {
+			amount, _ := currency.NewAmount("100", "EUR")
+
+			Convey("return an error", func() {
+				_, err := svc.RequestPayout(context.Background(), "other", "account", amount)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the amount is not positive", func() {
+			amount, _ := currency.NewAmount("0", "EUR")
+
+			Convey("return an error", func() {
+				_, err := svc.RequestPayout(context.Background(), "issuer", "account", amount)
+				So(errors.Is(err, ErrInvalidAmount), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the amount exceeds the balance left by pending payouts", func() {
+			amount, _ := currency.NewAmount("500", "EUR")
+			st.savePendingPayoutFunc = func(ctx context.Context, p Payout) error {
+				return ErrInsufficientFunds
+			}
+
+			Convey("return an error", func() {
+				_, err := svc.RequestPayout(context.Background(), "issuer", "account", amount)
+				So(errors.Is(err, ErrInsufficientFunds), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the amount fits in the available balance", func() {
+			amount, _ := currency.NewAmount("400", "EUR")
+			st.savePendingPayoutFunc = func(ctx context.Context, p Payout) error {
+				So(p.Amount, ShouldEqual, amount)
+				So(p.AccountID, ShouldEqual, "account")
+				return nil
+			}
+
+			Convey("return a pending payout", func() {
+				p, err := svc.RequestPayout(context.Background(), "issuer", "account", amount)
+				So(err, ShouldBeNil)
+				So(p.Status, ShouldEqual, PENDING)
+				So(p.IssuerID, ShouldEqual, "issuer")
+			})
+		})
+	})
+}
+
+func TestService_AutoPayout(t *testing.T) {
+	Convey("AutoPayout", t, func() {
+		st := &mockStorage{}
+		svc := NewService(st)
+
+		amount, _ := currency.NewAmount("400", "EUR")
+		accounts := []PayoutAccount{{ID: "manual", IssuerID: "issuer"}, {ID: "auto", IssuerID: "issuer", Auto: true}}
+		st.retrievePayoutAccountsFunc = func(ctx context.Context, id string) ([]PayoutAccount, error) {
+			return accounts, nil
+		}
+		st.retrievePayoutAccountFunc = func(ctx context.Context, id string) (PayoutAccount, error) {
+			return PayoutAccount{ID: id, IssuerID: "issuer", Auto: id == "auto"}, nil
+		}
+		st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+			return Payout{}, ErrNotFound
+		}
+		var saved []Payout
+		st.savePendingPayoutFunc = func(ctx context.Context, p Payout) error {
+			saved = append(saved, p)
+			return nil
+		}
+
+		Convey("when the issuer has an automatic account", func() {
+			err := svc.AutoPayout(context.Background(), "issuer", "payoutID", amount)
+
+			Convey("request a pending payout to it under the given id", func() {
+				So(err, ShouldBeNil)
+				So(saved, ShouldHaveLength, 1)
+				So(saved[0].ID, ShouldEqual, "payoutID")
+				So(saved[0].AccountID, ShouldEqual, "auto")
+				So(saved[0].Status, ShouldEqual, PENDING)
+			})
+		})
+
+		Convey("when the payout was already requested", func() {
+			st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+				return Payout{ID: id, IssuerID: "issuer", Status: PENDING}, nil
+			}
+
+			Convey("not request it again", func() {
+				So(svc.AutoPayout(context.Background(), "issuer", "payoutID", amount), ShouldBeNil)
+				So(saved, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the issuer has no automatic account", func() {
+			accounts = accounts[:1]
+
+			Convey("keep the proceeds in the balance", func() {
+				So(svc.AutoPayout(context.Background(), "issuer", "payoutID", amount), ShouldBeNil)
+				So(saved, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestService_FailAutoPayout(t *testing.T) {
+	Convey("FailAutoPayout", t, func() {
+		st := &mockStorage{}
+		svc := NewService(st)
+
+		amount, _ := currency.NewAmount("400", "EUR")
+		st.retrievePayoutAccountsFunc = func(ctx context.Context, id string) ([]PayoutAccount, error) {
+			return []PayoutAccount{{ID: "auto", IssuerID: "issuer", Auto: true}}, nil
+		}
+		st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+			return Payout{}, ErrNotFound
+		}
+		var saved []Payout
+		st.savePayoutFunc = func(ctx context.Context, p Payout) error {
+			saved = append(saved, p)
+			return nil
+		}
+
+		Convey("when the payout could never be requested", func() {
+			err := svc.FailAutoPayout(context.Background(), "issuer", "payoutID", amount)
+
+			Convey("record it as failed without touching the balance", func() {
+				So(err, ShouldBeNil)
+				So(saved, ShouldHaveLength, 1)
+				So(saved[0].ID, ShouldEqual, "payoutID")
+				So(saved[0].AccountID, ShouldEqual, "auto")
+				So(saved[0].Status, ShouldEqual, FAILED)
+				So(saved[0].FailedAt, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the payout was requested after all", func() {
+			st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+				return Payout{ID: id, IssuerID: "issuer", Status: PENDING}, nil
+			}
+
+			Convey("leave it as it is", func() {
+				So(svc.FailAutoPayout(context.Background(), "issuer", "payoutID", amount), ShouldBeNil)
+				So(saved, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestService_SendPayout(t *testing.T) {
+	Convey("SendPayout", t, func() {
+		st := &mockStorage{}
+		svc := NewService(st)
+
+		Convey("when the payout is not pending", func() {
+			st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+				return Payout{ID: id, IssuerID: "issuer", Status: SENT}, nil
+			}
+
+			Convey("return an error", func() {
+				_, err := svc.SendPayout(context.Background(), "issuer", "payout")
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the payout exceeds the balance", func() {
+			amount, _ := currency.NewAmount("1500", "EUR")
+			st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+				return Payout{ID: id, IssuerID: "issuer", Amount: amount, Status: PENDING}, nil
+			}
+			st.updatePayoutAndBalanceFunc = func(ctx context.Context, p Payout, from PayoutStatus, a currency.Amount) error {
+				return ErrInsufficientFunds
+			}
+
+			Convey("return an error", func() {
+				_, err := svc.SendPayout(context.Background(), "issuer", "payout")
+				So(errors.Is(err, ErrInsufficientFunds), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the payout fits in the balance", func() {
+			amount, _ := currency.NewAmount("400", "EUR")
+			st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+				return Payout{ID: id, IssuerID: "issuer", Amount: amount, Status: PENDING}, nil
+			}
+			st.updatePayoutAndBalanceFunc = func(ctx context.Context, p Payout, from PayoutStatus, a currency.Amount) error {
+				debit, _ := currency.NewAmount("-400", "EUR")
+				So(from, ShouldEqual, PENDING)
+				So(a, ShouldEqual, debit)
+				return nil
+			}
+
+			Convey("deduct the balance and return a sent payout", func() {
+				p, err := svc.SendPayout(context.Background(), "issuer", "payout")
+				So(err, ShouldBeNil)
+				So(p.Status, ShouldEqual, SENT)
+				So(p.SentAt, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestService_FailPayout(t *testing.T) {
+	Convey("FailPayout", t, func() {
+		st := &mockStorage{}
+		svc := NewService(st)
+
+		amount, _ := currency.NewAmount("400", "EUR")
+
+		Convey("when the payout is pending", func() {
+			st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+				return Payout{ID: id, IssuerID: "issuer", Amount: amount, Status: PENDING}, nil
+			}
+			st.updatePayoutStatusFunc = func(ctx context.Context, p Payout, from PayoutStatus) error {
+				So(from, ShouldEqual, PENDING)
+				So(p.Status, ShouldEqual, FAILED)
+				return nil
+			}
+
+			Convey("fail it without touching the balance", func() {
+				p, err := svc.FailPayout(context.Background(), "issuer", "payout")
+				So(err, ShouldBeNil)
+				So(p.Status, ShouldEqual, FAILED)
+			})
+		})
+
+		Convey("when the payout was sent", func() {
+			st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+				return Payout{ID: id, IssuerID: "issuer", Amount: amount, Status: SENT}, nil
+			}
+			st.updatePayoutAndBalanceFunc = func(ctx context.Context, p Payout, from PayoutStatus, a currency.Amount) error {
+				So(from, ShouldEqual, SENT)
+				So(a, ShouldEqual, amount)
+				return nil
+			}
+
+			Convey("reverse it into the balance", func() {
+				p, err := svc.FailPayout(context.Background(), "issuer", "payout")
+				So(err, ShouldBeNil)
+				So(p.Status, ShouldEqual, FAILED)
+				So(p.FailedAt, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the payout already failed", func() {
+			st.retrievePayoutFunc = func(ctx context.Context, id string) (Payout, error) {
+				return Payout{ID: id, IssuerID: "issuer", Amount: amount, Status: FAILED}, nil
+			}
+
+			Convey("return an error", func() {
+				_, err := svc.FailPayout(context.Background(), "issuer", "payout")
+				So(err, ShouldNotBeNil)
 			})
 		})
 	})
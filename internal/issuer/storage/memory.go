@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/issuer"
+)
+
+// MemoryStorage keeps issuers and their payouts in memory, remembering the
+// credited invoices so a trade is never paid twice.
+type MemoryStorage struct {
+	mu       sync.RWMutex
+	issuers  map[string]issuer.Issuer
+	accounts map[string]issuer.PayoutAccount
+	payouts  map[string]issuer.Payout
+	credits  map[string]bool
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		issuers:  map[string]issuer.Issuer{},
+		accounts: map[string]issuer.PayoutAccount{},
+		payouts:  map[string]issuer.Payout{},
+		credits:  map[string]bool{},
+	}
+}
+
+func (s *MemoryStorage) CreateIssuer(_ context.Context, iss issuer.Issuer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.issuers[iss.ID]; ok {
+		return fmt.Errorf("could not save issuer: issuer %s already exists", iss.ID)
+	}
+
+	s.issuers[iss.ID] = iss
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrieveIssuer(_ context.Context, id string) (issuer.Issuer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	iss, ok := s.issuers[id]
+	if !ok {
+		return issuer.Issuer{ID: id}, fmt.Errorf("could not retrieve issuer: %w", issuer.ErrNotFound)
+	}
+
+	return iss, nil
+}
+
+func (s *MemoryStorage) RetrieveIssuers(_ context.Context, ids []string) ([]issuer.Issuer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var issuers []issuer.Issuer
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if iss, ok := s.issuers[id]; ok && !seen[id] {
+			seen[id] = true
+			issuers = append(issuers, iss)
+		}
+	}
+
+	return issuers, nil
+}
+
+func (s *MemoryStorage) UpdateBalance(_ context.Context, id string, balance currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.issuers[id]; !ok {
+		return fmt.Errorf("could not replace current issuer balance: %w", issuer.ErrNotFound)
+	}
+	s.setBalance(id, balance)
+
+	return nil
+}
+
+func (s *MemoryStorage) CreditTrade(_ context.Context, id, invoiceID string, amount currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	iss, ok := s.issuers[id]
+	if !ok {
+		return fmt.Errorf("could not update issuer balance: %w", issuer.ErrNotFound)
+	}
+
+	if s.credits[invoiceID] {
+		return nil
+	}
+
+	balance, err := creditBalance(iss.Balance, amount)
+	if err != nil {
+		return err
+	}
+
+	s.credits[invoiceID] = true
+	s.setBalance(id, balance)
+
+	return nil
+}
+
+func (s *MemoryStorage) SavePayoutAccount(_ context.Context, a issuer.PayoutAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[a.ID]; ok {
+		return fmt.Errorf("could not save payout account: account %s already exists", a.ID)
+	}
+	if _, ok := s.issuers[a.IssuerID]; !ok {
+		return fmt.Errorf("could not save payout account: %w", issuer.ErrNotFound)
+	}
+
+	if a.Auto {
+		for id, acc := range s.accounts {
+			if acc.IssuerID == a.IssuerID && acc.Auto {
+				acc.Auto = false
+				s.accounts[id] = acc
+			}
+		}
+	}
+
+	s.accounts[a.ID] = a
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrievePayoutAccount(_ context.Context, id string) (issuer.PayoutAccount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.accounts[id]
+	if !ok {
+		return issuer.PayoutAccount{ID: id}, fmt.Errorf("could not retrieve payout account: %w", issuer.ErrNotFound)
+	}
+
+	return a, nil
+}
+
+func (s *MemoryStorage) RetrievePayoutAccountsByIssuerID(_ context.Context, issuerID string) ([]issuer.PayoutAccount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var accounts []issuer.PayoutAccount
+	for _, a := range s.accounts {
+		if a.IssuerID == issuerID {
+			accounts = append(accounts, a)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].CreatedAt.Before(accounts[j].CreatedAt) })
+
+	return accounts, nil
+}
+
+func (s *MemoryStorage) SavePayout(_ context.Context, p issuer.Payout) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.payouts[p.ID]; ok {
+		return fmt.Errorf("could not save payout: payout %s already exists", p.ID)
+	}
+	if _, ok := s.issuers[p.IssuerID]; !ok {
+		return fmt.Errorf("could not save payout: %w", issuer.ErrNotFound)
+	}
+	if _, ok := s.accounts[p.AccountID]; !ok {
+		return fmt.Errorf("could not save payout: %w", issuer.ErrNotFound)
+	}
+
+	s.payouts[p.ID] = p
+
+	return nil
+}
+
+func (s *MemoryStorage) SavePendingPayout(_ context.Context, p issuer.Payout) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.payouts[p.ID]; ok {
+		return fmt.Errorf("could not save payout: payout %s already exists", p.ID)
+	}
+	iss, ok := s.issuers[p.IssuerID]
+	if !ok {
+		return fmt.Errorf("could not save payout: %w", issuer.ErrNotFound)
+	}
+	if _, ok := s.accounts[p.AccountID]; !ok {
+		return fmt.Errorf("could not save payout: %w", issuer.ErrNotFound)
+	}
+
+	var pending []currency.Amount
+	for _, o := range s.payouts {
+		if o.IssuerID == p.IssuerID && o.Status == issuer.PENDING {
+			pending = append(pending, o.Amount)
+		}
+	}
+	if err := reserveBalance(iss.Balance, append(pending, p.Amount)); err != nil {
+		return fmt.Errorf("could not save payout: %w", err)
+	}
+
+	p.Status = issuer.PENDING
+	s.payouts[p.ID] = p
+
+	return nil
+}
+
+func (s *MemoryStorage) RetrievePayout(_ context.Context, id string) (issuer.Payout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.payouts[id]
+	if !ok {
+		return issuer.Payout{ID: id}, fmt.Errorf("could not retrieve payout: %w", issuer.ErrNotFound)
+	}
+
+	return p, nil
+}
+
+func (s *MemoryStorage) RetrievePayoutsByIssuerID(_ context.Context, issuerID string) ([]issuer.Payout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var payouts []issuer.Payout
+	for _, p := range s.payouts {
+		if p.IssuerID == issuerID {
+			payouts = append(payouts, p)
+		}
+	}
+	sort.Slice(payouts, func(i, j int) bool { return payouts[i].CreatedAt.Before(payouts[j].CreatedAt) })
+
+	return payouts, nil
+}
+
+func (s *MemoryStorage) UpdatePayoutStatus(_ context.Context, p issuer.Payout, from issuer.PayoutStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updatePayoutStatus(p, from)
+}
+
+func (s *MemoryStorage) UpdatePayoutAndBalance(_ context.Context, p issuer.Payout, from issuer.PayoutStatus, amount currency.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	iss, ok := s.issuers[p.IssuerID]
+	if !ok {
+		return fmt.Errorf("could not update issuer balance: %w", issuer.ErrNotFound)
+	}
+
+	balance, err := creditBalance(iss.Balance, amount)
+	if err != nil {
+		return err
+	}
+	if balance.IsNegative() {
+		return fmt.Errorf("could not update issuer balance: %w", issuer.ErrInsufficientFunds)
+	}
+
+	if err := s.updatePayoutStatus(p, from); err != nil {
+		return err
+	}
+	s.setBalance(p.IssuerID, balance)
+
+	return nil
+}
+
+// updatePayoutStatus moves a payout out of the expected status, failing if
+// another request moved it first so the balance is never applied twice.
+func (s *MemoryStorage) updatePayoutStatus(p issuer.Payout, from issuer.PayoutStatus) error {
+	stored, ok := s.payouts[p.ID]
+	if !ok || stored.Status != from {
+		return fmt.Errorf("%w: payout is no longer %s", issuer.ErrInvalidTransition, from)
+	}
+
+	stored.Status = p.Status
+	stored.SentAt = p.SentAt
+	stored.FailedAt = p.FailedAt
+	s.payouts[p.ID] = stored
+
+	return nil
+}
+
+func (s *MemoryStorage) setBalance(id string, balance currency.Amount) {
+	if iss, ok := s.issuers[id]; ok {
+		iss.Balance = balance
+		s.issuers[id] = iss
+	}
+}
+
+// creditBalance adds the amount to the balance in its currency, an empty
+// balance takes the currency of the amount
+func creditBalance(balance, amount currency.Amount) (currency.Amount, error) {
+	if balance.IsZero() {
+		return amount, nil
+	}
+
+	credit, err := currency.NewAmount(amount.Number(), balance.CurrencyCode())
+	if err != nil {
+		return balance, fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	total, err := balance.Add(credit)
+	if err != nil {
+		return balance, fmt.Errorf("could not perform currency operation: %w", err)
+	}
+
+	return total, nil
+}
+
+// reserveBalance checks the pending payouts fit in the balance, failing with
+// ErrInsufficientFunds when they do not
+func reserveBalance(balance currency.Amount, pending []currency.Amount) error {
+	for _, amount := range pending {
+		debit, err := amount.Mul("-1")
+		if err != nil {
+			return fmt.Errorf("could not perform currency operation: %w", err)
+		}
+
+		if balance, err = creditBalance(balance, debit); err != nil {
+			return err
+		}
+	}
+
+	if balance.IsNegative() {
+		return issuer.ErrInsufficientFunds
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/nerock/invoicebidder/internal/issuer"
+	"github.com/nerock/invoicebidder/internal/issuer/storage/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) issuer.Storage {
+		return NewMemoryStorage()
+	})
+}
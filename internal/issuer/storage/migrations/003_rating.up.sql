@@ -0,0 +1,2 @@
+ALTER TABLE issuers
+ADD COLUMN rating TEXT NOT NULL DEFAULT ''
@@ -0,0 +1,24 @@
+CREATE TABLE payout_accounts (
+    id CHAR(36) PRIMARY KEY,
+    issuer_id CHAR(36) NOT NULL REFERENCES issuers (id),
+    iban TEXT NOT NULL,
+    holder TEXT NOT NULL,
+    auto BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX payout_accounts_issuer_id_idx ON payout_accounts (issuer_id);
+CREATE UNIQUE INDEX payout_accounts_auto_idx ON payout_accounts (issuer_id) WHERE auto;
+
+CREATE TABLE payouts (
+    id CHAR(36) PRIMARY KEY,
+    issuer_id CHAR(36) NOT NULL REFERENCES issuers (id),
+    account_id CHAR(36) NOT NULL REFERENCES payout_accounts (id),
+    amount amount NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    failed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX payouts_issuer_id_idx ON payouts (issuer_id, created_at);
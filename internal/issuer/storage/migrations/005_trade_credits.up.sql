@@ -0,0 +1,6 @@
+CREATE TABLE trade_credits (
+    invoice_id CHAR(36) PRIMARY KEY,
+    issuer_id CHAR(36) NOT NULL REFERENCES issuers (id),
+    amount amount NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
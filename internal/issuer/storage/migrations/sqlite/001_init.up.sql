@@ -0,0 +1,33 @@
+CREATE TABLE issuers (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    rating TEXT NOT NULL DEFAULT '',
+    balance_number TEXT NOT NULL,
+    balance_currency TEXT NOT NULL
+);
+
+CREATE TABLE payout_accounts (
+    id TEXT PRIMARY KEY,
+    issuer_id TEXT NOT NULL REFERENCES issuers (id),
+    iban TEXT NOT NULL,
+    holder TEXT NOT NULL,
+    auto INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX payout_accounts_issuer_id_idx ON payout_accounts (issuer_id);
+CREATE UNIQUE INDEX payout_accounts_auto_idx ON payout_accounts (issuer_id) WHERE auto = 1;
+
+CREATE TABLE payouts (
+    id TEXT PRIMARY KEY,
+    issuer_id TEXT NOT NULL REFERENCES issuers (id),
+    account_id TEXT NOT NULL REFERENCES payout_accounts (id),
+    amount_number TEXT NOT NULL,
+    amount_currency TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    sent_at TEXT,
+    failed_at TEXT
+);
+
+CREATE INDEX payouts_issuer_id_idx ON payouts (issuer_id, created_at);
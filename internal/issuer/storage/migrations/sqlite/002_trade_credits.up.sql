@@ -0,0 +1,7 @@
+CREATE TABLE trade_credits (
+    invoice_id TEXT PRIMARY KEY,
+    issuer_id TEXT NOT NULL REFERENCES issuers (id),
+    amount_number TEXT NOT NULL,
+    amount_currency TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
@@ -2,9 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bojanz/currency"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nerock/invoicebidder/internal/issuer"
 )
@@ -18,9 +22,9 @@ func New(c *pgxpool.Pool) *Storage {
 }
 
 func (s *Storage) CreateIssuer(ctx context.Context, issuer issuer.Issuer) error {
-	const query = `INSERT INTO issuers (id, name, balance) VALUES ($1, $2, $3)`
+	const query = `INSERT INTO issuers (id, name, rating, balance) VALUES ($1, $2, $3, $4)`
 
-	if _, err := s.c.Exec(ctx, query, issuer.ID, issuer.FullName, issuer.Balance); err != nil {
+	if _, err := s.c.Exec(ctx, query, issuer.ID, issuer.FullName, issuer.Rating, issuer.Balance); err != nil {
 		return fmt.Errorf("could not save issuer in db: %w", err)
 	}
 
@@ -28,10 +32,13 @@ func (s *Storage) CreateIssuer(ctx context.Context, issuer issuer.Issuer) error
 }
 
 func (s *Storage) RetrieveIssuer(ctx context.Context, id string) (issuer.Issuer, error) {
-	const query = `SELECT i.name, i.balance FROM issuers i WHERE i.id = $1`
+	const query = `SELECT i.name, i.rating, i.balance FROM issuers i WHERE i.id = $1`
 
 	iss := issuer.Issuer{ID: id}
-	err := s.c.QueryRow(ctx, query, id).Scan(&iss.FullName, &iss.Balance)
+	err := s.c.QueryRow(ctx, query, id).Scan(&iss.FullName, &iss.Rating, &iss.Balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return iss, fmt.Errorf("could not retrieve issuer: %w", issuer.ErrNotFound)
+	}
 	if err != nil {
 		return iss, fmt.Errorf("could not retrieve issuer: %w", err)
 	}
@@ -39,12 +46,304 @@ func (s *Storage) RetrieveIssuer(ctx context.Context, id string) (issuer.Issuer,
 	return iss, nil
 }
 
+func (s *Storage) RetrieveIssuers(ctx context.Context, ids []string) ([]issuer.Issuer, error) {
+	const query = `SELECT i.id, i.name, i.rating, i.balance FROM issuers i WHERE i.id = any($1)`
+
+	rows, err := s.c.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve issuers: %w", err)
+	}
+	defer rows.Close()
+
+	var issuers []issuer.Issuer
+	for rows.Next() {
+		var iss issuer.Issuer
+		if err := rows.Scan(&iss.ID, &iss.FullName, &iss.Rating, &iss.Balance); err != nil {
+			return nil, fmt.Errorf("could not scan issuers: %w", err)
+		}
+
+		issuers = append(issuers, iss)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve issuers: %w", err)
+	}
+
+	return issuers, nil
+}
+
 func (s *Storage) UpdateBalance(ctx context.Context, id string, balance currency.Amount) error {
 	const query = `UPDATE issuers SET balance = $1 WHERE id = $2`
 
-	if _, err := s.c.Exec(ctx, query, balance, id); err != nil {
+	tag, err := s.c.Exec(ctx, query, balance, id)
+	if err != nil {
 		return fmt.Errorf("could not replace current issuer balance in db: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("could not replace current issuer balance: %w", issuer.ErrNotFound)
+	}
+
+	return nil
+}
+
+// CreditTrade adds the amount to the balance, taking its currency when the
+// balance is empty, unless the invoice was already credited.
+func (s *Storage) CreditTrade(ctx context.Context, id, invoiceID string, amount currency.Amount) error {
+	const (
+		balanceQuery = `UPDATE issuers SET balance = CASE WHEN (balance).number = 0 THEN ROW($2::numeric, $3)::amount
+			ELSE ROW((balance).number + $2::numeric, (balance).currency_code)::amount END WHERE id = $1`
+		creditQuery = `INSERT INTO trade_credits (invoice_id, issuer_id, amount, created_at)
+			VALUES ($1, $2, $3, $4) ON CONFLICT (invoice_id) DO NOTHING`
+	)
+
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// the balance goes first so concurrent credits of an invoice wait on the
+	// issuer row and see the credit of the first one
+	tag, err := tx.Exec(ctx, balanceQuery, id, amount.Number(), amount.CurrencyCode())
+	if err != nil {
+		return fmt.Errorf("could not update issuer balance in db: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("could not update issuer balance: %w", issuer.ErrNotFound)
+	}
+
+	tag, err = tx.Exec(ctx, creditQuery, invoiceID, id, amount, time.Now())
+	if err != nil {
+		return fmt.Errorf("could not save trade credit in db: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SavePayoutAccount(ctx context.Context, a issuer.PayoutAccount) error {
+	const (
+		unsetAutoQuery = `UPDATE payout_accounts SET auto = FALSE WHERE issuer_id = $1 AND auto`
+		query          = `INSERT INTO payout_accounts (id, issuer_id, iban, holder, auto, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	)
+
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if a.Auto {
+		if _, err := tx.Exec(ctx, unsetAutoQuery, a.IssuerID); err != nil {
+			return fmt.Errorf("could not unset automatic payout account in db: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, query, a.ID, a.IssuerID, a.IBAN, a.Holder, a.Auto, a.CreatedAt); err != nil {
+		return fmt.Errorf("could not save payout account in db: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrievePayoutAccount(ctx context.Context, id string) (issuer.PayoutAccount, error) {
+	const query = `SELECT a.issuer_id, a.iban, a.holder, a.auto, a.created_at FROM payout_accounts a WHERE a.id = $1`
+
+	a := issuer.PayoutAccount{ID: id}
+	err := s.c.QueryRow(ctx, query, id).Scan(&a.IssuerID, &a.IBAN, &a.Holder, &a.Auto, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, fmt.Errorf("could not retrieve payout account: %w", issuer.ErrNotFound)
+	}
+	if err != nil {
+		return a, fmt.Errorf("could not retrieve payout account: %w", err)
+	}
+
+	return a, nil
+}
+
+func (s *Storage) RetrievePayoutAccountsByIssuerID(ctx context.Context, issuerID string) ([]issuer.PayoutAccount, error) {
+	const query = `SELECT a.id, a.iban, a.holder, a.auto, a.created_at
+		FROM payout_accounts a WHERE a.issuer_id = $1 ORDER BY a.created_at`
+
+	rows, err := s.c.Query(ctx, query, issuerID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve payout accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []issuer.PayoutAccount
+	for rows.Next() {
+		a := issuer.PayoutAccount{IssuerID: issuerID}
+		if err := rows.Scan(&a.ID, &a.IBAN, &a.Holder, &a.Auto, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan payout accounts: %w", err)
+		}
+
+		accounts = append(accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve payout accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+func (s *Storage) SavePayout(ctx context.Context, p issuer.Payout) error {
+	const query = `INSERT INTO payouts (id, issuer_id, account_id, amount, status, created_at, failed_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	if _, err := s.c.Exec(ctx, query, p.ID, p.IssuerID, p.AccountID, p.Amount, p.Status, p.CreatedAt, p.FailedAt); err != nil {
+		return fmt.Errorf("could not save payout in db: %w", err)
+	}
+
+	return nil
+}
+
+// SavePendingPayout saves a pending payout as long as it fits in the balance
+// left after the payouts that are still pending. The issuer row is locked
+// first so concurrent requests are checked one after the other.
+func (s *Storage) SavePendingPayout(ctx context.Context, p issuer.Payout) error {
+	const (
+		lockQuery   = `SELECT 1 FROM issuers WHERE id = $1 FOR UPDATE`
+		payoutQuery = `INSERT INTO payouts (id, issuer_id, account_id, amount, status, created_at)
+			SELECT $1::text, i.id, $3::text, $4::amount, $5::text, $6::timestamptz FROM issuers i WHERE i.id = $2 AND (i.balance).number - COALESCE((
+				SELECT SUM((o.amount).number) FROM payouts o WHERE o.issuer_id = i.id AND o.status = $5::text
+			), 0) >= $7::numeric`
+	)
+
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked int
+	err = tx.QueryRow(ctx, lockQuery, p.IssuerID).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("could not lock issuer: %w", issuer.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("could not lock issuer: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, payoutQuery, p.ID, p.IssuerID, p.AccountID, p.Amount, issuer.PENDING, p.CreatedAt, p.Amount.Number())
+	if err != nil {
+		return fmt.Errorf("could not save payout in db: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("could not save payout: %w", issuer.ErrInsufficientFunds)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetrievePayout(ctx context.Context, id string) (issuer.Payout, error) {
+	const query = `SELECT p.issuer_id, p.account_id, p.amount, p.status, p.created_at, p.sent_at, p.failed_at
+		FROM payouts p WHERE p.id = $1`
+
+	p := issuer.Payout{ID: id}
+	err := s.c.QueryRow(ctx, query, id).Scan(&p.IssuerID, &p.AccountID, &p.Amount, &p.Status, &p.CreatedAt, &p.SentAt, &p.FailedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return p, fmt.Errorf("could not retrieve payout: %w", issuer.ErrNotFound)
+	}
+	if err != nil {
+		return p, fmt.Errorf("could not retrieve payout: %w", err)
+	}
+
+	return p, nil
+}
+
+func (s *Storage) RetrievePayoutsByIssuerID(ctx context.Context, issuerID string) ([]issuer.Payout, error) {
+	const query = `SELECT p.id, p.account_id, p.amount, p.status, p.created_at, p.sent_at, p.failed_at
+		FROM payouts p WHERE p.issuer_id = $1 ORDER BY p.created_at`
+
+	rows, err := s.c.Query(ctx, query, issuerID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve payouts: %w", err)
+	}
+	defer rows.Close()
+
+	var payouts []issuer.Payout
+	for rows.Next() {
+		p := issuer.Payout{IssuerID: issuerID}
+		if err := rows.Scan(&p.ID, &p.AccountID, &p.Amount, &p.Status, &p.CreatedAt, &p.SentAt, &p.FailedAt); err != nil {
+			return nil, fmt.Errorf("could not scan payouts: %w", err)
+		}
+
+		payouts = append(payouts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not retrieve payouts: %w", err)
+	}
+
+	return payouts, nil
+}
+
+func (s *Storage) UpdatePayoutStatus(ctx context.Context, p issuer.Payout, from issuer.PayoutStatus) error {
+	return updatePayoutStatus(ctx, s.c, p, from)
+}
+
+// UpdatePayoutAndBalance moves the payout out of the given status and adds the
+// amount to the balance in the same transaction, refusing a debit that would
+// leave the balance negative.
+func (s *Storage) UpdatePayoutAndBalance(ctx context.Context, p issuer.Payout, from issuer.PayoutStatus, amount currency.Amount) error {
+	const balanceQuery = `UPDATE issuers SET balance = CASE WHEN (balance).number = 0 THEN ROW($2::numeric, $3)::amount
+		ELSE ROW((balance).number + $2::numeric, (balance).currency_code)::amount END
+		WHERE id = $1 AND (balance).number + $2::numeric >= 0`
+
+	tx, err := s.c.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updatePayoutStatus(ctx, tx, p, from); err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, balanceQuery, p.IssuerID, amount.Number(), amount.CurrencyCode())
+	if err != nil {
+		return fmt.Errorf("could not update issuer balance in db: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("could not update issuer balance: %w", issuer.ErrInsufficientFunds)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+type execer interface {
+	Exec(context.Context, string, ...any) (pgconn.CommandTag, error)
+}
+
+// updatePayoutStatus moves a payout out of the expected status, failing if
+// another request moved it first so the balance is never applied twice.
+func updatePayoutStatus(ctx context.Context, c execer, p issuer.Payout, from issuer.PayoutStatus) error {
+	const query = `UPDATE payouts SET status = $3, sent_at = $4, failed_at = $5 WHERE id = $1 AND status = $2`
+
+	tag, err := c.Exec(ctx, query, p.ID, from, p.Status, p.SentAt, p.FailedAt)
+	if err != nil {
+		return fmt.Errorf("could not update payout status in db: %w", err)
+	}
+
+	if tag.RowsAffected() != 1 {
+		return fmt.Errorf("%w: payout is no longer %s", issuer.ErrInvalidTransition, from)
+	}
 
 	return nil
 }
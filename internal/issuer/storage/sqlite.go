@@ -0,0 +1,418 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/issuer"
+	"github.com/nerock/invoicebidder/internal/sqlite"
+)
+
+//go:embed migrations/sqlite/*.up.sql
+var sqliteMigrations embed.FS
+
+// SQLiteStorage keeps issuers in an embedded sqlite db, amounts are stored
+// as their decimal text and currency code.
+type SQLiteStorage struct {
+	c *sql.DB
+}
+
+func NewSQLiteStorage(c *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{c}
+}
+
+func (s *SQLiteStorage) Migrate(ctx context.Context) error {
+	migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	return sqlite.Migrate(ctx, s.c, migrations)
+}
+
+func (s *SQLiteStorage) CreateIssuer(ctx context.Context, iss issuer.Issuer) error {
+	const query = `INSERT INTO issuers (id, name, rating, balance_number, balance_currency) VALUES (?, ?, ?, ?, ?)`
+
+	if _, err := s.c.ExecContext(ctx, query, iss.ID, iss.FullName, iss.Rating, iss.Balance.Number(), iss.Balance.CurrencyCode()); err != nil {
+		return fmt.Errorf("could not save issuer in db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrieveIssuer(ctx context.Context, id string) (issuer.Issuer, error) {
+	const query = `SELECT i.name, i.rating, (i.balance_number || ' ' || i.balance_currency) FROM issuers i WHERE i.id = ?`
+
+	iss := issuer.Issuer{ID: id}
+	err := s.c.QueryRowContext(ctx, query, id).Scan(&iss.FullName, &iss.Rating, sqlite.Amount{A: &iss.Balance})
+	if errors.Is(err, sql.ErrNoRows) {
+		return iss, fmt.Errorf("could not retrieve issuer: %w", issuer.ErrNotFound)
+	}
+	if err != nil {
+		return iss, fmt.Errorf("could not retrieve issuer: %w", err)
+	}
+
+	return iss, nil
+}
+
+func (s *SQLiteStorage) RetrieveIssuers(ctx context.Context, ids []string) ([]issuer.Issuer, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`SELECT i.id, i.name, i.rating, (i.balance_number || ' ' || i.balance_currency) FROM issuers i WHERE i.id IN (%s)`,
+		strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "))
+
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := s.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve issuers: %w", err)
+	}
+	defer rows.Close()
+
+	var issuers []issuer.Issuer
+	for rows.Next() {
+		var iss issuer.Issuer
+		if err := rows.Scan(&iss.ID, &iss.FullName, &iss.Rating, sqlite.Amount{A: &iss.Balance}); err != nil {
+			return nil, fmt.Errorf("could not scan issuers: %w", err)
+		}
+
+		issuers = append(issuers, iss)
+	}
+
+	return issuers, rows.Err()
+}
+
+func (s *SQLiteStorage) UpdateBalance(ctx context.Context, id string, balance currency.Amount) error {
+	return updateSQLiteBalance(ctx, s.c, id, balance)
+}
+
+func (s *SQLiteStorage) CreditTrade(ctx context.Context, id, invoiceID string, amount currency.Amount) error {
+	const creditQuery = `INSERT INTO trade_credits (invoice_id, issuer_id, amount_number, amount_currency, created_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT (invoice_id) DO NOTHING`
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	balance, err := retrieveSQLiteBalance(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, creditQuery, invoiceID, id, amount.Number(), amount.CurrencyCode(), sqlite.FormatTime(time.Now()))
+	if err != nil {
+		return fmt.Errorf("could not save trade credit in db: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not save trade credit in db: %w", err)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	if balance, err = creditBalance(balance, amount); err != nil {
+		return err
+	}
+
+	if err := updateSQLiteBalance(ctx, tx, id, balance); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) SavePayoutAccount(ctx context.Context, a issuer.PayoutAccount) error {
+	const (
+		unsetAutoQuery = `UPDATE payout_accounts SET auto = 0 WHERE issuer_id = ? AND auto = 1`
+		query          = `INSERT INTO payout_accounts (id, issuer_id, iban, holder, auto, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	)
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if a.Auto {
+		if _, err := tx.ExecContext(ctx, unsetAutoQuery, a.IssuerID); err != nil {
+			return fmt.Errorf("could not unset automatic payout account in db: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, query, a.ID, a.IssuerID, a.IBAN, a.Holder, a.Auto, sqlite.FormatTime(a.CreatedAt)); err != nil {
+		return fmt.Errorf("could not save payout account in db: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) RetrievePayoutAccount(ctx context.Context, id string) (issuer.PayoutAccount, error) {
+	const query = `SELECT a.issuer_id, a.iban, a.holder, a.auto, a.created_at FROM payout_accounts a WHERE a.id = ?`
+
+	a := issuer.PayoutAccount{ID: id}
+	err := s.c.QueryRowContext(ctx, query, id).Scan(&a.IssuerID, &a.IBAN, &a.Holder, &a.Auto, sqlite.Time{T: &a.CreatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, fmt.Errorf("could not retrieve payout account: %w", issuer.ErrNotFound)
+	}
+	if err != nil {
+		return a, fmt.Errorf("could not retrieve payout account: %w", err)
+	}
+
+	return a, nil
+}
+
+func (s *SQLiteStorage) RetrievePayoutAccountsByIssuerID(ctx context.Context, issuerID string) ([]issuer.PayoutAccount, error) {
+	const query = `SELECT a.id, a.iban, a.holder, a.auto, a.created_at
+		FROM payout_accounts a WHERE a.issuer_id = ? ORDER BY a.created_at`
+
+	rows, err := s.c.QueryContext(ctx, query, issuerID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve payout accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []issuer.PayoutAccount
+	for rows.Next() {
+		a := issuer.PayoutAccount{IssuerID: issuerID}
+		if err := rows.Scan(&a.ID, &a.IBAN, &a.Holder, &a.Auto, sqlite.Time{T: &a.CreatedAt}); err != nil {
+			return nil, fmt.Errorf("could not scan payout accounts: %w", err)
+		}
+
+		accounts = append(accounts, a)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (s *SQLiteStorage) SavePayout(ctx context.Context, p issuer.Payout) error {
+	const query = `INSERT INTO payouts (id, issuer_id, account_id, amount_number, amount_currency, status, created_at, failed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if _, err := s.c.ExecContext(ctx, query, p.ID, p.IssuerID, p.AccountID, p.Amount.Number(), p.Amount.CurrencyCode(), p.Status,
+		sqlite.FormatTime(p.CreatedAt), sqlite.FormatNullTime(p.FailedAt)); err != nil {
+		return fmt.Errorf("could not save payout in db: %w", err)
+	}
+
+	return nil
+}
+
+// SavePendingPayout saves a pending payout as long as it fits in the balance
+// left after the payouts that are still pending, reading both in the same
+// transaction.
+func (s *SQLiteStorage) SavePendingPayout(ctx context.Context, p issuer.Payout) error {
+	const query = `INSERT INTO payouts (id, issuer_id, account_id, amount_number, amount_currency, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	balance, err := retrieveSQLiteBalance(ctx, tx, p.IssuerID)
+	if err != nil {
+		return err
+	}
+
+	pending, err := retrieveSQLitePending(ctx, tx, p.IssuerID)
+	if err != nil {
+		return err
+	}
+
+	if err := reserveBalance(balance, append(pending, p.Amount)); err != nil {
+		return fmt.Errorf("could not save payout: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, query, p.ID, p.IssuerID, p.AccountID, p.Amount.Number(), p.Amount.CurrencyCode(), issuer.PENDING,
+		sqlite.FormatTime(p.CreatedAt)); err != nil {
+		return fmt.Errorf("could not save payout in db: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+const sqlitePayoutColumns = `p.id, p.issuer_id, p.account_id, (p.amount_number || ' ' || p.amount_currency), p.status, p.created_at, p.sent_at, p.failed_at`
+
+func sqlitePayoutDest(p *issuer.Payout) []any {
+	return []any{&p.ID, &p.IssuerID, &p.AccountID, sqlite.Amount{A: &p.Amount}, &p.Status, sqlite.Time{T: &p.CreatedAt},
+		sqlite.NullTime{T: &p.SentAt}, sqlite.NullTime{T: &p.FailedAt}}
+}
+
+func (s *SQLiteStorage) RetrievePayout(ctx context.Context, id string) (issuer.Payout, error) {
+	query := fmt.Sprintf(`SELECT %s FROM payouts p WHERE p.id = ?`, sqlitePayoutColumns)
+
+	p := issuer.Payout{ID: id}
+	err := s.c.QueryRowContext(ctx, query, id).Scan(sqlitePayoutDest(&p)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, fmt.Errorf("could not retrieve payout: %w", issuer.ErrNotFound)
+	}
+	if err != nil {
+		return p, fmt.Errorf("could not retrieve payout: %w", err)
+	}
+
+	return p, nil
+}
+
+func (s *SQLiteStorage) RetrievePayoutsByIssuerID(ctx context.Context, issuerID string) ([]issuer.Payout, error) {
+	query := fmt.Sprintf(`SELECT %s FROM payouts p WHERE p.issuer_id = ? ORDER BY p.created_at`, sqlitePayoutColumns)
+
+	rows, err := s.c.QueryContext(ctx, query, issuerID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve payouts: %w", err)
+	}
+	defer rows.Close()
+
+	var payouts []issuer.Payout
+	for rows.Next() {
+		var p issuer.Payout
+		if err := rows.Scan(sqlitePayoutDest(&p)...); err != nil {
+			return nil, fmt.Errorf("could not scan payouts: %w", err)
+		}
+
+		payouts = append(payouts, p)
+	}
+
+	return payouts, rows.Err()
+}
+
+func (s *SQLiteStorage) UpdatePayoutStatus(ctx context.Context, p issuer.Payout, from issuer.PayoutStatus) error {
+	return updateSQLitePayoutStatus(ctx, s.c, p, from)
+}
+
+func (s *SQLiteStorage) UpdatePayoutAndBalance(ctx context.Context, p issuer.Payout, from issuer.PayoutStatus, amount currency.Amount) error {
+	tx, err := s.c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateSQLitePayoutStatus(ctx, tx, p, from); err != nil {
+		return err
+	}
+
+	balance, err := retrieveSQLiteBalance(ctx, tx, p.IssuerID)
+	if err != nil {
+		return err
+	}
+
+	if balance, err = creditBalance(balance, amount); err != nil {
+		return err
+	}
+	if balance.IsNegative() {
+		return fmt.Errorf("could not update issuer balance: %w", issuer.ErrInsufficientFunds)
+	}
+
+	if err := updateSQLiteBalance(ctx, tx, p.IssuerID, balance); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+type sqlExecer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
+
+func retrieveSQLiteBalance(ctx context.Context, tx *sql.Tx, id string) (currency.Amount, error) {
+	const query = `SELECT (i.balance_number || ' ' || i.balance_currency) FROM issuers i WHERE i.id = ?`
+
+	var balance currency.Amount
+	err := tx.QueryRowContext(ctx, query, id).Scan(sqlite.Amount{A: &balance})
+	if errors.Is(err, sql.ErrNoRows) {
+		return balance, fmt.Errorf("could not retrieve issuer balance: %w", issuer.ErrNotFound)
+	}
+	if err != nil {
+		return balance, fmt.Errorf("could not retrieve issuer balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+func retrieveSQLitePending(ctx context.Context, tx *sql.Tx, issuerID string) ([]currency.Amount, error) {
+	const query = `SELECT (p.amount_number || ' ' || p.amount_currency) FROM payouts p WHERE p.issuer_id = ? AND p.status = ?`
+
+	rows, err := tx.QueryContext(ctx, query, issuerID, issuer.PENDING)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve pending payouts: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []currency.Amount
+	for rows.Next() {
+		var amount currency.Amount
+		if err := rows.Scan(sqlite.Amount{A: &amount}); err != nil {
+			return nil, fmt.Errorf("could not scan pending payouts: %w", err)
+		}
+
+		pending = append(pending, amount)
+	}
+
+	return pending, rows.Err()
+}
+
+func updateSQLiteBalance(ctx context.Context, c sqlExecer, id string, balance currency.Amount) error {
+	const query = `UPDATE issuers SET balance_number = ?, balance_currency = ? WHERE id = ?`
+
+	res, err := c.ExecContext(ctx, query, balance.Number(), balance.CurrencyCode(), id)
+	if err != nil {
+		return fmt.Errorf("could not replace current issuer balance in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not replace current issuer balance in db: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("could not replace current issuer balance: %w", issuer.ErrNotFound)
+	}
+
+	return nil
+}
+
+func updateSQLitePayoutStatus(ctx context.Context, c sqlExecer, p issuer.Payout, from issuer.PayoutStatus) error {
+	const query = `UPDATE payouts SET status = ?3, sent_at = ?4, failed_at = ?5 WHERE id = ?1 AND status = ?2`
+
+	res, err := c.ExecContext(ctx, query, p.ID, from, p.Status, sqlite.FormatNullTime(p.SentAt), sqlite.FormatNullTime(p.FailedAt))
+	if err != nil {
+		return fmt.Errorf("could not update payout status in db: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update payout status in db: %w", err)
+	}
+	if n != 1 {
+		return fmt.Errorf("%w: payout is no longer %s", issuer.ErrInvalidTransition, from)
+	}
+
+	return nil
+}
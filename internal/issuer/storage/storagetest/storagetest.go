@@ -0,0 +1,352 @@
+// Package storagetest holds the behaviour every issuer.Storage must have, so
+// all the backends are tested alike.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/issuer"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Run checks an issuer.Storage, newStorage must return an empty storage on
+// every call.
+func Run(t *testing.T, newStorage func(*testing.T) issuer.Storage) {
+	Convey("issuer.Storage", t, func() {
+		ctx := context.Background()
+		st := newStorage(t)
+
+		created := time.Now().UTC().Truncate(time.Millisecond)
+		So(st.CreateIssuer(ctx, issuer.Issuer{ID: id(1), FullName: "ACME", Rating: "A", Balance: amount("1250.75")}), ShouldBeNil)
+		So(st.CreateIssuer(ctx, issuer.Issuer{ID: id(2), FullName: "Globex", Rating: "C", Balance: amount("0")}), ShouldBeNil)
+
+		Convey("when an issuer is retrieved", func() {
+			iss, err := st.RetrieveIssuer(ctx, id(1))
+			So(err, ShouldBeNil)
+
+			Convey("return it as it was saved", func() {
+				So(iss.FullName, ShouldEqual, "ACME")
+				So(iss.Rating, ShouldEqual, "A")
+				So(iss.Balance.Equal(amount("1250.75")), ShouldBeTrue)
+			})
+		})
+
+		Convey("when nothing was saved under an id", func() {
+			_, errIssuer := st.RetrieveIssuer(ctx, id(99))
+			_, errAccount := st.RetrievePayoutAccount(ctx, id(99))
+			_, errPayout := st.RetrievePayout(ctx, id(99))
+
+			Convey("return not found", func() {
+				So(errors.Is(errIssuer, issuer.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errAccount, issuer.ErrNotFound), ShouldBeTrue)
+				So(errors.Is(errPayout, issuer.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when issuers are retrieved by ids", func() {
+			issuers, err := st.RetrieveIssuers(ctx, []string{id(2), id(1), id(99)})
+			So(err, ShouldBeNil)
+
+			Convey("return only the ones that exist", func() {
+				var ids []string
+				for _, iss := range issuers {
+					ids = append(ids, iss.ID)
+				}
+				sort.Strings(ids)
+				So(ids, ShouldResemble, []string{id(1), id(2)})
+			})
+		})
+
+		Convey("when the balance is updated", func() {
+			So(st.UpdateBalance(ctx, id(2), amount("99.99")), ShouldBeNil)
+
+			Convey("replace it", func() {
+				iss, err := st.RetrieveIssuer(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(iss.Balance.Equal(amount("99.99")), ShouldBeTrue)
+			})
+
+			Convey("return not found for a missing issuer", func() {
+				err := st.UpdateBalance(ctx, id(99), amount("99.99"))
+				So(errors.Is(err, issuer.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when trades are credited", func() {
+			So(st.CreditTrade(ctx, id(1), id(31), amount("100.25")), ShouldBeNil)
+			So(st.CreditTrade(ctx, id(2), id(32), amount("50")), ShouldBeNil)
+
+			Convey("add them to the balance", func() {
+				iss, err := st.RetrieveIssuer(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(iss.Balance.Equal(amount("1351")), ShouldBeTrue)
+
+				iss, err = st.RetrieveIssuer(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(iss.Balance.Equal(amount("50")), ShouldBeTrue)
+			})
+
+			Convey("credit each invoice once", func() {
+				So(st.CreditTrade(ctx, id(1), id(31), amount("100.25")), ShouldBeNil)
+
+				iss, err := st.RetrieveIssuer(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(iss.Balance.Equal(amount("1351")), ShouldBeTrue)
+			})
+
+			Convey("credit each invoice once when credited concurrently", func() {
+				credit := amount("10")
+				errs := make([]error, 8)
+				var wg sync.WaitGroup
+				for i := range errs {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						errs[i] = st.CreditTrade(ctx, id(2), id(33), credit)
+					}(i)
+				}
+				wg.Wait()
+
+				for _, err := range errs {
+					So(err, ShouldBeNil)
+				}
+				iss, err := st.RetrieveIssuer(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(iss.Balance.Equal(amount("60")), ShouldBeTrue)
+			})
+
+			Convey("reject a missing issuer", func() {
+				err := st.CreditTrade(ctx, id(99), id(34), amount("1"))
+				So(errors.Is(err, issuer.ErrNotFound), ShouldBeTrue)
+			})
+		})
+
+		Convey("when payout accounts are saved", func() {
+			So(st.SavePayoutAccount(ctx, issuer.PayoutAccount{ID: id(11), IssuerID: id(1), IBAN: "ES9121000418450200051332", Holder: "ACME",
+				Auto: true, CreatedAt: created}), ShouldBeNil)
+			So(st.SavePayoutAccount(ctx, issuer.PayoutAccount{ID: id(12), IssuerID: id(1), IBAN: "DE89370400440532013000", Holder: "ACME GmbH",
+				CreatedAt: created.Add(time.Second)}), ShouldBeNil)
+
+			Convey("return them in creation order", func() {
+				accounts, err := st.RetrievePayoutAccountsByIssuerID(ctx, id(1))
+				So(err, ShouldBeNil)
+				So(accounts, ShouldHaveLength, 2)
+				So(accounts[0].ID, ShouldEqual, id(11))
+				So(accounts[0].IBAN, ShouldEqual, "ES9121000418450200051332")
+				So(accounts[0].CreatedAt.Equal(created), ShouldBeTrue)
+				So(accounts[1].ID, ShouldEqual, id(12))
+
+				accounts, err = st.RetrievePayoutAccountsByIssuerID(ctx, id(2))
+				So(err, ShouldBeNil)
+				So(accounts, ShouldBeEmpty)
+			})
+
+			Convey("keep a single automatic account per issuer", func() {
+				So(st.SavePayoutAccount(ctx, issuer.PayoutAccount{ID: id(13), IssuerID: id(1), IBAN: "FR1420041010050500013M02606", Holder: "ACME SA",
+					Auto: true, CreatedAt: created.Add(2 * time.Second)}), ShouldBeNil)
+
+				old, err := st.RetrievePayoutAccount(ctx, id(11))
+				So(err, ShouldBeNil)
+				So(old.Auto, ShouldBeFalse)
+
+				auto, err := st.RetrievePayoutAccount(ctx, id(13))
+				So(err, ShouldBeNil)
+				So(auto.Auto, ShouldBeTrue)
+			})
+
+			Convey("reject an account of a missing issuer", func() {
+				err := st.SavePayoutAccount(ctx, issuer.PayoutAccount{ID: id(14), IssuerID: id(99), IBAN: "ES9121000418450200051332", Holder: "Nobody", CreatedAt: created})
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("when payouts are saved", func() {
+				So(st.SavePendingPayout(ctx, issuer.Payout{ID: id(21), IssuerID: id(1), AccountID: id(11), Amount: amount("250.5"),
+					Status: issuer.PENDING, CreatedAt: created}), ShouldBeNil)
+				So(st.SavePendingPayout(ctx, issuer.Payout{ID: id(22), IssuerID: id(1), AccountID: id(12), Amount: amount("100"),
+					Status: issuer.PENDING, CreatedAt: created.Add(time.Second)}), ShouldBeNil)
+
+				Convey("return them as they were saved in creation order", func() {
+					p, err := st.RetrievePayout(ctx, id(21))
+					So(err, ShouldBeNil)
+					So(p.IssuerID, ShouldEqual, id(1))
+					So(p.AccountID, ShouldEqual, id(11))
+					So(p.Amount.Equal(amount("250.5")), ShouldBeTrue)
+					So(p.Status, ShouldEqual, issuer.PENDING)
+					So(p.SentAt, ShouldBeNil)
+					So(p.FailedAt, ShouldBeNil)
+
+					payouts, err := st.RetrievePayoutsByIssuerID(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(payouts, ShouldHaveLength, 2)
+					So(payouts[0].ID, ShouldEqual, id(21))
+					So(payouts[1].ID, ShouldEqual, id(22))
+				})
+
+				Convey("save a payout that already failed", func() {
+					failed := created.Add(time.Minute)
+					So(st.SavePayout(ctx, issuer.Payout{ID: id(23), IssuerID: id(1), AccountID: id(11), Amount: amount("10"),
+						Status: issuer.FAILED, CreatedAt: created, FailedAt: &failed}), ShouldBeNil)
+
+					p, err := st.RetrievePayout(ctx, id(23))
+					So(err, ShouldBeNil)
+					So(p.Status, ShouldEqual, issuer.FAILED)
+					So(p.FailedAt, ShouldNotBeNil)
+					So(p.FailedAt.Equal(failed), ShouldBeTrue)
+				})
+
+				Convey("move them out of the expected status only", func() {
+					sent := created.Add(time.Minute)
+					So(st.UpdatePayoutStatus(ctx, issuer.Payout{ID: id(21), Status: issuer.SENT, SentAt: &sent}, issuer.PENDING), ShouldBeNil)
+
+					p, err := st.RetrievePayout(ctx, id(21))
+					So(err, ShouldBeNil)
+					So(p.Status, ShouldEqual, issuer.SENT)
+					So(p.SentAt, ShouldNotBeNil)
+					So(p.SentAt.Equal(sent), ShouldBeTrue)
+
+					err = st.UpdatePayoutStatus(ctx, issuer.Payout{ID: id(21), Status: issuer.FAILED, FailedAt: &sent}, issuer.PENDING)
+					So(errors.Is(err, issuer.ErrInvalidTransition), ShouldBeTrue)
+				})
+
+				Convey("keep pending payouts within the balance", func() {
+					err := st.SavePendingPayout(ctx, issuer.Payout{ID: id(24), IssuerID: id(1), AccountID: id(11), Amount: amount("900.26"),
+						Status: issuer.PENDING, CreatedAt: created})
+					So(errors.Is(err, issuer.ErrInsufficientFunds), ShouldBeTrue)
+
+					So(st.SavePendingPayout(ctx, issuer.Payout{ID: id(24), IssuerID: id(1), AccountID: id(11), Amount: amount("900.25"),
+						Status: issuer.PENDING, CreatedAt: created}), ShouldBeNil)
+				})
+
+				Convey("reject a pending payout of a missing issuer", func() {
+					err := st.SavePendingPayout(ctx, issuer.Payout{ID: id(24), IssuerID: id(99), AccountID: id(11), Amount: amount("1"),
+						Status: issuer.PENDING, CreatedAt: created})
+					So(errors.Is(err, issuer.ErrNotFound), ShouldBeTrue)
+				})
+
+				Convey("reserve the balance once when requested concurrently", func() {
+					const requests = 8
+
+					request := amount("200")
+					errs := make([]error, requests)
+					var wg sync.WaitGroup
+					for i := 0; i < requests; i++ {
+						wg.Add(1)
+						go func(i int) {
+							defer wg.Done()
+							errs[i] = st.SavePendingPayout(ctx, issuer.Payout{ID: id(40 + i), IssuerID: id(1), AccountID: id(11), Amount: request,
+								Status: issuer.PENDING, CreatedAt: time.Now()})
+						}(i)
+					}
+					wg.Wait()
+
+					var saved int
+					for _, err := range errs {
+						if err == nil {
+							saved++
+							continue
+						}
+						So(errors.Is(err, issuer.ErrInsufficientFunds), ShouldBeTrue)
+					}
+					So(saved, ShouldEqual, 4)
+				})
+
+				Convey("update the balance with the status", func() {
+					sent := created.Add(time.Minute)
+					So(st.UpdatePayoutAndBalance(ctx, issuer.Payout{ID: id(21), IssuerID: id(1), Status: issuer.SENT, SentAt: &sent},
+						issuer.PENDING, amount("-250.5")), ShouldBeNil)
+
+					iss, err := st.RetrieveIssuer(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(iss.Balance.Equal(amount("1000.25")), ShouldBeTrue)
+
+					Convey("but not when the status already moved", func() {
+						err := st.UpdatePayoutAndBalance(ctx, issuer.Payout{ID: id(21), IssuerID: id(1), Status: issuer.SENT, SentAt: &sent},
+							issuer.PENDING, amount("-250.5"))
+						So(errors.Is(err, issuer.ErrInvalidTransition), ShouldBeTrue)
+
+						iss, err := st.RetrieveIssuer(ctx, id(1))
+						So(err, ShouldBeNil)
+						So(iss.Balance.Equal(amount("1000.25")), ShouldBeTrue)
+					})
+
+					Convey("and give it back when it fails", func() {
+						failed := sent.Add(time.Minute)
+						So(st.UpdatePayoutAndBalance(ctx, issuer.Payout{ID: id(21), IssuerID: id(1), Status: issuer.FAILED, FailedAt: &failed},
+							issuer.SENT, amount("250.5")), ShouldBeNil)
+
+						iss, err := st.RetrieveIssuer(ctx, id(1))
+						So(err, ShouldBeNil)
+						So(iss.Balance.Equal(amount("1250.75")), ShouldBeTrue)
+					})
+				})
+
+				Convey("refuse a debit larger than the balance", func() {
+					So(st.UpdateBalance(ctx, id(1), amount("50")), ShouldBeNil)
+
+					sent := created.Add(time.Minute)
+					err := st.UpdatePayoutAndBalance(ctx, issuer.Payout{ID: id(21), IssuerID: id(1), Status: issuer.SENT, SentAt: &sent},
+						issuer.PENDING, amount("-250.5"))
+					So(errors.Is(err, issuer.ErrInsufficientFunds), ShouldBeTrue)
+
+					p, err := st.RetrievePayout(ctx, id(21))
+					So(err, ShouldBeNil)
+					So(p.Status, ShouldEqual, issuer.PENDING)
+
+					iss, err := st.RetrieveIssuer(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(iss.Balance.Equal(amount("50")), ShouldBeTrue)
+				})
+
+				Convey("apply a single update when sent concurrently", func() {
+					const senders = 8
+
+					debit := amount("-100")
+					errs := make([]error, senders)
+					var wg sync.WaitGroup
+					for i := 0; i < senders; i++ {
+						wg.Add(1)
+						go func(i int) {
+							defer wg.Done()
+							sent := time.Now()
+							errs[i] = st.UpdatePayoutAndBalance(ctx, issuer.Payout{ID: id(22), IssuerID: id(1), Status: issuer.SENT, SentAt: &sent},
+								issuer.PENDING, debit)
+						}(i)
+					}
+					wg.Wait()
+
+					var succeeded int
+					for _, err := range errs {
+						if err == nil {
+							succeeded++
+							continue
+						}
+						So(errors.Is(err, issuer.ErrInvalidTransition), ShouldBeTrue)
+					}
+					So(succeeded, ShouldEqual, 1)
+
+					iss, err := st.RetrieveIssuer(ctx, id(1))
+					So(err, ShouldBeNil)
+					So(iss.Balance.Equal(amount("1150.75")), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+// id builds the ids like uuids, as postgres stores them in fixed width
+func id(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+func amount(n string) currency.Amount {
+	a, err := currency.NewAmount(n, "EUR")
+	So(err, ShouldBeNil)
+	return a
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+type APIKeyRequest struct {
+	Name string `json:"name" example:"accounting export"`
+}
+
+type APIKeyResponse struct {
+	ID         string   `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Name       string   `json:"name" example:"accounting export"`
+	Key        string   `json:"key,omitempty" example:"ibk_q1w2e3r4t5y6u7i8o9p0"`
+	IssuerID   string   `json:"issuerId,omitempty" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	InvestorID string   `json:"investorId,omitempty" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Roles      []string `json:"roles" example:"issuer"`
+	CreatedAt  string   `json:"createdAt" example:"2023-07-20T10:00:00Z"`
+	RevokedAt  string   `json:"revokedAt,omitempty" example:"2023-07-21T10:00:00Z"`
+}
+
+type AuthService interface {
+	Authenticate(context.Context, string) (auth.Principal, error)
+	CreateAPIKey(context.Context, auth.Principal, string) (string, auth.APIKey, error)
+	ListAPIKeys(context.Context, auth.Principal) ([]auth.APIKey, error)
+	RevokeAPIKey(context.Context, auth.Principal, string) error
+}
+
+// WithAuth requires every request to carry a JWT or an API key
+func (s *Server) WithAuth(authService AuthService) *Server {
+	s.authService = authService
+
+	return s
+}
+
+func (s *Server) authRoutes(g *echo.Group) {
+	g.POST("/api-keys", s.CreateAPIKey)
+	g.GET("/api-keys", s.ListAPIKeys)
+	g.DELETE("/api-keys/:id", s.RevokeAPIKey)
+}
+
+// authenticate puts the principal of the request credentials in the request
+// context. The docs and signed document urls stay public.
+func (s *Server) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if strings.HasPrefix(c.Path(), "/swagger") ||
+			(c.Path() == "/invoice/:id/document" && c.QueryParam("signature") != "") {
+			return next(c)
+		}
+
+		ctx := c.Request().Context()
+		p, err := s.authService.Authenticate(ctx, credential(c.Request()))
+		if err != nil {
+			return errHandler(err, c)
+		}
+
+		c.SetRequest(c.Request().WithContext(auth.WithPrincipal(ctx, p)))
+
+		return next(c)
+	}
+}
+
+// credential reads a bearer token, which may be an API key, or falls back to
+// the API key header
+func credential(r *http.Request) string {
+	if h := r.Header.Get(echo.HeaderAuthorization); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+
+	return r.Header.Get(apiKeyHeader)
+}
+
+func principal(c echo.Context) (auth.Principal, error) {
+	p, ok := auth.FromContext(c.Request().Context())
+	if !ok {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+
+	return p, nil
+}
+
+// CreateAPIKey creates an API key
+// @Summary      New API key
+// @Description  Create an API key acting as the authenticated user, with the same roles. The key is only returned once. API keys cannot create other keys.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param request body APIKeyRequest true "API key"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      201  {object}  APIKeyResponse
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/api-keys [post]
+func (s *Server) CreateAPIKey(c echo.Context) error {
+	p, err := principal(c)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	var req APIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+	if req.Name == "" {
+		return errBadRequest(errors.New("name cannot be empty"), c)
+	}
+
+	key, k, err := s.authService.CreateAPIKey(c.Request().Context(), p, req.Name)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	res := apiKeyResponse(k)
+	res.Key = key
+
+	return c.JSON(http.StatusCreated, res)
+}
+
+// ListAPIKeys lists the API keys of the user
+// @Summary      List API keys
+// @Description  List the API keys of the authenticated user, without the keys themselves
+// @Tags         auth
+// @Produce      json
+// @Security     Bearer
+// @Success      200  {array}   APIKeyResponse
+// @Failure      401  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/api-keys [get]
+func (s *Server) ListAPIKeys(c echo.Context) error {
+	p, err := principal(c)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	keys, err := s.authService.ListAPIKeys(c.Request().Context(), p)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	res := make([]APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, apiKeyResponse(k))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// RevokeAPIKey revokes an API key
+// @Summary      Revoke API key
+// @Description  Revoke an API key of the authenticated user
+// @Tags         auth
+// @Security     Bearer
+// @Param id path string true "API key id"
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /auth/api-keys/:id [delete]
+func (s *Server) RevokeAPIKey(c echo.Context) error {
+	p, err := principal(c)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	if err := s.authService.RevokeAPIKey(c.Request().Context(), p, c.Param("id")); err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func apiKeyResponse(k auth.APIKey) APIKeyResponse {
+	res := APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		IssuerID:   k.IssuerID,
+		InvestorID: k.InvestorID,
+		Roles:      make([]string, 0, len(k.Roles)),
+		CreatedAt:  k.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	for _, r := range k.Roles {
+		res.Roles = append(res.Roles, string(r))
+	}
+	if k.RevokedAt != nil {
+		res.RevokedAt = k.RevokedAt.UTC().Format(time.RFC3339)
+	}
+
+	return res
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockAuthService struct {
+	credentials map[string]auth.Principal
+}
+
+func (m *mockAuthService) Authenticate(_ context.Context, credential string) (auth.Principal, error) {
+	p, ok := m.credentials[credential]
+	if !ok {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+
+	return p, nil
+}
+
+func (m *mockAuthService) CreateAPIKey(context.Context, auth.Principal, string) (string, auth.APIKey, error) {
+	panic("implement me")
+}
+
+func (m *mockAuthService) ListAPIKeys(context.Context, auth.Principal) ([]auth.APIKey, error) {
+	panic("implement me")
+}
+
+func (m *mockAuthService) RevokeAPIKey(context.Context, auth.Principal, string) error {
+	panic("implement me")
+}
+
+func TestAuthenticate(t *testing.T) {
+	Convey("authenticate", t, func() {
+		user := auth.Principal{Subject: "user-1", IssuerID: "issuer-1", Method: auth.JWT}
+		srv := New(0, nil, nil, nil, nil, nil, nil).WithAuth(&mockAuthService{
+			credentials: map[string]auth.Principal{"token": user, "ibk_key": user},
+		})
+
+		var got *auth.Principal
+		handler := srv.authenticate(func(c echo.Context) error {
+			p, err := principal(c)
+			if err == nil {
+				got = &p
+			}
+			return c.NoContent(http.StatusOK)
+		})
+		serve := func(path, route string, header http.Header) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header = header
+			c := srv.e.NewContext(req, rec)
+			c.SetPath(route)
+			So(handler(c), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when the request has no credentials", func() {
+			rec := serve("/invoice", "/invoice", http.Header{})
+
+			Convey("return unauthorized", func() {
+				So(rec.Code, ShouldEqual, http.StatusUnauthorized)
+				So(rec.Header().Get(echo.HeaderWWWAuthenticate), ShouldEqual, "Bearer")
+				So(got, ShouldBeNil)
+			})
+		})
+
+		Convey("when the credentials are invalid", func() {
+			rec := serve("/invoice", "/invoice", http.Header{"Authorization": {"Bearer wrong"}})
+
+			Convey("return unauthorized", func() {
+				So(rec.Code, ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
+		Convey("when the request has valid credentials", func() {
+			for name, header := range map[string]http.Header{
+				"bearer token":   {"Authorization": {"Bearer token"}},
+				"bearer api key": {"Authorization": {"bearer ibk_key"}},
+				"api key header": {"X-Api-Key": {"ibk_key"}},
+			} {
+				Convey("put the principal of the "+name+" in the context", func() {
+					rec := serve("/invoice", "/invoice", header)
+					So(rec.Code, ShouldEqual, http.StatusOK)
+					So(*got, ShouldResemble, user)
+				})
+			}
+		})
+
+		Convey("when the route is public", func() {
+			for path, route := range map[string]string{
+				"/swagger/index.html":               "/swagger/*",
+				"/invoice/id/document?signature=q1": "/invoice/:id/document",
+			} {
+				Convey("skip authentication for "+path, func() {
+					rec := serve(path, route, http.Header{})
+					So(rec.Code, ShouldEqual, http.StatusOK)
+				})
+			}
+		})
+	})
+}
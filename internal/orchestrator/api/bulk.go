@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/orchestrator/bulk"
+)
+
+const (
+	// maxArchiveSize bounds the zip archive kept in memory until the job runs
+	maxArchiveSize = 100 << 20
+	// maxManifestSize bounds the csv manifest, read in memory as well
+	maxManifestSize = 10 << 20
+)
+
+type BulkJobResponse struct {
+	ID         string               `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Status     string               `json:"status" example:"finished"`
+	Succeeded  int                  `json:"succeeded" example:"98"`
+	Failed     int                  `json:"failed" example:"2"`
+	Results    []BulkResultResponse `json:"results"`
+	CreatedAt  string               `json:"createdAt" example:"2023-07-20T10:00:00Z"`
+	FinishedAt string               `json:"finishedAt,omitempty" example:"2023-07-20T10:01:00Z"`
+}
+
+type BulkResultResponse struct {
+	Line      int    `json:"line" example:"2"`
+	InvoiceID string `json:"invoiceId,omitempty" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Error     string `json:"error,omitempty" example:"file \"invoice-2.pdf\" not found in archive"`
+}
+
+type Uploader interface {
+	Submit(string, io.Reader, []byte) (bulk.Job, error)
+	GetJob(string) (bulk.Job, bool)
+}
+
+// CreateBulkUpload uploads invoices in bulk
+// @Summary      Bulk invoice upload
+// @Description  Create invoices from a csv manifest with columns issuer_id, price, currency, face_value (optional), due_date and file, plus a zip archive with the files. Every row must belong to the authenticated issuer. Rows are processed in the background.
+// @Tags         invoice
+// @Accept       mpfd
+// @Produce      json
+// @Security     Bearer
+// @Param manifest formData file true "CSV manifest"
+// @Param archive formData file true "ZIP archive with the invoice files"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      202  {object}  BulkJobResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      413  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Failure      503  {object}  Problem
+// @Router       /invoice/bulk [post]
+func (s *Server) CreateBulkUpload(c echo.Context) error {
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxArchiveSize+maxManifestSize+formOverhead)
+
+	manifestFile, err := c.FormFile("manifest")
+	if err != nil {
+		return errBulkForm(fmt.Errorf("could not read manifest file: %w", err), c)
+	}
+	if manifestFile.Size > maxManifestSize {
+		return errBadRequest(fmt.Errorf("manifest cannot be bigger than %d bytes", maxManifestSize), c)
+	}
+	archiveFile, err := c.FormFile("archive")
+	if err != nil {
+		return errBulkForm(fmt.Errorf("could not read archive file: %w", err), c)
+	}
+	if archiveFile.Size > maxArchiveSize {
+		return errBadRequest(fmt.Errorf("archive cannot be bigger than %d bytes", maxArchiveSize), c)
+	}
+
+	manifest, err := manifestFile.Open()
+	if err != nil {
+		return errBadRequest(fmt.Errorf("could not open manifest file: %w", err), c)
+	}
+	defer manifest.Close()
+
+	archive, err := archiveFile.Open()
+	if err != nil {
+		return errBadRequest(fmt.Errorf("could not open archive file: %w", err), c)
+	}
+	defer archive.Close()
+
+	data, err := io.ReadAll(archive)
+	if err != nil {
+		return errBadRequest(fmt.Errorf("could not read archive file: %w", err), c)
+	}
+
+	manifestData, err := io.ReadAll(manifest)
+	if err != nil {
+		return errBadRequest(fmt.Errorf("could not read manifest file: %w", err), c)
+	}
+
+	rows, err := bulk.ParseManifest(bytes.NewReader(manifestData))
+	if err != nil {
+		return errBadRequest(err, c)
+	}
+	for _, row := range rows {
+		if err := s.authorize(c, asIssuer(row.IssuerID)); err != nil {
+			return errHandler(fmt.Errorf("line %d: %w", row.Line, err), c)
+		}
+	}
+
+	var owner string
+	if p, ok := auth.FromContext(c.Request().Context()); ok {
+		owner = p.Subject
+	}
+
+	job, err := s.uploader.Submit(owner, bytes.NewReader(manifestData), data)
+	switch {
+	case errors.Is(err, bulk.ErrQueueFull):
+		return errHandler(err, c)
+	case err != nil:
+		return errBadRequest(err, c)
+	}
+
+	return c.JSON(http.StatusAccepted, bulkJobResponse(job))
+}
+
+// errBulkForm answers a bulk upload form that could not be read, as too large
+// if it went over the body limit
+func errBulkForm(err error, c echo.Context) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return errHandler(fmt.Errorf("%w: maximum size is %d bytes", ErrTooLarge, maxErr.Limit), c)
+	}
+
+	return errBadRequest(err, c)
+}
+
+// RetrieveBulkUpload retrieves a bulk upload job
+// @Summary      Get bulk upload
+// @Description  Retrieve the status of a bulk upload and the result of every manifest row
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Job id"
+// @Success      200  {object}  BulkJobResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Router       /invoice/bulk/:id [get]
+func (s *Server) RetrieveBulkUpload(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	job, ok := s.uploader.GetJob(id)
+	if !ok {
+		return errHandler(fmt.Errorf("%w: bulk upload not found", ErrNotFound), c)
+	}
+	if err := s.authorize(c, anyOf(audit, submittedBy(job.Owner))); err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusOK, bulkJobResponse(job))
+}
+
+func bulkJobResponse(job bulk.Job) BulkJobResponse {
+	res := BulkJobResponse{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Succeeded: job.Succeeded(),
+		Failed:    job.Failed(),
+		Results:   make([]BulkResultResponse, 0, len(job.Results)),
+		CreatedAt: job.CreatedAt.Format(time.RFC3339),
+	}
+
+	if job.FinishedAt != nil {
+		res.FinishedAt = job.FinishedAt.Format(time.RFC3339)
+	}
+
+	for _, r := range job.Results {
+		res.Results = append(res.Results, BulkResultResponse{
+			Line:      r.Line,
+			InvoiceID: r.InvoiceID,
+			Error:     r.Err,
+		})
+	}
+
+	return res
+}
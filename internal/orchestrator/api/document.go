@@ -0,0 +1,237 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+type DocumentURLResponse struct {
+	URL       string `json:"url" example:"/invoice/343abd7a-874c-4bb7-ba7b-81e9c71cf1b0/document?expires=1689847200&signature=q1w2e3"`
+	ExpiresAt string `json:"expiresAt" example:"2023-07-20T10:00:00Z"`
+}
+
+type IntegrityResponse struct {
+	InvoiceID string `json:"invoiceId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Expected  string `json:"expected,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Actual    string `json:"actual,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Status    string `json:"status" example:"intact"`
+}
+
+// urlSigner signs short-lived document urls so they can be shared without
+// the requester identity.
+type urlSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func (us *urlSigner) sign(invoiceID string, expires int64) string {
+	mac := hmac.New(sha256.New, us.secret)
+	mac.Write([]byte(invoiceID + "|" + strconv.FormatInt(expires, 10)))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (us *urlSigner) valid(invoiceID, expires, signature string, now time.Time) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > exp {
+		return false
+	}
+
+	return hmac.Equal([]byte(us.sign(invoiceID, exp)), []byte(signature))
+}
+
+// WithSignedURLs enables short-lived signed urls for invoice documents
+func (s *Server) WithSignedURLs(secret []byte, ttl time.Duration) *Server {
+	s.signer = &urlSigner{secret: secret, ttl: ttl}
+
+	return s
+}
+
+// RetrieveDocument downloads the file of an invoice
+// @Summary      Get invoice document
+// @Description  Download the invoice file, supporting range requests. Allowed for the owning issuer, investors, admins and auditors, or with a valid signed url.
+// @Tags         invoice
+// @Produce      application/pdf
+// @Produce      application/xml
+// @Produce      image/png
+// @Produce      image/jpeg
+// @Security     Bearer
+// @Param id path string true "Invoice id"
+// @Param expires query string false "Signed url expiration"
+// @Param signature query string false "Signed url signature"
+// @Param Range header string false "Byte range"
+// @Success      200  {file}    file
+// @Success      206  {file}    file
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/:id/document [get]
+func (s *Server) RetrieveDocument(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	if sig := c.QueryParam("signature"); sig == "" || s.signer == nil || !s.signer.valid(id, c.QueryParam("expires"), sig, time.Now()) {
+		if err := s.authorizeInvoice(c, id, documentReaders); err != nil {
+			return errHandler(err, c)
+		}
+	}
+
+	inv, doc, err := s.invoiceService.GetDocument(c.Request().Context(), id)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errHandler(fmt.Errorf("%w: invoice document not found", ErrNotFound), c)
+	}
+	if err != nil {
+		return errHandler(err, c)
+	}
+	defer func() {
+		if err := doc.Close(); err != nil {
+			s.e.Logger.Errorf("could not close invoice document: %v", err)
+		}
+	}()
+
+	// Invoices uploaded before the content type was recorded are sniffed
+	contentType := inv.ContentType
+	if contentType == "" {
+		if contentType, err = detectContentType(doc); err != nil {
+			return errHandler(err, c)
+		}
+	}
+
+	filename := inv.ID
+	if ext, ok := documentExtensions[contentType]; ok {
+		filename += ext
+	} else if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		filename += exts[0]
+	}
+
+	h := c.Response().Header()
+	h.Set(echo.HeaderContentType, contentType)
+	h.Set(echo.HeaderContentDisposition, mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	http.ServeContent(c.Response(), c.Request(), filename, doc.ModTime, doc)
+
+	return nil
+}
+
+// RetrieveDocumentURL creates a signed url for an invoice document
+// @Summary      Get signed document url
+// @Description  Create a short-lived url to download the invoice file without identifying the requester
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Invoice id"
+// @Success      200  {object}  DocumentURLResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/:id/document/url [get]
+func (s *Server) RetrieveDocumentURL(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	if s.signer == nil {
+		return errHandler(fmt.Errorf("%w: signed urls are disabled", ErrNotFound), c)
+	}
+
+	if err := s.authorizeInvoice(c, id, documentReaders); err != nil {
+		return errHandler(err, c)
+	}
+
+	expiresAt := time.Now().Add(s.signer.ttl).Truncate(time.Second)
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	q.Set("signature", s.signer.sign(id, expiresAt.Unix()))
+
+	return c.JSON(http.StatusOK, DocumentURLResponse{
+		URL:       fmt.Sprintf("/invoice/%s/document?%s", url.PathEscape(id), q.Encode()),
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// documentReaders are the issuer of the invoice and any investor, who needs
+// the document to decide on bidding
+func documentReaders(issuerID string) policy {
+	return anyOf(viewIssuer(issuerID), investors)
+}
+
+// detectContentType sniffs the document and rewinds it for serving
+// documentExtensions are the file extensions of the accepted uploads, not left
+// to the mime tables of the host
+var documentExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"application/xml": ".xml",
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+}
+
+func detectContentType(doc invoice.Document) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(doc, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("could not read invoice document: %w", err)
+	}
+
+	if _, err := doc.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("could not rewind invoice document: %w", err)
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// VerifyDocument re-verifies the stored file of an invoice
+// @Summary      Verify invoice document
+// @Description  Re-hash the stored invoice file and compare it with the hash recorded on upload
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Invoice id"
+// @Success      200  {object}  IntegrityResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/:id/integrity [get]
+func (s *Server) VerifyDocument(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	if err := s.authorizeInvoice(c, id, viewIssuer); err != nil {
+		return errHandler(err, c)
+	}
+
+	integrity, err := s.invoiceService.VerifyFile(c.Request().Context(), id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusOK, integrityResponse(integrity))
+}
+
+func integrityResponse(i invoice.Integrity) IntegrityResponse {
+	return IntegrityResponse{
+		InvoiceID: i.InvoiceID,
+		Expected:  i.Expected,
+		Actual:    i.Actual,
+		Status:    string(i.Status),
+	}
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func TestRetrieveDocument(t *testing.T) {
+	Convey("RetrieveDocument", t, func() {
+		content := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("x"), 100)...)
+		invSvc := &mockInvoiceService{
+			getInvoiceFunc: func(ctx context.Context, id string) (invoice.Invoice, error) {
+				return invoice.Invoice{ID: id, IssuerID: "issuer-1"}, nil
+			},
+			getDocumentFunc: func(ctx context.Context, id string) (invoice.Invoice, invoice.Document, error) {
+				return invoice.Invoice{ID: id, IssuerID: "issuer-1"}, invoice.Document{
+					ReadSeekCloser: nopSeekCloser{bytes.NewReader(content)},
+					Size:           int64(len(content)),
+					ModTime:        time.Now(),
+				}, nil
+			},
+		}
+		srv := New(0, invSvc, nil, nil, nil, nil, nil).WithSignedURLs([]byte("secret"), time.Minute).WithAuth(&mockAuthService{})
+		rec := httptest.NewRecorder()
+
+		get := func(target string, header http.Header, p *auth.Principal) {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			if p != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *p))
+			}
+			c := srv.e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("invoice")
+			So(srv.RetrieveDocument(c), ShouldBeNil)
+		}
+
+		Convey("when the requester is not identified", func() {
+			get("/invoice/invoice/document", nil, nil)
+
+			Convey("return unauthorized", func() {
+				So(rec.Code, ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
+		Convey("when the issuer does not own the invoice", func() {
+			p := principals["issuer-2"]
+			get("/invoice/invoice/document", nil, &p)
+
+			Convey("return forbidden", func() {
+				So(rec.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+
+		Convey("when the signed url expired", func() {
+			expires := time.Now().Add(-time.Minute).Unix()
+			q := url.Values{"expires": {strconv.FormatInt(expires, 10)}, "signature": {srv.signer.sign("invoice", expires)}}
+			get("/invoice/invoice/document?"+q.Encode(), nil, nil)
+
+			Convey("return unauthorized", func() {
+				So(rec.Code, ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
+		Convey("when the document does not exist", func() {
+			invSvc.getDocumentFunc = func(ctx context.Context, id string) (invoice.Invoice, invoice.Document, error) {
+				return invoice.Invoice{}, invoice.Document{}, os.ErrNotExist
+			}
+			p := principals["investor"]
+			get("/invoice/invoice/document", nil, &p)
+
+			Convey("return not found", func() {
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("when the owning issuer requests it", func() {
+			p := principals["issuer"]
+			get("/invoice/invoice/document", nil, &p)
+
+			Convey("return the document with its detected content type", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Header().Get("Content-Type"), ShouldEqual, "application/pdf")
+				So(rec.Header().Get("Content-Disposition"), ShouldEqual, `inline; filename=invoice.pdf`)
+				So(rec.Body.Bytes(), ShouldResemble, content)
+			})
+		})
+
+		Convey("when the content type was recorded on upload", func() {
+			content = []byte(`<?xml version="1.0"?><Invoice/>`)
+			invSvc.getDocumentFunc = func(ctx context.Context, id string) (invoice.Invoice, invoice.Document, error) {
+				return invoice.Invoice{ID: id, IssuerID: "issuer-1", ContentType: "application/xml"}, invoice.Document{
+					ReadSeekCloser: nopSeekCloser{bytes.NewReader(content)},
+					Size:           int64(len(content)),
+					ModTime:        time.Now(),
+				}, nil
+			}
+			p := principals["issuer"]
+			get("/invoice/invoice/document", nil, &p)
+
+			Convey("return the document with it", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(rec.Header().Get("Content-Type"), ShouldEqual, "application/xml")
+				So(rec.Header().Get("Content-Disposition"), ShouldEqual, `inline; filename=invoice.xml`)
+				So(rec.Body.Bytes(), ShouldResemble, content)
+			})
+		})
+
+		Convey("when a range is requested with a valid signed url", func() {
+			expires := time.Now().Add(time.Minute).Unix()
+			q := url.Values{"expires": {strconv.FormatInt(expires, 10)}, "signature": {srv.signer.sign("invoice", expires)}}
+			get("/invoice/invoice/document?"+q.Encode(), http.Header{"Range": {"bytes=0-3"}}, nil)
+
+			Convey("return the partial content", func() {
+				So(rec.Code, ShouldEqual, http.StatusPartialContent)
+				So(rec.Body.String(), ShouldEqual, "%PDF")
+			})
+		})
+	})
+}
+
+func TestRetrieveDocumentURL(t *testing.T) {
+	Convey("RetrieveDocumentURL", t, func() {
+		invSvc := &mockInvoiceService{
+			getInvoiceFunc: func(ctx context.Context, id string) (invoice.Invoice, error) {
+				return invoice.Invoice{ID: id, IssuerID: "issuer-1"}, nil
+			},
+		}
+		srv := New(0, invSvc, nil, nil, nil, nil, nil).WithAuth(&mockAuthService{})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/invoice/invoice/document/url", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principals["issuer"]))
+		c := srv.e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("invoice")
+
+		Convey("when signed urls are disabled", func() {
+			So(srv.RetrieveDocumentURL(c), ShouldBeNil)
+
+			Convey("return not found", func() {
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("when signed urls are enabled", func() {
+			srv.WithSignedURLs([]byte("secret"), time.Minute)
+			So(srv.RetrieveDocumentURL(c), ShouldBeNil)
+
+			Convey("return a url valid for the invoice", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+
+				var res DocumentURLResponse
+				So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+
+				u, err := url.Parse(res.URL)
+				So(err, ShouldBeNil)
+				So(u.Path, ShouldEqual, "/invoice/invoice/document")
+				So(srv.signer.valid("invoice", u.Query().Get("expires"), u.Query().Get("signature"), time.Now()), ShouldBeTrue)
+				So(srv.signer.valid("other", u.Query().Get("expires"), u.Query().Get("signature"), time.Now()), ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+)
+
+type EInvoiceResponse struct {
+	Format    string                 `json:"format" example:"ubl"`
+	Number    string                 `json:"number" example:"INV-2023-001"`
+	IssueDate string                 `json:"issueDate" example:"2023-11-01"`
+	DueDate   string                 `json:"dueDate,omitempty" example:"2023-12-31"`
+	Total     string                 `json:"total" example:"1 300,00 €"`
+	Seller    EInvoicePartyResponse  `json:"seller"`
+	Buyer     EInvoicePartyResponse  `json:"buyer"`
+	Lines     []EInvoiceLineResponse `json:"lines,omitempty"`
+}
+
+type EInvoicePartyResponse struct {
+	Name  string `json:"name" example:"ACME Ltd"`
+	TaxID string `json:"taxId,omitempty" example:"DE811907980"`
+}
+
+type EInvoiceLineResponse struct {
+	ID          string `json:"id" example:"1"`
+	Description string `json:"description" example:"Consulting services"`
+	Quantity    string `json:"quantity,omitempty" example:"10"`
+	Amount      string `json:"amount" example:"1 300,00 €"`
+}
+
+// RetrieveEInvoice returns the e-invoice read from an invoice file
+// @Summary      Get invoice e-invoice
+// @Description  Get the seller, buyer, lines and totals of the UBL, CII or Factur-X file of an invoice. Allowed for the owning issuer, investors, admins and auditors.
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Invoice id"
+// @Success      200  {object}  EInvoiceResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/:id/einvoice [get]
+func (s *Server) RetrieveEInvoice(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	if err := s.authorizeInvoice(c, id, documentReaders); err != nil {
+		return errHandler(err, c)
+	}
+
+	doc, err := s.invoiceService.GetEInvoice(c.Request().Context(), id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusOK, eInvoiceResponse(doc))
+}
+
+func eInvoiceResponse(doc einvoice.Document) *EInvoiceResponse {
+	res := &EInvoiceResponse{
+		Format:    string(doc.Format),
+		Number:    doc.Number,
+		IssueDate: doc.IssueDate.Format(dateLayout),
+		Total:     currFmt.Format(doc.Total),
+		Seller:    EInvoicePartyResponse{Name: doc.Seller.Name, TaxID: doc.Seller.TaxID},
+		Buyer:     EInvoicePartyResponse{Name: doc.Buyer.Name, TaxID: doc.Buyer.TaxID},
+	}
+	if !doc.DueDate.IsZero() {
+		res.DueDate = doc.DueDate.Format(dateLayout)
+	}
+
+	for _, l := range doc.Lines {
+		res.Lines = append(res.Lines, EInvoiceLineResponse{
+			ID:          l.ID,
+			Description: l.Description,
+			Quantity:    l.Quantity,
+			Amount:      currFmt.Format(l.Amount),
+		})
+	}
+
+	return res
+}
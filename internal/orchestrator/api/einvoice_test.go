@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetrieveEInvoice(t *testing.T) {
+	Convey("RetrieveEInvoice", t, func() {
+		total, _ := currency.NewAmount("1210", "EUR")
+		invSvc := &mockInvoiceService{
+			getInvoiceFunc: func(ctx context.Context, id string) (invoice.Invoice, error) {
+				return invoice.Invoice{ID: id, IssuerID: "issuer-1"}, nil
+			},
+			getEInvoiceFunc: func(ctx context.Context, id string) (einvoice.Document, error) {
+				if id != "invoice" {
+					return einvoice.Document{}, fmt.Errorf("could not retrieve e-invoice: %w", invoice.ErrNotFound)
+				}
+
+				return einvoice.Document{Format: einvoice.UBL, Number: "INV-2023-001", Total: total,
+					DueDate: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+					Seller:  einvoice.Party{Name: "ACME Ltd"}, Buyer: einvoice.Party{Name: "Buyer GmbH", TaxID: "DE811907980"},
+					Lines: []einvoice.Line{{ID: "1", Description: "Consulting", Amount: total}}}, nil
+			},
+		}
+		srv := New(0, invSvc, nil, nil, nil, nil, nil).WithAuth(&mockAuthService{})
+		rec := httptest.NewRecorder()
+
+		get := func(id string, p auth.Principal) {
+			req := httptest.NewRequest(http.MethodGet, "/invoice/"+id+"/einvoice", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+			c := srv.e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(id)
+			So(srv.RetrieveEInvoice(c), ShouldBeNil)
+		}
+
+		Convey("when an investor requests it", func() {
+			get("invoice", principals["investor"])
+
+			Convey("return the seller, buyer and lines", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+
+				var res EInvoiceResponse
+				So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Number, ShouldEqual, "INV-2023-001")
+				So(res.DueDate, ShouldEqual, "2023-12-31")
+				So(res.Seller.Name, ShouldEqual, "ACME Ltd")
+				So(res.Buyer.TaxID, ShouldEqual, "DE811907980")
+				So(res.Lines, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("when another issuer requests it", func() {
+			get("invoice", principals["issuer-2"])
+
+			Convey("return forbidden", func() {
+				So(rec.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+
+		Convey("when the invoice file is not an e-invoice", func() {
+			get("other", principals["issuer"])
+
+			Convey("return not found", func() {
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestCreateInvoice_UploadLimit(t *testing.T) {
+	Convey("CreateInvoice", t, func() {
+		srv := New(0, &mockInvoiceService{}, nil, nil, nil, nil, nil).WithAuth(&mockAuthService{}).WithUploadLimit(64)
+		rec := httptest.NewRecorder()
+
+		upload := func(size int) {
+			var body bytes.Buffer
+			w := multipart.NewWriter(&body)
+			So(w.WriteField("issuer_id", "issuer-1"), ShouldBeNil)
+			f, err := w.CreateFormFile("invoice", "invoice.pdf")
+			So(err, ShouldBeNil)
+			_, err = f.Write(bytes.Repeat([]byte("x"), size))
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+
+			req := httptest.NewRequest(http.MethodPost, "/invoice", &body)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principals["issuer"]))
+			So(srv.CreateInvoice(srv.e.NewContext(req, rec)), ShouldBeNil)
+		}
+
+		Convey("when the file is over the limit", func() {
+			upload(65)
+
+			Convey("return too large", func() {
+				So(rec.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			})
+		})
+
+		Convey("when the request is over the limit", func() {
+			upload(formOverhead + 65)
+
+			Convey("return too large without reading it whole", func() {
+				So(rec.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			})
+		})
+	})
+}
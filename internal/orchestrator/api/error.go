@@ -4,32 +4,168 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
-	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/fee"
+	"github.com/nerock/invoicebidder/internal/idempotency"
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/issuer"
+	"github.com/nerock/invoicebidder/internal/orchestrator/bulk"
 )
 
+const problemContentType = "application/problem+json"
+
+// retryAfter is how many seconds clients are told to wait when a problem is
+// temporary
+const retryAfter = "30"
+
 var (
 	ErrBadRequest = errors.New("bad request")
-	ErrNotFound   = pgx.ErrNoRows
+	ErrForbidden  = errors.New("forbidden")
+	ErrNotFound   = errors.New("not found")
+	ErrTooLarge   = errors.New("request body too large")
 )
 
-type HTTPError struct {
-	Err string `json:"error"`
+// Problem is an RFC 7807 error. Type and code are stable for clients to
+// match on, the detail is only meant to be read.
+type Problem struct {
+	Type     string `json:"type" example:"/problems/insufficient-funds"`
+	Title    string `json:"title" example:"Insufficient funds"`
+	Status   int    `json:"status" example:"409"`
+	Code     string `json:"code" example:"insufficient_funds"`
+	Detail   string `json:"detail,omitempty" example:"insufficient funds"`
+	Instance string `json:"instance,omitempty" example:"/invoice/343abd7a-874c-4bb7-ba7b-81e9c71cf1b0/bid"`
+}
+
+type problemKind struct {
+	status int
+	code   string
+	title  string
+	// hideDetail keeps errors that may wrap storage internals to the title
+	hideDetail bool
+}
+
+var internalProblem = problemKind{http.StatusInternalServerError, "internal", "Internal server error", true}
+
+// problems maps the errors clients can act on to their problem, checked in
+// order
+var problems = []struct {
+	err  error
+	kind problemKind
+}{
+	{ErrNotFound, problemKind{http.StatusNotFound, "not_found", "Resource not found", false}},
+	{invoice.ErrNotFound, problemKind{http.StatusNotFound, "not_found", "Resource not found", false}},
+	{issuer.ErrNotFound, problemKind{http.StatusNotFound, "not_found", "Resource not found", false}},
+	{investor.ErrNotFound, problemKind{http.StatusNotFound, "not_found", "Resource not found", false}},
+	{fee.ErrNotFound, problemKind{http.StatusNotFound, "not_found", "Resource not found", false}},
+	{auth.ErrNotFound, problemKind{http.StatusNotFound, "not_found", "Resource not found", false}},
+	{ErrBadRequest, problemKind{http.StatusBadRequest, "bad_request", "Bad request", false}},
+	{invoice.ErrInvalidCursor, problemKind{http.StatusBadRequest, "invalid_cursor", "Invalid cursor", false}},
+	{auth.ErrUnauthenticated, problemKind{http.StatusUnauthorized, "unauthenticated", "Unauthenticated", false}},
+	{ErrForbidden, problemKind{http.StatusForbidden, "forbidden", "Forbidden", false}},
+	{auth.ErrKeyFromKey, problemKind{http.StatusForbidden, "forbidden", "Forbidden", false}},
+	{invoice.ErrNotOwner, problemKind{http.StatusForbidden, "not_owner", "Not the owner", false}},
+	{investor.ErrNotOwner, problemKind{http.StatusForbidden, "not_owner", "Not the owner", false}},
+	{issuer.ErrNotOwner, problemKind{http.StatusForbidden, "not_owner", "Not the owner", false}},
+	{invoice.ErrInvoiceNotOpen, problemKind{http.StatusConflict, "invoice_not_open", "Invoice is not open", false}},
+	{invoice.ErrInvalidTransition, problemKind{http.StatusConflict, "invalid_transition", "Invalid status transition", false}},
+	{investor.ErrInvalidTransition, problemKind{http.StatusConflict, "invalid_transition", "Invalid status transition", false}},
+	{issuer.ErrInvalidTransition, problemKind{http.StatusConflict, "invalid_transition", "Invalid status transition", false}},
+	{investor.ErrInsufficientFunds, problemKind{http.StatusConflict, "insufficient_funds", "Insufficient funds", false}},
+	{issuer.ErrInsufficientFunds, problemKind{http.StatusConflict, "insufficient_funds", "Insufficient funds", false}},
+	{invoice.ErrOnHold, problemKind{http.StatusConflict, "invoice_on_hold", "Invoice on hold for review", false}},
+	{invoice.ErrDuplicateFile, problemKind{http.StatusConflict, "duplicate_file", "Invoice file already uploaded", false}},
+	{idempotency.ErrInProgress, problemKind{http.StatusConflict, "request_in_progress", "Request in progress", false}},
+	{invoice.ErrCurrencyMismatch, problemKind{http.StatusUnprocessableEntity, "currency_mismatch", "Currency mismatch", false}},
+	{investor.ErrCurrencyMismatch, problemKind{http.StatusUnprocessableEntity, "currency_mismatch", "Currency mismatch", false}},
+	{invoice.ErrInvalidAmount, problemKind{http.StatusUnprocessableEntity, "invalid_amount", "Invalid amount", false}},
+	{investor.ErrInvalidAmount, problemKind{http.StatusUnprocessableEntity, "invalid_amount", "Invalid amount", false}},
+	{issuer.ErrInvalidAmount, problemKind{http.StatusUnprocessableEntity, "invalid_amount", "Invalid amount", false}},
+	{issuer.ErrInvalidRating, problemKind{http.StatusUnprocessableEntity, "invalid_rating", "Invalid rating", false}},
+	{invoice.ErrEInvoiceMismatch, problemKind{http.StatusUnprocessableEntity, "einvoice_mismatch", "Declared values contradict the e-invoice", false}},
+	{invoice.ErrMissingValue, problemKind{http.StatusUnprocessableEntity, "missing_value", "Missing invoice value", false}},
+	{invoice.ErrInvalidFile, problemKind{http.StatusUnprocessableEntity, "invalid_file", "Invalid invoice file", false}},
+	{idempotency.ErrConflict, problemKind{http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency key reused", false}},
+	{invoice.ErrFileTooLarge, problemKind{http.StatusRequestEntityTooLarge, "file_too_large", "Invoice file too large", false}},
+	{ErrTooLarge, problemKind{http.StatusRequestEntityTooLarge, "request_too_large", "Request too large", false}},
+	{bulk.ErrQueueFull, problemKind{http.StatusServiceUnavailable, "too_many_bulk_uploads", "Too many bulk uploads", false}},
 }
 
 func errBadRequest(err error, c echo.Context) error {
 	return errHandler(fmt.Errorf("%w: %w", ErrBadRequest, err), c)
 }
 
+// errHandler responds with the problem of the error. Unknown errors are
+// logged and answered without any detail.
 func errHandler(err error, c echo.Context) error {
-	code := http.StatusInternalServerError
-	switch {
-	case errors.Is(err, ErrNotFound):
-		code = http.StatusNotFound
-	case errors.Is(err, ErrBadRequest):
-		code = http.StatusBadRequest
+	kind := internalProblem
+	for _, p := range problems {
+		if errors.Is(err, p.err) {
+			kind = p.kind
+			break
+		}
+	}
+
+	if kind.status == http.StatusUnauthorized {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	}
+	if kind.status == http.StatusServiceUnavailable {
+		c.Response().Header().Set(echo.HeaderRetryAfter, retryAfter)
+	}
+	if kind.status >= http.StatusInternalServerError {
+		c.Logger().Errorf("%s %s: %s", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	return problem(c, kind, err)
+}
+
+// httpErrorHandler answers the errors raised by echo itself, like unknown
+// routes, unsupported methods or failed binds, with problems as well
+func httpErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		err = errHandler(err, c)
+	} else {
+		kind := problemKind{
+			status:     he.Code,
+			code:       strings.ToLower(strings.ReplaceAll(http.StatusText(he.Code), " ", "_")),
+			title:      http.StatusText(he.Code),
+			hideDetail: he.Code >= http.StatusInternalServerError,
+		}
+		if kind.code == "" {
+			kind = internalProblem
+		}
+		if kind.status >= http.StatusInternalServerError {
+			c.Logger().Errorf("%s %s: %s", c.Request().Method, c.Request().URL.Path, err)
+		}
+
+		err = problem(c, kind, fmt.Errorf("%v", he.Message))
+	}
+
+	if err != nil {
+		c.Logger().Errorf("could not send problem: %s", err)
+	}
+}
+
+func problem(c echo.Context, kind problemKind, err error) error {
+	p := Problem{
+		Type:     "/problems/" + strings.ReplaceAll(kind.code, "_", "-"),
+		Title:    kind.title,
+		Status:   kind.status,
+		Code:     kind.code,
+		Instance: c.Request().URL.Path,
+	}
+	if !kind.hideDetail {
+		p.Detail = err.Error()
 	}
 
-	return c.JSON(code, HTTPError{Err: err.Error()})
+	c.Response().Header().Set(echo.HeaderContentType, problemContentType)
+	return c.JSON(kind.status, p)
 }
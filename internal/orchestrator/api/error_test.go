@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/fee"
+	"github.com/nerock/invoicebidder/internal/idempotency"
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/issuer"
+	"github.com/nerock/invoicebidder/internal/orchestrator/bulk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrHandler(t *testing.T) {
+	Convey("errHandler", t, func() {
+		handle := func(err error) (*httptest.ResponseRecorder, Problem) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/invoice/invoice-1/bid", nil)
+			So(errHandler(err, echo.New().NewContext(req, rec)), ShouldBeNil)
+
+			var p Problem
+			So(json.Unmarshal(rec.Body.Bytes(), &p), ShouldBeNil)
+			return rec, p
+		}
+
+		Convey("answer each known error with its status and code", func() {
+			tests := []struct {
+				err    error
+				status int
+				code   string
+			}{
+				{fmt.Errorf("%w: bad amount", ErrBadRequest), http.StatusBadRequest, "bad_request"},
+				{auth.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated"},
+				{ErrForbidden, http.StatusForbidden, "forbidden"},
+				{fmt.Errorf("could not withdraw: %w", investor.ErrNotOwner), http.StatusForbidden, "not_owner"},
+				{fmt.Errorf("could not bid: %w", invoice.ErrInvoiceNotOpen), http.StatusConflict, "invoice_not_open"},
+				{issuer.ErrInvalidTransition, http.StatusConflict, "invalid_transition"},
+				{fmt.Errorf("could not bid: %w", investor.ErrInsufficientFunds), http.StatusConflict, "insufficient_funds"},
+				{invoice.ErrDuplicateFile, http.StatusConflict, "duplicate_file"},
+				{idempotency.ErrInProgress, http.StatusConflict, "request_in_progress"},
+				{invoice.ErrCurrencyMismatch, http.StatusUnprocessableEntity, "currency_mismatch"},
+				{issuer.ErrInvalidAmount, http.StatusUnprocessableEntity, "invalid_amount"},
+				{issuer.ErrInvalidRating, http.StatusUnprocessableEntity, "invalid_rating"},
+				{idempotency.ErrConflict, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+				{invoice.ErrFileTooLarge, http.StatusRequestEntityTooLarge, "file_too_large"},
+				{bulk.ErrQueueFull, http.StatusServiceUnavailable, "too_many_bulk_uploads"},
+				{errors.New("connection refused"), http.StatusInternalServerError, "internal"},
+			}
+
+			for _, tt := range tests {
+				rec, p := handle(tt.err)
+				So(rec.Code, ShouldEqual, tt.status)
+				So(rec.Header().Get(echo.HeaderContentType), ShouldEqual, problemContentType)
+				So(p.Status, ShouldEqual, tt.status)
+				So(p.Code, ShouldEqual, tt.code)
+				So(p.Type, ShouldStartWith, "/problems/")
+				So(p.Title, ShouldNotBeEmpty)
+				So(p.Instance, ShouldEqual, "/invoice/invoice-1/bid")
+			}
+		})
+
+		Convey("explain the errors clients can act on", func() {
+			_, p := handle(fmt.Errorf("could not bid: %w", investor.ErrInsufficientFunds))
+			So(p.Type, ShouldEqual, "/problems/insufficient-funds")
+			So(p.Detail, ShouldContainSubstring, "insufficient funds")
+		})
+
+		Convey("tell clients when to retry temporary errors", func() {
+			rec, _ := handle(bulk.ErrQueueFull)
+			So(rec.Header().Get(echo.HeaderRetryAfter), ShouldEqual, retryAfter)
+		})
+
+		Convey("hide the detail of internal errors", func() {
+			_, p := handle(errors.New("dial tcp 10.0.0.1:5432: connection refused"))
+			So(p.Detail, ShouldBeEmpty)
+		})
+
+		Convey("answer missing resources of every domain as not found", func() {
+			for _, err := range []error{ErrNotFound, invoice.ErrNotFound, issuer.ErrNotFound, investor.ErrNotFound, fee.ErrNotFound, auth.ErrNotFound} {
+				rec, p := handle(fmt.Errorf("could not retrieve resource: %w", err))
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+				So(p.Code, ShouldEqual, "not_found")
+				So(p.Detail, ShouldEqual, "could not retrieve resource: "+err.Error())
+			}
+		})
+
+		Convey("ask for credentials when unauthenticated", func() {
+			rec, _ := handle(auth.ErrUnauthenticated)
+			So(rec.Header().Get(echo.HeaderWWWAuthenticate), ShouldEqual, "Bearer")
+		})
+	})
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	Convey("httpErrorHandler", t, func() {
+		srv := New(0, nil, nil, nil, nil, nil, nil)
+		srv.e.GET("/ping", func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		})
+		srv.e.GET("/fail", func(c echo.Context) error {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "pool exhausted")
+		})
+		srv.e.GET("/forbidden", func(c echo.Context) error {
+			return ErrForbidden
+		})
+		serve := func(method, target string) (*httptest.ResponseRecorder, Problem) {
+			rec := httptest.NewRecorder()
+			srv.e.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+
+			var p Problem
+			So(json.Unmarshal(rec.Body.Bytes(), &p), ShouldBeNil)
+			return rec, p
+		}
+
+		Convey("when the route does not exist", func() {
+			rec, p := serve(http.MethodGet, "/missing")
+
+			Convey("answer not found as a problem", func() {
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+				So(rec.Header().Get(echo.HeaderContentType), ShouldEqual, problemContentType)
+				So(p.Code, ShouldEqual, "not_found")
+				So(p.Type, ShouldEqual, "/problems/not-found")
+				So(p.Instance, ShouldEqual, "/missing")
+			})
+		})
+
+		Convey("when the method is not allowed", func() {
+			rec, p := serve(http.MethodPost, "/ping")
+
+			Convey("answer it as a problem", func() {
+				So(rec.Code, ShouldEqual, http.StatusMethodNotAllowed)
+				So(p.Code, ShouldEqual, "method_not_allowed")
+			})
+		})
+
+		Convey("when a handler returns a server error", func() {
+			rec, p := serve(http.MethodGet, "/fail")
+
+			Convey("hide its detail", func() {
+				So(rec.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(p.Code, ShouldEqual, "service_unavailable")
+				So(p.Detail, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when a handler returns a domain error", func() {
+			rec, p := serve(http.MethodGet, "/forbidden")
+
+			Convey("answer its problem", func() {
+				So(rec.Code, ShouldEqual, http.StatusForbidden)
+				So(p.Code, ShouldEqual, "forbidden")
+			})
+		})
+	})
+}
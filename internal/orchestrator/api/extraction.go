@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+type ExtractionResponse struct {
+	InvoiceID   string   `json:"invoiceId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Number      string   `json:"number,omitempty" example:"INV-2023-001"`
+	IssueDate   string   `json:"issueDate,omitempty" example:"2023-11-01"`
+	DueDate     string   `json:"dueDate,omitempty" example:"2023-12-31"`
+	Total       string   `json:"total,omitempty" example:"1190.00"`
+	Currency    string   `json:"currency,omitempty" example:"EUR"`
+	DebtorTaxID string   `json:"debtorTaxId,omitempty" example:"DE811907980"`
+	Flagged     bool     `json:"flagged" example:"true"`
+	Mismatches  []string `json:"mismatches,omitempty" example:"total"`
+	ExtractedAt string   `json:"extractedAt" example:"2023-11-02T10:00:00Z"`
+	ReviewedAt  string   `json:"reviewedAt,omitempty" example:"2023-11-03T09:00:00Z"`
+}
+
+// RetrieveExtraction returns the values read from an invoice file
+// @Summary      Get invoice extraction
+// @Description  Get the values read from the invoice file and the declared values they contradict
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Invoice id"
+// @Success      200  {object}  ExtractionResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/:id/extraction [get]
+func (s *Server) RetrieveExtraction(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	if err := s.authorizeInvoice(c, id, viewIssuer); err != nil {
+		return errHandler(err, c)
+	}
+
+	extraction, err := s.invoiceService.GetExtraction(c.Request().Context(), id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusOK, extractionResponse(extraction))
+}
+
+// ListFlaggedInvoices lists the invoices whose file contradicts them
+// @Summary      List invoices to review
+// @Description  List the extractions of invoices whose file does not match the declared face value, currency or due date
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Success      200  {array}   ExtractionResponse
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/review [get]
+func (s *Server) ListFlaggedInvoices(c echo.Context) error {
+	if err := s.authorize(c, audit); err != nil {
+		return errHandler(err, c)
+	}
+
+	extractions, err := s.invoiceService.ListFlaggedExtractions(c.Request().Context())
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	res := make([]ExtractionResponse, 0, len(extractions))
+	for _, e := range extractions {
+		res = append(res, extractionResponse(e))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ReviewExtraction accepts the mismatches of an invoice file
+// @Summary      Review invoice extraction
+// @Description  Accept the mismatches between the invoice file and the declared values, releasing the hold on the invoice
+// @Tags         invoice
+// @Security     Bearer
+// @Param id path string true "Invoice id"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/:id/extraction/review [post]
+func (s *Server) ReviewExtraction(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	if err := s.authorize(c, operate); err != nil {
+		return errHandler(err, c)
+	}
+
+	if err := s.invoiceService.ReviewExtraction(c.Request().Context(), id); err != nil {
+		return errHandler(err, c)
+	}
+	s.broker.SendInvoiceReleasedEvent(id)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func extractionResponse(e invoice.Extraction) ExtractionResponse {
+	res := ExtractionResponse{
+		InvoiceID:   e.InvoiceID,
+		Number:      e.Number,
+		Total:       e.Total,
+		Currency:    e.Currency,
+		DebtorTaxID: e.DebtorTaxID,
+		Flagged:     e.Flagged(),
+		ExtractedAt: e.ExtractedAt.UTC().Format(time.RFC3339),
+	}
+	if e.IssueDate != nil {
+		res.IssueDate = e.IssueDate.Format(dateLayout)
+	}
+	if e.DueDate != nil {
+		res.DueDate = e.DueDate.Format(dateLayout)
+	}
+	if e.ReviewedAt != nil {
+		res.ReviewedAt = e.ReviewedAt.UTC().Format(time.RFC3339)
+	}
+	for _, m := range e.Mismatches {
+		res.Mismatches = append(res.Mismatches, string(m))
+	}
+
+	return res
+}
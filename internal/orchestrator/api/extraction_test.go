@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReviewExtraction(t *testing.T) {
+	Convey("ReviewExtraction", t, func() {
+		var reviewed []string
+		invSvc := &mockInvoiceService{reviewExtractionFunc: func(_ context.Context, id string) error {
+			if id != "invoiceID" {
+				return fmt.Errorf("could not review extraction: %w", invoice.ErrNotFound)
+			}
+
+			reviewed = append(reviewed, id)
+			return nil
+		}}
+		brk := &mockBroker{}
+		srv := New(0, invSvc, nil, nil, nil, brk, nil).WithAuth(&mockAuthService{})
+		rec := httptest.NewRecorder()
+
+		request := func(id string, p auth.Principal) echo.Context {
+			req := httptest.NewRequest(http.MethodPost, "/invoice/"+id+"/extraction/review", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+			c := srv.e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(id)
+			return c
+		}
+		admin := auth.Principal{Subject: "admin", Roles: []auth.Role{auth.ADMIN}}
+
+		Convey("when an admin reviews an extraction", func() {
+			So(srv.ReviewExtraction(request("invoiceID", admin)), ShouldBeNil)
+
+			Convey("release the invoice to the bid rules", func() {
+				So(rec.Code, ShouldEqual, http.StatusNoContent)
+				So(reviewed, ShouldResemble, []string{"invoiceID"})
+				So(brk.released, ShouldResemble, []string{"invoiceID"})
+			})
+		})
+
+		Convey("when an auditor reviews an extraction", func() {
+			So(srv.ReviewExtraction(request("invoiceID", auth.Principal{Subject: "auditor", Roles: []auth.Role{auth.AUDITOR}})), ShouldBeNil)
+
+			Convey("forbid it", func() {
+				So(rec.Code, ShouldEqual, http.StatusForbidden)
+				So(reviewed, ShouldBeEmpty)
+				So(brk.released, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the invoice was never extracted", func() {
+			So(srv.ReviewExtraction(request("missing", admin)), ShouldBeNil)
+
+			Convey("return not found", func() {
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+				So(brk.released, ShouldBeEmpty)
+			})
+		})
+	})
+}
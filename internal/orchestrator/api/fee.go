@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/fee"
+)
+
+type SettlementResponse struct {
+	InvoiceID    string                   `json:"invoiceId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	IssuerID     string                   `json:"issuerId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Amount       string                   `json:"amount" example:"1 230,45 €"`
+	IssuerFees   string                   `json:"issuerFees" example:"12,30 €"`
+	InvestorFees string                   `json:"investorFees" example:"5,00 €"`
+	IssuerNet    string                   `json:"issuerNet" example:"1 218,15 €"`
+	Items        []SettlementItemResponse `json:"items"`
+	CreatedAt    string                   `json:"createdAt" example:"2023-07-20T10:00:00Z"`
+}
+
+type SettlementItemResponse struct {
+	Payer   string `json:"payer" example:"issuer"`
+	PayerID string `json:"payerId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Kind    string `json:"kind" example:"percentage"`
+	Rate    string `json:"rate,omitempty" example:"1"`
+	Base    string `json:"base" example:"1 230,45 €"`
+	Amount  string `json:"amount" example:"12,30 €"`
+}
+
+type RevenueResponse struct {
+	Revenue map[string]string `json:"revenue"`
+}
+
+type FeeService interface {
+	GetSettlement(context.Context, string) (fee.Settlement, error)
+	GetRevenue(context.Context) ([]currency.Amount, error)
+	GetIssuerFees(context.Context, string) (map[string]currency.Amount, error)
+}
+
+func (s *Server) platformRoutes(g *echo.Group) {
+	g.GET("/revenue", s.RetrieveRevenue)
+}
+
+// RetrieveSettlement retrieves the settlement of a traded invoice
+// @Summary      Get trade settlement
+// @Description  Retrieve the fees charged on a traded invoice and the amount received by the issuer
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Invoice id"
+// @Success      200  {object}  SettlementResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/:id/settlement [get]
+func (s *Server) RetrieveSettlement(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	st, err := s.feeService.GetSettlement(c.Request().Context(), id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+	if err := s.authorize(c, viewIssuer(st.IssuerID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	res := SettlementResponse{
+		InvoiceID:    st.InvoiceID,
+		IssuerID:     st.IssuerID,
+		Amount:       currFmt.Format(st.Amount),
+		IssuerFees:   currFmt.Format(st.Total(fee.ISSUER)),
+		InvestorFees: currFmt.Format(st.Total(fee.INVESTOR)),
+		IssuerNet:    currFmt.Format(st.IssuerNet),
+		Items:        make([]SettlementItemResponse, 0, len(st.Items)),
+		CreatedAt:    st.CreatedAt.Format(time.RFC3339),
+	}
+
+	for _, item := range st.Items {
+		res.Items = append(res.Items, SettlementItemResponse{
+			Payer:   string(item.Payer),
+			PayerID: item.PayerID,
+			Kind:    string(item.Kind),
+			Rate:    item.Rate,
+			Base:    currFmt.Format(item.Base),
+			Amount:  currFmt.Format(item.Amount),
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// RetrieveRevenue retrieves the platform revenue account
+// @Summary      Get platform revenue
+// @Description  Retrieve the fees collected by the platform per currency
+// @Tags         platform
+// @Produce      json
+// @Security     Bearer
+// @Success      200  {object}  RevenueResponse
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /platform/revenue [get]
+func (s *Server) RetrieveRevenue(c echo.Context) error {
+	if err := s.authorize(c, audit); err != nil {
+		return errHandler(err, c)
+	}
+
+	revenue, err := s.feeService.GetRevenue(c.Request().Context())
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	t := totals{}
+	for _, r := range revenue {
+		t.add(r)
+	}
+
+	return c.JSON(http.StatusOK, RevenueResponse{Revenue: t.response()})
+}
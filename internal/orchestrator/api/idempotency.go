@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/idempotency"
+)
+
+const (
+	idempotencyKeyHeader   = "Idempotency-Key"
+	idempotentReplayHeader = "Idempotent-Replayed"
+	maxIdempotencyKey      = 255
+)
+
+// maxHashedBody bounds the bodies read to hash a request, and maxHashedForm
+// the multipart ones, as big as the largest upload
+const (
+	maxHashedBody = 1 << 20
+	maxHashedForm = maxArchiveSize + formOverhead
+)
+
+type IdempotencyService interface {
+	Begin(context.Context, string, string, string) (*idempotency.Response, error)
+	Complete(context.Context, string, string, idempotency.Response) error
+	Release(context.Context, string, string) error
+}
+
+// WithIdempotency replays the response of POST requests retried with the
+// same Idempotency-Key header
+func (s *Server) WithIdempotency(idempotencyService IdempotencyService) *Server {
+	s.idempotencyService = idempotencyService
+
+	return s
+}
+
+// idempotent runs a POST request with an idempotency key once, so a client
+// retrying after a timeout does not bid or pay twice. Keys are scoped to the
+// principal, and reusing one for a different request is rejected.
+func (s *Server) idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(idempotencyKeyHeader)
+		if c.Request().Method != http.MethodPost || key == "" {
+			return next(c)
+		}
+		if len(key) > maxIdempotencyKey {
+			return errBadRequest(fmt.Errorf("idempotency key cannot be longer than %d characters", maxIdempotencyKey), c)
+		}
+
+		hash, err := requestHash(c)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return errHandler(fmt.Errorf("%w: maximum size is %d bytes", ErrTooLarge, maxErr.Limit), c)
+			}
+
+			return errBadRequest(err, c)
+		}
+
+		var subject string
+		if p, ok := auth.FromContext(c.Request().Context()); ok {
+			subject = p.Subject
+		}
+
+		res, err := s.idempotencyService.Begin(c.Request().Context(), subject, key, hash)
+		if err != nil {
+			return errHandler(err, c)
+		}
+		if res != nil {
+			c.Response().Header().Set(idempotentReplayHeader, "true")
+			return c.Blob(res.Status, res.ContentType, res.Body)
+		}
+
+		rec := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = rec
+
+		defer func() {
+			if r := recover(); r != nil {
+				s.releaseKey(subject, key)
+				panic(r)
+			}
+		}()
+
+		err = next(c)
+
+		if err != nil || c.Response().Status >= http.StatusInternalServerError {
+			s.releaseKey(subject, key)
+
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := s.idempotencyService.Complete(ctx, subject, key, idempotency.Response{
+			Status:      c.Response().Status,
+			ContentType: c.Response().Header().Get(echo.HeaderContentType),
+			Body:        rec.body.Bytes(),
+		}); err != nil {
+			s.e.Logger.Errorf("could not store idempotent response: %s", err)
+		}
+
+		return nil
+	}
+}
+
+// releaseKey frees the key of a request that failed or panicked. The client
+// may be gone after a timeout, so it does not use the request context.
+func (s *Server) releaseKey(subject, key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.idempotencyService.Release(ctx, subject, key); err != nil {
+		s.e.Logger.Errorf("could not release idempotency key: %s", err)
+	}
+}
+
+// requestHash identifies a request by its method, url and body, read up to a
+// limit. The body is put back for the handler, and multipart forms are left
+// parsed for it.
+func requestHash(c echo.Context) (string, error) {
+	r := c.Request()
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get(echo.HeaderContentType)); mediaType == echo.MIMEMultipartForm {
+		r.Body = http.MaxBytesReader(c.Response(), r.Body, maxHashedForm)
+		if err := hashForm(h, c); err != nil {
+			return "", err
+		}
+
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	var body bytes.Buffer
+	if _, err := io.Copy(h, io.TeeReader(http.MaxBytesReader(c.Response(), r.Body, maxHashedBody), &body)); err != nil {
+		return "", fmt.Errorf("could not read request body: %w", err)
+	}
+	r.Body = io.NopCloser(&body)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashForm writes the fields of a multipart form and the digests of its
+// files, in name order, as the boundary changes on every retry
+func hashForm(h io.Writer, c echo.Context) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return fmt.Errorf("could not read form: %w", err)
+	}
+
+	fields := make([]string, 0, len(form.Value))
+	for name := range form.Value {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	for _, name := range fields {
+		for _, v := range form.Value[name] {
+			fmt.Fprintf(h, "%q=%q\n", name, v)
+		}
+	}
+
+	files := make([]string, 0, len(form.File))
+	for name := range form.File {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		for _, fh := range form.File[name] {
+			digest, err := fileDigest(fh)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%q=%q %x\n", name, fh.Filename, digest)
+		}
+	}
+
+	return nil
+}
+
+func fileDigest(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, fmt.Errorf("could not open form file: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("could not read form file: %w", err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
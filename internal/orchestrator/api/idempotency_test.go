@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/idempotency"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockIdempotencyService struct {
+	hashes    map[string]string
+	responses map[string]idempotency.Response
+}
+
+func (m *mockIdempotencyService) Begin(_ context.Context, subject, key, hash string) (*idempotency.Response, error) {
+	k := subject + "/" + key
+	held, ok := m.hashes[k]
+	if !ok {
+		m.hashes[k] = hash
+		return nil, nil
+	}
+
+	if held != hash {
+		return nil, idempotency.ErrConflict
+	}
+
+	res, ok := m.responses[k]
+	if !ok {
+		return nil, idempotency.ErrInProgress
+	}
+
+	return &res, nil
+}
+
+func (m *mockIdempotencyService) Complete(_ context.Context, subject, key string, res idempotency.Response) error {
+	m.responses[subject+"/"+key] = res
+	return nil
+}
+
+func (m *mockIdempotencyService) Release(_ context.Context, subject, key string) error {
+	delete(m.hashes, subject+"/"+key)
+	return nil
+}
+
+func TestIdempotent(t *testing.T) {
+	Convey("idempotent", t, func() {
+		svc := &mockIdempotencyService{hashes: map[string]string{}, responses: map[string]idempotency.Response{}}
+		srv := New(0, nil, nil, nil, nil, nil, nil).WithIdempotency(svc)
+
+		calls, status := 0, http.StatusCreated
+		handler := srv.idempotent(func(c echo.Context) error {
+			calls++
+			return c.JSON(status, map[string]int{"calls": calls})
+		})
+		serve := func(method, key, body string, p *auth.Principal) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(method, "/invoice/invoice-1/bid", strings.NewReader(body))
+			if key != "" {
+				req.Header.Set(idempotencyKeyHeader, key)
+			}
+			if p != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *p))
+			}
+			So(handler(srv.e.NewContext(req, rec)), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when a request is retried with the same key", func() {
+			first := serve(http.MethodPost, "key-1", `{"amount":"100"}`, nil)
+			retry := serve(http.MethodPost, "key-1", `{"amount":"100"}`, nil)
+
+			Convey("replay the original response without running it again", func() {
+				So(calls, ShouldEqual, 1)
+				So(retry.Code, ShouldEqual, http.StatusCreated)
+				So(retry.Body.String(), ShouldEqual, first.Body.String())
+				So(retry.Header().Get(echo.HeaderContentType), ShouldEqual, first.Header().Get(echo.HeaderContentType))
+				So(retry.Header().Get(idempotentReplayHeader), ShouldEqual, "true")
+				So(first.Header().Get(idempotentReplayHeader), ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the key is reused for a different request", func() {
+			serve(http.MethodPost, "key-1", `{"amount":"100"}`, nil)
+			rec := serve(http.MethodPost, "key-1", `{"amount":"200"}`, nil)
+
+			Convey("return unprocessable entity", func() {
+				So(calls, ShouldEqual, 1)
+				So(rec.Code, ShouldEqual, http.StatusUnprocessableEntity)
+			})
+		})
+
+		Convey("when the first request is still running", func() {
+			req := httptest.NewRequest(http.MethodPost, "/invoice/invoice-1/bid", strings.NewReader("{}"))
+			svc.hashes["/key-1"], _ = requestHash(srv.e.NewContext(req, httptest.NewRecorder()))
+			rec := serve(http.MethodPost, "key-1", "{}", nil)
+
+			Convey("return conflict", func() {
+				So(calls, ShouldEqual, 0)
+				So(rec.Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("when the request failed", func() {
+			status = http.StatusInternalServerError
+			serve(http.MethodPost, "key-1", "{}", nil)
+			status = http.StatusCreated
+			rec := serve(http.MethodPost, "key-1", "{}", nil)
+
+			Convey("let it be retried", func() {
+				So(calls, ShouldEqual, 2)
+				So(rec.Code, ShouldEqual, http.StatusCreated)
+			})
+		})
+
+		Convey("when different principals use the same key", func() {
+			serve(http.MethodPost, "key-1", "{}", &auth.Principal{Subject: "user-1"})
+			serve(http.MethodPost, "key-1", "{}", &auth.Principal{Subject: "user-2"})
+
+			Convey("run both requests", func() {
+				So(calls, ShouldEqual, 2)
+			})
+		})
+
+		Convey("when the request has no key or is not a POST", func() {
+			serve(http.MethodPost, "", "{}", nil)
+			serve(http.MethodPost, "", "{}", nil)
+			serve(http.MethodGet, "key-1", "", nil)
+			serve(http.MethodGet, "key-1", "", nil)
+
+			Convey("run it every time", func() {
+				So(calls, ShouldEqual, 4)
+				So(svc.hashes, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the handler panics", func() {
+			panicking := srv.idempotent(func(c echo.Context) error {
+				panic("boom")
+			})
+			req := httptest.NewRequest(http.MethodPost, "/invoice/invoice-1/bid", strings.NewReader("{}"))
+			req.Header.Set(idempotencyKeyHeader, "key-1")
+
+			Convey("release the key", func() {
+				So(func() { _ = panicking(srv.e.NewContext(req, httptest.NewRecorder())) }, ShouldPanic)
+				So(svc.hashes, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the body is too large", func() {
+			rec := serve(http.MethodPost, "key-1", strings.Repeat("x", maxHashedBody+1), nil)
+
+			Convey("return too large without reserving the key", func() {
+				So(calls, ShouldEqual, 0)
+				So(rec.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+				So(svc.hashes, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when a form is retried with the same key", func() {
+			formHandler := srv.idempotent(func(c echo.Context) error {
+				calls++
+				fh, err := c.FormFile("invoice")
+				if err != nil {
+					return err
+				}
+				return c.JSON(http.StatusCreated, map[string]string{"issuer": c.FormValue("issuer_id"), "file": fh.Filename})
+			})
+			post := func(issuerID, content string) *httptest.ResponseRecorder {
+				var body bytes.Buffer
+				w := multipart.NewWriter(&body)
+				So(w.WriteField("issuer_id", issuerID), ShouldBeNil)
+				f, err := w.CreateFormFile("invoice", "invoice.pdf")
+				So(err, ShouldBeNil)
+				_, err = f.Write([]byte(content))
+				So(err, ShouldBeNil)
+				So(w.Close(), ShouldBeNil)
+
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/invoice", &body)
+				req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+				req.Header.Set(idempotencyKeyHeader, "key-1")
+				So(formHandler(srv.e.NewContext(req, rec)), ShouldBeNil)
+				return rec
+			}
+
+			first := post("issuer-1", "%PDF-1.7 invoice")
+
+			Convey("replay it despite the new boundary", func() {
+				retry := post("issuer-1", "%PDF-1.7 invoice")
+				So(calls, ShouldEqual, 1)
+				So(first.Code, ShouldEqual, http.StatusCreated)
+				So(first.Body.String(), ShouldContainSubstring, "invoice.pdf")
+				So(retry.Body.String(), ShouldEqual, first.Body.String())
+			})
+
+			Convey("reject it with a different file", func() {
+				rec := post("issuer-1", "%PDF-1.7 other")
+				So(calls, ShouldEqual, 1)
+				So(rec.Code, ShouldEqual, http.StatusUnprocessableEntity)
+			})
+
+			Convey("reject it with a different field", func() {
+				rec := post("issuer-2", "%PDF-1.7 invoice")
+				So(calls, ShouldEqual, 1)
+				So(rec.Code, ShouldEqual, http.StatusUnprocessableEntity)
+			})
+		})
+
+		Convey("when the key is too long", func() {
+			rec := serve(http.MethodPost, strings.Repeat("k", maxIdempotencyKey+1), "{}", nil)
+
+			Convey("return bad request", func() {
+				So(calls, ShouldEqual, 0)
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
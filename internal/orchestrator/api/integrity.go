@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/orchestrator/integrity"
+)
+
+type IntegrityJobResponse struct {
+	ID         string              `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Status     string              `json:"status" example:"finished"`
+	Results    []IntegrityResponse `json:"results"`
+	Error      string              `json:"error,omitempty" example:"could not retrieve invoice files"`
+	CreatedAt  string              `json:"createdAt" example:"2023-07-20T10:00:00Z"`
+	FinishedAt string              `json:"finishedAt,omitempty" example:"2023-07-20T10:01:00Z"`
+}
+
+type IntegrityChecker interface {
+	Start(string) (integrity.Job, error)
+	GetJob(string) (integrity.Job, bool)
+}
+
+// WithIntegrityChecks lets admins and auditors re-hash every stored invoice
+// file in the background
+func (s *Server) WithIntegrityChecks(checker IntegrityChecker) *Server {
+	s.integrityChecker = checker
+
+	return s
+}
+
+// StartIntegrityCheck re-verifies the stored files of every invoice
+// @Summary      Verify invoice documents
+// @Description  Start re-hashing every stored invoice file in the background to compare it with the hash recorded on upload. Returns the check already in progress if there is one.
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      202  {object}  IntegrityJobResponse
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/integrity [post]
+func (s *Server) StartIntegrityCheck(c echo.Context) error {
+	if err := s.authorize(c, audit); err != nil {
+		return errHandler(err, c)
+	}
+
+	var owner string
+	if p, ok := auth.FromContext(c.Request().Context()); ok {
+		owner = p.Subject
+	}
+
+	job, err := s.integrityChecker.Start(owner)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusAccepted, integrityJobResponse(job))
+}
+
+// RetrieveIntegrityCheck retrieves an integrity check
+// @Summary      Get invoice documents verification
+// @Description  Retrieve the status of an integrity check and the result of every invoice file
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Job id"
+// @Success      200  {object}  IntegrityJobResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Router       /invoice/integrity/:id [get]
+func (s *Server) RetrieveIntegrityCheck(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	if err := s.authorize(c, audit); err != nil {
+		return errHandler(err, c)
+	}
+
+	job, ok := s.integrityChecker.GetJob(id)
+	if !ok {
+		return errHandler(fmt.Errorf("%w: integrity check not found", ErrNotFound), c)
+	}
+
+	return c.JSON(http.StatusOK, integrityJobResponse(job))
+}
+
+func integrityJobResponse(job integrity.Job) IntegrityJobResponse {
+	res := IntegrityJobResponse{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Results:   make([]IntegrityResponse, 0, len(job.Results)),
+		Error:     job.Err,
+		CreatedAt: job.CreatedAt.Format(time.RFC3339),
+	}
+
+	if job.FinishedAt != nil {
+		res.FinishedAt = job.FinishedAt.Format(time.RFC3339)
+	}
+
+	for _, r := range job.Results {
+		res.Results = append(res.Results, integrityResponse(r))
+	}
+
+	return res
+}
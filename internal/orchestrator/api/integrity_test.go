@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/orchestrator/integrity"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockIntegrityChecker struct {
+	jobs map[string]integrity.Job
+}
+
+func (m *mockIntegrityChecker) Start(owner string) (integrity.Job, error) {
+	job := integrity.Job{ID: "job", Owner: owner, Status: integrity.PENDING, CreatedAt: time.Now()}
+	m.jobs[job.ID] = job
+
+	return job, nil
+}
+
+func (m *mockIntegrityChecker) GetJob(id string) (integrity.Job, bool) {
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+func TestIntegrityCheck(t *testing.T) {
+	Convey("IntegrityCheck", t, func() {
+		checker := &mockIntegrityChecker{jobs: map[string]integrity.Job{}}
+		srv := New(0, nil, nil, nil, nil, nil, nil).WithAuth(&mockAuthService{}).WithIntegrityChecks(checker)
+		rec := httptest.NewRecorder()
+
+		request := func(method string, p auth.Principal) echo.Context {
+			req := httptest.NewRequest(method, "/invoice/integrity", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+			return srv.e.NewContext(req, rec)
+		}
+		auditor := auth.Principal{Subject: "auditor", Roles: []auth.Role{auth.AUDITOR}}
+
+		Convey("when an auditor starts a check", func() {
+			So(srv.StartIntegrityCheck(request(http.MethodPost, auditor)), ShouldBeNil)
+
+			Convey("accept it as a background job", func() {
+				So(rec.Code, ShouldEqual, http.StatusAccepted)
+
+				var res IntegrityJobResponse
+				So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+				So(res.ID, ShouldEqual, "job")
+				So(res.Status, ShouldEqual, "pending")
+				So(checker.jobs["job"].Owner, ShouldEqual, "auditor")
+			})
+		})
+
+		Convey("when an issuer starts a check", func() {
+			So(srv.StartIntegrityCheck(request(http.MethodPost, auth.Principal{Subject: "issuer", Roles: []auth.Role{auth.ISSUER}})), ShouldBeNil)
+
+			Convey("forbid it", func() {
+				So(rec.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+
+		Convey("when a finished check is retrieved", func() {
+			finished := time.Now()
+			checker.jobs["job"] = integrity.Job{ID: "job", Status: integrity.FINISHED, CreatedAt: finished, FinishedAt: &finished,
+				Results: []invoice.Integrity{{InvoiceID: "a", Expected: "x", Actual: "y", Status: invoice.MISMATCH}}}
+			c := request(http.MethodGet, auditor)
+			c.SetParamNames("id")
+			c.SetParamValues("job")
+			So(srv.RetrieveIntegrityCheck(c), ShouldBeNil)
+
+			Convey("return its results", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+
+				var res IntegrityJobResponse
+				So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Status, ShouldEqual, "finished")
+				So(res.Results, ShouldResemble, []IntegrityResponse{{InvoiceID: "a", Expected: "x", Actual: "y", Status: "mismatch"}})
+			})
+		})
+
+		Convey("when the check does not exist", func() {
+			c := request(http.MethodGet, auditor)
+			c.SetParamNames("id")
+			c.SetParamValues("unknown")
+			So(srv.RetrieveIntegrityCheck(c), ShouldBeNil)
+
+			Convey("return not found", func() {
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
@@ -2,9 +2,11 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/bojanz/currency"
 	"github.com/labstack/echo/v4"
@@ -17,16 +19,26 @@ type CreateInvestorRequest struct {
 }
 
 type InvestorBidResponse struct {
-	ID      string             `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
-	Amount  string             `json:"amount" example:"1 230,45 €"`
-	Invoice InvoiceBidResponse `json:"invoice"`
+	ID      string                  `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Amount  string                  `json:"amount" example:"1 230,45 €"`
+	Cost    string                  `json:"cost" example:"1 230,45 €"`
+	Invoice InvestorInvoiceResponse `json:"invoice"`
+	Pricing *BidPricingResponse     `json:"pricing,omitempty"`
+}
+
+type InvestorInvoiceResponse struct {
+	ID      string                `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Status  string                `json:"status" example:"traded"`
+	DueDate string                `json:"dueDate" example:"2023-12-31"`
+	Issuer  InvoiceIssuerResponse `json:"issuer"`
 }
 
 type InvestorResponse struct {
-	ID       string                `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
-	FullName string                `json:"fullName" example:"Manuel Adalid"`
-	Balance  string                `json:"balance,omitempty" example:"1 230,45 €"`
-	Bids     []BidInvestorResponse `json:"bids,omitempty"`
+	ID        string                `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	FullName  string                `json:"fullName" example:"Manuel Adalid"`
+	Balance   string                `json:"balance,omitempty" example:"1 230,45 €"`
+	Bids      []InvestorBidResponse `json:"bids,omitempty"`
+	Portfolio *PortfolioResponse    `json:"portfolio,omitempty"`
 }
 
 type InvestorService interface {
@@ -34,11 +46,26 @@ type InvestorService interface {
 	ListInvestors(context.Context, []string) (map[string]investor.Investor, error)
 	CreateInvestor(context.Context, string, currency.Amount) (investor.Investor, error)
 	Bid(context.Context, string, currency.Amount) error
+	Transfer(context.Context, string, string, currency.Amount) error
+	CreateRule(context.Context, investor.Rule) (investor.Rule, error)
+	ListRules(context.Context, string) ([]investor.Rule, error)
+	ListRuleBids(context.Context, string) ([]investor.RuleBid, error)
+	RequestDeposit(context.Context, string, currency.Amount, string, string) (investor.Payment, error)
+	RequestWithdrawal(context.Context, string, currency.Amount, string, string) (investor.Payment, error)
+	ListPayments(context.Context, string) ([]investor.Payment, error)
+	SettlePayment(context.Context, string, string, bool) (investor.Payment, error)
 }
 
 func (s *Server) investorRoutes(g *echo.Group) {
 	g.POST("", s.CreateInvestor)
 	g.GET("", s.ListInvestors)
+	g.GET("/:id", s.RetrieveInvestor)
+	g.POST("/:id/rules", s.CreateRule)
+	g.GET("/:id/rules", s.ListRules)
+	g.POST("/:id/deposits", s.CreateDeposit)
+	g.POST("/:id/withdrawals", s.CreateWithdrawal)
+	g.GET("/:id/payments", s.ListPayments)
+	g.POST("/:id/payments/:paymentId/settlement", s.SettlePayment)
 }
 
 // CreateInvestor creates a new investor
@@ -47,12 +74,19 @@ func (s *Server) investorRoutes(g *echo.Group) {
 // @Tags         investor
 // @Accept       json
 // @Produce      json
+// @Security     Bearer
 // @Param request body CreateInvestorRequest true "Issuer request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
 // @Success      201  {object}  InvestorResponse
-// @Failure      400  {object}  HTTPError
-// @Failure      500  {object}  HTTPError
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /investor [post]
 func (s *Server) CreateInvestor(c echo.Context) error {
+	if err := s.authorize(c, operate); err != nil {
+		return errHandler(err, c)
+	}
+
 	var req CreateInvestorRequest
 	if err := c.Bind(&req); err != nil {
 		return errBadRequest(err, c)
@@ -82,11 +116,17 @@ func (s *Server) CreateInvestor(c echo.Context) error {
 // @Tags         investor
 // @Accept       json
 // @Produce      json
+// @Security     Bearer
 // @Param ids query []string false "list of comma separated ids for filtering"
 // @Success      200  {array}   InvestorResponse
-// @Failure      500  {object}  HTTPError
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /investor [get]
 func (s *Server) ListInvestors(c echo.Context) error {
+	if err := s.authorize(c, audit); err != nil {
+		return errHandler(err, c)
+	}
+
 	param := c.QueryParam("ids")
 	var ids []string
 	if param != "" {
@@ -110,3 +150,62 @@ func (s *Server) ListInvestors(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, res)
 }
+
+// RetrieveInvestor retrieves an investor with its portfolio
+// @Summary      Get investor
+// @Description  Retrieve an investor by ID with its active bids, funded positions, committed capital and returns
+// @Tags         investor
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Investor id"
+// @Success      200  {object}  InvestorResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /investor/:id [get]
+func (s *Server) RetrieveInvestor(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, viewInvestor(id)); err != nil {
+		return errHandler(err, c)
+	}
+
+	ctx := c.Request().Context()
+	inv, err := s.investorService.GetInvestor(ctx, id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	positions, err := s.invoiceService.GetPositions(ctx, id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	sales, err := s.invoiceService.GetSales(ctx, id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	issuerIDs := make([]string, 0, len(positions))
+	for _, p := range positions {
+		issuerIDs = append(issuerIDs, p.Invoice.IssuerID)
+	}
+
+	issuers, err := s.issuerService.ListIssuers(ctx, issuerIDs)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	bids, portfolio := buildPortfolio(positions, sales, issuers, time.Now())
+
+	return c.JSON(http.StatusOK, InvestorResponse{
+		ID:        inv.ID,
+		FullName:  inv.FullName,
+		Balance:   fmtBalance(inv.Balance),
+		Bids:      bids,
+		Portfolio: &portfolio,
+	})
+}
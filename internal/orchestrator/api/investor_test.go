@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/issuer"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetrieveInvestor(t *testing.T) {
+	Convey("RetrieveInvestor", t, func() {
+		amount := func(n, code string) currency.Amount {
+			a, err := currency.NewAmount(n, code)
+			So(err, ShouldBeNil)
+			return a
+		}
+
+		due := time.Now().AddDate(0, 0, 30)
+		open := invoice.Invoice{ID: "open", IssuerID: "iss1", Price: amount("900", "EUR"), FaceValue: amount("1000", "EUR"), DueDate: due, Status: invoice.OPEN}
+		traded := invoice.Invoice{ID: "traded", IssuerID: "iss2", Price: amount("90", "EUR"), FaceValue: amount("100", "EUR"), DueDate: due, Status: invoice.TRADED}
+
+		invstSvc := &mockInvestorService{
+			getInvestorFunc: func(_ context.Context, id string) (investor.Investor, error) {
+				return investor.Investor{ID: id, FullName: "manu", Balance: amount("10", "EUR")}, nil
+			},
+		}
+		invSvc := &mockInvoiceService{
+			getPositionsFunc: func(_ context.Context, id string) ([]invoice.Position, error) {
+				So(id, ShouldEqual, "invstID")
+				return []invoice.Position{
+					{Bid: invoice.Bid{ID: "bid1", InvoiceID: "open", Amount: amount("300", "EUR")}, Invoice: open, Cost: amount("300", "EUR")},
+					// bought in the market for more than the bid amount
+					{Bid: invoice.Bid{ID: "bid2", InvoiceID: "traded", Amount: amount("90", "EUR")}, Invoice: traded, Cost: amount("95", "EUR")},
+				}, nil
+			},
+			getSalesFunc: func(_ context.Context, id string) ([]invoice.Sale, error) {
+				return []invoice.Sale{
+					{Listing: invoice.Listing{ID: "l1", Price: amount("48", "EUR"), Status: invoice.SOLD}, Cost: amount("45", "EUR")},
+				}, nil
+			},
+		}
+		issSvc := &mockIssuerService{
+			listIssuersFunc: func(_ context.Context, ids []string) (map[string]issuer.Issuer, error) {
+				return map[string]issuer.Issuer{"iss1": {ID: "iss1", FullName: "first"}, "iss2": {ID: "iss2", FullName: "second"}}, nil
+			},
+		}
+		srv := New(0, invSvc, invstSvc, issSvc, nil, nil, nil)
+
+		serve := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			c := srv.e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("invstID")
+			So(srv.RetrieveInvestor(c), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when the investor is not found", func() {
+			invstSvc.getInvestorFunc = func(context.Context, string) (investor.Investor, error) {
+				return investor.Investor{}, investor.ErrNotFound
+			}
+
+			Convey("return not found", func() {
+				So(serve().Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("when the positions can't be retrieved", func() {
+			invSvc.getPositionsFunc = func(context.Context, string) ([]invoice.Position, error) {
+				return nil, errors.New("db down")
+			}
+
+			Convey("return internal error", func() {
+				So(serve().Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+
+		Convey("when everything succeeds", func() {
+			rec := serve()
+			So(rec.Code, ShouldEqual, http.StatusOK)
+
+			var res InvestorResponse
+			So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+
+			Convey("split bids on funding invoices from traded positions", func() {
+				So(res.Balance, ShouldEqual, currFmt.Format(amount("10", "EUR")))
+				So(res.Bids, ShouldHaveLength, 1)
+				So(res.Bids[0].ID, ShouldEqual, "bid1")
+				So(res.Bids[0].Invoice.Issuer.FullName, ShouldEqual, "first")
+
+				So(res.Portfolio, ShouldNotBeNil)
+				So(res.Portfolio.Positions, ShouldHaveLength, 1)
+				So(res.Portfolio.Positions[0].ID, ShouldEqual, "bid2")
+				So(res.Portfolio.Positions[0].Cost, ShouldEqual, currFmt.Format(amount("95", "EUR")))
+			})
+
+			Convey("aggregate committed capital and returns by cost", func() {
+				So(res.Portfolio.CommittedByCurrency, ShouldResemble, map[string]string{"EUR": currFmt.Format(amount("395", "EUR"))})
+				So(res.Portfolio.CommittedByIssuer, ShouldHaveLength, 2)
+				So(res.Portfolio.CommittedByIssuer[1].Issuer.FullName, ShouldEqual, "second")
+				So(res.Portfolio.CommittedByIssuer[1].Committed, ShouldResemble, map[string]string{"EUR": currFmt.Format(amount("95", "EUR"))})
+
+				// a 90 bid on a 90/100 invoice pays out 100, bought at 95
+				So(res.Portfolio.UnrealisedReturns, ShouldResemble, map[string]string{"EUR": currFmt.Format(amount("5", "EUR"))})
+				So(res.Portfolio.RealisedReturns, ShouldResemble, map[string]string{"EUR": currFmt.Format(amount("3", "EUR"))})
+			})
+		})
+	})
+}
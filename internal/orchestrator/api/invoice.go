@@ -1,23 +1,47 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bojanz/currency"
 	"github.com/labstack/echo/v4"
 	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
+	"github.com/nerock/invoicebidder/internal/issuer"
 )
 
 type InvoiceResponse struct {
-	ID     string                `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
-	Price  string                `json:"price" example:"1 230,45 €"`
-	Status string                `json:"status" example:"open"`
-	Issuer InvoiceIssuerResponse `json:"issuer"`
-	Bids   []InvoiceBidResponse  `json:"bids,omitempty"`
+	ID        string                 `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Price     string                 `json:"price" example:"1 230,45 €"`
+	FaceValue string                 `json:"faceValue" example:"1 300,00 €"`
+	DueDate   string                 `json:"dueDate" example:"2023-12-31"`
+	Status    string                 `json:"status" example:"open"`
+	Pricing   InvoicePricingResponse `json:"pricing"`
+	Issuer    InvoiceIssuerResponse  `json:"issuer"`
+	Bids      []InvoiceBidResponse   `json:"bids,omitempty"`
+	EInvoice  *EInvoiceResponse      `json:"eInvoice,omitempty"`
+}
+
+type InvoicePageResponse struct {
+	Invoices   []InvoiceResponse `json:"invoices"`
+	NextCursor string            `json:"nextCursor,omitempty" example:"eyJzIjoiZHVlX2RhdGUiLCJ2IjoiMjAyMy0xMi0zMSIsImlkIjoiMzQzYWJkN2EifQ"`
+}
+
+type InvoicePricingResponse struct {
+	Discount       string  `json:"discount" example:"69,55 €"`
+	DiscountRate   float64 `json:"discountRate" example:"0.0535"`
+	Yield          float64 `json:"yield" example:"0.0565"`
+	APR            float64 `json:"apr" example:"0.2292"`
+	DaysToMaturity int     `json:"daysToMaturity" example:"90"`
 }
 
 type InvoiceIssuerResponse struct {
@@ -26,11 +50,19 @@ type InvoiceIssuerResponse struct {
 }
 
 type InvoiceBidResponse struct {
-	ID       string `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
-	Amount   string `json:"string" example:"1 230,45 €"`
+	ID       string              `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Amount   string              `json:"string" example:"1 230,45 €"`
+	Pricing  *BidPricingResponse `json:"pricing,omitempty"`
 	Investor BidInvestorResponse
 }
 
+type BidPricingResponse struct {
+	ExpectedPayout string  `json:"expectedPayout" example:"1 300,00 €"`
+	ExpectedReturn string  `json:"expectedReturn" example:"69,55 €"`
+	Yield          float64 `json:"yield" example:"0.0565"`
+	APR            float64 `json:"apr" example:"0.2292"`
+}
+
 type BidInvestorResponse struct {
 	ID       string `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
 	FullName string `json:"fullName" example:"Manuel Adalid"`
@@ -49,55 +81,94 @@ type InvoiceService interface {
 	GetInvoice(context.Context, string) (invoice.Invoice, error)
 	GetRemainingPrice(context.Context, string) (currency.Amount, error)
 	GetByIssuerID(context.Context, string) ([]invoice.Invoice, error)
-	CreateInvoice(context.Context, string, currency.Amount, io.Reader) (invoice.Invoice, error)
+	GetIssuerDashboard(context.Context, string) (invoice.Dashboard, error)
+	ListInvoices(context.Context, invoice.Query) ([]invoice.Invoice, *invoice.Cursor, error)
+	CreateInvoice(context.Context, string, currency.Amount, currency.Amount, time.Time, io.Reader) (invoice.Invoice, error)
 	PlaceBid(context.Context, string, string, currency.Amount) (string, error)
 	ApproveTrade(context.Context, string, bool) ([]string, error)
+	GetDocument(context.Context, string) (invoice.Invoice, invoice.Document, error)
+	GetEInvoice(context.Context, string) (einvoice.Document, error)
+	VerifyFile(context.Context, string) (invoice.Integrity, error)
+	GetExtraction(context.Context, string) (invoice.Extraction, error)
+	ListFlaggedExtractions(context.Context) ([]invoice.Extraction, error)
+	ReviewExtraction(context.Context, string) error
+
+	ListPosition(context.Context, string, string, currency.Amount) (invoice.Listing, error)
+	GetListing(context.Context, string) (invoice.Listing, error)
+	ListOpenListings(context.Context) ([]invoice.Listing, error)
+	ReserveListing(context.Context, string, string) (invoice.Listing, error)
+	ReleaseListing(context.Context, string, string) error
+	BuyListing(context.Context, string, string) (invoice.Listing, error)
+	WithdrawListing(context.Context, string, string) error
+
+	GetPositions(context.Context, string) ([]invoice.Position, error)
+	GetSales(context.Context, string) ([]invoice.Sale, error)
 }
 
 func (s *Server) invoiceRoutes(g *echo.Group) {
 	g.POST("", s.CreateInvoice)
+	g.GET("", s.ListInvoices)
+	g.POST("/bulk", s.CreateBulkUpload)
+	g.GET("/bulk/:id", s.RetrieveBulkUpload)
+	if s.integrityChecker != nil {
+		g.POST("/integrity", s.StartIntegrityCheck)
+		g.GET("/integrity/:id", s.RetrieveIntegrityCheck)
+	}
+	g.GET("/review", s.ListFlaggedInvoices)
 	g.GET("/:id", s.RetrieveInvoice)
 	g.POST("/:id/bid", s.Bid)
 	g.POST("/:id/trade", s.ApproveTrade)
+	g.GET("/:id/settlement", s.RetrieveSettlement)
+	g.GET("/:id/document", s.RetrieveDocument)
+	g.GET("/:id/document/url", s.RetrieveDocumentURL)
+	g.GET("/:id/einvoice", s.RetrieveEInvoice)
+	g.GET("/:id/integrity", s.VerifyDocument)
+	g.GET("/:id/extraction", s.RetrieveExtraction)
+	g.POST("/:id/extraction/review", s.ReviewExtraction)
 }
 
 // CreateInvoice creates a new invoice
 // @Summary      New invoice
-// @Description  Create a new invoice to sell
+// @Description  Create a new invoice to sell. UBL 2.1 xml and Factur-X pdfs fill the currency, face value and due date from the e-invoice, and the price defaults to its total.
 // @Tags         invoice
 // @Accept       x-www-form-urlencoded
 // @Produce      json
-// @Param issuer_id formData string true "ID of publishing issuer"
-// @Param price formData string true "Price string"
-// @Param currency formData string true "Currency code"
-// @Param invoice formData file true "Invoice file"
+// @Security     Bearer
+// @Param issuer_id formData string true "ID of publishing issuer, which must be the authenticated one"
+// @Param price formData string false "Price string, required unless the file is an e-invoice"
+// @Param currency formData string false "Currency code, required with a price or face value"
+// @Param face_value formData string false "Face value string, defaults to the price"
+// @Param due_date formData string false "Due date (YYYY-MM-DD), required unless the file is an e-invoice"
+// @Param invoice formData file true "Invoice file, a pdf, a UBL 2.1 or CII xml e-invoice, or an image if allowed"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
 // @Success      201  {object}   InvoiceResponse
-// @Failure      400  {object}  HTTPError
-// @Failure      404  {object}  HTTPError
-// @Failure      500  {object}  HTTPError
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      413  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /invoice [post]
 func (s *Server) CreateInvoice(c echo.Context) error {
-	issID := c.FormValue("issuer_id")
-	if issID == "" {
-		return errBadRequest(fmt.Errorf("issuer id cannot be empty"), c)
+	if s.maxUpload > 0 {
+		c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, s.maxUpload+formOverhead)
 	}
-	price := c.FormValue("price")
-	if issID == "" {
-		return errBadRequest(fmt.Errorf("price cannot be empty"), c)
+	if _, err := c.MultipartForm(); err != nil {
+		return errUpload(fmt.Errorf("could not read form: %w", err), c)
 	}
-	curr := c.FormValue("currency")
+
+	issID := c.FormValue("issuer_id")
 	if issID == "" {
-		return errBadRequest(fmt.Errorf("currency cannot be empty"), c)
+		return errBadRequest(fmt.Errorf("issuer id cannot be empty"), c)
 	}
-
-	amount, err := currency.NewAmount(price, curr)
-	if err != nil {
-		return errBadRequest(err, c)
+	if err := s.authorize(c, asIssuer(issID)); err != nil {
+		return errHandler(err, c)
 	}
 
 	formFile, err := c.FormFile("invoice")
 	if err != nil {
-		return errBadRequest(fmt.Errorf("could not read invoice file: %w", err), c)
+		return errUpload(fmt.Errorf("could not read invoice file: %w", err), c)
 	}
 	file, err := formFile.Open()
 	if err != nil {
@@ -105,31 +176,254 @@ func (s *Server) CreateInvoice(c echo.Context) error {
 	}
 	defer func() {
 		if err := file.Close(); err != nil {
-			s.e.Logger.Errorf("could not close request file: %w", err)
+			s.e.Logger.Errorf("could not close request file: %v", err)
 		}
 	}()
 
+	data, err := s.readUpload(file)
+	if err != nil {
+		return errUpload(err, c)
+	}
+
+	amount, faceValue, dueDate, err := invoiceForm(c)
+	if err != nil {
+		return errBadRequest(err, c)
+	}
+
 	ctx := c.Request().Context()
 	iss, err := s.issuerService.GetIssuer(ctx, issID)
 	if err != nil {
 		return errHandler(err, c)
 	}
 
-	inv, err := s.invoiceService.CreateInvoice(ctx, iss.ID, amount, file)
+	inv, err := s.invoiceService.CreateInvoice(ctx, iss.ID, amount, faceValue, dueDate, bytes.NewReader(data))
 	if err != nil {
 		return errHandler(err, c)
 	}
 
-	return c.JSON(http.StatusCreated, InvoiceResponse{
-		ID:     inv.ID,
-		Price:  currFmt.Format(inv.Price),
-		Status: string(inv.Status),
-		Issuer: InvoiceIssuerResponse{
-			ID:       iss.ID,
-			FullName: iss.FullName,
-		},
-		Bids: nil,
-	})
+	s.broker.SendInvoiceCreatedEvent(inv.ID)
+
+	res := invoiceResponse(inv, iss, time.Now())
+	switch einv, err := s.invoiceService.GetEInvoice(ctx, inv.ID); {
+	case err == nil:
+		res.EInvoice = eInvoiceResponse(einv)
+	case !errors.Is(err, invoice.ErrNotFound):
+		s.e.Logger.Errorf("could not retrieve e-invoice of invoice %s: %v", inv.ID, err)
+	}
+
+	return c.JSON(http.StatusCreated, res)
+}
+
+// formOverhead is how much the multipart framing and the other fields of an
+// upload may add to the file size
+const formOverhead = 1 << 20
+
+// WithUploadLimit rejects invoice uploads larger than maxSize bytes before
+// reading them whole
+func (s *Server) WithUploadLimit(maxSize int64) *Server {
+	s.maxUpload = maxSize
+
+	return s
+}
+
+func (s *Server) readUpload(r io.Reader) ([]byte, error) {
+	if s.maxUpload <= 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not read invoice file: %w", err)
+		}
+
+		return data, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, s.maxUpload+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read invoice file: %w", err)
+	}
+	if int64(len(data)) > s.maxUpload {
+		return nil, fmt.Errorf("%w: maximum size is %d bytes", invoice.ErrFileTooLarge, s.maxUpload)
+	}
+
+	return data, nil
+}
+
+// errUpload reports an upload over the body limit as too large and any other
+// failure to read it as a bad request
+func errUpload(err error, c echo.Context) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return errHandler(fmt.Errorf("%w: maximum request size is %d bytes", invoice.ErrFileTooLarge, maxErr.Limit), c)
+	}
+	if errors.Is(err, invoice.ErrFileTooLarge) {
+		return errHandler(err, c)
+	}
+
+	return errBadRequest(err, c)
+}
+
+// invoiceForm reads the declared values of an upload. The ones left empty are
+// zero, for the invoice service to take them from an e-invoice file. A
+// currency without amounts is kept in a zero price so it is still checked.
+func invoiceForm(c echo.Context) (currency.Amount, currency.Amount, time.Time, error) {
+	var price, faceValue currency.Amount
+	var dueDate time.Time
+
+	curr, p, fv := c.FormValue("currency"), c.FormValue("price"), c.FormValue("face_value")
+	if curr == "" {
+		if p != "" || fv != "" {
+			return price, faceValue, dueDate, errors.New("currency cannot be empty")
+		}
+	} else {
+		if p == "" {
+			p = "0"
+		}
+
+		var err error
+		if price, err = currency.NewAmount(p, curr); err != nil {
+			return price, faceValue, dueDate, err
+		}
+	}
+
+	if fv != "" {
+		var err error
+		if faceValue, err = currency.NewAmount(fv, curr); err != nil {
+			return price, faceValue, dueDate, fmt.Errorf("invalid face value: %w", err)
+		}
+	}
+
+	if d := c.FormValue("due_date"); d != "" {
+		var err error
+		if dueDate, err = time.Parse(dateLayout, d); err != nil {
+			return price, faceValue, dueDate, fmt.Errorf("invalid due date: %w", err)
+		}
+	}
+
+	return price, faceValue, dueDate, nil
+}
+
+// ListInvoices retrieves invoices
+// @Summary      List invoices
+// @Description  Browse the invoice marketplace with filters, sorting and cursor based pagination
+// @Tags         invoice
+// @Produce      json
+// @Security     Bearer
+// @Param status query string false "Invoice status" Enums(open, locked, traded)
+// @Param currency query string false "Currency code"
+// @Param min_price query string false "Minimum price"
+// @Param max_price query string false "Maximum price"
+// @Param issuer_id query string false "ID of the issuer"
+// @Param due_after query string false "Earliest due date (YYYY-MM-DD)"
+// @Param due_before query string false "Latest due date (YYYY-MM-DD)"
+// @Param min_apr query number false "Minimum APR as a fraction"
+// @Param max_apr query number false "Maximum APR as a fraction"
+// @Param sort query string false "Sort field, prefix with - for descending order" Enums(due_date, -due_date, price, -price, apr, -apr)
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param limit query int false "Page size, up to 100"
+// @Success      200  {object}  InvoicePageResponse
+// @Failure      400  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice [get]
+func (s *Server) ListInvoices(c echo.Context) error {
+	q, err := invoiceQuery(c)
+	if err != nil {
+		return errBadRequest(err, c)
+	}
+
+	ctx := c.Request().Context()
+	invoices, next, err := s.invoiceService.ListInvoices(ctx, q)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	issuerIDs := make([]string, 0, len(invoices))
+	for _, inv := range invoices {
+		issuerIDs = append(issuerIDs, inv.IssuerID)
+	}
+
+	issuers, err := s.issuerService.ListIssuers(ctx, issuerIDs)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	now := time.Now()
+	res := InvoicePageResponse{Invoices: make([]InvoiceResponse, 0, len(invoices))}
+	for _, inv := range invoices {
+		res.Invoices = append(res.Invoices, invoiceResponse(inv, issuers[inv.IssuerID], now))
+	}
+
+	if next != nil {
+		res.NextCursor = next.String()
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func invoiceQuery(c echo.Context) (invoice.Query, error) {
+	q := invoice.Query{
+		Status:   invoice.Status(c.QueryParam("status")),
+		Currency: c.QueryParam("currency"),
+		MinPrice: c.QueryParam("min_price"),
+		MaxPrice: c.QueryParam("max_price"),
+		IssuerID: c.QueryParam("issuer_id"),
+		Sort:     invoice.SortDueDate,
+	}
+
+	switch q.Status {
+	case "", invoice.OPEN, invoice.LOCKED, invoice.TRADED:
+	default:
+		return q, fmt.Errorf("invalid status %q", q.Status)
+	}
+
+	for _, price := range []string{q.MinPrice, q.MaxPrice} {
+		if price != "" && !invoice.ValidDecimal(price) {
+			return q, fmt.Errorf("invalid price %q", price)
+		}
+	}
+
+	var err error
+	if q.DueAfter, err = dateParam(c, "due_after"); err != nil {
+		return q, err
+	}
+	if q.DueBefore, err = dateParam(c, "due_before"); err != nil {
+		return q, err
+	}
+	if q.MinAPR, err = floatParam(c, "min_apr"); err != nil {
+		return q, err
+	}
+	if q.MaxAPR, err = floatParam(c, "max_apr"); err != nil {
+		return q, err
+	}
+
+	if sort := c.QueryParam("sort"); sort != "" {
+		q.Desc = strings.HasPrefix(sort, "-")
+		q.Sort = invoice.SortField(strings.TrimPrefix(sort, "-"))
+
+		switch q.Sort {
+		case invoice.SortDueDate, invoice.SortPrice, invoice.SortAPR:
+		default:
+			return q, fmt.Errorf("invalid sort %q", sort)
+		}
+	}
+
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		after, err := invoice.ParseCursor(cursor)
+		if err != nil {
+			return q, err
+		}
+
+		if after.Sort != q.Sort || after.Desc != q.Desc {
+			return q, errors.New("cursor does not match the requested sort")
+		}
+		q.After = &after
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 1 || q.Limit > invoice.MaxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", invoice.MaxLimit)
+		}
+	}
+
+	return q, nil
 }
 
 // RetrieveInvoice retrieves an invoice by ID
@@ -137,12 +431,13 @@ func (s *Server) CreateInvoice(c echo.Context) error {
 // @Description  Retrieve an invoice by ID
 // @Tags         invoice
 // @Produce      json
+// @Security     Bearer
 // @Param id path string true "Invoice id"
 // @Success      200  {object}  InvoiceResponse
-// @Failure      400  {object}  HTTPError
-// @Failure      404  {object}  HTTPError
-// @Failure      500  {object}  HTTPError
-// @Router       /invoice [get]
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /invoice/:id [get]
 func (s *Server) RetrieveInvoice(c echo.Context) error {
 	id := c.Param("id")
 	if id == "" {
@@ -160,15 +455,8 @@ func (s *Server) RetrieveInvoice(c echo.Context) error {
 		return errHandler(err, c)
 	}
 
-	res := InvoiceResponse{
-		ID:     inv.ID,
-		Price:  currFmt.Format(inv.Price),
-		Status: string(inv.Status),
-		Issuer: InvoiceIssuerResponse{
-			ID:       iss.ID,
-			FullName: iss.FullName,
-		},
-	}
+	now := time.Now()
+	res := invoiceResponse(inv, iss, now)
 
 	if len(inv.Bids) > 0 {
 		res.Bids = make([]InvoiceBidResponse, 0, len(inv.Bids))
@@ -184,17 +472,18 @@ func (s *Server) RetrieveInvoice(c echo.Context) error {
 		}
 
 		for _, b := range inv.Bids {
-			inv, ok := investors[b.InvestorID]
+			investor, ok := investors[b.InvestorID]
 			if !ok {
 				return fmt.Errorf("missing investor info: %w", ErrNotFound)
 			}
 
 			res.Bids = append(res.Bids, InvoiceBidResponse{
-				ID:     b.ID,
-				Amount: currFmt.Format(b.Amount),
+				ID:      b.ID,
+				Amount:  currFmt.Format(b.Amount),
+				Pricing: bidPricingResponse(inv, b, now),
 				Investor: BidInvestorResponse{
-					ID:       inv.ID,
-					FullName: inv.FullName,
+					ID:       investor.ID,
+					FullName: investor.FullName,
 				},
 			})
 		}
@@ -205,16 +494,21 @@ func (s *Server) RetrieveInvoice(c echo.Context) error {
 
 // Bid places a bid into an invoice
 // @Summary      Bid on invoice
-// @Description  Places a bid in an invoice
+// @Description  Places a bid in an invoice as the investor of the request, which defaults to the authenticated one
 // @Tags         invoice
 // @Accept       json
 // @Produce      json
+// @Security     Bearer
 // @Param id path string true "Invoice id"
 // @Param request body BidRequest true "Bid request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
 // @Success      201  {object}  InvoiceBidResponse
-// @Failure      400  {object}  HTTPError
-// @Failure      404  {object}  HTTPError
-// @Failure      500  {object}  HTTPError
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /invoice/:id/bid [post]
 func (s *Server) Bid(c echo.Context) error {
 	var req BidRequest
@@ -231,8 +525,13 @@ func (s *Server) Bid(c echo.Context) error {
 		return errBadRequest(errors.New("id cannot be empty"), c)
 	}
 
+	investorID := actingInvestor(c, req.InvestorID)
+	if err := s.authorize(c, asInvestor(investorID)); err != nil {
+		return errHandler(err, c)
+	}
+
 	ctx := c.Request().Context()
-	investor, err := s.investorService.GetInvestor(ctx, req.InvestorID)
+	investor, err := s.investorService.GetInvestor(ctx, investorID)
 	if err != nil {
 		return errHandler(err, c)
 	}
@@ -258,9 +557,15 @@ func (s *Server) Bid(c echo.Context) error {
 		return errHandler(err, c)
 	}
 
+	inv, err := s.invoiceService.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
 	return c.JSON(http.StatusCreated, InvoiceBidResponse{
-		ID:     id,
-		Amount: currFmt.Format(bidAmount),
+		ID:      id,
+		Amount:  currFmt.Format(bidAmount),
+		Pricing: bidPricingResponse(inv, invoice.Bid{Amount: bidAmount}, time.Now()),
 		Investor: BidInvestorResponse{
 			ID:       investor.ID,
 			FullName: investor.FullName,
@@ -270,14 +575,18 @@ func (s *Server) Bid(c echo.Context) error {
 
 // ApproveTrade approves or cancels a trade in an invoice
 // @Summary      Approve invoice trade
-// @Description  Approves or cancels an invoice trade
+// @Description  Approves or cancels an invoice trade, only allowed for its issuer
 // @Tags         invoice
 // @Accept       json
+// @Security     Bearer
 // @Param id path string true "Invoice id"
 // @Param request body ApproveTradeRequest true "Trade request"
-// @Failure      400  {object}  HTTPError
-// @Failure      404  {object}  HTTPError
-// @Failure      500  {object}  HTTPError
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /invoice/:id/trade [post]
 func (s *Server) ApproveTrade(c echo.Context) error {
 	invoiceID := c.Param("id")
@@ -290,6 +599,10 @@ func (s *Server) ApproveTrade(c echo.Context) error {
 		return errBadRequest(err, c)
 	}
 
+	if err := s.authorizeInvoice(c, invoiceID, asIssuer); err != nil {
+		return errHandler(err, c)
+	}
+
 	ctx := c.Request().Context()
 	bids, err := s.invoiceService.ApproveTrade(ctx, invoiceID, req.Approved)
 	if err != nil {
@@ -301,13 +614,80 @@ func (s *Server) ApproveTrade(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+func invoiceResponse(inv invoice.Invoice, iss issuer.Issuer, now time.Time) InvoiceResponse {
+	pricing := inv.Pricing(now)
+
+	return InvoiceResponse{
+		ID:        inv.ID,
+		Price:     currFmt.Format(inv.Price),
+		FaceValue: currFmt.Format(inv.FaceValue),
+		DueDate:   inv.DueDate.Format(dateLayout),
+		Status:    string(inv.Status),
+		Pricing: InvoicePricingResponse{
+			Discount:       currFmt.Format(pricing.Discount),
+			DiscountRate:   roundRate(pricing.DiscountRate),
+			Yield:          roundRate(pricing.Yield),
+			APR:            roundRate(pricing.APR),
+			DaysToMaturity: pricing.DaysToMaturity,
+		},
+		Issuer: InvoiceIssuerResponse{
+			ID:       iss.ID,
+			FullName: iss.FullName,
+		},
+	}
+}
+
+func bidPricingResponse(inv invoice.Invoice, b invoice.Bid, now time.Time) *BidPricingResponse {
+	pricing := inv.BidPricing(b, now)
+
+	return &BidPricingResponse{
+		ExpectedPayout: currFmt.Format(pricing.ExpectedPayout),
+		ExpectedReturn: currFmt.Format(pricing.ExpectedReturn),
+		Yield:          roundRate(pricing.Yield),
+		APR:            roundRate(pricing.APR),
+	}
+}
+
+func dateParam(c echo.Context, name string) (time.Time, error) {
+	param := c.QueryParam(name)
+	if param == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(dateLayout, param)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return t, nil
+}
+
+func floatParam(c echo.Context, name string) (*float64, error) {
+	param := c.QueryParam(name)
+	if param == "" {
+		return nil, nil
+	}
+
+	// plain decimals only, ParseFloat also takes hex, exponents, Inf and NaN
+	if !invoice.ValidDecimal(param) {
+		return nil, fmt.Errorf("invalid %s %q", name, param)
+	}
+
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return &f, nil
+}
+
+func roundRate(rate float64) float64 {
+	return math.Round(rate*10000) / 10000
+}
+
 func compareAmounts(a, b currency.Amount) (int, error) {
 	if a.CurrencyCode() != b.CurrencyCode() {
-		var err error
-		b, err = b.Convert(b.CurrencyCode(), "1")
-		if err != nil {
-			return 0, err
-		}
+		return 0, fmt.Errorf("%w: amount must be in %s", invoice.ErrCurrencyMismatch, a.CurrencyCode())
 	}
 
 	cmp, err := a.Cmp(b)
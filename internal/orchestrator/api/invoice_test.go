@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/issuer"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestListInvoices(t *testing.T) {
+	Convey("ListInvoices", t, func() {
+		issSvc := &mockIssuerService{}
+		invSvc := &mockInvoiceService{}
+		srv := New(0, invSvc, nil, issSvc, nil, nil, nil)
+		rec := httptest.NewRecorder()
+
+		Convey("when the query is invalid", func() {
+			for _, query := range []string{
+				"status=closed",
+				"min_price=abc",
+				"min_price=0x10",
+				"max_price=1e3",
+				"max_price=NaN",
+				"min_apr=Inf",
+				"max_apr=0x1p-2",
+				"due_after=31/12/2023",
+				"min_apr=high",
+				"sort=name",
+				"cursor=invalid",
+				"limit=1000",
+				"sort=price&cursor=" + invoice.Cursor{Sort: invoice.SortAPR, Value: "0.1", ID: "id"}.String(),
+				"cursor=" + invoice.Cursor{Sort: invoice.SortDueDate, Value: "yesterday", ID: "id"}.String(),
+				"sort=price&cursor=" + invoice.Cursor{Sort: invoice.SortPrice, Value: "1; DROP TABLE invoices", ID: "id"}.String(),
+				"sort=apr&cursor=" + invoice.Cursor{Sort: invoice.SortAPR, Value: "NaN", ID: "id"}.String(),
+			} {
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/invoice?"+query, nil)
+
+				Convey("return bad request code and an error for "+query, func() {
+					err := srv.ListInvoices(srv.e.NewContext(req, rec))
+					So(err, ShouldBeNil)
+					So(rec.Code, ShouldEqual, http.StatusBadRequest)
+				})
+			}
+		})
+
+		Convey("when the query is valid", func() {
+			cursor := invoice.Cursor{Sort: invoice.SortPrice, Desc: true, Value: "1000", ID: "prevID"}
+			req := httptest.NewRequest(http.MethodGet, "/invoice?status=open&currency=EUR&min_price=100&due_before=2023-12-31&sort=-price&limit=1&cursor="+cursor.String(), nil)
+
+			Convey("when the invoice service fails", func() {
+				invSvc.listInvoicesFunc = func(_ context.Context, _ invoice.Query) ([]invoice.Invoice, *invoice.Cursor, error) {
+					return nil, nil, errors.New("internal error")
+				}
+
+				Convey("return internal error code and an error", func() {
+					err := srv.ListInvoices(srv.e.NewContext(req, rec))
+					So(err, ShouldBeNil)
+					So(rec.Code, ShouldEqual, http.StatusInternalServerError)
+				})
+			})
+
+			Convey("when the invoice service succeeds", func() {
+				price, _ := currency.NewAmount("950", "EUR")
+				inv := invoice.Invoice{
+					ID:        "invID",
+					IssuerID:  "issID",
+					Price:     price,
+					FaceValue: price,
+					DueDate:   time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+					Status:    invoice.OPEN,
+				}
+				next := &invoice.Cursor{Sort: invoice.SortPrice, Desc: true, Value: "950", ID: inv.ID}
+				invSvc.listInvoicesFunc = func(_ context.Context, q invoice.Query) ([]invoice.Invoice, *invoice.Cursor, error) {
+					So(q.Status, ShouldEqual, invoice.OPEN)
+					So(q.Currency, ShouldEqual, "EUR")
+					So(q.MinPrice, ShouldEqual, "100")
+					So(q.DueBefore, ShouldEqual, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+					So(q.Sort, ShouldEqual, invoice.SortPrice)
+					So(q.Desc, ShouldBeTrue)
+					So(*q.After, ShouldResemble, cursor)
+					So(q.Limit, ShouldEqual, 1)
+					return []invoice.Invoice{inv}, next, nil
+				}
+				issSvc.listIssuersFunc = func(_ context.Context, ids []string) (map[string]issuer.Issuer, error) {
+					So(ids, ShouldResemble, []string{"issID"})
+					return map[string]issuer.Issuer{"issID": {ID: "issID", FullName: "manu"}}, nil
+				}
+
+				Convey("return ok code and the page with the next cursor", func() {
+					err := srv.ListInvoices(srv.e.NewContext(req, rec))
+					So(err, ShouldBeNil)
+					So(rec.Code, ShouldEqual, http.StatusOK)
+
+					var res InvoicePageResponse
+					So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+					So(res.Invoices, ShouldHaveLength, 1)
+					So(res.Invoices[0].ID, ShouldEqual, inv.ID)
+					So(res.Invoices[0].Issuer.FullName, ShouldEqual, "manu")
+					So(res.NextCursor, ShouldEqual, next.String())
+				})
+			})
+		})
+	})
+}
+
+func TestInvoiceForm(t *testing.T) {
+	Convey("invoiceForm", t, func() {
+		srv := New(0, nil, nil, nil, nil, nil, nil)
+		form := func(values url.Values) echo.Context {
+			req := httptest.NewRequest(http.MethodPost, "/invoice", strings.NewReader(values.Encode()))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			return srv.e.NewContext(req, httptest.NewRecorder())
+		}
+
+		Convey("parse the declared fields", func() {
+			price, faceValue, dueDate, err := invoiceForm(form(url.Values{"price": {"100"}, "face_value": {"120"}, "currency": {"EUR"}, "due_date": {"2023-12-31"}}))
+			So(err, ShouldBeNil)
+			So(price.String(), ShouldEqual, "100 EUR")
+			So(faceValue.String(), ShouldEqual, "120 EUR")
+			So(dueDate, ShouldEqual, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+		})
+
+		Convey("leave the missing fields empty", func() {
+			price, faceValue, dueDate, err := invoiceForm(form(url.Values{}))
+			So(err, ShouldBeNil)
+			So(price.IsZero(), ShouldBeTrue)
+			So(faceValue.IsZero(), ShouldBeTrue)
+			So(dueDate.IsZero(), ShouldBeTrue)
+		})
+
+		Convey("keep a currency declared without a price", func() {
+			price, _, _, err := invoiceForm(form(url.Values{"currency": {"USD"}}))
+			So(err, ShouldBeNil)
+			So(price.IsZero(), ShouldBeTrue)
+			So(price.CurrencyCode(), ShouldEqual, "USD")
+		})
+
+		Convey("reject invalid fields", func() {
+			for _, values := range []url.Values{
+				{"price": {"100"}},
+				{"face_value": {"100"}},
+				{"price": {"abc"}, "currency": {"EUR"}},
+				{"due_date": {"31/12/2023"}},
+			} {
+				_, _, _, err := invoiceForm(form(values))
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
@@ -3,8 +3,12 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
 
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/invoice"
 	"github.com/nerock/invoicebidder/internal/issuer"
 
 	"github.com/labstack/echo/v4"
@@ -12,11 +16,13 @@ import (
 
 type CreateIssuerRequest struct {
 	FullName string `json:"fullName"`
+	Rating   string `json:"rating" example:"B"`
 }
 
 type IssuerResponse struct {
 	ID       string                  `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
 	FullName string                  `json:"fullName" example:"Manuel Adalid"`
+	Rating   string                  `json:"rating,omitempty" example:"B"`
 	Balance  string                  `json:"balance,omitempty" example:"1 230,45 €"`
 	Invoices []IssuerInvoiceResponse `json:"invoices,omitempty"`
 }
@@ -33,14 +39,54 @@ type IssuerBidResponse struct {
 	Amount string `json:"string" example:"1 230,45 €"`
 }
 
+type IssuerDashboardResponse struct {
+	ID                  string                     `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	FullName            string                     `json:"fullName" example:"Manuel Adalid"`
+	Balance             string                     `json:"balance,omitempty" example:"1 230,45 €"`
+	Pipeline            []PipelineResponse         `json:"pipeline"`
+	AverageFundingHours float64                    `json:"averageFundingHours" example:"36.5"`
+	ApprovalRate        float64                    `json:"approvalRate" example:"0.75"`
+	CashReceived        map[string]string          `json:"cashReceived"`
+	UpcomingMaturities  []DashboardInvoiceResponse `json:"upcomingMaturities"`
+	Invoices            []DashboardInvoiceResponse `json:"invoices"`
+}
+
+type PipelineResponse struct {
+	Status string            `json:"status" example:"open"`
+	Count  int               `json:"count" example:"3"`
+	Value  map[string]string `json:"value"`
+}
+
+type DashboardInvoiceResponse struct {
+	ID             string  `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Price          string  `json:"price" example:"1 230,45 €"`
+	RemainingPrice string  `json:"remainingPrice" example:"230,45 €"`
+	FundedRatio    float64 `json:"fundedRatio" example:"0.8127"`
+	Status         string  `json:"status" example:"open"`
+	DueDate        string  `json:"dueDate" example:"2023-12-31"`
+}
+
 type IssuerService interface {
 	GetIssuer(context.Context, string) (issuer.Issuer, error)
-	CreateIssuer(context.Context, string) (issuer.Issuer, error)
+	ListIssuers(context.Context, []string) (map[string]issuer.Issuer, error)
+	CreateIssuer(context.Context, string, string) (issuer.Issuer, error)
+	AddPayoutAccount(context.Context, string, string, string, bool) (issuer.PayoutAccount, error)
+	ListPayoutAccounts(context.Context, string) ([]issuer.PayoutAccount, error)
+	RequestPayout(context.Context, string, string, currency.Amount) (issuer.Payout, error)
+	ListPayouts(context.Context, string) ([]issuer.Payout, error)
+	SendPayout(context.Context, string, string) (issuer.Payout, error)
+	FailPayout(context.Context, string, string) (issuer.Payout, error)
 }
 
 func (s *Server) issuerRoutes(g *echo.Group) {
 	g.POST("", s.CreateIssuer)
 	g.GET("/:id", s.RetrieveIssuer)
+	g.GET("/:id/dashboard", s.RetrieveIssuerDashboard)
+	g.POST("/:id/payout-accounts", s.CreatePayoutAccount)
+	g.GET("/:id/payout-accounts", s.ListPayoutAccounts)
+	g.POST("/:id/payouts", s.CreatePayout)
+	g.GET("/:id/payouts", s.ListPayouts)
+	g.POST("/:id/payouts/:payoutId/status", s.UpdatePayoutStatus)
 }
 
 // CreateIssuer creates a new issuer
@@ -49,12 +95,20 @@ func (s *Server) issuerRoutes(g *echo.Group) {
 // @Tags         issuer
 // @Accept       json
 // @Produce      json
+// @Security     Bearer
 // @Param request body CreateIssuerRequest true "Issuer request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
 // @Success      201  {object}  IssuerResponse
-// @Failure      400  {object}  HTTPError
-// @Failure      500  {object}  HTTPError
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /issuer [post]
 func (s *Server) CreateIssuer(c echo.Context) error {
+	if err := s.authorize(c, operate); err != nil {
+		return errHandler(err, c)
+	}
+
 	var req CreateIssuerRequest
 	if err := c.Bind(&req); err != nil {
 		return errBadRequest(err, c)
@@ -62,9 +116,12 @@ func (s *Server) CreateIssuer(c echo.Context) error {
 	if req.FullName == "" {
 		return errBadRequest(errors.New("issuer name cannot be empty"), c)
 	}
+	if !issuer.ValidRating(req.Rating) {
+		return errBadRequest(fmt.Errorf("invalid rating %q", req.Rating), c)
+	}
 
 	ctx := c.Request().Context()
-	iss, err := s.issuerService.CreateIssuer(ctx, req.FullName)
+	iss, err := s.issuerService.CreateIssuer(ctx, req.FullName, req.Rating)
 	if err != nil {
 		return errHandler(err, c)
 	}
@@ -72,6 +129,7 @@ func (s *Server) CreateIssuer(c echo.Context) error {
 	return c.JSON(http.StatusCreated, IssuerResponse{
 		ID:       iss.ID,
 		FullName: iss.FullName,
+		Rating:   iss.Rating,
 	})
 }
 
@@ -80,17 +138,22 @@ func (s *Server) CreateIssuer(c echo.Context) error {
 // @Description  Retrieve an issuer by ID
 // @Tags         issuer
 // @Produce      json
+// @Security     Bearer
 // @Param id path string true "Issuer id"
 // @Success      200  {object}  IssuerResponse
-// @Failure      400  {object}  HTTPError
-// @Failure      404  {object}  HTTPError
-// @Failure      500  {object}  HTTPError
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /issuer [get]
 func (s *Server) RetrieveIssuer(c echo.Context) error {
 	id := c.Param("id")
 	if id == "" {
 		return errBadRequest(errors.New("id cannot be empty"), c)
 	}
+	if err := s.authorize(c, viewIssuer(id)); err != nil {
+		return errHandler(err, c)
+	}
 
 	ctx := c.Request().Context()
 	iss, err := s.issuerService.GetIssuer(ctx, id)
@@ -99,7 +162,7 @@ func (s *Server) RetrieveIssuer(c echo.Context) error {
 	}
 
 	invoices, err := s.invoiceService.GetByIssuerID(ctx, id)
-	if err != nil && !errors.Is(err, ErrNotFound) {
+	if err != nil && !errors.Is(err, invoice.ErrNotFound) {
 		return errHandler(err, c)
 	}
 
@@ -124,7 +187,90 @@ func (s *Server) RetrieveIssuer(c echo.Context) error {
 	return c.JSON(http.StatusOK, IssuerResponse{
 		ID:       iss.ID,
 		FullName: iss.FullName,
+		Rating:   iss.Rating,
 		Balance:  fmtBalance(iss.Balance),
 		Invoices: invoicesRes,
 	})
 }
+
+// RetrieveIssuerDashboard retrieves the dashboard of an issuer
+// @Summary      Get issuer dashboard
+// @Description  Retrieve the invoice pipeline, funding and approval figures, cash received net of fees and upcoming maturities of an issuer
+// @Tags         issuer
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Issuer id"
+// @Success      200  {object}  IssuerDashboardResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /issuer/:id/dashboard [get]
+func (s *Server) RetrieveIssuerDashboard(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, viewIssuer(id)); err != nil {
+		return errHandler(err, c)
+	}
+
+	ctx := c.Request().Context()
+	iss, err := s.issuerService.GetIssuer(ctx, id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	dashboard, err := s.invoiceService.GetIssuerDashboard(ctx, id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	fees, err := s.feeService.GetIssuerFees(ctx, id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+	dashboard.DeductFees(fees)
+
+	res := IssuerDashboardResponse{
+		ID:                  iss.ID,
+		FullName:            iss.FullName,
+		Balance:             fmtBalance(iss.Balance),
+		Pipeline:            make([]PipelineResponse, 0, len(dashboard.ByStatus)),
+		AverageFundingHours: math.Round(dashboard.AverageFundingTime.Hours()*100) / 100,
+		ApprovalRate:        roundRate(dashboard.ApprovalRate),
+		CashReceived:        totals(dashboard.CashReceived).response(),
+		UpcomingMaturities:  make([]DashboardInvoiceResponse, 0, len(dashboard.UpcomingMaturities)),
+		Invoices:            make([]DashboardInvoiceResponse, 0, len(dashboard.Invoices)),
+	}
+
+	for _, status := range []invoice.Status{invoice.OPEN, invoice.LOCKED, invoice.TRADED} {
+		summary := dashboard.ByStatus[status]
+		res.Pipeline = append(res.Pipeline, PipelineResponse{
+			Status: string(status),
+			Count:  summary.Count,
+			Value:  totals(summary.Value).response(),
+		})
+	}
+
+	for _, inv := range dashboard.UpcomingMaturities {
+		res.UpcomingMaturities = append(res.UpcomingMaturities, dashboardInvoiceResponse(inv))
+	}
+
+	for _, inv := range dashboard.Invoices {
+		res.Invoices = append(res.Invoices, dashboardInvoiceResponse(inv))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func dashboardInvoiceResponse(inv invoice.Invoice) DashboardInvoiceResponse {
+	return DashboardInvoiceResponse{
+		ID:             inv.ID,
+		Price:          currFmt.Format(inv.Price),
+		RemainingPrice: currFmt.Format(inv.RemainingPrice()),
+		FundedRatio:    roundRate(inv.FundedRatio()),
+		Status:         string(inv.Status),
+		DueDate:        inv.DueDate.Format(dateLayout),
+	}
+}
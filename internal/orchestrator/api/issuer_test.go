@@ -4,12 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
-
-	"github.com/jackc/pgx/v5"
+	"time"
 
 	"github.com/nerock/invoicebidder/internal/invoice"
 
@@ -24,7 +24,7 @@ func TestCreateIssuer(t *testing.T) {
 	Convey("CreateIssuer", t, func() {
 		Convey("with a valid server and service", func() {
 			issSvc := &mockIssuerService{}
-			srv := New(0, nil, nil, issSvc, nil)
+			srv := New(0, nil, nil, issSvc, nil, nil, nil)
 			rec := httptest.NewRecorder()
 
 			Convey("when request is invalid", func() {
@@ -43,7 +43,7 @@ func TestCreateIssuer(t *testing.T) {
 
 				Convey("it tries to create the issuer", func() {
 					Convey("when the service fails", func() {
-						issSvc.createIssuerFunc = func(_ context.Context, _ string) (issuer.Issuer, error) {
+						issSvc.createIssuerFunc = func(_ context.Context, _, _ string) (issuer.Issuer, error) {
 							return issuer.Issuer{}, errors.New("error")
 						}
 
@@ -59,7 +59,7 @@ func TestCreateIssuer(t *testing.T) {
 							ID:       "id",
 							FullName: "manu",
 						}
-						issSvc.createIssuerFunc = func(_ context.Context, _ string) (issuer.Issuer, error) {
+						issSvc.createIssuerFunc = func(_ context.Context, _, _ string) (issuer.Issuer, error) {
 							return iss, nil
 						}
 
@@ -83,7 +83,7 @@ func TestGetIssuer(t *testing.T) {
 	Convey("RetrieveIssuer", t, func() {
 		issSvc := &mockIssuerService{}
 		invSvc := &mockInvoiceService{}
-		srv := New(0, invSvc, nil, issSvc, nil)
+		srv := New(0, invSvc, nil, issSvc, nil, nil, nil)
 		rec := httptest.NewRecorder()
 
 		Convey("when request is invalid", func() {
@@ -108,7 +108,7 @@ func TestGetIssuer(t *testing.T) {
 					Convey("because the issuer does not exist", func() {
 						issSvc.getIssuerFunc = func(_ context.Context, id string) (issuer.Issuer, error) {
 							So(id, ShouldEqual, issID)
-							return issuer.Issuer{}, pgx.ErrNoRows
+							return issuer.Issuer{}, fmt.Errorf("could not retrieve issuer: %w", issuer.ErrNotFound)
 						}
 
 						Convey("return not found error code and an error", func() {
@@ -208,3 +208,67 @@ func TestGetIssuer(t *testing.T) {
 		})
 	})
 }
+
+func TestRetrieveIssuerDashboard(t *testing.T) {
+	Convey("RetrieveIssuerDashboard", t, func() {
+		amount := func(n, code string) currency.Amount {
+			a, err := currency.NewAmount(n, code)
+			So(err, ShouldBeNil)
+			return a
+		}
+
+		issSvc := &mockIssuerService{
+			getIssuerFunc: func(_ context.Context, id string) (issuer.Issuer, error) {
+				return issuer.Issuer{ID: id, FullName: "manu", Balance: amount("0", "EUR")}, nil
+			},
+		}
+		invSvc := &mockInvoiceService{
+			getIssuerDashboardFunc: func(_ context.Context, id string) (invoice.Dashboard, error) {
+				return invoice.NewDashboard([]invoice.Invoice{
+					{ID: "traded", IssuerID: id, Price: amount("1000", "EUR"), Status: invoice.TRADED, DueDate: time.Now().AddDate(0, 1, 0)},
+					{ID: "open", IssuerID: id, Price: amount("500", "EUR"), Status: invoice.OPEN, DueDate: time.Now().AddDate(0, 1, 0)},
+				}, time.Now()), nil
+			},
+		}
+		feeSvc := &mockFeeService{
+			getIssuerFeesFunc: func(_ context.Context, id string) (map[string]currency.Amount, error) {
+				So(id, ShouldEqual, "issID")
+				return map[string]currency.Amount{"traded": amount("12.5", "EUR")}, nil
+			},
+		}
+		srv := New(0, invSvc, nil, issSvc, feeSvc, nil, nil)
+
+		serve := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			c := srv.e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues("issID")
+			So(srv.RetrieveIssuerDashboard(c), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when the fees can't be retrieved", func() {
+			feeSvc.getIssuerFeesFunc = func(context.Context, string) (map[string]currency.Amount, error) {
+				return nil, errors.New("db down")
+			}
+
+			Convey("return internal error", func() {
+				So(serve().Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+
+		Convey("when everything succeeds", func() {
+			rec := serve()
+			So(rec.Code, ShouldEqual, http.StatusOK)
+
+			var res IssuerDashboardResponse
+			So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+
+			Convey("return the cash received net of the settled fees", func() {
+				So(res.CashReceived, ShouldResemble, map[string]string{"EUR": currFmt.Format(amount("987.5", "EUR"))})
+				So(res.Invoices, ShouldHaveLength, 2)
+				So(res.UpcomingMaturities, ShouldHaveLength, 1)
+			})
+		})
+	})
+}
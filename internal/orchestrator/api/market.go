@@ -0,0 +1,255 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+type CreateListingRequest struct {
+	BidID      string        `json:"bidId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	InvestorID string        `json:"investorId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Price      AmountRequest `json:"price"`
+}
+
+type ListingInvestorRequest struct {
+	InvestorID string `json:"investorId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+}
+
+type ListingResponse struct {
+	ID        string `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	BidID     string `json:"bidId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	InvoiceID string `json:"invoiceId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	SellerID  string `json:"sellerId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	BuyerID   string `json:"buyerId,omitempty" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Price     string `json:"price" example:"1 230,45 €"`
+	Status    string `json:"status" example:"listed"`
+	CreatedAt string `json:"createdAt" example:"2023-07-20T10:00:00Z"`
+}
+
+func (s *Server) marketRoutes(g *echo.Group) {
+	g.POST("/listings", s.CreateListing)
+	g.GET("/listings", s.ListListings)
+	g.GET("/listings/:id", s.RetrieveListing)
+	g.POST("/listings/:id/buy", s.BuyListing)
+	g.POST("/listings/:id/withdraw", s.WithdrawListing)
+}
+
+// CreateListing lists a funded bid for sale
+// @Summary      List position
+// @Description  Put a funded bid of a traded invoice up for sale in the secondary market
+// @Tags         market
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param request body CreateListingRequest true "Listing request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      201  {object}  ListingResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /market/listings [post]
+func (s *Server) CreateListing(c echo.Context) error {
+	var req CreateListingRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+	investorID := actingInvestor(c, req.InvestorID)
+	if req.BidID == "" || investorID == "" {
+		return errBadRequest(errors.New("bid id and investor id cannot be empty"), c)
+	}
+	if err := s.authorize(c, asInvestor(investorID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	price, err := currency.NewAmount(req.Price.Amount, req.Price.Currency)
+	if err != nil {
+		return errBadRequest(err, c)
+	}
+
+	ctx := c.Request().Context()
+	seller, err := s.investorService.GetInvestor(ctx, investorID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	listing, err := s.invoiceService.ListPosition(ctx, req.BidID, seller.ID, price)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusCreated, listingResponse(listing))
+}
+
+// ListListings retrieves the open listings
+// @Summary      List open listings
+// @Description  Retrieve the positions currently for sale in the secondary market
+// @Tags         market
+// @Produce      json
+// @Security     Bearer
+// @Success      200  {array}   ListingResponse
+// @Failure      500  {object}  Problem
+// @Router       /market/listings [get]
+func (s *Server) ListListings(c echo.Context) error {
+	listings, err := s.invoiceService.ListOpenListings(c.Request().Context())
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	res := make([]ListingResponse, 0, len(listings))
+	for _, l := range listings {
+		res = append(res, listingResponse(l))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// RetrieveListing retrieves a listing by ID
+// @Summary      Get listing
+// @Description  Retrieve a secondary market listing by ID
+// @Tags         market
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Listing id"
+// @Success      200  {object}  ListingResponse
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /market/listings/:id [get]
+func (s *Server) RetrieveListing(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	listing, err := s.invoiceService.GetListing(c.Request().Context(), id)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusOK, listingResponse(listing))
+}
+
+// BuyListing buys a listed position
+// @Summary      Buy position
+// @Description  Buy a listed position, paying the seller and taking over the bid
+// @Tags         market
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Listing id"
+// @Param request body ListingInvestorRequest true "Buyer request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      200  {object}  ListingResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /market/listings/:id/buy [post]
+func (s *Server) BuyListing(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	var req ListingInvestorRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+
+	investorID := actingInvestor(c, req.InvestorID)
+	if err := s.authorize(c, asInvestor(investorID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	ctx := c.Request().Context()
+	buyer, err := s.investorService.GetInvestor(ctx, investorID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	// the listing is reserved before paying so no other buyer can pay for it
+	listing, err := s.invoiceService.ReserveListing(ctx, id, buyer.ID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	if err := s.investorService.Transfer(ctx, buyer.ID, listing.SellerID, listing.Price); err != nil {
+		if errRelease := s.invoiceService.ReleaseListing(ctx, id, buyer.ID); errRelease != nil {
+			err = fmt.Errorf("%w: %w", err, errRelease)
+		}
+		return errHandler(err, c)
+	}
+
+	sold, err := s.invoiceService.BuyListing(ctx, id, buyer.ID)
+	if err != nil {
+		s.broker.SendFailedTransferEvent(buyer.ID, listing.SellerID, listing.Price)
+		if errRelease := s.invoiceService.ReleaseListing(ctx, id, buyer.ID); errRelease != nil {
+			err = fmt.Errorf("%w: %w", err, errRelease)
+		}
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusOK, listingResponse(sold))
+}
+
+// WithdrawListing withdraws a listed position
+// @Summary      Withdraw listing
+// @Description  Take a listed position off the secondary market
+// @Tags         market
+// @Accept       json
+// @Security     Bearer
+// @Param id path string true "Listing id"
+// @Param request body ListingInvestorRequest true "Seller request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /market/listings/:id/withdraw [post]
+func (s *Server) WithdrawListing(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+
+	var req ListingInvestorRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+
+	investorID := actingInvestor(c, req.InvestorID)
+	if err := s.authorize(c, asInvestor(investorID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	if err := s.invoiceService.WithdrawListing(c.Request().Context(), id, investorID); err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func listingResponse(l invoice.Listing) ListingResponse {
+	return ListingResponse{
+		ID:        l.ID,
+		BidID:     l.BidID,
+		InvoiceID: l.InvoiceID,
+		SellerID:  l.SellerID,
+		BuyerID:   l.BuyerID,
+		Price:     currFmt.Format(l.Price),
+		Status:    string(l.Status),
+		CreatedAt: l.CreatedAt.Format(time.RFC3339),
+	}
+}
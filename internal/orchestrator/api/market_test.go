@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBuyListing(t *testing.T) {
+	Convey("BuyListing", t, func() {
+		price, err := currency.NewAmount("95", "EUR")
+		So(err, ShouldBeNil)
+
+		var calls []string
+		invSvc := &mockInvoiceService{
+			reserveListingFunc: func(_ context.Context, id, buyerID string) (invoice.Listing, error) {
+				calls = append(calls, "reserve")
+				return invoice.Listing{ID: id, SellerID: "seller", BuyerID: buyerID, Price: price, Status: invoice.RESERVED}, nil
+			},
+			releaseListingFunc: func(context.Context, string, string) error {
+				calls = append(calls, "release")
+				return nil
+			},
+			buyListingFunc: func(_ context.Context, id, buyerID string) (invoice.Listing, error) {
+				calls = append(calls, "buy")
+				return invoice.Listing{ID: id, SellerID: "seller", BuyerID: buyerID, Price: price, Status: invoice.SOLD}, nil
+			},
+		}
+		invstSvc := &mockInvestorService{
+			getInvestorFunc: func(_ context.Context, id string) (investor.Investor, error) {
+				return investor.Investor{ID: id}, nil
+			},
+			transferFunc: func(_ context.Context, fromID, toID string, amount currency.Amount) error {
+				calls = append(calls, "transfer "+fromID+"->"+toID+" "+amount.Number())
+				return nil
+			},
+		}
+		brk := &mockBroker{}
+		srv := New(0, invSvc, invstSvc, nil, nil, brk, nil)
+
+		serve := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"investorId":"buyer"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c := srv.e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("listing-1")
+			So(srv.BuyListing(c), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when everything succeeds", func() {
+			rec := serve()
+
+			Convey("reserve the listing before paying the seller", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(calls, ShouldResemble, []string{"reserve", "transfer buyer->seller 95", "buy"})
+				So(brk.failedTransfers, ShouldBeEmpty)
+
+				var res ListingResponse
+				So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+				So(res.BuyerID, ShouldEqual, "buyer")
+				So(res.Status, ShouldEqual, string(invoice.SOLD))
+			})
+		})
+
+		Convey("when the listing can't be reserved", func() {
+			invSvc.reserveListingFunc = func(context.Context, string, string) (invoice.Listing, error) {
+				calls = append(calls, "reserve")
+				return invoice.Listing{}, invoice.ErrInvalidTransition
+			}
+			rec := serve()
+
+			Convey("return conflict without moving any money", func() {
+				So(rec.Code, ShouldEqual, http.StatusConflict)
+				So(calls, ShouldResemble, []string{"reserve"})
+			})
+		})
+
+		Convey("when the buyer can't pay", func() {
+			invstSvc.transferFunc = func(context.Context, string, string, currency.Amount) error {
+				calls = append(calls, "transfer")
+				return investor.ErrInsufficientFunds
+			}
+			rec := serve()
+
+			Convey("release the listing and return conflict", func() {
+				So(rec.Code, ShouldEqual, http.StatusConflict)
+				So(calls, ShouldResemble, []string{"reserve", "transfer", "release"})
+				So(brk.failedTransfers, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the position can't be transferred", func() {
+			invSvc.buyListingFunc = func(context.Context, string, string) (invoice.Listing, error) {
+				calls = append(calls, "buy")
+				return invoice.Listing{}, errors.New("error")
+			}
+			rec := serve()
+
+			Convey("give the money back, release the listing and return internal error", func() {
+				So(rec.Code, ShouldEqual, http.StatusInternalServerError)
+				So(calls, ShouldResemble, []string{"reserve", "transfer buyer->seller 95", "buy", "release"})
+				So(brk.failedTransfers, ShouldResemble, []string{"buyer->seller"})
+			})
+		})
+
+		Convey("when the buyer does not exist", func() {
+			invstSvc.getInvestorFunc = func(context.Context, string) (investor.Investor, error) {
+				return investor.Investor{}, investor.ErrNotFound
+			}
+			rec := serve()
+
+			Convey("return not found", func() {
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+				So(calls, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestWithdrawListing(t *testing.T) {
+	Convey("WithdrawListing", t, func() {
+		var withdrawn []string
+		invSvc := &mockInvoiceService{
+			withdrawListingFunc: func(_ context.Context, id, sellerID string) error {
+				withdrawn = append(withdrawn, id+" by "+sellerID)
+				return nil
+			},
+		}
+		srv := New(0, invSvc, nil, nil, nil, nil, nil)
+
+		serve := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"investorId":"seller"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c := srv.e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("listing-1")
+			So(srv.WithdrawListing(c), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when the seller withdraws it", func() {
+			rec := serve()
+
+			Convey("return ok", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				So(withdrawn, ShouldResemble, []string{"listing-1 by seller"})
+			})
+		})
+
+		for err, code := range map[error]int{
+			invoice.ErrNotOwner:          http.StatusForbidden,
+			invoice.ErrInvalidTransition: http.StatusConflict,
+			invoice.ErrNotFound:          http.StatusNotFound,
+		} {
+			err, code := err, code
+
+			Convey("when the service fails with "+err.Error(), func() {
+				invSvc.withdrawListingFunc = func(context.Context, string, string) error {
+					return err
+				}
+				rec := serve()
+
+				Convey("return "+http.StatusText(code), func() {
+					So(rec.Code, ShouldEqual, code)
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/investor"
+)
+
+type PaymentRequest struct {
+	Amount    AmountRequest `json:"amount"`
+	IBAN      string        `json:"iban" example:"ES9121000418450200051332"`
+	Reference string        `json:"reference" example:"Top up July"`
+}
+
+type SettlePaymentRequest struct {
+	Settled bool `json:"settled" example:"true"`
+}
+
+type PaymentResponse struct {
+	ID        string `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Kind      string `json:"kind" example:"deposit"`
+	Amount    string `json:"amount" example:"1 230,45 €"`
+	IBAN      string `json:"iban" example:"ES9121000418450200051332"`
+	Reference string `json:"reference" example:"Top up July"`
+	Status    string `json:"status" example:"pending"`
+	CreatedAt string `json:"createdAt" example:"2023-07-20T10:00:00Z"`
+	SettledAt string `json:"settledAt,omitempty" example:"2023-07-21T10:00:00Z"`
+}
+
+// CreateDeposit records a deposit instruction for an investor
+// @Summary      New deposit
+// @Description  Record a pending deposit that credits the investor balance once it settles
+// @Tags         investor
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Investor id"
+// @Param request body PaymentRequest true "Deposit request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      201  {object}  PaymentResponse
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /investor/:id/deposits [post]
+func (s *Server) CreateDeposit(c echo.Context) error {
+	return s.createPayment(c, investor.DEPOSIT)
+}
+
+// CreateWithdrawal records a withdrawal instruction for an investor
+// @Summary      New withdrawal
+// @Description  Record a pending withdrawal that debits the investor balance once it settles, up to the available funds
+// @Tags         investor
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Investor id"
+// @Param request body PaymentRequest true "Withdrawal request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      201  {object}  PaymentResponse
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /investor/:id/withdrawals [post]
+func (s *Server) CreateWithdrawal(c echo.Context) error {
+	return s.createPayment(c, investor.WITHDRAWAL)
+}
+
+func (s *Server) createPayment(c echo.Context, kind investor.PaymentKind) error {
+	investorID := c.Param("id")
+	if investorID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, manageInvestor(investorID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	var req PaymentRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+
+	amount, err := currency.NewAmount(req.Amount.Amount, req.Amount.Currency)
+	if err != nil {
+		return errBadRequest(err, c)
+	}
+	if !amount.IsPositive() {
+		return errBadRequest(errors.New("amount must be positive"), c)
+	}
+
+	iban := normalizeIBAN(req.IBAN)
+	if !validIBAN(iban) {
+		return errBadRequest(fmt.Errorf("invalid iban %q", req.IBAN), c)
+	}
+
+	ctx := c.Request().Context()
+	inv, err := s.investorService.GetInvestor(ctx, investorID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	var payment investor.Payment
+	if kind == investor.DEPOSIT {
+		payment, err = s.investorService.RequestDeposit(ctx, inv.ID, amount, iban, req.Reference)
+	} else {
+		payment, err = s.investorService.RequestWithdrawal(ctx, inv.ID, amount, iban, req.Reference)
+	}
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusCreated, paymentResponse(payment))
+}
+
+// ListPayments retrieves the payments of an investor
+// @Summary      List payments
+// @Description  Retrieve the deposits and withdrawals of an investor
+// @Tags         investor
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Investor id"
+// @Success      200  {array}   PaymentResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /investor/:id/payments [get]
+func (s *Server) ListPayments(c echo.Context) error {
+	investorID := c.Param("id")
+	if investorID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, viewInvestor(investorID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	payments, err := s.investorService.ListPayments(c.Request().Context(), investorID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	res := make([]PaymentResponse, 0, len(payments))
+	for _, p := range payments {
+		res = append(res, paymentResponse(p))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// SettlePayment settles or fails a pending payment
+// @Summary      Settle payment
+// @Description  Mark a pending payment as settled, applying it to the balance, or as failed
+// @Tags         investor
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Investor id"
+// @Param paymentId path string true "Payment id"
+// @Param request body SettlePaymentRequest true "Settlement request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      200  {object}  PaymentResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /investor/:id/payments/:paymentId/settlement [post]
+func (s *Server) SettlePayment(c echo.Context) error {
+	investorID, paymentID := c.Param("id"), c.Param("paymentId")
+	if investorID == "" || paymentID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, operate); err != nil {
+		return errHandler(err, c)
+	}
+
+	var req SettlePaymentRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+
+	payment, err := s.investorService.SettlePayment(c.Request().Context(), investorID, paymentID, req.Settled)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusOK, paymentResponse(payment))
+}
+
+func paymentResponse(p investor.Payment) PaymentResponse {
+	res := PaymentResponse{
+		ID:        p.ID,
+		Kind:      string(p.Kind),
+		Amount:    currFmt.Format(p.Amount),
+		IBAN:      p.IBAN,
+		Reference: p.Reference,
+		Status:    string(p.Status),
+		CreatedAt: p.CreatedAt.Format(time.RFC3339),
+	}
+
+	if p.SettledAt != nil {
+		res.SettledAt = p.SettledAt.Format(time.RFC3339)
+	}
+
+	return res
+}
+
+func normalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.ReplaceAll(iban, " ", ""))
+}
+
+// validIBAN checks the length and the ISO 13616 mod 97 checksum
+func validIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			digits.WriteString(fmt.Sprint(r - 'A' + 10))
+		default:
+			return false
+		}
+	}
+
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && n.Mod(n, big.NewInt(97)).Int64() == 1
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidIBAN(t *testing.T) {
+	Convey("validIBAN", t, func() {
+		Convey("accepts valid ibans", func() {
+			for _, iban := range []string{
+				"ES9121000418450200051332",
+				"GB29 NWBK 6016 1331 9268 19",
+				"de89370400440532013000",
+			} {
+				So(validIBAN(normalizeIBAN(iban)), ShouldBeTrue)
+			}
+		})
+
+		Convey("rejects invalid ibans", func() {
+			for _, iban := range []string{
+				"",
+				"ES9121000418450200051333",
+				"ES91-2100-0418-4502-0005-1332",
+				"ES91",
+			} {
+				So(validIBAN(normalizeIBAN(iban)), ShouldBeFalse)
+			}
+		})
+	})
+}
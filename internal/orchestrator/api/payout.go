@@ -0,0 +1,289 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/issuer"
+)
+
+type PayoutAccountRequest struct {
+	IBAN   string `json:"iban" example:"ES9121000418450200051332"`
+	Holder string `json:"holder" example:"Manuel Adalid"`
+	Auto   bool   `json:"auto" example:"true"`
+}
+
+type PayoutAccountResponse struct {
+	ID     string `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	IBAN   string `json:"iban" example:"ES9121000418450200051332"`
+	Holder string `json:"holder" example:"Manuel Adalid"`
+	Auto   bool   `json:"auto" example:"true"`
+}
+
+type PayoutRequest struct {
+	AccountID string        `json:"accountId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Amount    AmountRequest `json:"amount"`
+}
+
+type PayoutStatusRequest struct {
+	Status string `json:"status" example:"sent"`
+}
+
+type PayoutResponse struct {
+	ID        string `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	AccountID string `json:"accountId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Amount    string `json:"amount" example:"1 230,45 €"`
+	Status    string `json:"status" example:"pending"`
+	CreatedAt string `json:"createdAt" example:"2023-07-20T10:00:00Z"`
+	SentAt    string `json:"sentAt,omitempty" example:"2023-07-21T10:00:00Z"`
+	FailedAt  string `json:"failedAt,omitempty" example:"2023-07-22T10:00:00Z"`
+}
+
+// CreatePayoutAccount registers a payout account for an issuer
+// @Summary      New payout account
+// @Description  Register a bank account to receive payouts, optionally receiving trade proceeds automatically
+// @Tags         issuer
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Issuer id"
+// @Param request body PayoutAccountRequest true "Payout account request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      201  {object}  PayoutAccountResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /issuer/:id/payout-accounts [post]
+func (s *Server) CreatePayoutAccount(c echo.Context) error {
+	issuerID := c.Param("id")
+	if issuerID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, manageIssuer(issuerID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	var req PayoutAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+	if req.Holder == "" {
+		return errBadRequest(errors.New("holder cannot be empty"), c)
+	}
+
+	iban := normalizeIBAN(req.IBAN)
+	if !validIBAN(iban) {
+		return errBadRequest(fmt.Errorf("invalid iban %q", req.IBAN), c)
+	}
+
+	account, err := s.issuerService.AddPayoutAccount(c.Request().Context(), issuerID, iban, req.Holder, req.Auto)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusCreated, payoutAccountResponse(account))
+}
+
+// ListPayoutAccounts retrieves the payout accounts of an issuer
+// @Summary      List payout accounts
+// @Description  Retrieve the payout accounts of an issuer
+// @Tags         issuer
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Issuer id"
+// @Success      200  {array}   PayoutAccountResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /issuer/:id/payout-accounts [get]
+func (s *Server) ListPayoutAccounts(c echo.Context) error {
+	issuerID := c.Param("id")
+	if issuerID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, viewIssuer(issuerID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	accounts, err := s.issuerService.ListPayoutAccounts(c.Request().Context(), issuerID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	res := make([]PayoutAccountResponse, 0, len(accounts))
+	for _, a := range accounts {
+		res = append(res, payoutAccountResponse(a))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// CreatePayout requests a payout for an issuer
+// @Summary      New payout
+// @Description  Request a payout from the issuer balance to one of its payout accounts
+// @Tags         issuer
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Issuer id"
+// @Param request body PayoutRequest true "Payout request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      201  {object}  PayoutResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /issuer/:id/payouts [post]
+func (s *Server) CreatePayout(c echo.Context) error {
+	issuerID := c.Param("id")
+	if issuerID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, manageIssuer(issuerID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	var req PayoutRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+	if req.AccountID == "" {
+		return errBadRequest(errors.New("account id cannot be empty"), c)
+	}
+
+	amount, err := currency.NewAmount(req.Amount.Amount, req.Amount.Currency)
+	if err != nil {
+		return errBadRequest(err, c)
+	}
+	if !amount.IsPositive() {
+		return errBadRequest(errors.New("amount must be positive"), c)
+	}
+
+	payout, err := s.issuerService.RequestPayout(c.Request().Context(), issuerID, req.AccountID, amount)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusCreated, payoutResponse(payout))
+}
+
+// ListPayouts retrieves the payouts of an issuer
+// @Summary      List payouts
+// @Description  Retrieve the payouts of an issuer
+// @Tags         issuer
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Issuer id"
+// @Success      200  {array}   PayoutResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /issuer/:id/payouts [get]
+func (s *Server) ListPayouts(c echo.Context) error {
+	issuerID := c.Param("id")
+	if issuerID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, viewIssuer(issuerID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	payouts, err := s.issuerService.ListPayouts(c.Request().Context(), issuerID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	res := make([]PayoutResponse, 0, len(payouts))
+	for _, p := range payouts {
+		res = append(res, payoutResponse(p))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// UpdatePayoutStatus marks a payout as sent or failed
+// @Summary      Update payout status
+// @Description  Mark a pending payout as sent, deducting the issuer balance, or a payout as failed, reversing it if it was sent
+// @Tags         issuer
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Issuer id"
+// @Param payoutId path string true "Payout id"
+// @Param request body PayoutStatusRequest true "Payout status request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      200  {object}  PayoutResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /issuer/:id/payouts/:payoutId/status [post]
+func (s *Server) UpdatePayoutStatus(c echo.Context) error {
+	issuerID, payoutID := c.Param("id"), c.Param("payoutId")
+	if issuerID == "" || payoutID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, operate); err != nil {
+		return errHandler(err, c)
+	}
+
+	var req PayoutStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+
+	var (
+		payout issuer.Payout
+		err    error
+		ctx    = c.Request().Context()
+	)
+	switch issuer.PayoutStatus(req.Status) {
+	case issuer.SENT:
+		payout, err = s.issuerService.SendPayout(ctx, issuerID, payoutID)
+	case issuer.FAILED:
+		payout, err = s.issuerService.FailPayout(ctx, issuerID, payoutID)
+	default:
+		return errBadRequest(fmt.Errorf("invalid payout status %q", req.Status), c)
+	}
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusOK, payoutResponse(payout))
+}
+
+func payoutAccountResponse(a issuer.PayoutAccount) PayoutAccountResponse {
+	return PayoutAccountResponse{
+		ID:     a.ID,
+		IBAN:   a.IBAN,
+		Holder: a.Holder,
+		Auto:   a.Auto,
+	}
+}
+
+func payoutResponse(p issuer.Payout) PayoutResponse {
+	res := PayoutResponse{
+		ID:        p.ID,
+		AccountID: p.AccountID,
+		Amount:    currFmt.Format(p.Amount),
+		Status:    string(p.Status),
+		CreatedAt: p.CreatedAt.Format(time.RFC3339),
+	}
+
+	if p.SentAt != nil {
+		res.SentAt = p.SentAt.Format(time.RFC3339)
+	}
+	if p.FailedAt != nil {
+		res.FailedAt = p.FailedAt.Format(time.RFC3339)
+	}
+
+	return res
+}
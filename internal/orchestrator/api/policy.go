@@ -0,0 +1,121 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+)
+
+// policy decides if a principal may perform an action
+type policy func(auth.Principal) bool
+
+func anyOf(policies ...policy) policy {
+	return func(p auth.Principal) bool {
+		for _, allowed := range policies {
+			if allowed(p) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func hasRole(roles ...auth.Role) policy {
+	return func(p auth.Principal) bool {
+		return p.HasRole(roles...)
+	}
+}
+
+var (
+	// operate covers onboarding and the money movements reported by banks
+	operate = hasRole(auth.ADMIN)
+	// audit lets admins and auditors read everything, auditors never write
+	audit = hasRole(auth.ADMIN, auth.AUDITOR)
+	// investors lets in any principal acting as an investor
+	investors policy = func(p auth.Principal) bool { return p.IsInvestor(p.InvestorID) }
+)
+
+// asIssuer only lets the issuer act for itself, as approving or cancelling
+// its trades
+func asIssuer(id string) policy {
+	return func(p auth.Principal) bool {
+		return p.IsIssuer(id)
+	}
+}
+
+// asInvestor only lets the investor act for itself, as bidding or trading
+// its positions
+func asInvestor(id string) policy {
+	return func(p auth.Principal) bool {
+		return p.IsInvestor(id)
+	}
+}
+
+func manageIssuer(id string) policy {
+	return anyOf(operate, asIssuer(id))
+}
+
+func viewIssuer(id string) policy {
+	return anyOf(audit, asIssuer(id))
+}
+
+func manageInvestor(id string) policy {
+	return anyOf(operate, asInvestor(id))
+}
+
+func viewInvestor(id string) policy {
+	return anyOf(audit, asInvestor(id))
+}
+
+// submittedBy only lets in the subject that submitted a job
+func submittedBy(subject string) policy {
+	return func(p auth.Principal) bool {
+		return subject != "" && p.Subject == subject
+	}
+}
+
+// authorize checks the principal of the request against the policy. Without
+// authentication there is no principal, and every request is let in.
+func (s *Server) authorize(c echo.Context, allowed policy) error {
+	if s.authService == nil {
+		return nil
+	}
+
+	p, err := principal(c)
+	if err != nil {
+		return err
+	}
+
+	if !allowed(p) {
+		return fmt.Errorf("%w: %s %s is not allowed for %s", ErrForbidden, c.Request().Method, c.Path(), p.Subject)
+	}
+
+	return nil
+}
+
+// authorizeInvoice checks the principal against the policy for the issuer
+// of an invoice
+func (s *Server) authorizeInvoice(c echo.Context, invoiceID string, allowed func(issuerID string) policy) error {
+	if s.authService == nil {
+		return nil
+	}
+
+	inv, err := s.invoiceService.GetInvoice(c.Request().Context(), invoiceID)
+	if err != nil {
+		return err
+	}
+
+	return s.authorize(c, allowed(inv.IssuerID))
+}
+
+// actingInvestor is the investor of the request, the one in the body when
+// given or the one of the principal otherwise
+func actingInvestor(c echo.Context, id string) string {
+	if p, ok := auth.FromContext(c.Request().Context()); ok && id == "" {
+		return p.InvestorID
+	}
+
+	return id
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/auth"
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockInvestorService struct {
+	InvestorService
+	getInvestorFunc  func(context.Context, string) (investor.Investor, error)
+	transferFunc     func(context.Context, string, string, currency.Amount) error
+	createRuleFunc   func(context.Context, investor.Rule) (investor.Rule, error)
+	listRulesFunc    func(context.Context, string) ([]investor.Rule, error)
+	listRuleBidsFunc func(context.Context, string) ([]investor.RuleBid, error)
+}
+
+func (m *mockInvestorService) GetInvestor(ctx context.Context, id string) (investor.Investor, error) {
+	return m.getInvestorFunc(ctx, id)
+}
+
+func (m *mockInvestorService) Transfer(ctx context.Context, fromID, toID string, amount currency.Amount) error {
+	return m.transferFunc(ctx, fromID, toID, amount)
+}
+
+func (m *mockInvestorService) CreateRule(ctx context.Context, rule investor.Rule) (investor.Rule, error) {
+	return m.createRuleFunc(ctx, rule)
+}
+
+func (m *mockInvestorService) ListRules(ctx context.Context, investorID string) ([]investor.Rule, error) {
+	return m.listRulesFunc(ctx, investorID)
+}
+
+func (m *mockInvestorService) ListRuleBids(ctx context.Context, investorID string) ([]investor.RuleBid, error) {
+	return m.listRuleBidsFunc(ctx, investorID)
+}
+
+type mockBroker struct {
+	Broker
+	trades          []string
+	failedTransfers []string
+	released        []string
+}
+
+func (m *mockBroker) SendInvoiceReleasedEvent(invoiceID string) {
+	m.released = append(m.released, invoiceID)
+}
+
+func (m *mockBroker) SendTradeEvent(invoiceID string, _ []string, _ bool) {
+	m.trades = append(m.trades, invoiceID)
+}
+
+func (m *mockBroker) SendFailedTransferEvent(fromID, toID string, _ currency.Amount) {
+	m.failedTransfers = append(m.failedTransfers, fromID+"->"+toID)
+}
+
+var principals = map[string]auth.Principal{
+	"admin":      {Subject: "admin", Roles: []auth.Role{auth.ADMIN}},
+	"auditor":    {Subject: "auditor", Roles: []auth.Role{auth.AUDITOR}},
+	"issuer":     {Subject: "issuer", IssuerID: "issuer-1", Roles: []auth.Role{auth.ISSUER}},
+	"issuer-2":   {Subject: "issuer-2", IssuerID: "issuer-2", Roles: []auth.Role{auth.ISSUER}},
+	"investor":   {Subject: "investor", InvestorID: "investor-1", Roles: []auth.Role{auth.INVESTOR}},
+	"investor-2": {Subject: "investor-2", InvestorID: "investor-2", Roles: []auth.Role{auth.INVESTOR}},
+	// linked to an issuer and an investor, without being granted their roles
+	"unlinked": {Subject: "unlinked", IssuerID: "issuer-1", InvestorID: "investor-1", Roles: []auth.Role{auth.AUDITOR}},
+}
+
+func TestPolicies(t *testing.T) {
+	Convey("policies", t, func() {
+		for _, tc := range []struct {
+			name    string
+			policy  policy
+			allowed []string
+		}{
+			{"operate", operate, []string{"admin"}},
+			{"audit", audit, []string{"admin", "auditor", "unlinked"}},
+			{"investors", investors, []string{"investor", "investor-2"}},
+			{"asIssuer", asIssuer("issuer-1"), []string{"issuer"}},
+			{"asInvestor", asInvestor("investor-1"), []string{"investor"}},
+			{"manageIssuer", manageIssuer("issuer-1"), []string{"admin", "issuer"}},
+			{"viewIssuer", viewIssuer("issuer-1"), []string{"admin", "auditor", "issuer", "unlinked"}},
+			{"manageInvestor", manageInvestor("investor-1"), []string{"admin", "investor"}},
+			{"viewInvestor", viewInvestor("investor-1"), []string{"admin", "auditor", "investor", "unlinked"}},
+			{"documentReaders", documentReaders("issuer-1"), []string{"admin", "auditor", "issuer", "investor", "investor-2", "unlinked"}},
+			{"submittedBy", submittedBy("issuer"), []string{"issuer"}},
+			{"asIssuer without id", asIssuer(""), nil},
+			{"submittedBy without subject", submittedBy(""), nil},
+		} {
+			for name, p := range principals {
+				allowed := false
+				for _, a := range tc.allowed {
+					allowed = allowed || a == name
+				}
+
+				Convey(tc.name+" for "+name, func() {
+					So(tc.policy(p), ShouldEqual, allowed)
+				})
+			}
+		}
+	})
+}
+
+func TestAuthorize(t *testing.T) {
+	Convey("authorize", t, func() {
+		invSvc := &mockInvoiceService{
+			getInvoiceFunc: func(ctx context.Context, id string) (invoice.Invoice, error) {
+				return invoice.Invoice{ID: id, IssuerID: "issuer-1"}, nil
+			},
+			approveTradeFunc: func(context.Context, string, bool) ([]string, error) {
+				return nil, nil
+			},
+		}
+		var bidder string
+		invstSvc := &mockInvestorService{
+			getInvestorFunc: func(_ context.Context, id string) (investor.Investor, error) {
+				bidder = id
+				return investor.Investor{}, investor.ErrNotFound
+			},
+		}
+		brk := &mockBroker{}
+		srv := New(0, invSvc, invstSvc, nil, nil, brk, nil).WithAuth(&mockAuthService{})
+
+		serve := func(handler echo.HandlerFunc, method, body string, p *auth.Principal) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(method, "/", strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if p != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *p))
+			}
+			c := srv.e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("invoice-1")
+			So(handler(c), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when approving a trade", func() {
+			for name, code := range map[string]int{
+				"issuer":   http.StatusOK,
+				"issuer-2": http.StatusForbidden,
+				"admin":    http.StatusForbidden,
+				"auditor":  http.StatusForbidden,
+				"investor": http.StatusForbidden,
+				"unlinked": http.StatusForbidden,
+			} {
+				p := principals[name]
+
+				Convey("return "+http.StatusText(code)+" for "+name, func() {
+					rec := serve(srv.ApproveTrade, http.MethodPost, `{"approve":false}`, &p)
+					So(rec.Code, ShouldEqual, code)
+					if code == http.StatusOK {
+						So(brk.trades, ShouldResemble, []string{"invoice-1"})
+					} else {
+						So(brk.trades, ShouldBeEmpty)
+					}
+				})
+			}
+		})
+
+		Convey("when bidding", func() {
+			for _, tc := range []struct {
+				name      string
+				principal string
+				body      string
+				code      int
+				bidder    string
+			}{
+				{"as itself", "investor", `,"investorId":"investor-1"`, http.StatusNotFound, "investor-1"},
+				{"without an investor", "investor", "", http.StatusNotFound, "investor-1"},
+				{"as another investor", "investor", `,"investorId":"investor-2"`, http.StatusForbidden, ""},
+				{"as an admin", "admin", `,"investorId":"investor-1"`, http.StatusForbidden, ""},
+				{"as an issuer", "issuer", `,"investorId":"investor-1"`, http.StatusForbidden, ""},
+				{"without the investor role", "unlinked", "", http.StatusForbidden, ""},
+			} {
+				p := principals[tc.principal]
+				body := `{"amount":{"amount":"100","currency":"EUR"}` + tc.body + "}"
+
+				Convey("return "+http.StatusText(tc.code)+" for "+tc.name, func() {
+					rec := serve(srv.Bid, http.MethodPost, body, &p)
+					So(rec.Code, ShouldEqual, tc.code)
+					So(bidder, ShouldEqual, tc.bidder)
+				})
+			}
+		})
+
+		Convey("when the request has no principal", func() {
+			rec := serve(srv.CreateIssuer, http.MethodPost, `{}`, nil)
+
+			Convey("return unauthorized", func() {
+				So(rec.Code, ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
+		Convey("when an auditor writes", func() {
+			p := principals["auditor"]
+			rec := serve(srv.CreateIssuer, http.MethodPost, `{}`, &p)
+
+			Convey("return forbidden", func() {
+				So(rec.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"sort"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/issuer"
+)
+
+type PortfolioResponse struct {
+	Positions           []InvestorBidResponse      `json:"positions"`
+	CommittedByCurrency map[string]string          `json:"committedByCurrency"`
+	CommittedByIssuer   []IssuerCommitmentResponse `json:"committedByIssuer"`
+	RealisedReturns     map[string]string          `json:"realisedReturns"`
+	UnrealisedReturns   map[string]string          `json:"unrealisedReturns"`
+}
+
+type IssuerCommitmentResponse struct {
+	Issuer    InvoiceIssuerResponse `json:"issuer"`
+	Committed map[string]string     `json:"committed"`
+}
+
+// totals accumulates amounts per currency code
+type totals map[string]currency.Amount
+
+func (t totals) add(a currency.Amount) {
+	if current, ok := t[a.CurrencyCode()]; ok {
+		t[a.CurrencyCode()], _ = current.Add(a)
+	} else {
+		t[a.CurrencyCode()] = a
+	}
+}
+
+func (t totals) response() map[string]string {
+	res := make(map[string]string, len(t))
+	for code, a := range t {
+		res[code] = currFmt.Format(a)
+	}
+
+	return res
+}
+
+// buildPortfolio splits the held bids between active bids, on invoices still being
+// funded, and positions on traded invoices, and aggregates committed capital
+// and returns.
+func buildPortfolio(positions []invoice.Position, sales []invoice.Sale, issuers map[string]issuer.Issuer, now time.Time) ([]InvestorBidResponse, PortfolioResponse) {
+	activeBids := make([]InvestorBidResponse, 0)
+	res := PortfolioResponse{Positions: make([]InvestorBidResponse, 0)}
+
+	committed := totals{}
+	committedByIssuer := map[string]totals{}
+	unrealised := totals{}
+	for _, p := range positions {
+		committed.add(p.Cost)
+		if _, ok := committedByIssuer[p.Invoice.IssuerID]; !ok {
+			committedByIssuer[p.Invoice.IssuerID] = totals{}
+		}
+		committedByIssuer[p.Invoice.IssuerID].add(p.Cost)
+
+		pricing := p.Invoice.BidPricing(p.Bid, now)
+		bid := InvestorBidResponse{
+			ID:     p.Bid.ID,
+			Amount: currFmt.Format(p.Bid.Amount),
+			Cost:   currFmt.Format(p.Cost),
+			Invoice: InvestorInvoiceResponse{
+				ID:      p.Invoice.ID,
+				Status:  string(p.Invoice.Status),
+				DueDate: p.Invoice.DueDate.Format(dateLayout),
+				Issuer: InvoiceIssuerResponse{
+					ID:       p.Invoice.IssuerID,
+					FullName: issuers[p.Invoice.IssuerID].FullName,
+				},
+			},
+			Pricing: bidPricingResponse(p.Invoice, p.Bid, now),
+		}
+
+		if p.Invoice.Status != invoice.TRADED {
+			activeBids = append(activeBids, bid)
+			continue
+		}
+
+		if gain, err := pricing.ExpectedPayout.Sub(p.Cost); err == nil {
+			unrealised.add(gain)
+		}
+		res.Positions = append(res.Positions, bid)
+	}
+
+	realised := totals{}
+	for _, s := range sales {
+		realised.add(s.Return())
+	}
+
+	res.CommittedByCurrency = committed.response()
+	res.RealisedReturns = realised.response()
+	res.UnrealisedReturns = unrealised.response()
+
+	res.CommittedByIssuer = make([]IssuerCommitmentResponse, 0, len(committedByIssuer))
+	for id, t := range committedByIssuer {
+		res.CommittedByIssuer = append(res.CommittedByIssuer, IssuerCommitmentResponse{
+			Issuer: InvoiceIssuerResponse{
+				ID:       id,
+				FullName: issuers[id].FullName,
+			},
+			Committed: t.response(),
+		})
+	}
+	sort.Slice(res.CommittedByIssuer, func(i, j int) bool {
+		return res.CommittedByIssuer[i].Issuer.ID < res.CommittedByIssuer[j].Issuer.ID
+	})
+
+	return activeBids, res
+}
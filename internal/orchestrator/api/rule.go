@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/issuer"
+)
+
+type CreateRuleRequest struct {
+	Currency      string `json:"currency" example:"EUR"`
+	MinRating     string `json:"minRating" example:"B"`
+	MaxDueDays    int    `json:"maxDueDays" example:"90"`
+	MaxPerInvoice string `json:"maxPerInvoice" example:"500"`
+	MaxDeployed   string `json:"maxDeployed" example:"10000"`
+}
+
+type RuleResponse struct {
+	ID            string            `json:"id" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Currency      string            `json:"currency" example:"EUR"`
+	MinRating     string            `json:"minRating,omitempty" example:"B"`
+	MaxDueDays    int               `json:"maxDueDays,omitempty" example:"90"`
+	MaxPerInvoice string            `json:"maxPerInvoice" example:"500,00 €"`
+	MaxDeployed   string            `json:"maxDeployed" example:"10 000,00 €"`
+	Deployed      string            `json:"deployed" example:"1 230,45 €"`
+	Active        bool              `json:"active" example:"true"`
+	Bids          []RuleBidResponse `json:"bids,omitempty"`
+}
+
+type RuleBidResponse struct {
+	BidID     string `json:"bidId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	InvoiceID string `json:"invoiceId" example:"343abd7a-874c-4bb7-ba7b-81e9c71cf1b0"`
+	Amount    string `json:"amount" example:"500,00 €"`
+	CreatedAt string `json:"createdAt" example:"2023-07-20T10:00:00Z"`
+}
+
+// CreateRule creates an auto-bid rule for an investor
+// @Summary      New auto-bid rule
+// @Description  Create a rule that automatically bids on new invoices matching its criteria
+// @Tags         investor
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Investor id"
+// @Param request body CreateRuleRequest true "Rule request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Success      201  {object}  RuleResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /investor/:id/rules [post]
+func (s *Server) CreateRule(c echo.Context) error {
+	investorID := c.Param("id")
+	if investorID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, manageInvestor(investorID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	var req CreateRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return errBadRequest(err, c)
+	}
+	if !issuer.ValidRating(req.MinRating) {
+		return errBadRequest(fmt.Errorf("invalid rating %q", req.MinRating), c)
+	}
+	if req.MaxDueDays < 0 {
+		return errBadRequest(errors.New("max due days cannot be negative"), c)
+	}
+
+	maxPerInvoice, err := currency.NewAmount(req.MaxPerInvoice, req.Currency)
+	if err != nil {
+		return errBadRequest(fmt.Errorf("invalid max per invoice: %w", err), c)
+	}
+	maxDeployed, err := currency.NewAmount(req.MaxDeployed, req.Currency)
+	if err != nil {
+		return errBadRequest(fmt.Errorf("invalid max deployed: %w", err), c)
+	}
+	if !maxPerInvoice.IsPositive() || !maxDeployed.IsPositive() {
+		return errBadRequest(errors.New("rule limits must be positive"), c)
+	}
+
+	ctx := c.Request().Context()
+	inv, err := s.investorService.GetInvestor(ctx, investorID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	rule, err := s.investorService.CreateRule(ctx, investor.Rule{
+		InvestorID:    inv.ID,
+		Currency:      req.Currency,
+		MinRating:     req.MinRating,
+		MaxDueDays:    req.MaxDueDays,
+		MaxPerInvoice: maxPerInvoice,
+		MaxDeployed:   maxDeployed,
+	})
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	return c.JSON(http.StatusCreated, ruleResponse(rule, nil))
+}
+
+// ListRules retrieves the auto-bid rules of an investor
+// @Summary      List auto-bid rules
+// @Description  Retrieve the auto-bid rules of an investor with the bids each of them placed
+// @Tags         investor
+// @Produce      json
+// @Security     Bearer
+// @Param id path string true "Investor id"
+// @Success      200  {array}   RuleResponse
+// @Failure      400  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /investor/:id/rules [get]
+func (s *Server) ListRules(c echo.Context) error {
+	investorID := c.Param("id")
+	if investorID == "" {
+		return errBadRequest(errors.New("id cannot be empty"), c)
+	}
+	if err := s.authorize(c, viewInvestor(investorID)); err != nil {
+		return errHandler(err, c)
+	}
+
+	ctx := c.Request().Context()
+	rules, err := s.investorService.ListRules(ctx, investorID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	ruleBids, err := s.investorService.ListRuleBids(ctx, investorID)
+	if err != nil {
+		return errHandler(err, c)
+	}
+
+	bidsByRule := make(map[string][]investor.RuleBid, len(rules))
+	for _, rb := range ruleBids {
+		bidsByRule[rb.RuleID] = append(bidsByRule[rb.RuleID], rb)
+	}
+
+	res := make([]RuleResponse, 0, len(rules))
+	for _, r := range rules {
+		res = append(res, ruleResponse(r, bidsByRule[r.ID]))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func ruleResponse(rule investor.Rule, ruleBids []investor.RuleBid) RuleResponse {
+	res := RuleResponse{
+		ID:            rule.ID,
+		Currency:      rule.Currency,
+		MinRating:     rule.MinRating,
+		MaxDueDays:    rule.MaxDueDays,
+		MaxPerInvoice: currFmt.Format(rule.MaxPerInvoice),
+		MaxDeployed:   currFmt.Format(rule.MaxDeployed),
+		Deployed:      currFmt.Format(rule.Deployed),
+		Active:        rule.Active,
+	}
+
+	for _, rb := range ruleBids {
+		res.Bids = append(res.Bids, RuleBidResponse{
+			BidID:     rb.BidID,
+			InvoiceID: rb.InvoiceID,
+			Amount:    currFmt.Format(rb.Amount),
+			CreatedAt: rb.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/labstack/echo/v4"
+	"github.com/nerock/invoicebidder/internal/investor"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCreateRule(t *testing.T) {
+	Convey("CreateRule", t, func() {
+		var created investor.Rule
+		invstSvc := &mockInvestorService{
+			getInvestorFunc: func(_ context.Context, id string) (investor.Investor, error) {
+				return investor.Investor{ID: id}, nil
+			},
+			createRuleFunc: func(_ context.Context, rule investor.Rule) (investor.Rule, error) {
+				created = rule
+				rule.ID = "rule-1"
+				rule.Deployed, _ = currency.NewAmount("0", rule.Currency)
+				rule.Active = true
+				return rule, nil
+			},
+		}
+		srv := New(0, nil, invstSvc, nil, nil, nil, nil)
+
+		serve := func(body string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c := srv.e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("investor-1")
+			So(srv.CreateRule(c), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when the request is valid", func() {
+			rec := serve(`{"currency":"EUR","minRating":"B","maxDueDays":90,"maxPerInvoice":"500","maxDeployed":"10000"}`)
+
+			Convey("create the rule for the investor", func() {
+				So(rec.Code, ShouldEqual, http.StatusCreated)
+				So(created.InvestorID, ShouldEqual, "investor-1")
+				So(created.MinRating, ShouldEqual, "B")
+				So(created.MaxDueDays, ShouldEqual, 90)
+				So(created.MaxPerInvoice.Number(), ShouldEqual, "500")
+				So(created.MaxDeployed.Number(), ShouldEqual, "10000")
+
+				var res RuleResponse
+				So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+				So(res.ID, ShouldEqual, "rule-1")
+				So(res.Active, ShouldBeTrue)
+			})
+		})
+
+		for name, body := range map[string]string{
+			"an invalid rating":            `{"currency":"EUR","minRating":"Z","maxPerInvoice":"500","maxDeployed":"10000"}`,
+			"negative due days":            `{"currency":"EUR","maxDueDays":-1,"maxPerInvoice":"500","maxDeployed":"10000"}`,
+			"an invalid currency":          `{"currency":"XXX1","maxPerInvoice":"500","maxDeployed":"10000"}`,
+			"an invalid amount":            `{"currency":"EUR","maxPerInvoice":"abc","maxDeployed":"10000"}`,
+			"a limit that is not positive": `{"currency":"EUR","maxPerInvoice":"500","maxDeployed":"0"}`,
+		} {
+			body := body
+
+			Convey("when the request has "+name, func() {
+				rec := serve(body)
+
+				Convey("return bad request without creating the rule", func() {
+					So(rec.Code, ShouldEqual, http.StatusBadRequest)
+					So(created.InvestorID, ShouldBeEmpty)
+				})
+			})
+		}
+
+		Convey("when the investor does not exist", func() {
+			invstSvc.getInvestorFunc = func(context.Context, string) (investor.Investor, error) {
+				return investor.Investor{}, investor.ErrNotFound
+			}
+			rec := serve(`{"currency":"EUR","maxPerInvoice":"500","maxDeployed":"10000"}`)
+
+			Convey("return not found", func() {
+				So(rec.Code, ShouldEqual, http.StatusNotFound)
+				So(created.InvestorID, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the service fails", func() {
+			invstSvc.createRuleFunc = func(context.Context, investor.Rule) (investor.Rule, error) {
+				return investor.Rule{}, errors.New("error")
+			}
+			rec := serve(`{"currency":"EUR","maxPerInvoice":"500","maxDeployed":"10000"}`)
+
+			Convey("return internal error", func() {
+				So(rec.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+func TestListRules(t *testing.T) {
+	Convey("ListRules", t, func() {
+		amount := func(n string) currency.Amount {
+			a, err := currency.NewAmount(n, "EUR")
+			So(err, ShouldBeNil)
+			return a
+		}
+
+		created := time.Date(2023, 7, 20, 10, 0, 0, 0, time.UTC)
+		invstSvc := &mockInvestorService{
+			listRulesFunc: func(context.Context, string) ([]investor.Rule, error) {
+				return []investor.Rule{
+					{ID: "rule-1", Currency: "EUR", MaxPerInvoice: amount("100"), MaxDeployed: amount("500"), Deployed: amount("150"), Active: true},
+					{ID: "rule-2", Currency: "EUR", MaxPerInvoice: amount("50"), MaxDeployed: amount("100"), Deployed: amount("0"), Active: true},
+				}, nil
+			},
+			listRuleBidsFunc: func(context.Context, string) ([]investor.RuleBid, error) {
+				return []investor.RuleBid{
+					{RuleID: "rule-1", BidID: "bid-1", InvoiceID: "invoice-1", Amount: amount("100"), CreatedAt: created},
+					{RuleID: "rule-1", BidID: "bid-2", InvoiceID: "invoice-2", Amount: amount("50"), CreatedAt: created},
+				}, nil
+			},
+		}
+		srv := New(0, nil, invstSvc, nil, nil, nil, nil)
+
+		serve := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			c := srv.e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues("investor-1")
+			So(srv.ListRules(c), ShouldBeNil)
+			return rec
+		}
+
+		Convey("when the investor has rules", func() {
+			rec := serve()
+
+			Convey("return them with the bids each one placed", func() {
+				So(rec.Code, ShouldEqual, http.StatusOK)
+
+				var res []RuleResponse
+				So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+				So(res, ShouldHaveLength, 2)
+				So(res[0].ID, ShouldEqual, "rule-1")
+				So(res[0].Bids, ShouldHaveLength, 2)
+				So(res[0].Bids[0].BidID, ShouldEqual, "bid-1")
+				So(res[0].Bids[0].CreatedAt, ShouldEqual, "2023-07-20T10:00:00Z")
+				So(res[1].ID, ShouldEqual, "rule-2")
+				So(res[1].Bids, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the bids can't be retrieved", func() {
+			invstSvc.listRuleBidsFunc = func(context.Context, string) ([]investor.RuleBid, error) {
+				return nil, errors.New("error")
+			}
+			rec := serve()
+
+			Convey("return internal error", func() {
+				So(rec.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
@@ -15,14 +15,19 @@ import (
 
 var currFmt = currency.NewFormatter(currency.NewLocale("fr"))
 
+const dateLayout = "2006-01-02"
+
 type AmountRequest struct {
 	Amount   string `json:"amount" example:"1200.50"`
 	Currency string `json:"currency" example:"EUR"`
 }
 
 type Broker interface {
+	SendInvoiceCreatedEvent(string)
+	SendInvoiceReleasedEvent(string)
 	SendTradeEvent(string, []string, bool)
 	SendFailedBidEvent(string, currency.Amount)
+	SendFailedTransferEvent(string, string, currency.Amount)
 }
 
 type Server struct {
@@ -32,8 +37,16 @@ type Server struct {
 	invoiceService  InvoiceService
 	investorService InvestorService
 	issuerService   IssuerService
+	feeService      FeeService
+
+	broker           Broker
+	uploader         Uploader
+	integrityChecker IntegrityChecker
+	signer           *urlSigner
+	authService      AuthService
+	maxUpload        int64
 
-	broker Broker
+	idempotencyService IdempotencyService
 }
 
 // New creates a new server
@@ -43,8 +56,13 @@ type Server struct {
 // @contact.name Manuel Adalid
 // @contact.url https://manueladalid.dev
 // @contact.email manueladalidmoya@gmail.com
-func New(port int, invoiceService InvoiceService, investorService InvestorService, issuerService IssuerService, broker Broker) *Server {
+// @securityDefinitions.apikey Bearer
+// @in header
+// @name Authorization
+// @description JWT or API key, as "Bearer <credential>"
+func New(port int, invoiceService InvoiceService, investorService InvestorService, issuerService IssuerService, feeService FeeService, broker Broker, uploader Uploader) *Server {
 	e := echo.New()
+	e.HTTPErrorHandler = httpErrorHandler
 	e.Use(middleware.Logger())
 	e.Pre(middleware.RemoveTrailingSlash())
 
@@ -54,16 +72,28 @@ func New(port int, invoiceService InvoiceService, investorService InvestorServic
 		invoiceService:  invoiceService,
 		investorService: investorService,
 		issuerService:   issuerService,
+		feeService:      feeService,
 		broker:          broker,
+		uploader:        uploader,
 	}
 }
 
 func (s *Server) Serve() error {
 	s.e.GET("/swagger/*", echoSwagger.WrapHandler)
 
+	if s.authService != nil {
+		s.e.Use(s.authenticate)
+		s.authRoutes(s.e.Group("/auth"))
+	}
+	if s.idempotencyService != nil {
+		s.e.Use(s.idempotent)
+	}
+
 	s.issuerRoutes(s.e.Group("/issuer"))
 	s.investorRoutes(s.e.Group("/investor"))
 	s.invoiceRoutes(s.e.Group("/invoice"))
+	s.marketRoutes(s.e.Group("/market"))
+	s.platformRoutes(s.e.Group("/platform"))
 
 	go func() {
 		if err := s.e.Start(fmt.Sprintf(":%d", s.port)); err != nil {
@@ -3,38 +3,112 @@ package api
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/bojanz/currency"
 	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/invoice/einvoice"
 	"github.com/nerock/invoicebidder/internal/issuer"
 )
 
 type mockIssuerService struct {
 	getIssuerFunc    func(context.Context, string) (issuer.Issuer, error)
-	createIssuerFunc func(context.Context, string) (issuer.Issuer, error)
+	listIssuersFunc  func(context.Context, []string) (map[string]issuer.Issuer, error)
+	createIssuerFunc func(context.Context, string, string) (issuer.Issuer, error)
 }
 
 func (m *mockIssuerService) GetIssuer(ctx context.Context, id string) (issuer.Issuer, error) {
 	return m.getIssuerFunc(ctx, id)
 }
 
-func (m *mockIssuerService) CreateIssuer(ctx context.Context, name string) (issuer.Issuer, error) {
-	return m.createIssuerFunc(ctx, name)
+func (m *mockIssuerService) ListIssuers(ctx context.Context, ids []string) (map[string]issuer.Issuer, error) {
+	return m.listIssuersFunc(ctx, ids)
+}
+
+func (m *mockIssuerService) CreateIssuer(ctx context.Context, name, rating string) (issuer.Issuer, error) {
+	return m.createIssuerFunc(ctx, name, rating)
+}
+
+func (m *mockIssuerService) AddPayoutAccount(ctx context.Context, issuerID, iban, holder string, auto bool) (issuer.PayoutAccount, error) {
+	panic("implement me")
+}
+
+func (m *mockIssuerService) ListPayoutAccounts(ctx context.Context, issuerID string) ([]issuer.PayoutAccount, error) {
+	panic("implement me")
+}
+
+func (m *mockIssuerService) RequestPayout(ctx context.Context, issuerID, accountID string, amount currency.Amount) (issuer.Payout, error) {
+	panic("implement me")
+}
+
+func (m *mockIssuerService) ListPayouts(ctx context.Context, issuerID string) ([]issuer.Payout, error) {
+	panic("implement me")
+}
+
+func (m *mockIssuerService) SendPayout(ctx context.Context, issuerID, id string) (issuer.Payout, error) {
+	panic("implement me")
+}
+
+func (m *mockIssuerService) FailPayout(ctx context.Context, issuerID, id string) (issuer.Payout, error) {
+	panic("implement me")
 }
 
 type mockInvoiceService struct {
 	getByIssuerIDFunc func(context.Context, string) ([]invoice.Invoice, error)
+	listInvoicesFunc  func(context.Context, invoice.Query) ([]invoice.Invoice, *invoice.Cursor, error)
+	getInvoiceFunc    func(context.Context, string) (invoice.Invoice, error)
+	getDocumentFunc   func(context.Context, string) (invoice.Invoice, invoice.Document, error)
+	approveTradeFunc  func(context.Context, string, bool) ([]string, error)
+
+	reserveListingFunc  func(context.Context, string, string) (invoice.Listing, error)
+	releaseListingFunc  func(context.Context, string, string) error
+	buyListingFunc      func(context.Context, string, string) (invoice.Listing, error)
+	withdrawListingFunc func(context.Context, string, string) error
+
+	getPositionsFunc       func(context.Context, string) ([]invoice.Position, error)
+	getSalesFunc           func(context.Context, string) ([]invoice.Sale, error)
+	getIssuerDashboardFunc func(context.Context, string) (invoice.Dashboard, error)
+	reviewExtractionFunc   func(context.Context, string) error
+	getEInvoiceFunc        func(context.Context, string) (einvoice.Document, error)
 }
 
-func (m *mockInvoiceService) GetInvoice(ctx context.Context, s string) (invoice.Invoice, error) {
+func (m *mockInvoiceService) VerifyFile(ctx context.Context, id string) (invoice.Integrity, error) {
+	panic("implement me")
+}
+
+func (m *mockInvoiceService) GetExtraction(ctx context.Context, id string) (invoice.Extraction, error) {
+	panic("implement me")
+}
+
+func (m *mockInvoiceService) ListFlaggedExtractions(ctx context.Context) ([]invoice.Extraction, error) {
 	panic("implement me")
 }
 
+func (m *mockInvoiceService) ReviewExtraction(ctx context.Context, id string) error {
+	return m.reviewExtractionFunc(ctx, id)
+}
+
+func (m *mockInvoiceService) GetEInvoice(ctx context.Context, id string) (einvoice.Document, error) {
+	return m.getEInvoiceFunc(ctx, id)
+}
+
+func (m *mockInvoiceService) GetDocument(ctx context.Context, id string) (invoice.Invoice, invoice.Document, error) {
+	return m.getDocumentFunc(ctx, id)
+}
+
+func (m *mockInvoiceService) GetInvoice(ctx context.Context, s string) (invoice.Invoice, error) {
+	return m.getInvoiceFunc(ctx, s)
+}
+
 func (m *mockInvoiceService) GetRemainingPrice(ctx context.Context, s string) (currency.Amount, error) {
 	panic("implement me")
 }
 
-func (m *mockInvoiceService) CreateInvoice(ctx context.Context, s string, amount currency.Amount, reader io.Reader) (invoice.Invoice, error) {
+func (m *mockInvoiceService) ListInvoices(ctx context.Context, q invoice.Query) ([]invoice.Invoice, *invoice.Cursor, error) {
+	return m.listInvoicesFunc(ctx, q)
+}
+
+func (m *mockInvoiceService) CreateInvoice(ctx context.Context, s string, amount currency.Amount, faceValue currency.Amount, t time.Time, reader io.Reader) (invoice.Invoice, error) {
 	panic("implement me")
 }
 
@@ -43,9 +117,58 @@ func (m *mockInvoiceService) PlaceBid(ctx context.Context, s string, s2 string,
 }
 
 func (m *mockInvoiceService) ApproveTrade(ctx context.Context, s string, b bool) ([]string, error) {
+	return m.approveTradeFunc(ctx, s, b)
+}
+
+func (m *mockInvoiceService) ListPosition(ctx context.Context, s string, s2 string, amount currency.Amount) (invoice.Listing, error) {
 	panic("implement me")
 }
 
+func (m *mockInvoiceService) GetListing(ctx context.Context, s string) (invoice.Listing, error) {
+	panic("implement me")
+}
+
+func (m *mockInvoiceService) ListOpenListings(ctx context.Context) ([]invoice.Listing, error) {
+	panic("implement me")
+}
+
+func (m *mockInvoiceService) ReserveListing(ctx context.Context, s string, s2 string) (invoice.Listing, error) {
+	return m.reserveListingFunc(ctx, s, s2)
+}
+
+func (m *mockInvoiceService) ReleaseListing(ctx context.Context, s string, s2 string) error {
+	return m.releaseListingFunc(ctx, s, s2)
+}
+
+func (m *mockInvoiceService) BuyListing(ctx context.Context, s string, s2 string) (invoice.Listing, error) {
+	return m.buyListingFunc(ctx, s, s2)
+}
+
+func (m *mockInvoiceService) WithdrawListing(ctx context.Context, s string, s2 string) error {
+	return m.withdrawListingFunc(ctx, s, s2)
+}
+
+func (m *mockInvoiceService) GetPositions(ctx context.Context, s string) ([]invoice.Position, error) {
+	return m.getPositionsFunc(ctx, s)
+}
+
+func (m *mockInvoiceService) GetSales(ctx context.Context, s string) ([]invoice.Sale, error) {
+	return m.getSalesFunc(ctx, s)
+}
+
+func (m *mockInvoiceService) GetIssuerDashboard(ctx context.Context, s string) (invoice.Dashboard, error) {
+	return m.getIssuerDashboardFunc(ctx, s)
+}
+
 func (m *mockInvoiceService) GetByIssuerID(ctx context.Context, id string) ([]invoice.Invoice, error) {
 	return m.getByIssuerIDFunc(ctx, id)
 }
+
+type mockFeeService struct {
+	FeeService
+	getIssuerFeesFunc func(context.Context, string) (map[string]currency.Amount, error)
+}
+
+func (m *mockFeeService) GetIssuerFees(ctx context.Context, issuerID string) (map[string]currency.Amount, error) {
+	return m.getIssuerFeesFunc(ctx, issuerID)
+}
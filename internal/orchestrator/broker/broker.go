@@ -2,69 +2,161 @@ package broker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/nerock/invoicebidder/internal/fee"
 	"github.com/nerock/invoicebidder/internal/investor"
+	"github.com/nerock/invoicebidder/internal/issuer"
 
 	"github.com/bojanz/currency"
+	"github.com/google/uuid"
 	"github.com/nerock/invoicebidder/internal/invoice"
 )
 
 type InvoiceService interface {
 	GetInvoice(context.Context, string) (invoice.Invoice, error)
+	GetRemainingPrice(context.Context, string) (currency.Amount, error)
 	ListBidsByIDs(context.Context, []string) ([]invoice.Bid, error)
+	PlaceBidWithID(context.Context, string, string, string, currency.Amount) error
+	ExtractInvoice(context.Context, string) (invoice.Extraction, error)
 }
 type InvestorService interface {
+	Bid(context.Context, string, currency.Amount) error
 	CancelTrade(context.Context, []investor.Bid) error
 	CancelBid(context.Context, string, currency.Amount) error
+	Transfer(context.Context, string, string, currency.Amount) error
+	MatchRules(context.Context, string, string, string, time.Time) ([]investor.Rule, error)
+	RecordRuleBid(context.Context, string, string, string, currency.Amount) error
+	ReleaseRuleBids(context.Context, []string) error
+	ChargeFee(context.Context, string, currency.Amount) error
 }
 
 type IssuerService interface {
-	ApproveTrade(context.Context, string, currency.Amount) error
+	GetIssuer(context.Context, string) (issuer.Issuer, error)
+	ApproveTrade(context.Context, string, string, currency.Amount) error
+	AutoPayout(context.Context, string, string, currency.Amount) error
+	FailAutoPayout(context.Context, string, string, currency.Amount) error
 }
 
+type FeeService interface {
+	Settle(context.Context, string, string, currency.Amount, []fee.Bid) (fee.Settlement, error)
+	WaiveFee(context.Context, currency.Amount) error
+}
+
+// retryBackoff is how long a failed event waits before its first retry, the
+// wait doubles on every following one
+const retryBackoff = 500 * time.Millisecond
+
 type Broker struct {
 	maxRetries    int
+	backoff       time.Duration
 	eventHandlers int
 	events        chan Event
 	wg            *sync.WaitGroup
+	mu            sync.RWMutex
+	closed        bool
 
 	invoiceService  InvoiceService
 	investorService InvestorService
 	issuerService   IssuerService
+	feeService      FeeService
+}
+
+// SendInvoiceCreatedEvent extracts a new invoice. The bid rules are matched
+// once the extraction is saved, so they never bid on an invoice that is about
+// to be held.
+func (b *Broker) SendInvoiceCreatedEvent(invoiceID string) {
+	b.send(&ExtractionEvent{
+		InvoiceID: invoiceID,
+	})
+}
+
+// SendInvoiceReleasedEvent matches the bid rules against an invoice whose
+// hold was lifted.
+func (b *Broker) SendInvoiceReleasedEvent(invoiceID string) {
+	b.send(&InvoiceCreatedEvent{
+		InvoiceID: invoiceID,
+	})
 }
 
 func (b *Broker) SendTradeEvent(invoiceID string, bidsIDs []string, approved bool) {
-	b.events <- &TradeEvent{
+	b.send(&TradeEvent{
 		InvoiceID: invoiceID,
 		Bids:      bidsIDs,
 		Approved:  approved,
-	}
+	})
 }
 
 func (b *Broker) SendFailedBidEvent(investorID string, amount currency.Amount) {
-	b.events <- &FailedBidEvent{
+	b.send(&FailedBidEvent{
 		InvestorID: investorID,
 		Amount:     amount,
+	})
+}
+
+// SendFailedTransferEvent reverts a transfer between investors whose
+// counterpart operation could not be completed.
+func (b *Broker) SendFailedTransferEvent(fromID, toID string, amount currency.Amount) {
+	b.send(&FailedTransferEvent{
+		FromID: fromID,
+		ToID:   toID,
+		Amount: amount,
+	})
+}
+
+// SendPayoutEvent pays out trade proceeds to the automatic payout account of
+// the issuer, if any, under the given payout id.
+func (b *Broker) SendPayoutEvent(payoutID, issuerID string, amount currency.Amount) {
+	b.send(&PayoutEvent{
+		PayoutID: payoutID,
+		IssuerID: issuerID,
+		Amount:   amount,
+	})
+}
+
+// SendFeeEvent charges a trade fee to an investor.
+func (b *Broker) SendFeeEvent(investorID string, amount currency.Amount) {
+	b.send(&FeeEvent{
+		InvestorID: investorID,
+		Amount:     amount,
+	})
+}
+
+// send queues an event unless the broker is shutting down
+func (b *Broker) send(e Event) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		log.Printf("broker closed, dropping %s event", e.Type())
+		return false
 	}
+	b.events <- e
+
+	return true
 }
 
-func New(eventHandlers, eventBuffer, maxRetries int, invoiceService InvoiceService, investorService InvestorService, issuerService IssuerService) *Broker {
+func New(eventHandlers, eventBuffer, maxRetries int, invoiceService InvoiceService, investorService InvestorService, issuerService IssuerService, feeService FeeService) *Broker {
 	return &Broker{
 		invoiceService:  invoiceService,
 		investorService: investorService,
 		issuerService:   issuerService,
+		feeService:      feeService,
 		events:          make(chan Event, eventBuffer), // Random buffer number
 		eventHandlers:   eventHandlers,
 		maxRetries:      maxRetries,
+		backoff:         retryBackoff,
 		wg:              &sync.WaitGroup{},
 	}
 }
 
 func (b *Broker) Serve() error {
 	for i := 0; i < b.eventHandlers; i++ {
+		b.wg.Add(1)
 		go b.eventHandler(b.wg, b.events)
 	}
 
@@ -72,7 +164,12 @@ func (b *Broker) Serve() error {
 }
 
 func (b *Broker) Shutdown(ctx context.Context) error {
-	close(b.events)
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.events)
+	}
+	b.mu.Unlock()
 
 	closeChan := make(chan struct{})
 	go func() {
@@ -91,7 +188,6 @@ func (b *Broker) Shutdown(ctx context.Context) error {
 }
 
 func (b *Broker) eventHandler(wg *sync.WaitGroup, events chan Event) {
-	wg.Add(1)
 	defer wg.Done()
 
 	for e := range events {
@@ -102,23 +198,70 @@ func (b *Broker) eventHandler(wg *sync.WaitGroup, events chan Event) {
 			err = b.tradeEventHandler(e.(*TradeEvent))
 		case TypeFailedBidEvent:
 			err = b.failedBidEventHandler(e.(*FailedBidEvent))
+		case TypeInvoiceCreatedEvent:
+			err = b.invoiceCreatedEventHandler(e.(*InvoiceCreatedEvent))
+		case TypeFailedTransferEvent:
+			err = b.failedTransferEventHandler(e.(*FailedTransferEvent))
+		case TypePayoutEvent:
+			err = b.payoutEventHandler(e.(*PayoutEvent))
+		case TypeFeeEvent:
+			err = b.feeEventHandler(e.(*FeeEvent))
+		case TypeExtractionEvent:
+			err = b.extractionEventHandler(e.(*ExtractionEvent))
 		}
 
 		if err != nil {
-			if e.Retries() > b.maxRetries {
-				log.Printf("max retries exahusted: %s", err)
+			if e.Retries() >= b.maxRetries {
+				b.deadLetter(e, err)
 			} else {
 				log.Println(err)
-				events <- e
+				b.retry(e)
 			}
 		}
 	}
 }
 
+// retry queues the event again once its backoff has passed, without holding
+// up the handler meanwhile. Retries due after shutdown are dropped.
+func (b *Broker) retry(e Event) {
+	delay := b.backoff << e.Retries()
+	e.Resend()
+
+	time.AfterFunc(delay, func() {
+		b.send(e)
+	})
+}
+
+// deadLetter logs an event that will not be retried again with everything
+// needed to replay it by hand, and runs its compensation
+func (b *Broker) deadLetter(e Event, err error) {
+	log.Printf("dead letter %s %+v after %d retries: %s", e.Type(), e, e.Retries(), err)
+
+	switch ev := e.(type) {
+	case *PayoutEvent:
+		if err := b.issuerService.FailAutoPayout(context.Background(), ev.IssuerID, ev.PayoutID, ev.Amount); err != nil {
+			log.Printf("could not fail payout %s: %s", ev.PayoutID, err)
+		}
+	case *ExtractionEvent:
+		// an invoice without extraction is not held, so the bid rules still
+		// get to bid on it
+		b.SendInvoiceReleasedEvent(ev.InvoiceID)
+	case *FeeEvent:
+		// the fee was credited to the revenue when the trade was settled
+		if err := b.feeService.WaiveFee(context.Background(), ev.Amount); err != nil {
+			log.Printf("could not waive fee of investor %s: %s", ev.InvestorID, err)
+		}
+	}
+}
+
 func (b *Broker) failedBidEventHandler(be *FailedBidEvent) error {
 	return b.investorService.CancelBid(context.Background(), be.InvestorID, be.Amount)
 }
 
+func (b *Broker) failedTransferEventHandler(te *FailedTransferEvent) error {
+	return b.investorService.Transfer(context.Background(), te.ToID, te.FromID, te.Amount)
+}
+
 func (b *Broker) tradeEventHandler(te *TradeEvent) error {
 	var err error
 	if te.Approved {
@@ -131,12 +274,68 @@ func (b *Broker) tradeEventHandler(te *TradeEvent) error {
 }
 
 func (b *Broker) approveTradeEvent(invoiceID string) error {
-	inv, err := b.invoiceService.GetInvoice(context.Background(), invoiceID)
+	ctx := context.Background()
+	inv, err := b.invoiceService.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+
+	payoutID, err := uuid.NewUUID()
+	if err != nil {
+		return fmt.Errorf("could not generate id: %w", err)
+	}
+
+	bids := make([]fee.Bid, 0, len(inv.Bids))
+	for _, bid := range inv.Bids {
+		bids = append(bids, fee.Bid{
+			InvestorID: bid.InvestorID,
+			Amount:     bid.Amount,
+		})
+	}
+
+	settlement, err := b.feeService.Settle(ctx, inv.ID, inv.IssuerID, inv.Price, bids)
+	if err != nil {
+		return err
+	}
+
+	if err := b.issuerService.ApproveTrade(ctx, inv.IssuerID, inv.ID, settlement.IssuerNet); err != nil {
+		return err
+	}
+
+	// Fees and payout are separate events so retrying them never credits the trade twice
+	for _, item := range settlement.Items {
+		if item.Payer == fee.INVESTOR && item.Amount.IsPositive() {
+			b.SendFeeEvent(item.PayerID, item.Amount)
+		}
+	}
+	b.SendPayoutEvent(payoutID.String(), inv.IssuerID, settlement.IssuerNet)
+
+	return nil
+}
+
+func (b *Broker) feeEventHandler(fe *FeeEvent) error {
+	return b.investorService.ChargeFee(context.Background(), fe.InvestorID, fe.Amount)
+}
+
+func (b *Broker) extractionEventHandler(ee *ExtractionEvent) error {
+	extraction, err := b.invoiceService.ExtractInvoice(context.Background(), ee.InvoiceID)
 	if err != nil {
 		return err
 	}
 
-	return b.issuerService.ApproveTrade(context.Background(), inv.IssuerID, inv.Price)
+	// the invoice service holds flagged invoices until they are reviewed
+	if extraction.Flagged() {
+		log.Printf("invoice %s held for review: %v", ee.InvoiceID, extraction.Mismatches)
+		return nil
+	}
+
+	b.SendInvoiceReleasedEvent(ee.InvoiceID)
+
+	return nil
+}
+
+func (b *Broker) payoutEventHandler(pe *PayoutEvent) error {
+	return b.issuerService.AutoPayout(context.Background(), pe.IssuerID, pe.PayoutID, pe.Amount)
 }
 
 func (b *Broker) cancelTradeEvent(bidsIDs []string) error {
@@ -145,6 +344,11 @@ func (b *Broker) cancelTradeEvent(bidsIDs []string) error {
 		return err
 	}
 
+	// released first as it can be retried without side effects
+	if err := b.investorService.ReleaseRuleBids(context.Background(), bidsIDs); err != nil {
+		return err
+	}
+
 	invBids := make([]investor.Bid, len(bids))
 	for _, bid := range bids {
 		invBids = append(invBids, investor.Bid{
@@ -155,3 +359,70 @@ func (b *Broker) cancelTradeEvent(bidsIDs []string) error {
 
 	return b.investorService.CancelTrade(context.Background(), invBids)
 }
+
+func (b *Broker) invoiceCreatedEventHandler(ie *InvoiceCreatedEvent) error {
+	ctx := context.Background()
+	inv, err := b.invoiceService.GetInvoice(ctx, ie.InvoiceID)
+	if err != nil {
+		return err
+	}
+
+	iss, err := b.issuerService.GetIssuer(ctx, inv.IssuerID)
+	if err != nil {
+		return err
+	}
+
+	rules, err := b.investorService.MatchRules(ctx, inv.ID, inv.Price.CurrencyCode(), iss.Rating, inv.DueDate)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rules {
+		remaining, err := b.invoiceService.GetRemainingPrice(ctx, inv.ID)
+		if err != nil {
+			return err
+		}
+
+		if !remaining.IsPositive() {
+			return nil
+		}
+
+		amount := r.BidAmount(remaining)
+		if !amount.IsPositive() {
+			continue
+		}
+
+		id, err := uuid.NewUUID()
+		if err != nil {
+			return fmt.Errorf("could not generate id: %w", err)
+		}
+		bidID := id.String()
+
+		// the amount is reserved in the rule before bidding so concurrent
+		// invoices can't deploy more than the rule allows
+		if err := b.investorService.RecordRuleBid(ctx, r.ID, bidID, inv.ID, amount); err != nil {
+			if errors.Is(err, investor.ErrRuleExhausted) {
+				continue
+			}
+			return err
+		}
+
+		if err := b.investorService.Bid(ctx, r.InvestorID, amount); err != nil {
+			log.Printf("rule %s could not bid on invoice %s: %s", r.ID, inv.ID, err)
+			if err := b.investorService.ReleaseRuleBids(ctx, []string{bidID}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := b.invoiceService.PlaceBidWithID(ctx, bidID, inv.ID, r.InvestorID, amount); err != nil {
+			b.SendFailedBidEvent(r.InvestorID, amount)
+			if errRelease := b.investorService.ReleaseRuleBids(ctx, []string{bidID}); errRelease != nil {
+				err = fmt.Errorf("%w: %w", err, errRelease)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
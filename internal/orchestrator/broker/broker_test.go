@@ -0,0 +1,320 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockIssuerService struct {
+	IssuerService
+
+	mu          sync.Mutex
+	autoPayouts []string
+	autoPayout  func(context.Context, string, string, currency.Amount) error
+	failed      chan string
+}
+
+func (m *mockIssuerService) AutoPayout(ctx context.Context, issuerID, payoutID string, amount currency.Amount) error {
+	m.mu.Lock()
+	m.autoPayouts = append(m.autoPayouts, payoutID)
+	m.mu.Unlock()
+
+	return m.autoPayout(ctx, issuerID, payoutID, amount)
+}
+
+func (m *mockIssuerService) FailAutoPayout(_ context.Context, _, payoutID string, _ currency.Amount) error {
+	m.failed <- payoutID
+	return nil
+}
+
+func (m *mockIssuerService) calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.autoPayouts...)
+}
+
+type mockInvestorService struct {
+	InvestorService
+
+	chargeFee func(context.Context, string, currency.Amount) error
+}
+
+func (m *mockInvestorService) ChargeFee(ctx context.Context, id string, amount currency.Amount) error {
+	return m.chargeFee(ctx, id, amount)
+}
+
+type mockFeeService struct {
+	FeeService
+
+	waived chan currency.Amount
+}
+
+func (m *mockFeeService) WaiveFee(_ context.Context, amount currency.Amount) error {
+	m.waived <- amount
+	return nil
+}
+
+type mockInvoiceService struct {
+	InvoiceService
+
+	extractions chan time.Time
+	extract     func(int) (invoice.Extraction, error)
+	calls       int
+	// released gets the invoices whose bid rules were matched
+	released chan string
+}
+
+func (m *mockInvoiceService) GetInvoice(_ context.Context, id string) (invoice.Invoice, error) {
+	m.released <- id
+	return invoice.Invoice{}, invoice.ErrNotFound
+}
+
+func (m *mockInvoiceService) ExtractInvoice(context.Context, string) (invoice.Extraction, error) {
+	m.calls++
+	defer func() { m.extractions <- time.Now() }()
+
+	return m.extract(m.calls)
+}
+
+func TestBroker_ExtractionEvent(t *testing.T) {
+	Convey("ExtractionEvent", t, func() {
+		const maxRetries = 2
+
+		invSvc := &mockInvoiceService{extractions: make(chan time.Time, 10), released: make(chan string, 10)}
+		b := New(1, 10, maxRetries, invSvc, nil, nil, nil)
+		b.backoff = 20 * time.Millisecond
+		So(b.Serve(), ShouldBeNil)
+
+		wait := func() time.Time {
+			select {
+			case at := <-invSvc.extractions:
+				return at
+			case <-time.After(time.Second):
+				So("extraction never ran", ShouldBeEmpty)
+				return time.Time{}
+			}
+		}
+
+		Convey("when the invoice is flagged", func() {
+			invSvc.extract = func(int) (invoice.Extraction, error) {
+				return invoice.Extraction{InvoiceID: "invoiceID", Mismatches: []invoice.Mismatch{invoice.TOTAL_MISMATCH}}, nil
+			}
+			b.send(&ExtractionEvent{InvoiceID: "invoiceID"})
+
+			Convey("extract it once and hold it from the bid rules", func() {
+				wait()
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				So(b.Shutdown(ctx), ShouldBeNil)
+
+				So(invSvc.calls, ShouldEqual, 1)
+				So(invSvc.released, ShouldBeEmpty)
+			})
+		})
+
+		Convey("when the invoice matches its file", func() {
+			invSvc.extract = func(int) (invoice.Extraction, error) {
+				return invoice.Extraction{InvoiceID: "invoiceID"}, nil
+			}
+			b.send(&ExtractionEvent{InvoiceID: "invoiceID"})
+
+			Convey("match the bid rules once it is extracted", func() {
+				extracted := wait()
+				select {
+				case id := <-invSvc.released:
+					So(id, ShouldEqual, "invoiceID")
+					So(time.Now().After(extracted), ShouldBeTrue)
+				case <-time.After(time.Second):
+					So("bid rules were never matched", ShouldBeEmpty)
+				}
+			})
+		})
+
+		Convey("when the extraction keeps failing", func() {
+			invSvc.extract = func(int) (invoice.Extraction, error) {
+				return invoice.Extraction{}, errors.New("file storage down")
+			}
+			b.send(&ExtractionEvent{InvoiceID: "invoiceID"})
+
+			Convey("retry it with a growing backoff until it is dead lettered", func() {
+				first := wait()
+				second := wait()
+				third := wait()
+
+				So(second.Sub(first), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+				So(third.Sub(second), ShouldBeGreaterThanOrEqualTo, 40*time.Millisecond)
+
+				select {
+				case <-invSvc.extractions:
+					So("extraction retried after max retries", ShouldBeEmpty)
+				case <-time.After(200 * time.Millisecond):
+				}
+			})
+		})
+
+		Convey("when the extraction succeeds on a retry", func() {
+			invSvc.extract = func(call int) (invoice.Extraction, error) {
+				if call == 1 {
+					return invoice.Extraction{}, errors.New("file storage down")
+				}
+				return invoice.Extraction{InvoiceID: "invoiceID"}, nil
+			}
+			b.send(&ExtractionEvent{InvoiceID: "invoiceID"})
+
+			Convey("stop retrying", func() {
+				wait()
+				wait()
+
+				select {
+				case <-invSvc.extractions:
+					So("extraction retried after succeeding", ShouldBeEmpty)
+				case <-time.After(100 * time.Millisecond):
+				}
+			})
+		})
+
+		Reset(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = b.Shutdown(ctx)
+		})
+	})
+}
+
+func TestBroker_PayoutEvent(t *testing.T) {
+	Convey("PayoutEvent", t, func() {
+		const maxRetries = 2
+
+		issSvc := &mockIssuerService{failed: make(chan string, 1)}
+		b := New(1, 10, maxRetries, nil, nil, issSvc, nil)
+		b.backoff = time.Millisecond
+		So(b.Serve(), ShouldBeNil)
+		amount, _ := currency.NewAmount("100", "EUR")
+
+		Convey("when the payout keeps failing", func() {
+			issSvc.autoPayout = func(context.Context, string, string, currency.Amount) error {
+				return errors.New("bank down")
+			}
+			b.SendPayoutEvent("payoutID", "issuerID", amount)
+
+			Convey("retry it a bounded number of times and mark it failed", func() {
+				select {
+				case id := <-issSvc.failed:
+					So(id, ShouldEqual, "payoutID")
+				case <-time.After(time.Second):
+					So("payout was never failed", ShouldBeEmpty)
+				}
+
+				So(issSvc.calls(), ShouldResemble, []string{"payoutID", "payoutID", "payoutID"})
+			})
+		})
+
+		Convey("when the payout succeeds on a retry", func() {
+			var attempts int
+			paid := make(chan struct{})
+			issSvc.autoPayout = func(context.Context, string, string, currency.Amount) error {
+				attempts++
+				if attempts == 1 {
+					return errors.New("bank down")
+				}
+				close(paid)
+				return nil
+			}
+			b.SendPayoutEvent("payoutID", "issuerID", amount)
+
+			Convey("not mark it failed", func() {
+				select {
+				case <-paid:
+				case <-time.After(time.Second):
+					So("payout was never retried", ShouldBeEmpty)
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				So(b.Shutdown(ctx), ShouldBeNil)
+
+				So(issSvc.calls(), ShouldHaveLength, 2)
+				So(issSvc.failed, ShouldBeEmpty)
+			})
+		})
+
+		Reset(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = b.Shutdown(ctx)
+		})
+	})
+}
+
+func TestBroker_FeeEvent(t *testing.T) {
+	Convey("FeeEvent", t, func() {
+		const maxRetries = 2
+
+		invstSvc := &mockInvestorService{}
+		feeSvc := &mockFeeService{waived: make(chan currency.Amount, 1)}
+		b := New(1, 10, maxRetries, nil, invstSvc, nil, feeSvc)
+		b.backoff = time.Millisecond
+		So(b.Serve(), ShouldBeNil)
+		amount, _ := currency.NewAmount("2.5", "EUR")
+
+		Convey("when the fee can never be charged", func() {
+			var mu sync.Mutex
+			var attempts int
+			invstSvc.chargeFee = func(context.Context, string, currency.Amount) error {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts++
+				return errors.New("insufficient funds")
+			}
+			b.SendFeeEvent("investorID", amount)
+
+			Convey("take it back out of the revenue once it is dead lettered", func() {
+				select {
+				case waived := <-feeSvc.waived:
+					So(waived.Equal(amount), ShouldBeTrue)
+				case <-time.After(time.Second):
+					So("fee was never waived", ShouldBeEmpty)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				So(attempts, ShouldEqual, maxRetries+1)
+			})
+		})
+
+		Convey("when the fee is charged", func() {
+			charged := make(chan struct{})
+			invstSvc.chargeFee = func(context.Context, string, currency.Amount) error {
+				close(charged)
+				return nil
+			}
+			b.SendFeeEvent("investorID", amount)
+
+			Convey("keep it in the revenue", func() {
+				select {
+				case <-charged:
+				case <-time.After(time.Second):
+					So("fee was never charged", ShouldBeEmpty)
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				So(b.Shutdown(ctx), ShouldBeNil)
+
+				So(feeSvc.waived, ShouldBeEmpty)
+			})
+		})
+
+		Reset(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = b.Shutdown(ctx)
+		})
+	})
+}
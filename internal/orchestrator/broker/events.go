@@ -5,8 +5,13 @@ import "github.com/bojanz/currency"
 type EventType string
 
 const (
-	TypeTradeEvent     EventType = "TradeEvent"
-	TypeFailedBidEvent EventType = "TypeFailedBidEvent"
+	TypeTradeEvent          EventType = "TradeEvent"
+	TypeFailedBidEvent      EventType = "TypeFailedBidEvent"
+	TypeInvoiceCreatedEvent EventType = "InvoiceCreatedEvent"
+	TypeFailedTransferEvent EventType = "FailedTransferEvent"
+	TypePayoutEvent         EventType = "PayoutEvent"
+	TypeFeeEvent            EventType = "FeeEvent"
+	TypeExtractionEvent     EventType = "ExtractionEvent"
 )
 
 type Event interface {
@@ -51,3 +56,93 @@ func (be *FailedBidEvent) Resend() {
 func (be *FailedBidEvent) Retries() int {
 	return be.r
 }
+
+type InvoiceCreatedEvent struct {
+	InvoiceID string
+	r         int
+}
+
+func (ie *InvoiceCreatedEvent) Type() EventType {
+	return TypeInvoiceCreatedEvent
+}
+
+func (ie *InvoiceCreatedEvent) Resend() {
+	ie.r++
+}
+
+func (ie *InvoiceCreatedEvent) Retries() int {
+	return ie.r
+}
+
+type FailedTransferEvent struct {
+	FromID string
+	ToID   string
+	Amount currency.Amount
+	r      int
+}
+
+func (te *FailedTransferEvent) Type() EventType {
+	return TypeFailedTransferEvent
+}
+
+func (te *FailedTransferEvent) Resend() {
+	te.r++
+}
+
+func (te *FailedTransferEvent) Retries() int {
+	return te.r
+}
+
+type PayoutEvent struct {
+	PayoutID string
+	IssuerID string
+	Amount   currency.Amount
+	r        int
+}
+
+func (pe *PayoutEvent) Type() EventType {
+	return TypePayoutEvent
+}
+
+func (pe *PayoutEvent) Resend() {
+	pe.r++
+}
+
+func (pe *PayoutEvent) Retries() int {
+	return pe.r
+}
+
+type FeeEvent struct {
+	InvestorID string
+	Amount     currency.Amount
+	r          int
+}
+
+func (fe *FeeEvent) Type() EventType {
+	return TypeFeeEvent
+}
+
+func (fe *FeeEvent) Resend() {
+	fe.r++
+}
+
+func (fe *FeeEvent) Retries() int {
+	return fe.r
+}
+
+type ExtractionEvent struct {
+	InvoiceID string
+	r         int
+}
+
+func (ee *ExtractionEvent) Type() EventType {
+	return TypeExtractionEvent
+}
+
+func (ee *ExtractionEvent) Resend() {
+	ee.r++
+}
+
+func (ee *ExtractionEvent) Retries() int {
+	return ee.r
+}
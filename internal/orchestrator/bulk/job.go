@@ -0,0 +1,143 @@
+package bulk
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Status string
+
+const (
+	PENDING  Status = "pending"
+	RUNNING  Status = "running"
+	FINISHED Status = "finished"
+)
+
+// Job is a bulk upload of invoices, with the result of every manifest row.
+// The owner is the subject that submitted it.
+type Job struct {
+	ID         string
+	Owner      string
+	Status     Status
+	Results    []Result
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// Result of a manifest row, either the created invoice or why it failed.
+type Result struct {
+	Line      int
+	InvoiceID string
+	Err       string
+}
+
+func (j Job) Succeeded() int {
+	var n int
+	for _, r := range j.Results {
+		if r.InvoiceID != "" {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (j Job) Failed() int {
+	var n int
+	for _, r := range j.Results {
+		if r.Err != "" {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Row is a manifest line as found in the csv, validated when processed.
+type Row struct {
+	Line      int
+	IssuerID  string
+	Price     string
+	Currency  string
+	FaceValue string
+	DueDate   string
+	File      string
+}
+
+var requiredColumns = []string{"issuer_id", "price", "currency", "due_date", "file"}
+
+// ParseManifest reads the csv manifest. The header names the columns, and
+// face_value is the only optional one.
+func ParseManifest(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read manifest header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("manifest is missing column %q", name)
+		}
+	}
+
+	value := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+
+		return ""
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read manifest: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, Row{
+			Line:      line,
+			IssuerID:  value(record, "issuer_id"),
+			Price:     value(record, "price"),
+			Currency:  value(record, "currency"),
+			FaceValue: value(record, "face_value"),
+			DueDate:   value(record, "due_date"),
+			File:      value(record, "file"),
+		})
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("manifest has no rows")
+	}
+
+	return rows, nil
+}
+
+// files indexes the archive entries by name, ignoring directories.
+func files(archive *zip.Reader) map[string]*zip.File {
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		if !f.FileInfo().IsDir() {
+			files[f.Name] = f
+		}
+	}
+
+	return files
+}
@@ -0,0 +1,303 @@
+package bulk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/google/uuid"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/issuer"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	// archives are read in memory, so the files they expand to are capped too
+	maxArchiveEntries   = 1000
+	maxUncompressedSize = 512 << 20
+
+	// finished jobs are kept this long for their results to be retrieved
+	jobRetention = 24 * time.Hour
+)
+
+// ErrQueueFull is returned when the queue of pending uploads is full
+var ErrQueueFull = errors.New("too many bulk uploads in progress")
+
+type InvoiceService interface {
+	CreateInvoice(context.Context, string, currency.Amount, currency.Amount, time.Time, io.Reader) (invoice.Invoice, error)
+}
+
+type IssuerService interface {
+	GetIssuer(context.Context, string) (issuer.Issuer, error)
+}
+
+type Broker interface {
+	SendInvoiceCreatedEvent(string)
+}
+
+// Uploader processes bulk uploads in the background. Jobs are kept in memory
+// so they are only available until the application restarts.
+type Uploader struct {
+	workers int
+	queue   chan *task
+	wg      *sync.WaitGroup
+
+	mu     sync.RWMutex
+	jobs   map[string]*Job
+	closed bool
+
+	invoiceService InvoiceService
+	issuerService  IssuerService
+	broker         Broker
+}
+
+type task struct {
+	job     *Job
+	rows    []Row
+	archive *zip.Reader
+}
+
+func New(workers, buffer int, invoiceService InvoiceService, issuerService IssuerService, broker Broker) *Uploader {
+	return &Uploader{
+		workers:        workers,
+		queue:          make(chan *task, buffer),
+		wg:             &sync.WaitGroup{},
+		jobs:           map[string]*Job{},
+		invoiceService: invoiceService,
+		issuerService:  issuerService,
+		broker:         broker,
+	}
+}
+
+func (u *Uploader) Serve() error {
+	for i := 0; i < u.workers; i++ {
+		u.wg.Add(1)
+		go u.worker()
+	}
+
+	return nil
+}
+
+func (u *Uploader) Shutdown(ctx context.Context) error {
+	u.mu.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.queue)
+	}
+	u.mu.Unlock()
+
+	closeChan := make(chan struct{})
+	go func() {
+		u.wg.Wait()
+		closeChan <- struct{}{}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("timeout waiting for bulk uploads to complete")
+	case <-closeChan:
+		return nil
+	}
+}
+
+// Submit parses the manifest and the zip archive with the invoice files and
+// queues a job to create them. Only malformed uploads fail here, rows are
+// validated by the job.
+func (u *Uploader) Submit(owner string, manifest io.Reader, archive []byte) (Job, error) {
+	rows, err := ParseManifest(manifest)
+	if err != nil {
+		return Job{}, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return Job{}, fmt.Errorf("could not read archive: %w", err)
+	}
+
+	if err := checkArchive(zr); err != nil {
+		return Job{}, err
+	}
+
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return Job{}, fmt.Errorf("could not generate id: %w", err)
+	}
+
+	job := &Job{
+		ID:        id.String(),
+		Owner:     owner,
+		Status:    PENDING,
+		CreatedAt: time.Now(),
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.closed {
+		return Job{}, errors.New("bulk uploads are shutting down")
+	}
+	u.evict(job.CreatedAt)
+
+	// the send never blocks, so holding the lock keeps Shutdown from closing
+	// the queue under it
+	select {
+	case u.queue <- &task{job: job, rows: rows, archive: zr}:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	u.jobs[job.ID] = job
+
+	return u.copyJob(job), nil
+}
+
+// evict removes the jobs that finished longer than the retention ago
+func (u *Uploader) evict(now time.Time) {
+	for id, job := range u.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > jobRetention {
+			delete(u.jobs, id)
+		}
+	}
+}
+
+// checkArchive rejects archives with too many entries or expanding to too
+// much data. The zip reader fails on entries bigger than their declared size.
+func checkArchive(archive *zip.Reader) error {
+	if len(archive.File) > maxArchiveEntries {
+		return fmt.Errorf("archive cannot have more than %d files", maxArchiveEntries)
+	}
+
+	var size uint64
+	for _, f := range archive.File {
+		size += f.UncompressedSize64
+		if size > maxUncompressedSize {
+			return fmt.Errorf("archive cannot expand to more than %d bytes", maxUncompressedSize)
+		}
+	}
+
+	return nil
+}
+
+func (u *Uploader) GetJob(id string) (Job, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	job, ok := u.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	return u.copyJob(job), true
+}
+
+func (u *Uploader) copyJob(job *Job) Job {
+	j := *job
+	j.Results = append([]Result(nil), job.Results...)
+
+	return j
+}
+
+func (u *Uploader) worker() {
+	defer u.wg.Done()
+
+	for t := range u.queue {
+		u.process(t)
+	}
+}
+
+func (u *Uploader) process(t *task) {
+	u.mu.Lock()
+	t.job.Status = RUNNING
+	u.mu.Unlock()
+
+	ctx := context.Background()
+	archive := files(t.archive)
+	issuers := map[string]error{}
+	for _, row := range t.rows {
+		result := Result{Line: row.Line}
+
+		inv, err := u.createInvoice(ctx, row, archive, issuers)
+		if err != nil {
+			result.Err = err.Error()
+		} else {
+			result.InvoiceID = inv.ID
+			u.broker.SendInvoiceCreatedEvent(inv.ID)
+		}
+
+		u.mu.Lock()
+		t.job.Results = append(t.job.Results, result)
+		u.mu.Unlock()
+	}
+
+	now := time.Now()
+	u.mu.Lock()
+	t.job.Status = FINISHED
+	t.job.FinishedAt = &now
+	u.mu.Unlock()
+}
+
+// createInvoice validates a row and creates its invoice. Issuer lookups are
+// cached for the job as manifests usually repeat the same issuers, except
+// when they fail for a reason other than the issuer not existing.
+func (u *Uploader) createInvoice(ctx context.Context, row Row, archive map[string]*zip.File, issuers map[string]error) (invoice.Invoice, error) {
+	if row.IssuerID == "" {
+		return invoice.Invoice{}, errors.New("issuer id cannot be empty")
+	}
+
+	amount, err := currency.NewAmount(row.Price, row.Currency)
+	if err != nil {
+		return invoice.Invoice{}, fmt.Errorf("invalid price: %w", err)
+	}
+	if !amount.IsPositive() {
+		return invoice.Invoice{}, errors.New("price must be positive")
+	}
+
+	// an empty face value is left to the invoice service, which takes it from
+	// an e-invoice file or the price
+	var faceValue currency.Amount
+	if row.FaceValue != "" {
+		faceValue, err = currency.NewAmount(row.FaceValue, row.Currency)
+		if err != nil {
+			return invoice.Invoice{}, fmt.Errorf("invalid face value: %w", err)
+		}
+
+		if cmp, _ := faceValue.Cmp(amount); cmp < 0 {
+			return invoice.Invoice{}, errors.New("face value cannot be lower than the price")
+		}
+	}
+
+	dueDate, err := time.Parse(dateLayout, row.DueDate)
+	if err != nil {
+		return invoice.Invoice{}, fmt.Errorf("invalid due date: %w", err)
+	}
+
+	f, ok := archive[row.File]
+	if !ok {
+		return invoice.Invoice{}, fmt.Errorf("file %q not found in archive", row.File)
+	}
+
+	issErr, ok := issuers[row.IssuerID]
+	if !ok {
+		_, issErr = u.issuerService.GetIssuer(ctx, row.IssuerID)
+		if issErr == nil || errors.Is(issErr, issuer.ErrNotFound) {
+			issuers[row.IssuerID] = issErr
+		}
+	}
+	if issErr != nil {
+		return invoice.Invoice{}, fmt.Errorf("invalid issuer: %w", issErr)
+	}
+
+	file, err := f.Open()
+	if err != nil {
+		return invoice.Invoice{}, fmt.Errorf("could not open file %q: %w", row.File, err)
+	}
+	defer file.Close()
+
+	return u.invoiceService.CreateInvoice(ctx, row.IssuerID, amount, faceValue, dueDate, file)
+}
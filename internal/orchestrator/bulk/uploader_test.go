@@ -0,0 +1,214 @@
+package bulk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/nerock/invoicebidder/internal/invoice"
+	"github.com/nerock/invoicebidder/internal/issuer"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockInvoiceService struct {
+	created []string
+}
+
+func (m *mockInvoiceService) CreateInvoice(ctx context.Context, issuerID string, price, faceValue currency.Amount, dueDate time.Time, file io.Reader) (invoice.Invoice, error) {
+	content, _ := io.ReadAll(file)
+	m.created = append(m.created, string(content))
+
+	return invoice.Invoice{ID: "invoice-" + string(content), IssuerID: issuerID, Price: price}, nil
+}
+
+type mockIssuerService struct {
+	calls     int
+	transient int
+}
+
+func (m *mockIssuerService) GetIssuer(ctx context.Context, id string) (issuer.Issuer, error) {
+	m.calls++
+	if m.transient > 0 {
+		m.transient--
+		return issuer.Issuer{}, errors.New("connection reset")
+	}
+	if id != "issuer" {
+		return issuer.Issuer{}, issuer.ErrNotFound
+	}
+
+	return issuer.Issuer{ID: id}, nil
+}
+
+type mockBroker struct {
+	events []string
+}
+
+func (m *mockBroker) SendInvoiceCreatedEvent(id string) {
+	m.events = append(m.events, id)
+}
+
+func archive(files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = zw.Close()
+
+	return buf.Bytes()
+}
+
+func TestUploader(t *testing.T) {
+	Convey("Uploader", t, func() {
+		invSvc := &mockInvoiceService{}
+		brk := &mockBroker{}
+		issSvc := &mockIssuerService{}
+		u := New(1, 1, invSvc, issSvc, brk)
+		files := archive(map[string]string{"a.pdf": "a", "b.pdf": "b"})
+
+		Convey("when the manifest is missing columns", func() {
+			_, err := u.Submit("user-1", strings.NewReader("issuer_id,price\nissuer,100\n"), files)
+
+			Convey("return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the archive is not a zip", func() {
+			_, err := u.Submit("user-1", strings.NewReader("issuer_id,price,currency,due_date,file\nissuer,100,EUR,2023-12-31,a.pdf\n"), []byte("nope"))
+
+			Convey("return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when the upload is well formed", func() {
+			manifest := strings.Join([]string{
+				"issuer_id,price,currency,face_value,due_date,file",
+				"issuer,100,EUR,110,2023-12-31,a.pdf",
+				"other,100,EUR,,2023-12-31,b.pdf",
+				"issuer,abc,EUR,,2023-12-31,b.pdf",
+				"issuer,100,EUR,90,2023-12-31,b.pdf",
+				"issuer,100,EUR,,31/12/2023,b.pdf",
+				"issuer,100,EUR,,2023-12-31,c.pdf",
+				"issuer,200,EUR,,2023-12-31,b.pdf",
+			}, "\n")
+
+			job, err := u.Submit("user-1", strings.NewReader(manifest), files)
+			So(err, ShouldBeNil)
+			So(job.Status, ShouldEqual, PENDING)
+
+			So(u.Serve(), ShouldBeNil)
+			So(u.Shutdown(context.Background()), ShouldBeNil)
+
+			Convey("create the valid rows and report every row", func() {
+				job, ok := u.GetJob(job.ID)
+				So(ok, ShouldBeTrue)
+				So(job.Status, ShouldEqual, FINISHED)
+				So(job.Owner, ShouldEqual, "user-1")
+				So(job.FinishedAt, ShouldNotBeNil)
+				So(job.Results, ShouldHaveLength, 7)
+				So(job.Succeeded(), ShouldEqual, 2)
+				So(job.Failed(), ShouldEqual, 5)
+
+				So(job.Results[0], ShouldResemble, Result{Line: 2, InvoiceID: "invoice-a"})
+				So(job.Results[1].Err, ShouldContainSubstring, "invalid issuer")
+				So(job.Results[2].Err, ShouldContainSubstring, "invalid price")
+				So(job.Results[3].Err, ShouldContainSubstring, "face value")
+				So(job.Results[4].Err, ShouldContainSubstring, "invalid due date")
+				So(job.Results[5].Err, ShouldContainSubstring, "not found in archive")
+				So(job.Results[6], ShouldResemble, Result{Line: 8, InvoiceID: "invoice-b"})
+
+				So(invSvc.created, ShouldResemble, []string{"a", "b"})
+				So(brk.events, ShouldResemble, []string{"invoice-a", "invoice-b"})
+			})
+		})
+
+		Convey("when the archive has too many files", func() {
+			many := map[string]string{}
+			for i := 0; i <= maxArchiveEntries; i++ {
+				many[fmt.Sprintf("%d.pdf", i)] = ""
+			}
+			_, err := u.Submit("user-1", strings.NewReader("issuer_id,price,currency,due_date,file\nissuer,100,EUR,2023-12-31,0.pdf\n"), archive(many))
+
+			Convey("return an error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "more than")
+			})
+		})
+
+		Convey("when the issuer lookup fails transiently", func() {
+			issSvc.transient = 1
+			manifest := "issuer_id,price,currency,due_date,file\nissuer,100,EUR,2023-12-31,a.pdf\nissuer,100,EUR,2023-12-31,b.pdf\n"
+			job, err := u.Submit("user-1", strings.NewReader(manifest), files)
+			So(err, ShouldBeNil)
+
+			So(u.Serve(), ShouldBeNil)
+			So(u.Shutdown(context.Background()), ShouldBeNil)
+
+			Convey("look the issuer up again for the next row", func() {
+				job, _ := u.GetJob(job.ID)
+				So(job.Results[0].Err, ShouldContainSubstring, "invalid issuer")
+				So(job.Results[1].InvoiceID, ShouldEqual, "invoice-b")
+				So(issSvc.calls, ShouldEqual, 2)
+			})
+		})
+
+		Convey("when the queue is full", func() {
+			manifest := "issuer_id,price,currency,due_date,file\nissuer,100,EUR,2023-12-31,a.pdf\n"
+			_, err := u.Submit("user-1", strings.NewReader(manifest), files)
+			So(err, ShouldBeNil)
+
+			Convey("reject new uploads until it drains", func() {
+				_, err := u.Submit("user-1", strings.NewReader(manifest), files)
+				So(errors.Is(err, ErrQueueFull), ShouldBeTrue)
+			})
+		})
+
+		Convey("when the uploader is shut down", func() {
+			So(u.Serve(), ShouldBeNil)
+			So(u.Shutdown(context.Background()), ShouldBeNil)
+
+			Convey("reject new uploads", func() {
+				_, err := u.Submit("user-1", strings.NewReader("issuer_id,price,currency,due_date,file\nissuer,100,EUR,2023-12-31,a.pdf\n"), files)
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("allow shutting it down again", func() {
+				So(u.Shutdown(context.Background()), ShouldBeNil)
+			})
+		})
+
+		Convey("when a job finished longer than the retention ago", func() {
+			finished := time.Now().Add(-jobRetention - time.Minute)
+			u.jobs["old"] = &Job{ID: "old", Status: FINISHED, FinishedAt: &finished}
+			u.jobs["running"] = &Job{ID: "running", Status: RUNNING}
+
+			_, err := u.Submit("user-1", strings.NewReader("issuer_id,price,currency,due_date,file\nissuer,100,EUR,2023-12-31,a.pdf\n"), files)
+			So(err, ShouldBeNil)
+
+			Convey("evict it on the next upload", func() {
+				_, ok := u.GetJob("old")
+				So(ok, ShouldBeFalse)
+				_, ok = u.GetJob("running")
+				So(ok, ShouldBeTrue)
+			})
+		})
+
+		Convey("when the job does not exist", func() {
+			_, ok := u.GetJob("unknown")
+
+			Convey("return not found", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package cleaner
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type IdempotencyService interface {
+	DeleteExpired(context.Context) (int64, error)
+}
+
+// Cleaner periodically deletes the idempotency keys past their ttl, whose
+// responses are no longer replayed.
+type Cleaner struct {
+	interval time.Duration
+	done     chan struct{}
+	stopped  chan struct{}
+
+	idempotencyService IdempotencyService
+}
+
+func New(interval time.Duration, idempotencyService IdempotencyService) *Cleaner {
+	return &Cleaner{
+		interval:           interval,
+		done:               make(chan struct{}),
+		stopped:            make(chan struct{}),
+		idempotencyService: idempotencyService,
+	}
+}
+
+func (c *Cleaner) Serve() error {
+	go c.run()
+
+	return nil
+}
+
+func (c *Cleaner) Shutdown(ctx context.Context) error {
+	close(c.done)
+
+	select {
+	case <-c.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Cleaner) run() {
+	defer close(c.stopped)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.clean()
+		}
+	}
+}
+
+func (c *Cleaner) clean() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+	defer cancel()
+
+	n, err := c.idempotencyService.DeleteExpired(ctx)
+	if err != nil {
+		log.Printf("could not clean idempotency keys: %s", err)
+		return
+	}
+
+	if n > 0 {
+		log.Printf("deleted %d expired idempotency keys", n)
+	}
+}
@@ -0,0 +1,60 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockIdempotencyService struct {
+	calls chan struct{}
+	err   error
+}
+
+func (m *mockIdempotencyService) DeleteExpired(ctx context.Context) (int64, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return 0, errors.New("no deadline")
+	}
+
+	m.calls <- struct{}{}
+	return 1, m.err
+}
+
+func TestCleaner(t *testing.T) {
+	Convey("Cleaner", t, func() {
+		svc := &mockIdempotencyService{calls: make(chan struct{}, 10)}
+
+		Convey("when it is served", func() {
+			c := New(time.Millisecond, svc)
+			So(c.Serve(), ShouldBeNil)
+
+			Convey("delete the expired keys on every tick until it is shut down", func() {
+				for i := 0; i < 2; i++ {
+					select {
+					case <-svc.calls:
+					case <-time.After(time.Second):
+						So("no clean before the timeout", ShouldBeEmpty)
+					}
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				So(c.Shutdown(ctx), ShouldBeNil)
+			})
+		})
+
+		Convey("when a clean fails", func() {
+			svc.err = errors.New("db down")
+			c := New(time.Minute, svc)
+
+			Convey("keep going", func() {
+				c.clean()
+				c.clean()
+				So(svc.calls, ShouldHaveLength, 2)
+			})
+		})
+	})
+}
@@ -0,0 +1,193 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+// finished jobs are kept this long for their results to be retrieved
+const jobRetention = 24 * time.Hour
+
+type Status string
+
+const (
+	PENDING  Status = "pending"
+	RUNNING  Status = "running"
+	FINISHED Status = "finished"
+	FAILED   Status = "failed"
+)
+
+// Job is a check of every stored invoice file. The owner is the subject that
+// requested it.
+type Job struct {
+	ID         string
+	Owner      string
+	Status     Status
+	Results    []invoice.Integrity
+	Err        string
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+type InvoiceService interface {
+	VerifyFiles(context.Context) ([]invoice.Integrity, error)
+}
+
+// Checker re-hashes the stored invoice files in the background, one check at
+// a time as every check reads all the files. Jobs are kept in memory so they
+// are only available until the application restarts.
+type Checker struct {
+	queue  chan *Job
+	wg     *sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	jobs   map[string]*Job
+	active *Job
+	closed bool
+
+	invoiceService InvoiceService
+}
+
+func New(invoiceService InvoiceService) *Checker {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Checker{
+		queue:          make(chan *Job, 1),
+		wg:             &sync.WaitGroup{},
+		ctx:            ctx,
+		cancel:         cancel,
+		jobs:           map[string]*Job{},
+		invoiceService: invoiceService,
+	}
+}
+
+func (c *Checker) Serve() error {
+	c.wg.Add(1)
+	go c.worker()
+
+	return nil
+}
+
+// Shutdown stops the check in progress, as it can take long on a big bucket
+func (c *Checker) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+		c.cancel()
+	}
+	c.mu.Unlock()
+
+	closeChan := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		closeChan <- struct{}{}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("timeout waiting for integrity checks to complete")
+	case <-closeChan:
+		return nil
+	}
+}
+
+// Start queues a check of every invoice file, or returns the one already
+// pending or running.
+func (c *Checker) Start(owner string) (Job, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return Job{}, fmt.Errorf("could not generate id: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return Job{}, errors.New("integrity checks are shutting down")
+	}
+	if c.active != nil {
+		return c.copyJob(c.active), nil
+	}
+
+	job := &Job{
+		ID:        id.String(),
+		Owner:     owner,
+		Status:    PENDING,
+		CreatedAt: time.Now(),
+	}
+	c.evict(job.CreatedAt)
+
+	// only one job is active at a time, so the queue always has room for it
+	c.queue <- job
+	c.jobs[job.ID] = job
+	c.active = job
+
+	return c.copyJob(job), nil
+}
+
+func (c *Checker) GetJob(id string) (Job, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	job, ok := c.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	return c.copyJob(job), true
+}
+
+func (c *Checker) copyJob(job *Job) Job {
+	j := *job
+	j.Results = append([]invoice.Integrity(nil), job.Results...)
+
+	return j
+}
+
+// evict removes the jobs that finished longer than the retention ago
+func (c *Checker) evict(now time.Time) {
+	for id, job := range c.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > jobRetention {
+			delete(c.jobs, id)
+		}
+	}
+}
+
+func (c *Checker) worker() {
+	defer c.wg.Done()
+
+	for job := range c.queue {
+		c.process(job)
+	}
+}
+
+func (c *Checker) process(job *Job) {
+	c.mu.Lock()
+	job.Status = RUNNING
+	c.mu.Unlock()
+
+	results, err := c.invoiceService.VerifyFiles(c.ctx)
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = FAILED
+		job.Err = err.Error()
+	} else {
+		job.Status = FINISHED
+		job.Results = results
+	}
+	c.active = nil
+}
@@ -0,0 +1,133 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockInvoiceService struct {
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (m *mockInvoiceService) VerifyFiles(ctx context.Context) ([]invoice.Integrity, error) {
+	m.started <- struct{}{}
+
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return []invoice.Integrity{{InvoiceID: "a", Expected: "hash", Actual: "hash", Status: invoice.INTACT}}, nil
+}
+
+func TestChecker(t *testing.T) {
+	Convey("Checker", t, func() {
+		invSvc := &mockInvoiceService{started: make(chan struct{}, 1), release: make(chan struct{})}
+		c := New(invSvc)
+		So(c.Serve(), ShouldBeNil)
+
+		job, err := c.Start("auditor")
+		So(err, ShouldBeNil)
+		So(job.Owner, ShouldEqual, "auditor")
+		<-invSvc.started
+
+		Convey("when a check is already running", func() {
+			again, err := c.Start("other")
+
+			Convey("return the running one", func() {
+				So(err, ShouldBeNil)
+				So(again.ID, ShouldEqual, job.ID)
+				So(again.Status, ShouldEqual, RUNNING)
+			})
+		})
+
+		Convey("when the check finishes", func() {
+			close(invSvc.release)
+			So(c.Shutdown(context.Background()), ShouldBeNil)
+
+			Convey("keep its results", func() {
+				job, ok := c.GetJob(job.ID)
+				So(ok, ShouldBeTrue)
+				So(job.Status, ShouldEqual, FINISHED)
+				So(job.FinishedAt, ShouldNotBeNil)
+				So(job.Results, ShouldHaveLength, 1)
+				So(job.Results[0].Status, ShouldEqual, invoice.INTACT)
+			})
+		})
+
+		Convey("when the check fails", func() {
+			invSvc.err = errors.New("storage down")
+			close(invSvc.release)
+			So(c.Shutdown(context.Background()), ShouldBeNil)
+
+			Convey("report the error", func() {
+				job, _ := c.GetJob(job.ID)
+				So(job.Status, ShouldEqual, FAILED)
+				So(job.Err, ShouldEqual, "storage down")
+			})
+		})
+
+		Convey("when it is shut down during a check", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			So(c.Shutdown(ctx), ShouldBeNil)
+
+			Convey("cancel the check and reject new ones", func() {
+				job, _ := c.GetJob(job.ID)
+				So(job.Status, ShouldEqual, FAILED)
+
+				_, err := c.Start("auditor")
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("when a job finished longer than the retention ago", func() {
+			close(invSvc.release)
+			for {
+				if j, _ := c.GetJob(job.ID); j.Status == FINISHED {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			c.mu.Lock()
+			finished := time.Now().Add(-jobRetention - time.Minute)
+			c.jobs[job.ID].FinishedAt = &finished
+			c.mu.Unlock()
+
+			_, err := c.Start("auditor")
+			So(err, ShouldBeNil)
+			<-invSvc.started
+
+			Convey("evict it on the next check", func() {
+				_, ok := c.GetJob(job.ID)
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("when the job does not exist", func() {
+			_, ok := c.GetJob("unknown")
+
+			Convey("return not found", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Reset(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = c.Shutdown(ctx)
+		})
+	})
+}
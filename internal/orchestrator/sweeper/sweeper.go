@@ -0,0 +1,112 @@
+package sweeper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+)
+
+type InvoiceService interface {
+	Reconcile(context.Context, time.Time, time.Time) (invoice.Reconciliation, error)
+}
+
+// fullInterval is how often a sweep checks every invoice and looks for
+// orphaned committed files, as that lists the whole file storage. The sweeps
+// in between only check the invoices created since the last one.
+const fullInterval = 24 * time.Hour
+
+// Sweeper periodically reconciles invoices and their files, cleaning up
+// after creations interrupted between the database and the file storage.
+type Sweeper struct {
+	interval time.Duration
+	grace    time.Duration
+	done     chan struct{}
+	stopped  chan struct{}
+
+	// since is the cutoff of the last successful sweep and lastFull when
+	// the last full one ran, both only touched by the sweeping goroutine
+	since    time.Time
+	lastFull time.Time
+
+	invoiceService InvoiceService
+}
+
+func New(interval, grace time.Duration, invoiceService InvoiceService) *Sweeper {
+	return &Sweeper{
+		interval:       interval,
+		grace:          grace,
+		done:           make(chan struct{}),
+		stopped:        make(chan struct{}),
+		invoiceService: invoiceService,
+	}
+}
+
+func (s *Sweeper) Serve() error {
+	go s.run()
+
+	return nil
+}
+
+func (s *Sweeper) Shutdown(ctx context.Context) error {
+	close(s.done)
+
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Sweeper) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.sweep(now)
+		}
+	}
+}
+
+func (s *Sweeper) sweep(now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+	defer cancel()
+
+	cutoff := now.Add(-s.grace)
+	since := s.since
+	full := now.Sub(s.lastFull) >= fullInterval
+	if full {
+		since = time.Time{}
+	}
+
+	rec, err := s.invoiceService.Reconcile(ctx, since, cutoff)
+	if err != nil {
+		log.Printf("could not reconcile invoice files: %s", err)
+	} else {
+		s.since = cutoff
+		if full {
+			s.lastFull = now
+		}
+	}
+
+	for _, id := range rec.Committed {
+		log.Printf("committed staged file of invoice %s", id)
+	}
+	for _, id := range rec.Removed {
+		log.Printf("removed invoice %s without file", id)
+	}
+	for _, id := range rec.Unresolved {
+		log.Printf("invoice %s has no file and cannot be removed", id)
+	}
+	if n := len(rec.Discarded) + len(rec.Deleted); n > 0 {
+		log.Printf("removed %d orphaned invoice files", n)
+	}
+}
@@ -0,0 +1,93 @@
+package sweeper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nerock/invoicebidder/internal/invoice"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type reconcileCall struct {
+	since  time.Time
+	cutoff time.Time
+}
+
+type mockInvoiceService struct {
+	calls []reconcileCall
+	err   error
+}
+
+func (m *mockInvoiceService) Reconcile(_ context.Context, since, cutoff time.Time) (invoice.Reconciliation, error) {
+	m.calls = append(m.calls, reconcileCall{since: since, cutoff: cutoff})
+	return invoice.Reconciliation{Removed: []string{"a"}}, m.err
+}
+
+func TestSweeper_Sweep(t *testing.T) {
+	Convey("Sweep", t, func() {
+		svc := &mockInvoiceService{}
+		s := New(time.Minute, time.Hour, svc)
+		now := time.Now()
+
+		Convey("when it is the first sweep", func() {
+			s.sweep(now)
+
+			Convey("check everything up to the grace period", func() {
+				So(svc.calls, ShouldHaveLength, 1)
+				So(svc.calls[0].since.IsZero(), ShouldBeTrue)
+				So(svc.calls[0].cutoff, ShouldEqual, now.Add(-time.Hour))
+			})
+		})
+
+		Convey("when a sweep already succeeded", func() {
+			s.sweep(now)
+			s.sweep(now.Add(time.Minute))
+
+			Convey("check only what was created since its cutoff", func() {
+				So(svc.calls, ShouldHaveLength, 2)
+				So(svc.calls[1].since, ShouldEqual, now.Add(-time.Hour))
+				So(svc.calls[1].cutoff, ShouldEqual, now.Add(time.Minute-time.Hour))
+			})
+		})
+
+		Convey("when a sweep fails", func() {
+			s.sweep(now)
+			svc.err = errors.New("storage down")
+			s.sweep(now.Add(time.Minute))
+			svc.err = nil
+			s.sweep(now.Add(2 * time.Minute))
+
+			Convey("check its period again on the next one", func() {
+				So(svc.calls, ShouldHaveLength, 3)
+				So(svc.calls[2].since, ShouldEqual, now.Add(-time.Hour))
+				So(svc.calls[2].cutoff, ShouldEqual, now.Add(2*time.Minute-time.Hour))
+			})
+		})
+
+		Convey("when the last full sweep is too old", func() {
+			s.sweep(now)
+			s.sweep(now.Add(fullInterval))
+
+			Convey("check everything again", func() {
+				So(svc.calls, ShouldHaveLength, 2)
+				So(svc.calls[1].since.IsZero(), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestSweeper_Shutdown(t *testing.T) {
+	Convey("Shutdown", t, func() {
+		s := New(time.Millisecond, time.Hour, &mockInvoiceService{})
+		So(s.Serve(), ShouldBeNil)
+
+		Convey("stop the sweeps", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			So(s.Shutdown(ctx), ShouldBeNil)
+		})
+	})
+}
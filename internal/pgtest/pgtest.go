@@ -0,0 +1,132 @@
+// Package pgtest starts a throwaway postgres for the storage tests.
+package pgtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BinariesEnv points to a local postgres install, like /usr/lib/postgresql/15,
+// to use its binaries instead of downloading them
+const BinariesEnv = "PGTEST_BINARIES"
+
+// CIEnv is set by most CI providers, where postgres must start
+const CIEnv = "CI"
+
+// New starts postgres with the *.up.sql files of the migrations dir applied
+// and returns a pool to it, everything is removed when the test ends. The
+// test is skipped in short mode. When postgres can't be started, as the
+// binaries are downloaded on first use, it is skipped too unless the
+// binaries are given or it runs in CI, where the test fails instead.
+func New(t *testing.T, migrations string) *pgxpool.Pool {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("skipping postgres in short mode")
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("could not find a free port: %v", err)
+	}
+
+	cfg := embeddedpostgres.DefaultConfig().
+		Port(port).
+		Database("test").
+		RuntimePath(filepath.Join(t.TempDir(), "postgres")).
+		Logger(io.Discard)
+	if bin := os.Getenv(BinariesEnv); bin != "" {
+		cfg = cfg.BinariesPath(bin)
+	}
+
+	db := embeddedpostgres.NewDatabase(cfg)
+	if err := db.Start(); err != nil {
+		if os.Getenv(BinariesEnv) != "" || os.Getenv(CIEnv) != "" {
+			t.Fatalf("could not start postgres: %v", err)
+		}
+		t.Skipf("could not start postgres: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Stop(); err != nil {
+			t.Errorf("could not stop postgres: %v", err)
+		}
+	})
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, cfg.GetConnectionURL()+"?sslmode=disable")
+	if err != nil {
+		t.Fatalf("could not connect to postgres: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := migrate(ctx, pool, migrations); err != nil {
+		t.Fatalf("could not migrate postgres: %v", err)
+	}
+
+	return pool
+}
+
+// Truncate empties every table so each test starts from a clean db
+func Truncate(t *testing.T, pool *pgxpool.Pool) {
+	t.Helper()
+
+	const query = `DO $$ DECLARE tables TEXT;
+		BEGIN
+			SELECT string_agg(quote_ident(tablename), ', ') INTO tables FROM pg_tables WHERE schemaname = 'public';
+			IF tables IS NOT NULL THEN
+				EXECUTE 'TRUNCATE ' || tables || ' CASCADE';
+			END IF;
+		END $$`
+
+	if _, err := pool.Exec(context.Background(), query); err != nil {
+		t.Fatalf("could not truncate tables: %v", err)
+	}
+}
+
+// migrate applies the migrations like golang-migrate does, in name order
+func migrate(ctx context.Context, pool *pgxpool.Pool, dir string) error {
+	names, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return fmt.Errorf("could not list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("could not acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	for _, name := range names {
+		query, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("could not read migration %s: %w", name, err)
+		}
+
+		// the simple protocol allows several statements per file
+		if _, err := conn.Conn().PgConn().Exec(ctx, string(query)).ReadAll(); err != nil {
+			return fmt.Errorf("could not apply migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func freePort() (uint32, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return uint32(l.Addr().(*net.TCPAddr).Port), nil
+}
@@ -0,0 +1,260 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bojanz/currency"
+	driver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// TimeFormat is fixed width UTC, so stored times sort as text and are
+	// understood by the sqlite date functions
+	TimeFormat = "2006-01-02 15:04:05.000000000"
+	DateFormat = "2006-01-02"
+
+	// UnitScale is the fixed scale of the integer columns kept next to
+	// decimal amounts, finer than the minor unit of any currency
+	UnitScale = 1000000
+)
+
+// Open opens the database file with foreign keys enforced. A single
+// connection is kept, sqlite serializes writes anyway and this avoids busy
+// errors between them.
+func Open(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", path))
+	if err != nil {
+		return nil, fmt.Errorf("could not open sqlite db: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// Migrate applies the *.up.sql files of the migrations in name order. The
+// version applied last is kept as the user_version of the db.
+func Migrate(ctx context.Context, db *sql.DB, migrations fs.FS) error {
+	names, err := fs.Glob(migrations, "*.up.sql")
+	if err != nil {
+		return fmt.Errorf("could not list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	var current int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&current); err != nil {
+		return fmt.Errorf("could not retrieve migration version: %w", err)
+	}
+
+	for _, name := range names {
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration name %q", name)
+		}
+		if version <= current {
+			continue
+		}
+
+		query, err := fs.ReadFile(migrations, name)
+		if err != nil {
+			return fmt.Errorf("could not read migration %s: %w", name, err)
+		}
+
+		if err := migrate(ctx, db, string(query), version); err != nil {
+			return fmt.Errorf("could not apply migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, query string, version int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not initialize transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsUniqueViolation reports whether the error is a unique constraint failing
+// on the given table column, like "invoices.file_hash".
+func IsUniqueViolation(err error, column string) bool {
+	var sqliteErr *driver.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteErr.Error(), column)
+}
+
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// FormatNullTime stores a missing time as NULL
+func FormatNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return FormatTime(*t)
+}
+
+func FormatDate(t time.Time) string {
+	return t.Format(DateFormat)
+}
+
+// Units scales a decimal number to the integer kept in amount columns,
+// rounding finer digits down or, when up is set, up. Numbers past the int64
+// range are clamped to it, so they still compare past every stored amount.
+func Units(number string, up bool) (int64, error) {
+	r, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return 0, fmt.Errorf("invalid number %q", number)
+	}
+	r.Mul(r, big.NewRat(UnitScale, 1))
+
+	q, m := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	if up && m.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+
+	switch {
+	case q.IsInt64():
+		return q.Int64(), nil
+	case q.Sign() > 0:
+		return math.MaxInt64, nil
+	default:
+		return math.MinInt64, nil
+	}
+}
+
+// FormatNullDate stores a missing date as NULL
+func FormatNullDate(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return FormatDate(*t)
+}
+
+// Time scans a time stored with FormatTime
+type Time struct {
+	T *time.Time
+}
+
+func (t Time) Scan(src any) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into time", src)
+	}
+
+	parsed, err := time.ParseInLocation(TimeFormat, s, time.UTC)
+	if err != nil {
+		return fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	*t.T = parsed
+
+	return nil
+}
+
+// NullTime scans a time stored with FormatNullTime
+type NullTime struct {
+	T **time.Time
+}
+
+func (t NullTime) Scan(src any) error {
+	if src == nil {
+		*t.T = nil
+		return nil
+	}
+
+	var parsed time.Time
+	if err := (Time{&parsed}).Scan(src); err != nil {
+		return err
+	}
+	*t.T = &parsed
+
+	return nil
+}
+
+// Date scans a date stored with FormatDate
+type Date struct {
+	T *time.Time
+}
+
+func (d Date) Scan(src any) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into date", src)
+	}
+
+	parsed, err := time.ParseInLocation(DateFormat, s, time.UTC)
+	if err != nil {
+		return fmt.Errorf("invalid date %q: %w", s, err)
+	}
+	*d.T = parsed
+
+	return nil
+}
+
+// NullDate scans a date stored as NULL when missing
+type NullDate struct {
+	T **time.Time
+}
+
+func (d NullDate) Scan(src any) error {
+	if src == nil {
+		*d.T = nil
+		return nil
+	}
+
+	var parsed time.Time
+	if err := (Date{&parsed}).Scan(src); err != nil {
+		return err
+	}
+	*d.T = &parsed
+
+	return nil
+}
+
+// Amount scans an amount stored as its decimal text and currency code
+// columns, selected as (x_number || ' ' || x_currency)
+type Amount struct {
+	A *currency.Amount
+}
+
+func (a Amount) Scan(src any) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into amount", src)
+	}
+
+	number, code, ok := strings.Cut(s, " ")
+	if !ok {
+		return fmt.Errorf("invalid amount %q", s)
+	}
+
+	// the composite format keeps the zero amount without currency valid
+	return a.A.Scan(fmt.Sprintf("(%s,%s)", number, code))
+}
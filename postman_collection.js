@@ -377,6 +377,11 @@
 									"key": "currency",
 									"value": "EUR",
 									"type": "text"
+								},
+								{
+									"key": "due_date",
+									"value": "2023-12-31",
+									"type": "text"
 								}
 							]
 						},